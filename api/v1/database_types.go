@@ -23,7 +23,45 @@ import (
 // EDIT THIS FILE!  THIS IS SCAFFOLDING FOR YOU TO OWN!
 // NOTE: json tags are required.  Any new fields you add must have json tags for the fields to be serialized.
 
+// RotateCredentialsAnnotation requests an immediate password rotation when its value changes. On a
+// Database it rotates every user, on a user's secret only that user.
+const RotateCredentialsAnnotation = "postgres.silverswarm.io/rotate-credentials"
+
+// SecretChecksumAnnotation holds a hash of a secret's data, set on every secret the operator writes
+const SecretChecksumAnnotation = "postgres.silverswarm.io/checksum"
+
+// PodTemplateChecksumPrefix prefixes the pod template annotation carrying the checksum of a secret,
+// the secret name completes the key
+const PodTemplateChecksumPrefix = "checksum.postgres.silverswarm.io/"
+
+// PausedAnnotation suspends the reconciliation of a Database or PostGresConnection while it is "true"
+const PausedAnnotation = "postgres.silverswarm.io/paused"
+
+// RequeueAfterAnnotation overrides how soon a resource that is not ready is retried, a duration such as "10s"
+const RequeueAfterAnnotation = "postgres.silverswarm.io/requeue-after"
+
+// UserAnnotation names the user a secret written by the operator belongs to
+const UserAnnotation = "postgres.silverswarm.io/user"
+
+// CurrentSecretAnnotation names the current revision of an immutable user secret. It is set on the
+// secret of the unsuffixed name, which keeps no data and points consumers to the revision to read.
+const CurrentSecretAnnotation = "postgres.silverswarm.io/current-secret"
+
+// Labels identifying the Database that manages a user secret. Secrets outside the Database's namespace,
+// where owner references cannot point, rely on them for cleanup.
+const (
+	DatabaseNameLabel      = "postgres.silverswarm.io/database"
+	DatabaseNamespaceLabel = "postgres.silverswarm.io/database-namespace"
+	// ReplicaOfLabel marks a copy made by replicateTo, its value is the user the secret belongs to
+	ReplicaOfLabel = "postgres.silverswarm.io/replica-of"
+)
+
 // DatabaseSpec defines the desired state of Database
+// +kubebuilder:validation:XValidation:rule="!has(self.deletionPolicy) || self.deletionPolicy != 'DropWithBackup' || has(self.finalBackup)",message="finalBackup is required with the DropWithBackup deletionPolicy"
+// +kubebuilder:validation:XValidation:rule="has(oldSelf.lcCollate) == has(self.lcCollate)",message="lcCollate cannot be added or removed after creation"
+// +kubebuilder:validation:XValidation:rule="has(oldSelf.lcCtype) == has(self.lcCtype)",message="lcCtype cannot be added or removed after creation"
+// +kubebuilder:validation:XValidation:rule="has(oldSelf.localeProvider) == has(self.localeProvider)",message="localeProvider cannot be added or removed after creation"
+// +kubebuilder:validation:XValidation:rule="has(oldSelf.icuLocale) == has(self.icuLocale)",message="icuLocale cannot be added or removed after creation"
 type DatabaseSpec struct {
 	// ConnectionRef references a PostGresConnection resource
 	// +kubebuilder:validation:Required
@@ -31,21 +69,282 @@ type DatabaseSpec struct {
 
 	// DatabaseName is the name of the database to create
 	// +kubebuilder:validation:Required
-	// +kubebuilder:validation:Pattern=^[a-zA-Z][a-zA-Z0-9_]*$
+	// +kubebuilder:validation:Pattern=^[a-zA-Z][a-zA-Z0-9_-]*$
+	// +kubebuilder:validation:MaxLength=63
+	// +kubebuilder:validation:XValidation:rule="!(self in ['postgres', 'template0', 'template1'])",message="databaseName must not be a system database"
+	// +kubebuilder:validation:XValidation:rule="!self.startsWith('pg_')",message="databaseName must not start with pg_"
 	DatabaseName string `json:"databaseName"`
 
 	// Users defines the users/roles to create for this database
 	// +optional
 	Users []DatabaseUser `json:"users,omitempty"`
 
-	// Owner is the owner of the database (defaults to superuser if not specified)
+	// SecretNameTemplate names the secrets of users without secretName, a Go template over .Namespace,
+	// .Database, .DatabaseName and .User, e.g. "{{ .Database }}-{{ .User }}-credentials". Overrides the
+	// operator's --secret-name-template.
+	// +kubebuilder:validation:MaxLength=253
+	// +optional
+	SecretNameTemplate string `json:"secretNameTemplate,omitempty"`
+
+	// SecretKeys adds connection details to the user secrets next to username and password:
+	// host, port, dbname, sslmode, uri (postgresql://...), jdbc-uri, pgpass (a .pgpass line),
+	// pg_service.conf (a libpq service file with a service named after the database), host-ro
+	// and uri-ro pointing at the CNPG -ro service, and pooler-host, pooler-port and pooled-uri
+	// pointing at the connection's pooler
+	// +kubebuilder:validation:items:Enum=host;port;dbname;sslmode;uri;jdbc-uri;pgpass;pg_service.conf;host-ro;uri-ro;pooler-host;pooler-port;pooled-uri
+	// +optional
+	SecretKeys []string `json:"secretKeys,omitempty"`
+
+	// Vault also writes user credentials to HashiCorp Vault, using the operator's Vault settings. Combine
+	// it with createSecret false to keep a user's credentials out of Kubernetes.
+	// +optional
+	Vault *VaultCredentials `json:"vault,omitempty"`
+
+	// ServiceBinding publishes the Database as a servicebinding.io Provisioned Service
+	// +optional
+	ServiceBinding *ServiceBinding `json:"serviceBinding,omitempty"`
+
+	// Pooler provisions a CNPG Pooler for this database in the cluster's namespace. Its endpoint replaces
+	// the connection's pooler in user secrets. Requires a connection to a CNPG cluster.
+	// +optional
+	Pooler *DatabasePooler `json:"pooler,omitempty"`
+
+	// GrantReconciliation selects how user privileges are reconciled. Additive only grants what the spec
+	// declares; Exact also revokes privileges on the database and on objects in the public schema that
+	// the spec does not declare, and reports the result in the GrantsInSync condition.
+	// +kubebuilder:validation:Enum=Additive;Exact
+	// +kubebuilder:default=Additive
+	// +optional
+	GrantReconciliation GrantReconciliation `json:"grantReconciliation,omitempty"`
+
+	// PasswordPolicy overrides the operator's password generation settings for the users of this database
+	// +optional
+	PasswordPolicy *PasswordPolicy `json:"passwordPolicy,omitempty"`
+
+	// PasswordRotation regenerates the passwords of all users on a schedule, unless a user sets its own
+	// +optional
+	PasswordRotation *PasswordRotation `json:"passwordRotation,omitempty"`
+
+	// UserDeletionPolicy decides what happens to users removed from spec.users. Retain leaves the role
+	// and its secret alone, Revoke revokes the role's privileges on the database and deletes its secret,
+	// Drop drops the role and deletes its secret.
+	// +kubebuilder:validation:Enum=Retain;Revoke;Drop
+	// +kubebuilder:default=Retain
+	// +optional
+	UserDeletionPolicy UserDeletionPolicy `json:"userDeletionPolicy,omitempty"`
+
+	// DeletionPolicy decides what happens in PostgreSQL when the Database is deleted. Retain leaves the
+	// database and its users alone, Deprovision drops the managed users and group roles after handing the
+	// objects they own to the database owner, Drop drops the database itself as well. DropWithBackup
+	// only drops once a final DatabaseBackup as configured in finalBackup completed.
+	// +kubebuilder:validation:Enum=Retain;Deprovision;Drop;DropWithBackup
+	// +kubebuilder:default=Retain
+	// +optional
+	DeletionPolicy DatabaseDeletionPolicy `json:"deletionPolicy,omitempty"`
+
+	// ForceDrop lets the Drop and DropWithBackup deletion policies terminate the sessions still connected to
+	// the database, with DROP DATABASE ... WITH (FORCE) from PostgreSQL 13 on. Without it a database with
+	// sessions is not dropped.
+	// +kubebuilder:default=false
+	// +optional
+	ForceDrop bool `json:"forceDrop,omitempty"`
+
+	// FinalBackup configures the backup taken before the database is dropped with DropWithBackup
+	// +optional
+	FinalBackup *FinalBackup `json:"finalBackup,omitempty"`
+
+	// PermissionModel selects how users receive their privileges. direct grants each user's permissions
+	// to the user itself; groupRoles creates <databaseName>_readonly and <databaseName>_readwrite NOLOGIN
+	// roles holding the grants and makes each user a member of its group.
+	// +kubebuilder:default=direct
+	// +optional
+	PermissionModel PermissionModel `json:"permissionModel,omitempty"`
+
+	// Owner is the owner of the database (defaults to superuser if not specified).
+	// Changing it transfers ownership of the database with ALTER DATABASE ... OWNER TO.
 	// +optional
 	Owner string `json:"owner,omitempty"`
 
+	// CreateOwner creates the owner role as a NOLOGIN role when it does not exist
+	// +kubebuilder:default=false
+	// +optional
+	CreateOwner bool `json:"createOwner,omitempty"`
+
 	// Encoding for the database
 	// +kubebuilder:default="UTF8"
 	// +optional
 	Encoding string `json:"encoding,omitempty"`
+
+	// LcCollate is the collation order (LC_COLLATE) of the database. Cannot be changed after creation.
+	// +kubebuilder:validation:XValidation:rule="self == oldSelf",message="lcCollate is immutable"
+	// +optional
+	LcCollate string `json:"lcCollate,omitempty"`
+
+	// LcCtype is the character classification (LC_CTYPE) of the database. Cannot be changed after creation.
+	// +kubebuilder:validation:XValidation:rule="self == oldSelf",message="lcCtype is immutable"
+	// +optional
+	LcCtype string `json:"lcCtype,omitempty"`
+
+	// LocaleProvider is the provider of the default collation. Cannot be changed after creation.
+	// +kubebuilder:validation:Enum=libc;icu
+	// +kubebuilder:validation:XValidation:rule="self == oldSelf",message="localeProvider is immutable"
+	// +optional
+	LocaleProvider string `json:"localeProvider,omitempty"`
+
+	// IcuLocale is the ICU locale, used when localeProvider is icu. Cannot be changed after creation.
+	// +kubebuilder:validation:XValidation:rule="self == oldSelf",message="icuLocale is immutable"
+	// +optional
+	IcuLocale string `json:"icuLocale,omitempty"`
+
+	// TemplateDatabase is the database the new database is cloned from (CREATE DATABASE ... TEMPLATE).
+	// Only used when the database is created; the template must have no other open connections. It must be
+	// marked as a template or be managed by a Database in the same namespace.
+	// +kubebuilder:validation:Pattern=^[a-zA-Z][a-zA-Z0-9_-]*$
+	// +optional
+	TemplateDatabase string `json:"templateDatabase,omitempty"`
+
+	// Tablespace the database is stored in. Changing it moves the database with
+	// ALTER DATABASE ... SET TABLESPACE, which only happens while no sessions are connected.
+	// +kubebuilder:validation:Pattern=^[a-zA-Z][a-zA-Z0-9_-]*$
+	// +optional
+	Tablespace string `json:"tablespace,omitempty"`
+
+	// Comment is stored on the database (COMMENT ON DATABASE), followed by an operator ownership marker
+	// +optional
+	Comment string `json:"comment,omitempty"`
+
+	// RevokePublic revokes the default privileges of PUBLIC on the database and the CREATE privilege on
+	// its public schema, so only roles granted access explicitly can connect and create objects
+	// +kubebuilder:default=false
+	// +optional
+	RevokePublic bool `json:"revokePublic,omitempty"`
+
+	// ConnectionLimit caps concurrent connections to the database (-1 means no limit)
+	// +kubebuilder:default=-1
+	// +kubebuilder:validation:Minimum=-1
+	// +optional
+	ConnectionLimit *int32 `json:"connectionLimit,omitempty"`
+
+	// IsTemplate marks the database as a template that any role with CREATEDB can clone
+	// +kubebuilder:default=false
+	// +optional
+	IsTemplate bool `json:"isTemplate,omitempty"`
+
+	// AllowConnections controls whether clients may connect to the database
+	// Set to false to freeze the database for maintenance
+	// +kubebuilder:default=true
+	// +optional
+	AllowConnections *bool `json:"allowConnections,omitempty"`
+
+	// TerminateSessions terminates existing sessions when allowConnections is false
+	// +kubebuilder:default=false
+	// +optional
+	TerminateSessions bool `json:"terminateSessions,omitempty"`
+
+	// Extensions to install in the database
+	// +optional
+	Extensions []DatabaseExtension `json:"extensions,omitempty"`
+
+	// InitSQL runs once inside the database after it is created, e.g. to create schemas or seed lookup tables.
+	// It runs as the owner, which must not be a superuser.
+	// +optional
+	InitSQL *InitSQL `json:"initSQL,omitempty"`
+
+	// ResyncInterval is how often a ready Database is reconciled again, correcting drift such as dropped
+	// users or revoked privileges. Defaults to the operator's --resync-interval.
+	// +optional
+	ResyncInterval *metav1.Duration `json:"resyncInterval,omitempty"`
+}
+
+// InitSQL is a SQL script given inline or read from a ConfigMap
+type InitSQL struct {
+	// SQL is the inline script
+	// +optional
+	SQL string `json:"sql,omitempty"`
+
+	// ConfigMapRef references a ConfigMap key in the same namespace holding the script
+	// +optional
+	ConfigMapRef *ConfigMapKeyReference `json:"configMapRef,omitempty"`
+}
+
+// ConfigMapKeyReference references a key of a ConfigMap in the same namespace
+type ConfigMapKeyReference struct {
+	// Name of the ConfigMap
+	// +kubebuilder:validation:Required
+	Name string `json:"name"`
+
+	// Key within the ConfigMap
+	// +kubebuilder:validation:Required
+	Key string `json:"key"`
+}
+
+// PasswordPolicy controls how generated passwords look. Unset fields keep the operator's settings.
+type PasswordPolicy struct {
+	// Length of generated passwords
+	// +kubebuilder:validation:Minimum=12
+	// +kubebuilder:validation:Maximum=128
+	// +optional
+	Length *int32 `json:"length,omitempty"`
+
+	// Charset passwords are drawn from: alphanumeric, urlsafe (adds - and _) or ascii (printable
+	// characters without space, quotes and backslash)
+	// +kubebuilder:validation:Enum=alphanumeric;urlsafe;ascii
+	// +optional
+	Charset string `json:"charset,omitempty"`
+
+	// RequireSpecial makes sure every password contains a special character
+	// +optional
+	RequireSpecial *bool `json:"requireSpecial,omitempty"`
+
+	// ExcludeAmbiguous leaves out characters that are easily confused, such as 0, O, 1 and l
+	// +optional
+	ExcludeAmbiguous *bool `json:"excludeAmbiguous,omitempty"`
+}
+
+// PasswordRotation configures scheduled password rotation
+type PasswordRotation struct {
+	// Enabled turns rotation on
+	// +kubebuilder:default=false
+	// +optional
+	Enabled bool `json:"enabled,omitempty"`
+
+	// Interval between rotations
+	// +kubebuilder:default="720h"
+	// +optional
+	Interval metav1.Duration `json:"interval,omitempty"`
+}
+
+// SecretKeyReference references a key of a Secret in the same namespace
+type SecretKeyReference struct {
+	// Name of the Secret
+	// +kubebuilder:validation:Required
+	Name string `json:"name"`
+
+	// Key within the Secret
+	// +kubebuilder:validation:Required
+	Key string `json:"key"`
+}
+
+// DatabaseExtension defines a PostgreSQL extension to install
+type DatabaseExtension struct {
+	// Name of the extension, as listed in pg_available_extensions
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:Pattern=^[a-z0-9_-]+$
+	Name string `json:"name"`
+
+	// Version to install or update to (defaults to the extension's default version)
+	// +optional
+	Version string `json:"version,omitempty"`
+
+	// Schema to install the extension's objects into
+	// +kubebuilder:validation:Pattern=^[a-zA-Z][a-zA-Z0-9_-]*$
+	// +optional
+	Schema string `json:"schema,omitempty"`
+
+	// Cascade also installs the extensions this one depends on
+	// +kubebuilder:default=false
+	// +optional
+	Cascade bool `json:"cascade,omitempty"`
 }
 
 // ConnectionReference represents a reference to a PostGresConnection
@@ -63,23 +362,248 @@ type ConnectionReference struct {
 type DatabaseUser struct {
 	// Name of the user/role to create
 	// +kubebuilder:validation:Required
-	// +kubebuilder:validation:Pattern=^[a-zA-Z][a-zA-Z0-9_]*$
+	// +kubebuilder:validation:Pattern=^[a-zA-Z][a-zA-Z0-9_-]*$
+	// +kubebuilder:validation:MaxLength=63
+	// +kubebuilder:validation:XValidation:rule="!(self in ['postgres', 'public', 'none'])",message="name must not be a reserved or system role"
+	// +kubebuilder:validation:XValidation:rule="!self.startsWith('pg_')",message="name must not start with pg_"
 	Name string `json:"name"`
 
-	// Permissions for this user on the database
-	// +kubebuilder:validation:Required
-	Permissions []Permission `json:"permissions"`
+	// RenamedFrom is the user's previous name. When the previous name is a user managed for this
+	// Database, its role is renamed (ALTER ROLE ... RENAME TO) and keeps its password and grants.
+	// +kubebuilder:validation:Pattern=^[a-zA-Z][a-zA-Z0-9_-]*$
+	// +optional
+	RenamedFrom string `json:"renamedFrom,omitempty"`
+
+	// Login allows the user to log in. Users with login false are created as NOLOGIN roles without
+	// a password or secret, e.g. to own objects or to hold privileges for other roles.
+	// +kubebuilder:default=true
+	// +optional
+	Login *bool `json:"login,omitempty"`
+
+	// Disabled suspends the user by setting it to NOLOGIN. Its password, secret and grants are kept,
+	// so clearing the flag restores access.
+	// +kubebuilder:default=false
+	// +optional
+	Disabled bool `json:"disabled,omitempty"`
+
+	// TerminateSessions terminates the user's existing sessions while it is disabled
+	// +kubebuilder:default=false
+	// +optional
+	TerminateSessions bool `json:"terminateSessions,omitempty"`
+
+	// Permissions for this user on the database. With the direct permission model either
+	// permissions or role is required.
+	// +optional
+	Permissions []Permission `json:"permissions,omitempty"`
+
+	// Role is a preset expanding into a bundle of grants on the database and the public schema,
+	// including default privileges for objects created later. Combines with permissions.
+	// +optional
+	Role RolePreset `json:"role,omitempty"`
+
+	// Group the user joins with the groupRoles permission model
+	// +optional
+	Group UserGroup `json:"group,omitempty"`
+
+	// PreferReadOnlyEndpoint points host, uri and the other connection details in the user's secret at the
+	// CNPG -ro service, so e.g. reporting users connect to replicas by default
+	// +optional
+	PreferReadOnlyEndpoint bool `json:"preferReadOnlyEndpoint,omitempty"`
+
+	// PasswordSecretRef reads the user's password from an existing Secret, e.g. one managed by
+	// External Secrets, instead of generating it. The role follows changes to the Secret.
+	// +optional
+	PasswordSecretRef *SecretKeyReference `json:"passwordSecretRef,omitempty"`
 
 	// CreateSecret determines if a secret should be created with user credentials
 	// +kubebuilder:default=true
 	// +optional
 	CreateSecret *bool `json:"createSecret,omitempty"`
 
-	// SecretName is the name of the secret to create (defaults to <database>-<user>)
+	// SecretName is the name of the secret to create (defaults to the secret name template, or <database>-<user>
+	// with underscores replaced by dashes)
 	// +optional
 	SecretName string `json:"secretName,omitempty"`
+
+	// SecretNamespace is the namespace to create the secret in (defaults to the Database's namespace).
+	// Secrets in other namespaces are labelled with the Database and deleted together with it.
+	// +kubebuilder:validation:MaxLength=63
+	// +optional
+	SecretNamespace string `json:"secretNamespace,omitempty"`
+
+	// ReplicateTo keeps copies of the secret in other namespaces, e.g. for credentials shared by
+	// several applications. Copies in namespaces that are no longer selected are deleted.
+	// +optional
+	ReplicateTo *SecretReplication `json:"replicateTo,omitempty"`
+
+	// ImmutableSecret creates the secret as immutable and names it <secretName>-<revision>. When its contents
+	// change, e.g. on password rotation, the next revision is created and the one before the previous deleted.
+	// The secret named <secretName> carries no data, its current-secret annotation and status.managedUsers
+	// name the current revision.
+	// +optional
+	ImmutableSecret bool `json:"immutableSecret,omitempty"`
+
+	// PasswordDelivery hands the generated password to a sink once instead of keeping it in a secret.
+	// PostgreSQL only stores its SCRAM verifier and later reconciles leave the role's password alone;
+	// the rotate-credentials annotation on the Database delivers a new one.
+	// +optional
+	PasswordDelivery *PasswordDelivery `json:"passwordDelivery,omitempty"`
+
+	// RolloutDeployments lists Deployments in the secret's namespace that consume the secret. Their pod
+	// template is annotated with the secret's checksum, so their pods restart whenever it changes.
+	// +optional
+	RolloutDeployments []string `json:"rolloutDeployments,omitempty"`
+
+	// ConnectionLimit caps concurrent connections of the user (-1 means no limit). When unset, the limit of the
+	// role is left as it is.
+	// +kubebuilder:validation:Minimum=-1
+	// +optional
+	ConnectionLimit *int32 `json:"connectionLimit,omitempty"`
+
+	// RoleParameters sets session defaults for the user (ALTER ROLE ... SET),
+	// e.g. statement_timeout or idle_in_transaction_session_timeout. Parameters removed from this map are reset,
+	// parameters set on the role by others are left alone.
+	// +optional
+	RoleParameters map[string]string `json:"roleParameters,omitempty"`
+
+	// PasswordRotation regenerates the user's password on a schedule, overriding the database-wide setting
+	// +optional
+	PasswordRotation *PasswordRotation `json:"passwordRotation,omitempty"`
+
+	// ValidUntil is when the user's password stops being accepted (VALID UNTIL)
+	// +optional
+	ValidUntil *metav1.Time `json:"validUntil,omitempty"`
+
+	// TTL limits how long the password is accepted after it was issued; ignored when validUntil is set
+	// +optional
+	TTL *metav1.Duration `json:"ttl,omitempty"`
+
+	// DropAfterExpiry drops the role and deletes its secret once the password has expired
+	// +kubebuilder:default=false
+	// +optional
+	DropAfterExpiry bool `json:"dropAfterExpiry,omitempty"`
+
+	// Comment is stored on the role (COMMENT ON ROLE), followed by an operator ownership marker
+	// +optional
+	Comment string `json:"comment,omitempty"`
+
+	// DefaultPrivileges are granted to this user on objects created later (ALTER DEFAULT PRIVILEGES),
+	// so read-only users keep working after migrations add tables
+	// +optional
+	DefaultPrivileges []UserDefaultPrivilege `json:"defaultPrivileges,omitempty"`
 }
 
+// UserDefaultPrivilege grants privileges on future objects of one type in a schema
+type UserDefaultPrivilege struct {
+	// Schema the objects are created in
+	// +kubebuilder:default="public"
+	// +kubebuilder:validation:Pattern=^[a-zA-Z][a-zA-Z0-9_-]*$
+	// +optional
+	Schema string `json:"schema,omitempty"`
+
+	// ObjectType the privileges apply to
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:Enum=tables;sequences;functions
+	ObjectType string `json:"objectType"`
+
+	// Privileges granted on new objects
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinItems=1
+	Privileges []ObjectPrivilege `json:"privileges"`
+
+	// ForRole is the role whose new objects are covered (defaults to the database owner,
+	// or the operator's connection user when no owner is set)
+	// +kubebuilder:validation:Pattern=^[a-zA-Z][a-zA-Z0-9_-]*$
+	// +optional
+	ForRole string `json:"forRole,omitempty"`
+}
+
+// GrantReconciliation selects how user privileges are reconciled
+type GrantReconciliation string
+
+const (
+	// GrantReconciliationAdditive grants declared privileges and leaves others alone
+	GrantReconciliationAdditive GrantReconciliation = "Additive"
+	// GrantReconciliationExact grants declared privileges and revokes undeclared ones
+	GrantReconciliationExact GrantReconciliation = "Exact"
+)
+
+// UserDeletionPolicy decides what happens to users removed from a Database
+type UserDeletionPolicy string
+
+const (
+	// UserDeletionRetain keeps removed users and their secrets
+	UserDeletionRetain UserDeletionPolicy = "Retain"
+	// UserDeletionRevoke revokes the privileges of removed users and deletes their secrets
+	UserDeletionRevoke UserDeletionPolicy = "Revoke"
+	// UserDeletionDrop drops removed users and deletes their secrets
+	UserDeletionDrop UserDeletionPolicy = "Drop"
+)
+
+// DatabaseDeletionPolicy decides what happens in PostgreSQL when a Database is deleted
+type DatabaseDeletionPolicy string
+
+const (
+	// DatabaseDeletionRetain keeps the database and its users
+	DatabaseDeletionRetain DatabaseDeletionPolicy = "Retain"
+	// DatabaseDeletionDeprovision drops the managed users and group roles but keeps the database
+	DatabaseDeletionDeprovision DatabaseDeletionPolicy = "Deprovision"
+	// DatabaseDeletionDrop drops the managed users, the group roles and the database
+	DatabaseDeletionDrop DatabaseDeletionPolicy = "Drop"
+	// DatabaseDeletionDropWithBackup drops like Drop once a final backup of the database completed
+	DatabaseDeletionDropWithBackup DatabaseDeletionPolicy = "DropWithBackup"
+)
+
+// FinalBackup is the DatabaseBackup created for a Database deleted with the DropWithBackup policy
+type FinalBackup struct {
+	// Destination is the S3-compatible bucket the dump is uploaded to
+	// +kubebuilder:validation:Required
+	Destination S3Destination `json:"destination"`
+
+	// Format of the dump: custom (pg_restore compatible) or plain SQL
+	// +kubebuilder:validation:Enum=custom;plain
+	// +kubebuilder:default="custom"
+	// +optional
+	Format string `json:"format,omitempty"`
+}
+
+// PermissionModel selects how users receive their privileges
+// +kubebuilder:validation:Enum=direct;groupRoles
+type PermissionModel string
+
+const (
+	// PermissionModelDirect grants permissions to each user
+	PermissionModelDirect PermissionModel = "direct"
+	// PermissionModelGroupRoles grants permissions to group roles the users are members of
+	PermissionModelGroupRoles PermissionModel = "groupRoles"
+)
+
+// RolePreset is a named bundle of privileges
+// +kubebuilder:validation:Enum=readonly;readwrite;ddl;admin
+type RolePreset string
+
+const (
+	// RolePresetReadOnly can read tables and sequences
+	RolePresetReadOnly RolePreset = "readonly"
+	// RolePresetReadWrite can read and modify data and execute functions
+	RolePresetReadWrite RolePreset = "readwrite"
+	// RolePresetDDL can additionally create, alter and truncate objects in the public schema
+	RolePresetDDL RolePreset = "ddl"
+	// RolePresetAdmin holds all privileges on the database and the public schema
+	RolePresetAdmin RolePreset = "admin"
+)
+
+// UserGroup is a group role of the groupRoles permission model
+// +kubebuilder:validation:Enum=readonly;readwrite
+type UserGroup string
+
+const (
+	// UserGroupReadOnly can read tables and sequences in the public schema
+	UserGroupReadOnly UserGroup = "readonly"
+	// UserGroupReadWrite can read and modify tables and sequences in the public schema
+	UserGroupReadWrite UserGroup = "readwrite"
+)
+
 // Permission defines database permissions
 type Permission string
 
@@ -112,21 +636,262 @@ type DatabaseStatus struct {
 	// +optional
 	DatabaseCreated bool `json:"databaseCreated,omitempty"`
 
-	// UsersCreated tracks which users have been created
+	// Users reports the state of each user in spec.users
+	// +optional
+	Users []UserStatus `json:"users,omitempty"`
+
+	// Extensions lists the installed extensions and their versions
+	// +optional
+	Extensions []InstalledExtension `json:"extensions,omitempty"`
+
+	// ManagedUsers lists the users managed by the operator, so users removed from the spec can be cleaned up
 	// +optional
-	UsersCreated []string `json:"usersCreated,omitempty"`
+	ManagedUsers []ManagedUser `json:"managedUsers,omitempty"`
+
+	// CredentialRotationRequest is the last handled value of the rotate-credentials annotation on the Database
+	// +optional
+	CredentialRotationRequest string `json:"credentialRotationRequest,omitempty"`
+
+	// Credentials records when user passwords were issued and when they expire
+	// +optional
+	Credentials []UserCredentials `json:"credentials,omitempty"`
+
+	// InitSQLApplied indicates the init SQL has run and will not run again
+	// +optional
+	InitSQLApplied bool `json:"initSQLApplied,omitempty"`
+
+	// Binding references the servicebinding.io binding secret when serviceBinding is set
+	// +optional
+	Binding *LocalObjectReference `json:"binding,omitempty"`
+
+	// Pooler references the CNPG Pooler provisioned for the database
+	// +optional
+	Pooler *PoolerReference `json:"pooler,omitempty"`
+
+	// DDLHistory lists the newest DDL statements the operator executed for the resource, with passwords masked
+	// +optional
+	DDLHistory []ExecutedStatement `json:"ddlHistory,omitempty"`
+
+	// CompletedSteps records the last run of each step that cannot be rolled back, such as creating the
+	// database, as soon as it succeeds
+	// +optional
+	CompletedSteps []CompletedStep `json:"completedSteps,omitempty"`
 
 	// Message provides human readable status information
 	// +optional
 	Message string `json:"message,omitempty"`
 
+	// ObservedGeneration is the generation of the spec the status was last computed for
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
 	// Conditions represent the latest available observations
 	// +optional
 	Conditions []metav1.Condition `json:"conditions,omitempty"`
 }
 
+// CompletedStep records a statement that commits on its own, outside of a transaction
+type CompletedStep struct {
+	// Name of the step, CreateDatabase or MoveTablespace
+	Name string `json:"name"`
+
+	// Statement that completed the step
+	Statement string `json:"statement"`
+
+	// CompletedAt is when the statement succeeded
+	CompletedAt metav1.Time `json:"completedAt"`
+}
+
+// VaultCredentials configures where user credentials are written in Vault
+type VaultCredentials struct {
+	// Path of the credentials within the namespace's directory below the operator's --vault-path-root, a Go
+	// template over .Namespace, .Database, .DatabaseName and .User (defaults to the operator's
+	// --vault-path-template)
+	// +optional
+	Path string `json:"path,omitempty"`
+}
+
+// ServiceBinding configures the binding secret of a Provisioned Service
+type ServiceBinding struct {
+	// User whose credentials are published (defaults to the first user with a secret)
+	// +optional
+	User string `json:"user,omitempty"`
+
+	// SecretName is the name of the binding secret (defaults to <database>-binding)
+	// +optional
+	SecretName string `json:"secretName,omitempty"`
+}
+
+// PoolerReference references a CNPG Pooler
+type PoolerReference struct {
+	// Name of the Pooler and its service
+	Name string `json:"name"`
+
+	// Namespace of the Pooler, the namespace of the CNPG cluster
+	Namespace string `json:"namespace"`
+}
+
+// DatabasePooler configures the CNPG Pooler provisioned for a Database
+type DatabasePooler struct {
+	// Enabled creates the Pooler, disabling it deletes the Pooler again
+	Enabled bool `json:"enabled"`
+
+	// Mode is the PgBouncer pool mode
+	// +kubebuilder:validation:Enum=session;transaction
+	// +kubebuilder:default=session
+	// +optional
+	Mode string `json:"mode,omitempty"`
+
+	// Instances is the number of PgBouncer pods
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:default=1
+	// +optional
+	Instances int32 `json:"instances,omitempty"`
+
+	// MaxConnections limits the server connections PgBouncer opens to the database (max_db_connections)
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	MaxConnections int32 `json:"maxConnections,omitempty"`
+}
+
+// SecretReplication selects the namespaces that receive a copy of a user secret
+type SecretReplication struct {
+	// Namespaces lists namespaces by name, namespaces that do not exist are skipped
+	// +kubebuilder:validation:items:MaxLength=63
+	// +optional
+	Namespaces []string `json:"namespaces,omitempty"`
+
+	// NamespaceSelector selects namespaces by label
+	// +optional
+	NamespaceSelector *metav1.LabelSelector `json:"namespaceSelector,omitempty"`
+}
+
+// PasswordDelivery configures where a user's password is delivered once
+type PasswordDelivery struct {
+	// Sink receives the password. Vault writes it to the Database's Vault path, Secret creates a
+	// one-time secret at the user's secret name that is deleted once ttl has passed.
+	// +kubebuilder:validation:Enum=Vault;Secret
+	// +kubebuilder:default=Secret
+	// +optional
+	Sink PasswordDeliverySink `json:"sink,omitempty"`
+
+	// TTL is how long the one-time secret is kept (defaults to 1h)
+	// +optional
+	TTL *metav1.Duration `json:"ttl,omitempty"`
+}
+
+// PasswordDeliverySink is where a delivered password is handed over
+type PasswordDeliverySink string
+
+const (
+	// PasswordDeliveryVault writes the password to the Database's Vault path
+	PasswordDeliveryVault PasswordDeliverySink = "Vault"
+	// PasswordDeliverySecret creates a one-time secret holding the password
+	PasswordDeliverySecret PasswordDeliverySink = "Secret"
+)
+
+// ManagedUser is a user created by the operator
+type ManagedUser struct {
+	// Name of the user
+	Name string `json:"name"`
+
+	// SecretName is the secret holding the user's credentials, empty when no secret is created
+	// +optional
+	SecretName string `json:"secretName,omitempty"`
+
+	// SecretNamespace is the namespace of the secret
+	// +optional
+	SecretNamespace string `json:"secretNamespace,omitempty"`
+}
+
+// UserCredentials records the lifetime of a user's password
+type UserCredentials struct {
+	// Name of the user
+	Name string `json:"name"`
+
+	// IssuedAt is when the password was issued, the start of the ttl
+	IssuedAt metav1.Time `json:"issuedAt"`
+
+	// ValidUntil is when the password expires
+	// +optional
+	ValidUntil *metav1.Time `json:"validUntil,omitempty"`
+
+	// LastRotationTime is when the password was last rotated
+	// +optional
+	LastRotationTime *metav1.Time `json:"lastRotationTime,omitempty"`
+
+	// RotationRequest is the last handled value of the rotate-credentials annotation on the user's secret
+	// +optional
+	RotationRequest string `json:"rotationRequest,omitempty"`
+
+	// PasswordSecretVersion is the resourceVersion of the passwordSecretRef Secret last applied to the role
+	// +optional
+	PasswordSecretVersion string `json:"passwordSecretVersion,omitempty"`
+
+	// SecretRevision is the revision of the user's immutable secret
+	// +optional
+	SecretRevision int64 `json:"secretRevision,omitempty"`
+
+	// DeliveredAt is when the password was last handed to the passwordDelivery sink
+	// +optional
+	DeliveredAt *metav1.Time `json:"deliveredAt,omitempty"`
+
+	// DeliveryExpiresAt is when the one-time secret holding the delivered password is deleted
+	// +optional
+	DeliveryExpiresAt *metav1.Time `json:"deliveryExpiresAt,omitempty"`
+
+	// Dropped indicates the role was dropped after its password expired
+	// +optional
+	Dropped bool `json:"dropped,omitempty"`
+}
+
+// InstalledExtension reports an extension installed in the database
+type InstalledExtension struct {
+	// Name of the extension
+	Name string `json:"name"`
+
+	// Version currently installed
+	Version string `json:"version"`
+}
+
+// UserStatus reports the state of one user of a Database
+type UserStatus struct {
+	// Name of the user
+	Name string `json:"name"`
+
+	// Conditions of the user: Created when the role exists, SecretReady when its credentials are written,
+	// GrantsApplied when its settings and privileges are applied, and LastRotated, whose lastTransitionTime
+	// is when the password was last issued or rotated
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+
+	// RoleParameters lists the role parameters the operator set on the user, only these are reset once removed
+	// from the spec
+	// +optional
+	RoleParameters []string `json:"roleParameters,omitempty"`
+}
+
+// ExecutedStatement records a DDL statement the operator ran and its outcome
+type ExecutedStatement struct {
+	// Statement as executed, with password literals replaced by '***'
+	Statement string `json:"statement"`
+
+	// ExecutedAt is when the statement finished
+	ExecutedAt metav1.Time `json:"executedAt"`
+
+	// Error returned by the server, empty when the statement succeeded
+	// +optional
+	Error string `json:"error,omitempty"`
+}
+
 // +kubebuilder:object:root=true
 // +kubebuilder:subresource:status
+// +kubebuilder:resource:categories=postgres
+// +kubebuilder:printcolumn:name="Ready",type=boolean,JSONPath=`.status.ready`
+// +kubebuilder:printcolumn:name="Database",type=string,JSONPath=`.spec.databaseName`
+// +kubebuilder:printcolumn:name="Connection",type=string,JSONPath=`.spec.connectionRef.name`
+// +kubebuilder:printcolumn:name="Message",type=string,JSONPath=`.status.message`,priority=1
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
 
 // Database is the Schema for the databases API
 type Database struct {
@@ -0,0 +1,165 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// DatabaseBackupSpec defines the desired state of DatabaseBackup
+type DatabaseBackupSpec struct {
+	// DatabaseRef references the Database to back up, in the same namespace
+	// +kubebuilder:validation:Required
+	DatabaseRef LocalObjectReference `json:"databaseRef"`
+
+	// Destination is the S3-compatible bucket the dump is uploaded to
+	// +kubebuilder:validation:Required
+	Destination S3Destination `json:"destination"`
+
+	// Format of the dump: custom (pg_restore compatible) or plain SQL
+	// +kubebuilder:validation:Enum=custom;plain
+	// +kubebuilder:default="custom"
+	// +optional
+	Format string `json:"format,omitempty"`
+}
+
+// S3Destination defines an S3-compatible bucket
+type S3Destination struct {
+	// Bucket name
+	// +kubebuilder:validation:Required
+	Bucket string `json:"bucket"`
+
+	// Prefix prepended to the object key
+	// +optional
+	Prefix string `json:"prefix,omitempty"`
+
+	// Endpoint of an S3-compatible service such as MinIO (defaults to AWS)
+	// +optional
+	Endpoint string `json:"endpoint,omitempty"`
+
+	// Region of the bucket
+	// +kubebuilder:default="us-east-1"
+	// +optional
+	Region string `json:"region,omitempty"`
+
+	// CredentialsSecret references a secret in the same namespace with AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY keys
+	// +kubebuilder:validation:Required
+	CredentialsSecret LocalObjectReference `json:"credentialsSecret"`
+}
+
+// Labels naming the DatabaseBackup a backup Job and its secrets belong to. The Job runs in the operator's
+// namespace, where owner references to the DatabaseBackup cannot point.
+const (
+	BackupNameLabel      = "postgres.silverswarm.io/backup"
+	BackupNamespaceLabel = "postgres.silverswarm.io/backup-namespace"
+)
+
+// DatabaseBackupPhase is the lifecycle phase of a DatabaseBackup
+// +kubebuilder:validation:Enum=Pending;Running;Completed;Failed
+type DatabaseBackupPhase string
+
+const (
+	// DatabaseBackupPending means the backup job has not been created yet
+	DatabaseBackupPending DatabaseBackupPhase = "Pending"
+	// DatabaseBackupRunning means the backup job is running
+	DatabaseBackupRunning DatabaseBackupPhase = "Running"
+	// DatabaseBackupCompleted means the dump was uploaded
+	DatabaseBackupCompleted DatabaseBackupPhase = "Completed"
+	// DatabaseBackupFailed means the backup job failed
+	DatabaseBackupFailed DatabaseBackupPhase = "Failed"
+)
+
+// DatabaseBackupStatus defines the observed state of DatabaseBackup.
+type DatabaseBackupStatus struct {
+	// Phase of the backup
+	// +optional
+	Phase DatabaseBackupPhase `json:"phase,omitempty"`
+
+	// JobName is the Job running the backup
+	// +optional
+	JobName string `json:"jobName,omitempty"`
+
+	// Location is the URL of the uploaded dump
+	// +optional
+	Location string `json:"location,omitempty"`
+
+	// SizeBytes is the size of the dump
+	// +optional
+	SizeBytes int64 `json:"sizeBytes,omitempty"`
+
+	// StartedAt is when the backup job started
+	// +optional
+	StartedAt *metav1.Time `json:"startedAt,omitempty"`
+
+	// CompletedAt is when the backup job finished
+	// +optional
+	CompletedAt *metav1.Time `json:"completedAt,omitempty"`
+
+	// Duration of the backup job
+	// +optional
+	Duration *metav1.Duration `json:"duration,omitempty"`
+
+	// Message provides human readable status information
+	// +optional
+	Message string `json:"message,omitempty"`
+
+	// ObservedGeneration is the generation of the spec the status was last computed for
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// Conditions represent the latest available observations
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:categories=postgres
+// +kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.status.phase`
+// +kubebuilder:printcolumn:name="Database",type=string,JSONPath=`.spec.databaseRef.name`
+// +kubebuilder:printcolumn:name="Message",type=string,JSONPath=`.status.message`,priority=1
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// DatabaseBackup is the Schema for the databasebackups API
+type DatabaseBackup struct {
+	metav1.TypeMeta `json:",inline"`
+
+	// metadata is a standard object metadata
+	// +optional
+	metav1.ObjectMeta `json:"metadata,omitempty,omitzero"`
+
+	// spec defines the desired state of DatabaseBackup
+	// +required
+	Spec DatabaseBackupSpec `json:"spec"`
+
+	// status defines the observed state of DatabaseBackup
+	// +optional
+	Status DatabaseBackupStatus `json:"status,omitempty,omitzero"`
+}
+
+// +kubebuilder:object:root=true
+
+// DatabaseBackupList contains a list of DatabaseBackup
+type DatabaseBackupList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []DatabaseBackup `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&DatabaseBackup{}, &DatabaseBackupList{})
+}
@@ -0,0 +1,119 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// DatabaseClaimSpec defines the desired state of DatabaseClaim
+type DatabaseClaimSpec struct {
+	// ClassName is the PostgresClass to provision from (defaults to the default class)
+	// +optional
+	ClassName string `json:"className,omitempty"`
+
+	// DatabaseName is the name of the database (defaults to <namespace>_<name> with dashes replaced by underscores)
+	// +kubebuilder:validation:Pattern=^[a-zA-Z][a-zA-Z0-9_-]*$
+	// +kubebuilder:validation:MaxLength=63
+	// +optional
+	DatabaseName string `json:"databaseName,omitempty"`
+
+	// SecretName is the binding secret with the connection details (defaults to <name>-db)
+	// +optional
+	SecretName string `json:"secretName,omitempty"`
+}
+
+// DatabaseClaimPhase is the lifecycle phase of a DatabaseClaim
+// +kubebuilder:validation:Enum=Pending;Bound
+type DatabaseClaimPhase string
+
+const (
+	// DatabaseClaimPending means the claim is not bound or its database is not ready yet
+	DatabaseClaimPending DatabaseClaimPhase = "Pending"
+	// DatabaseClaimBound means the database is provisioned and the binding secret is written
+	DatabaseClaimBound DatabaseClaimPhase = "Bound"
+)
+
+// DatabaseClaimStatus defines the observed state of DatabaseClaim.
+type DatabaseClaimStatus struct {
+	// Phase of the claim
+	// +optional
+	Phase DatabaseClaimPhase `json:"phase,omitempty"`
+
+	// ClassName is the PostgresClass the claim is bound to
+	// +optional
+	ClassName string `json:"className,omitempty"`
+
+	// DatabaseName is the name of the provisioned database
+	// +optional
+	DatabaseName string `json:"databaseName,omitempty"`
+
+	// SecretName is the binding secret
+	// +optional
+	SecretName string `json:"secretName,omitempty"`
+
+	// Message provides human readable status information
+	// +optional
+	Message string `json:"message,omitempty"`
+
+	// ObservedGeneration is the generation of the spec the status was last computed for
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// Conditions represent the latest available observations
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:categories=postgres
+// +kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.status.phase`
+// +kubebuilder:printcolumn:name="Class",type=string,JSONPath=`.spec.className`
+// +kubebuilder:printcolumn:name="Database",type=string,JSONPath=`.status.databaseName`
+// +kubebuilder:printcolumn:name="Message",type=string,JSONPath=`.status.message`,priority=1
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// DatabaseClaim is the Schema for the databaseclaims API
+type DatabaseClaim struct {
+	metav1.TypeMeta `json:",inline"`
+
+	// metadata is a standard object metadata
+	// +optional
+	metav1.ObjectMeta `json:"metadata,omitempty,omitzero"`
+
+	// spec defines the desired state of DatabaseClaim
+	// +required
+	Spec DatabaseClaimSpec `json:"spec"`
+
+	// status defines the observed state of DatabaseClaim
+	// +optional
+	Status DatabaseClaimStatus `json:"status,omitempty,omitzero"`
+}
+
+// +kubebuilder:object:root=true
+
+// DatabaseClaimList contains a list of DatabaseClaim
+type DatabaseClaimList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []DatabaseClaim `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&DatabaseClaim{}, &DatabaseClaimList{})
+}
@@ -0,0 +1,140 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ForeignServerSpec defines the desired state of ForeignServer
+type ForeignServerSpec struct {
+	// DatabaseRef references the Database to create the server in, in the same namespace
+	// +kubebuilder:validation:Required
+	DatabaseRef LocalObjectReference `json:"databaseRef"`
+
+	// ServerName is the name of the foreign server in PostgreSQL
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:Pattern=^[a-zA-Z][a-zA-Z0-9_-]*$
+	// +kubebuilder:validation:MaxLength=63
+	ServerName string `json:"serverName"`
+
+	// Wrapper is the foreign data wrapper, installed as an extension of the same name
+	// +kubebuilder:default="postgres_fdw"
+	// +kubebuilder:validation:Pattern=^[a-z][a-z0-9_]*$
+	// +optional
+	Wrapper string `json:"wrapper,omitempty"`
+
+	// Options of the server, such as host, port and dbname for postgres_fdw
+	// +optional
+	Options map[string]string `json:"options,omitempty"`
+
+	// UserMappings map local roles to credentials on the foreign server
+	// +optional
+	UserMappings []UserMapping `json:"userMappings,omitempty"`
+
+	// ResyncInterval is how often the server and its user mappings are verified again once ready. Defaults to
+	// the operator's --resync-interval.
+	// +optional
+	ResyncInterval *metav1.Duration `json:"resyncInterval,omitempty"`
+}
+
+// UserMapping maps a local role to credentials on a foreign server
+type UserMapping struct {
+	// LocalUser is the local role the mapping applies to
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:Pattern=^[a-zA-Z][a-zA-Z0-9_-]*$
+	LocalUser string `json:"localUser"`
+
+	// CredentialsSecret references a secret in the same namespace with username and password keys
+	// +kubebuilder:validation:Required
+	CredentialsSecret LocalObjectReference `json:"credentialsSecret"`
+}
+
+// ForeignServerStatus defines the observed state of ForeignServer.
+type ForeignServerStatus struct {
+	// Ready indicates if the server and user mappings match the spec
+	// +optional
+	Ready bool `json:"ready,omitempty"`
+
+	// ServerCreated indicates if the foreign server exists
+	// +optional
+	ServerCreated bool `json:"serverCreated,omitempty"`
+
+	// CreatedByOperator indicates that this resource created the foreign server, only such servers are dropped
+	// when the resource is deleted. Servers that existed before are adopted and retained.
+	// +optional
+	CreatedByOperator bool `json:"createdByOperator,omitempty"`
+
+	// UserMappings lists the local roles that have a user mapping
+	// +optional
+	UserMappings []string `json:"userMappings,omitempty"`
+
+	// DDLHistory lists the newest DDL statements the operator executed for the resource, with passwords masked
+	// +optional
+	DDLHistory []ExecutedStatement `json:"ddlHistory,omitempty"`
+
+	// Message provides human readable status information
+	// +optional
+	Message string `json:"message,omitempty"`
+
+	// ObservedGeneration is the generation of the spec the status was last computed for
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// Conditions represent the latest available observations
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:categories=postgres
+// +kubebuilder:printcolumn:name="Ready",type=boolean,JSONPath=`.status.ready`
+// +kubebuilder:printcolumn:name="Database",type=string,JSONPath=`.spec.databaseRef.name`
+// +kubebuilder:printcolumn:name="Server",type=string,JSONPath=`.spec.serverName`
+// +kubebuilder:printcolumn:name="Message",type=string,JSONPath=`.status.message`,priority=1
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// ForeignServer is the Schema for the foreignservers API
+type ForeignServer struct {
+	metav1.TypeMeta `json:",inline"`
+
+	// metadata is a standard object metadata
+	// +optional
+	metav1.ObjectMeta `json:"metadata,omitempty,omitzero"`
+
+	// spec defines the desired state of ForeignServer
+	// +required
+	Spec ForeignServerSpec `json:"spec"`
+
+	// status defines the observed state of ForeignServer
+	// +optional
+	Status ForeignServerStatus `json:"status,omitempty,omitzero"`
+}
+
+// +kubebuilder:object:root=true
+
+// ForeignServerList contains a list of ForeignServer
+type ForeignServerList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ForeignServer `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ForeignServer{}, &ForeignServerList{})
+}
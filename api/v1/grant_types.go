@@ -0,0 +1,147 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// GrantSpec defines the desired state of Grant
+type GrantSpec struct {
+	// DatabaseRef references the Database the objects live in, in the same namespace
+	// +kubebuilder:validation:Required
+	DatabaseRef LocalObjectReference `json:"databaseRef"`
+
+	// Role that receives the privileges
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:Pattern=^[a-zA-Z][a-zA-Z0-9_-]*$
+	// +kubebuilder:validation:MaxLength=63
+	Role string `json:"role"`
+
+	// ObjectType is the kind of object the privileges apply to
+	// +kubebuilder:validation:Required
+	ObjectType GrantObjectType `json:"objectType"`
+
+	// Schema containing the objects (ignored when objectType is schema)
+	// +kubebuilder:default="public"
+	// +optional
+	Schema string `json:"schema,omitempty"`
+
+	// Objects lists object names or shell-style wildcard patterns such as "orders_*" or "*"
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinItems=1
+	Objects []string `json:"objects"`
+
+	// Privileges granted on every matching object
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinItems=1
+	Privileges []ObjectPrivilege `json:"privileges"`
+
+	// ResyncInterval grants the privileges again this often, which also covers objects matching the patterns
+	// that were created since. Defaults to the operator's --resync-interval.
+	// +optional
+	ResyncInterval *metav1.Duration `json:"resyncInterval,omitempty"`
+}
+
+// GrantObjectType is the kind of object a Grant applies to
+// +kubebuilder:validation:Enum=schema;table;sequence;function
+type GrantObjectType string
+
+const (
+	// GrantObjectSchema grants privileges on schemas
+	GrantObjectSchema GrantObjectType = "schema"
+	// GrantObjectTable grants privileges on tables, views and materialized views
+	GrantObjectTable GrantObjectType = "table"
+	// GrantObjectSequence grants privileges on sequences
+	GrantObjectSequence GrantObjectType = "sequence"
+	// GrantObjectFunction grants privileges on functions and procedures
+	GrantObjectFunction GrantObjectType = "function"
+)
+
+// ObjectPrivilege is a privilege on a database object
+// +kubebuilder:validation:Enum=SELECT;INSERT;UPDATE;DELETE;TRUNCATE;REFERENCES;TRIGGER;USAGE;CREATE;EXECUTE;ALL
+type ObjectPrivilege string
+
+// GrantStatus defines the observed state of Grant.
+type GrantStatus struct {
+	// Ready indicates if the privileges match the spec
+	// +optional
+	Ready bool `json:"ready,omitempty"`
+
+	// Objects lists the objects the privileges are currently granted on
+	// +optional
+	Objects []string `json:"objects,omitempty"`
+
+	// Privileges lists the privileges currently granted by this resource
+	// +optional
+	Privileges []string `json:"privileges,omitempty"`
+
+	// DDLHistory lists the newest DDL statements the operator executed for the resource, with passwords masked
+	// +optional
+	DDLHistory []ExecutedStatement `json:"ddlHistory,omitempty"`
+
+	// Message provides human readable status information
+	// +optional
+	Message string `json:"message,omitempty"`
+
+	// ObservedGeneration is the generation of the spec the status was last computed for
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// Conditions represent the latest available observations
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:categories=postgres
+// +kubebuilder:printcolumn:name="Ready",type=boolean,JSONPath=`.status.ready`
+// +kubebuilder:printcolumn:name="Database",type=string,JSONPath=`.spec.databaseRef.name`
+// +kubebuilder:printcolumn:name="Role",type=string,JSONPath=`.spec.role`
+// +kubebuilder:printcolumn:name="Message",type=string,JSONPath=`.status.message`,priority=1
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// Grant is the Schema for the grants API
+type Grant struct {
+	metav1.TypeMeta `json:",inline"`
+
+	// metadata is a standard object metadata
+	// +optional
+	metav1.ObjectMeta `json:"metadata,omitempty,omitzero"`
+
+	// spec defines the desired state of Grant
+	// +required
+	Spec GrantSpec `json:"spec"`
+
+	// status defines the observed state of Grant
+	// +optional
+	Status GrantStatus `json:"status,omitempty,omitzero"`
+}
+
+// +kubebuilder:object:root=true
+
+// GrantList contains a list of Grant
+type GrantList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []Grant `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&Grant{}, &GrantList{})
+}
@@ -0,0 +1,89 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// PostgresClassSpec defines the desired state of PostgresClass
+type PostgresClassSpec struct {
+	// ConnectionRef references the PostGresConnection databases of this class are provisioned on
+	// +kubebuilder:validation:Required
+	ConnectionRef ClassConnectionReference `json:"connectionRef"`
+
+	// Default marks the class used by DatabaseClaims that do not name one
+	// +kubebuilder:default=false
+	// +optional
+	Default bool `json:"default,omitempty"`
+
+	// Encoding for provisioned databases
+	// +kubebuilder:default="UTF8"
+	// +optional
+	Encoding string `json:"encoding,omitempty"`
+
+	// Extensions installed in every provisioned database
+	// +optional
+	Extensions []DatabaseExtension `json:"extensions,omitempty"`
+
+	// Permissions granted to the user of every provisioned database
+	// +kubebuilder:default={"ALL"}
+	// +optional
+	Permissions []Permission `json:"permissions,omitempty"`
+}
+
+// ClassConnectionReference references a PostGresConnection from a cluster-scoped resource
+type ClassConnectionReference struct {
+	// Name of the PostGresConnection resource
+	// +kubebuilder:validation:Required
+	Name string `json:"name"`
+
+	// Namespace of the PostGresConnection
+	// +kubebuilder:validation:Required
+	Namespace string `json:"namespace"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:scope=Cluster,categories=postgres
+// +kubebuilder:printcolumn:name="Connection",type=string,JSONPath=`.spec.connectionRef.name`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// PostgresClass is the Schema for the postgresclasses API
+type PostgresClass struct {
+	metav1.TypeMeta `json:",inline"`
+
+	// metadata is a standard object metadata
+	// +optional
+	metav1.ObjectMeta `json:"metadata,omitempty,omitzero"`
+
+	// spec defines the desired state of PostgresClass
+	// +required
+	Spec PostgresClassSpec `json:"spec"`
+}
+
+// +kubebuilder:object:root=true
+
+// PostgresClassList contains a list of PostgresClass
+type PostgresClassList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []PostgresClass `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&PostgresClass{}, &PostgresClassList{})
+}
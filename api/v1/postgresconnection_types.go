@@ -24,16 +24,43 @@ import (
 // NOTE: json tags are required.  Any new fields you add must have json tags for the fields to be serialized.
 
 // PostGresConnectionSpec defines the desired state of PostGresConnection
+// +kubebuilder:validation:XValidation:rule="has(self.clusterName) || has(self.host) || has(self.uri) || has(self.uriSecretRef) || has(self.proxy)",message="one of clusterName, host, uri, uriSecretRef and proxy is required"
+// +kubebuilder:validation:XValidation:rule="!has(self.proxy) || !(has(self.clusterName) || has(self.host) || has(self.uri) || has(self.uriSecretRef) || has(self.fallbackHosts))",message="proxy may not be combined with clusterName, host, uri, uriSecretRef or fallbackHosts"
+// +kubebuilder:validation:XValidation:rule="has(self.clusterName) || has(self.uri) || has(self.uriSecretRef) || has(self.credentialsSecretRef) || has(self.superUserSecret) || has(self.credentialsFrom)",message="credentialsSecretRef, superUserSecret or credentialsFrom is required without clusterName"
+// +kubebuilder:validation:XValidation:rule="!(has(self.uri) && has(self.uriSecretRef))",message="only one of uri and uriSecretRef may be set"
+// +kubebuilder:validation:XValidation:rule="!has(self.fallbackHosts) || !(has(self.uri) || has(self.uriSecretRef))",message="fallbackHosts may not be combined with a connection URI, list the hosts in the URI instead"
 type PostGresConnectionSpec struct {
-	// ClusterName references the CNPG cluster name
-	// +kubebuilder:validation:Required
-	ClusterName string `json:"clusterName"`
+	// ClusterName references the CNPG cluster name. Servers outside CNPG, e.g. RDS, leave it empty and set
+	// host or a URI instead.
+	// +optional
+	ClusterName string `json:"clusterName,omitempty"`
+
+	// URI is a libpq connection URI for servers outside CNPG, e.g.
+	// postgresql://admin@db1.example.com,db2.example.com/postgres?sslmode=verify-full&target_session_attrs=read-write.
+	// Several hosts are tried in order. Credentials missing from the URI are read from superUserSecret or
+	// credentialsFrom; use uriSecretRef for URIs holding a password.
+	// +optional
+	URI string `json:"uri,omitempty"`
+
+	// URISecretRef reads the connection URI from a Secret key in the connection's namespace
+	// +optional
+	URISecretRef *SecretKeyReference `json:"uriSecretRef,omitempty"`
+
+	// Proxy connects through a Cloud SQL or AlloyDB Auth Proxy listening on the operator's loopback
+	// interface, e.g. as a sidecar of the operator
+	// +optional
+	Proxy *AuthProxy `json:"proxy,omitempty"`
 
 	// ClusterNamespace is the namespace where the CNPG cluster is located
 	// Defaults to the same namespace as the PostGresConnection if not specified
 	// +optional
 	ClusterNamespace string `json:"clusterNamespace,omitempty"`
 
+	// WaitForCluster reads the CNPG Cluster and keeps the connection not ready until the cluster reports
+	// Ready, instead of failing connection attempts while it bootstraps or fails over
+	// +optional
+	WaitForCluster bool `json:"waitForCluster,omitempty"`
+
 	// SuperUserSecret references the secret containing superuser credentials
 	// Defaults to {clusterName}-superuser if not specified
 	// +optional
@@ -45,8 +72,18 @@ type PostGresConnectionSpec struct {
 	// +optional
 	UseAppSecret *bool `json:"useAppSecret,omitempty"`
 
+	// CredentialsSecretRef references a secret holding the connection credentials, with configurable keys
+	// for secrets written by other tools. Takes the place of superUserSecret.
+	// +optional
+	CredentialsSecretRef *CredentialsSecretReference `json:"credentialsSecretRef,omitempty"`
+
+	// CredentialsFrom reads the connection credentials from an external secret provider instead of a Secret.
+	// Requires sslMode verify-full unless the connection goes through an auth proxy.
+	// +optional
+	CredentialsFrom *ExternalCredentials `json:"credentialsFrom,omitempty"`
+
 	// Host is the PostgreSQL host (if not using CNPG service discovery)
-	// Defaults to {clusterName}-rw service if not specified
+	// Defaults to {clusterName}-rw service if not specified, required without clusterName
 	// +optional
 	Host string `json:"host,omitempty"`
 
@@ -60,6 +97,144 @@ type PostGresConnectionSpec struct {
 	// +kubebuilder:validation:Enum=disable;allow;prefer;require;verify-ca;verify-full
 	// +optional
 	SSLMode string `json:"sslMode,omitempty"`
+
+	// SSLServerName is the name the server certificate is verified against with verify-full, overriding the
+	// host dialed. Use it when connecting through an IP, a port-forward or a service name the certificate
+	// does not cover.
+	// +kubebuilder:validation:MaxLength=253
+	// +optional
+	SSLServerName string `json:"sslServerName,omitempty"`
+
+	// TLS configures certificate verification for the connection
+	// Use this with verify-ca or verify-full against servers signed by a private CA
+	// +optional
+	TLS *TLSConfig `json:"tls,omitempty"`
+
+	// ReadOnly validates the connection against the CNPG -ro service instead of the primary, for monitoring
+	// or verification. Read-only connections cannot run DDL, resources using them stay not ready.
+	// +optional
+	ReadOnly bool `json:"readOnly,omitempty"`
+
+	// AllowedNamespaces restricts which other namespaces may reference the connection, e.g. from Databases or
+	// PostgresRoles. Objects in the connection's own namespace are always allowed; without it every namespace is.
+	// +optional
+	AllowedNamespaces *AllowedNamespaces `json:"allowedNamespaces,omitempty"`
+
+	// MaxDatabases limits the Database resources bound to the connection. The oldest Databases are within the
+	// quota, newer ones get a QuotaExceeded condition and are not provisioned.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	MaxDatabases *int32 `json:"maxDatabases,omitempty"`
+
+	// FallbackHosts are tried in order when the primary's endpoint cannot be reached, e.g. while the CNPG -rw
+	// service points nowhere during a switchover. With fallback hosts the operator only uses a server that
+	// accepts writes, like target_session_attrs=read-write.
+	// +optional
+	FallbackHosts []HostEndpoint `json:"fallbackHosts,omitempty"`
+
+	// ConnectionOptions are further libpq parameters of the operator's connections, e.g. application_name,
+	// options or keepalives_idle. Credentials and parameters set by other fields cannot be overridden.
+	// +kubebuilder:validation:XValidation:rule="self.all(k, !(k in ['user', 'password', 'passfile', 'sslcert', 'sslkey', 'sslpassword', 'sslinline', 'host', 'hostaddr', 'port', 'dbname', 'service', 'servicefile', 'sslmode', 'sslrootcert', 'connect_timeout', 'target_session_attrs']))",message="connectionOptions cannot set credentials or parameters covered by other fields"
+	// +optional
+	ConnectionOptions map[string]string `json:"connectionOptions,omitempty"`
+
+	// ConnectTimeout bounds connecting to each host, including the TLS handshake and authentication
+	// +kubebuilder:default="30s"
+	// +optional
+	ConnectTimeout *metav1.Duration `json:"connectTimeout,omitempty"`
+
+	// StatementTimeout is set as statement_timeout on the operator's sessions, bounding every statement it
+	// runs. Unbounded unless set.
+	// +optional
+	StatementTimeout *metav1.Duration `json:"statementTimeout,omitempty"`
+
+	// LockTimeout is set as lock_timeout on the operator's sessions, so DDL waiting behind an application's
+	// lock fails instead of blocking everything queued behind it. Unbounded unless set.
+	// +optional
+	LockTimeout *metav1.Duration `json:"lockTimeout,omitempty"`
+
+	// RevalidationInterval is how often a ready connection is checked again, so that an outage turns it not
+	// ready. Without it the connection is only checked when its spec changes.
+	// +optional
+	RevalidationInterval *metav1.Duration `json:"revalidationInterval,omitempty"`
+
+	// Pooler is a CNPG Pooler (PgBouncer) in front of the cluster. Databases publish its endpoint in user
+	// secrets through the pooler-host, pooler-port and pooled-uri keys; the operator itself connects directly
+	// unless management is set.
+	// +optional
+	Pooler *PoolerEndpoint `json:"pooler,omitempty"`
+}
+
+// PoolerEndpoint locates a connection pooler
+type PoolerEndpoint struct {
+	// Name of the CNPG Pooler, whose service has the same name in the cluster's namespace
+	// +optional
+	Name string `json:"name,omitempty"`
+
+	// Host of the pooler, overriding the service of the named Pooler
+	// +optional
+	Host string `json:"host,omitempty"`
+
+	// Port of the pooler
+	// +kubebuilder:default=5432
+	// +optional
+	Port int32 `json:"port,omitempty"`
+
+	// Management routes the operator's own connections through the pooler too. The pooler must use session
+	// pooling, where a reconcile keeps one server connection; a named Pooler in transaction or statement
+	// mode turns the connection not ready.
+	// +optional
+	Management bool `json:"management,omitempty"`
+}
+
+// AllowedNamespaces selects namespaces by name or label, a namespace matching either is allowed
+type AllowedNamespaces struct {
+	// Names of the allowed namespaces
+	// +optional
+	Names []string `json:"names,omitempty"`
+
+	// Selector matches the labels of allowed namespaces
+	// +optional
+	Selector *metav1.LabelSelector `json:"selector,omitempty"`
+}
+
+// AuthProxy is a Cloud SQL or AlloyDB Auth Proxy, which encrypts and authorizes connections to the instance
+type AuthProxy struct {
+	// Port the proxy listens on at 127.0.0.1 for the instance
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	Port int32 `json:"port"`
+
+	// SecretHost is the host user secrets publish, e.g. the instance's private IP or the proxy of the
+	// applications, which cannot reach the proxy on the operator's loopback interface
+	// +kubebuilder:validation:MinLength=1
+	SecretHost string `json:"secretHost"`
+
+	// SecretPort is the port user secrets publish with secretHost
+	// +kubebuilder:default=5432
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	// +optional
+	SecretPort int32 `json:"secretPort,omitempty"`
+
+	// HealthCheckURL is the proxy's readiness endpoint on the loopback interface, served when the proxy runs
+	// with --health-check, e.g. http://127.0.0.1:9090/readiness. A failing connection is then reported as a
+	// proxy or a database failure.
+	// +optional
+	HealthCheckURL string `json:"healthCheckURL,omitempty"`
+}
+
+// HostEndpoint is a host and port of a server
+type HostEndpoint struct {
+	// Host of the server
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinLength=1
+	Host string `json:"host"`
+
+	// Port of the server
+	// +kubebuilder:default=5432
+	// +optional
+	Port int32 `json:"port,omitempty"`
 }
 
 // SecretReference represents a reference to a secret
@@ -73,6 +248,119 @@ type SecretReference struct {
 	Namespace string `json:"namespace,omitempty"`
 }
 
+// CredentialsSecretReference references a secret holding a username and password
+type CredentialsSecretReference struct {
+	// Name of the secret
+	// +kubebuilder:validation:Required
+	Name string `json:"name"`
+
+	// Namespace of the secret (defaults to same namespace as PostGresConnection)
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+
+	// UsernameKey is the key holding the username
+	// +kubebuilder:default="username"
+	// +optional
+	UsernameKey string `json:"usernameKey,omitempty"`
+
+	// PasswordKey is the key holding the password
+	// +kubebuilder:default="password"
+	// +optional
+	PasswordKey string `json:"passwordKey,omitempty"`
+}
+
+const (
+	// CredentialsProviderVault reads the credentials from the Vault KV engine
+	CredentialsProviderVault = "vault"
+	// CredentialsProviderAzure authenticates with a Microsoft Entra ID access token
+	CredentialsProviderAzure = "azure"
+)
+
+// ExternalCredentials locates connection credentials kept outside Kubernetes
+// +kubebuilder:validation:XValidation:rule="!has(self.provider) || self.provider != 'azure' || has(self.azure)",message="azure is required with the azure provider"
+// +kubebuilder:validation:XValidation:rule="(has(self.provider) && self.provider == 'azure') || has(self.vault)",message="vault is required with the vault provider"
+type ExternalCredentials struct {
+	// Provider holding the credentials, configured in the operator. vault reads a username and password,
+	// azure uses an access token of the operator's workload identity as the password.
+	// +kubebuilder:validation:Enum=vault;azure
+	// +kubebuilder:default=vault
+	// +optional
+	Provider string `json:"provider,omitempty"`
+
+	// Vault locates the credentials in the Vault KV engine
+	// +optional
+	Vault *VaultSecretReference `json:"vault,omitempty"`
+
+	// Azure configures Microsoft Entra ID authentication against Azure Database for PostgreSQL
+	// +optional
+	Azure *AzureCredentials `json:"azure,omitempty"`
+
+	// RefreshInterval is how long vault credentials are cached before they are read again. They are also
+	// read again as soon as the server rejects them. Access tokens are replaced shortly before they expire.
+	// +kubebuilder:default="5m"
+	// +optional
+	RefreshInterval *metav1.Duration `json:"refreshInterval,omitempty"`
+}
+
+// AzureCredentials configures Microsoft Entra ID authentication
+type AzureCredentials struct {
+	// Username is the PostgreSQL role of the operator's managed identity, as created by
+	// pgaadauth_create_principal
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinLength=1
+	Username string `json:"username"`
+}
+
+// VaultSecretReference references a secret in the Vault KV engine
+type VaultSecretReference struct {
+	// Path of the secret within the directory of the connection's namespace below the operator's --vault-path-root
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinLength=1
+	Path string `json:"path"`
+
+	// UsernameKey is the key holding the username
+	// +kubebuilder:default="username"
+	// +optional
+	UsernameKey string `json:"usernameKey,omitempty"`
+
+	// PasswordKey is the key holding the password
+	// +kubebuilder:default="password"
+	// +optional
+	PasswordKey string `json:"passwordKey,omitempty"`
+}
+
+// TLSConfig defines the TLS settings for a PostgreSQL connection
+type TLSConfig struct {
+	// CASecretRef references a secret key containing a PEM encoded CA bundle
+	// +optional
+	CASecretRef *KeyReference `json:"caSecretRef,omitempty"`
+
+	// CAConfigMapRef references a config map key containing a PEM encoded CA bundle
+	// +optional
+	CAConfigMapRef *KeyReference `json:"caConfigMapRef,omitempty"`
+
+	// UseClusterCA verifies the server against the CA CNPG signs the cluster's certificates with, read from
+	// the {clusterName}-ca secret. Clusters with their own serverCASecret need caSecretRef instead.
+	// +optional
+	UseClusterCA bool `json:"useClusterCA,omitempty"`
+}
+
+// KeyReference represents a reference to a key in a secret or config map
+type KeyReference struct {
+	// Name of the secret or config map
+	// +kubebuilder:validation:Required
+	Name string `json:"name"`
+
+	// Namespace of the secret or config map (defaults to same namespace as PostGresConnection)
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+
+	// Key holding the data
+	// +kubebuilder:default="ca.crt"
+	// +optional
+	Key string `json:"key,omitempty"`
+}
+
 // PostGresConnectionStatus defines the observed state of PostGresConnection.
 type PostGresConnectionStatus struct {
 	// Ready indicates if the connection is ready to be used
@@ -87,13 +375,95 @@ type PostGresConnectionStatus struct {
 	// +optional
 	LastChecked *metav1.Time `json:"lastChecked,omitempty"`
 
+	// LatencyMillis is the round trip time of a ping at the last check, in milliseconds
+	// +optional
+	LatencyMillis *int64 `json:"latencyMillis,omitempty"`
+
+	// ReadOnly is set when the connection is read-only or the server it reaches does not accept writes,
+	// e.g. a standby
+	// +optional
+	ReadOnly bool `json:"readOnly,omitempty"`
+
+	// ServerVersion is the version the server reported at the last successful check
+	// +optional
+	ServerVersion string `json:"serverVersion,omitempty"`
+
+	// ServerVersionNum is server_version_num of the server at the last successful check, e.g. 160004 for 16.4
+	// +optional
+	ServerVersionNum int32 `json:"serverVersionNum,omitempty"`
+
+	// Encryption is the TLS version and cipher of the connection, or none when it is not encrypted
+	// +optional
+	Encryption string `json:"encryption,omitempty"`
+
+	// ConnectionEndpoint is the address and port of the server that answered the last check, e.g. the pod IP
+	// of the current primary behind a CNPG service
+	// +optional
+	ConnectionEndpoint string `json:"connectionEndpoint,omitempty"`
+
+	// Privileges reports what the connection account is allowed to do
+	// +optional
+	Privileges *ConnectionPrivileges `json:"privileges,omitempty"`
+
+	// Databases are the Database resources bound to the connection, as namespace/name. At most 100 are listed.
+	// +optional
+	Databases []string `json:"databases,omitempty"`
+
+	// DatabaseCount is the number of Database resources bound to the connection
+	// +optional
+	DatabaseCount int32 `json:"databaseCount,omitempty"`
+
+	// Cluster reports the CNPG cluster behind the connection when waitForCluster is set
+	// +optional
+	Cluster *CNPGClusterStatus `json:"cluster,omitempty"`
+
+	// ObservedGeneration is the generation of the spec the status was last computed for
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
 	// Conditions represent the latest available observations
 	// +optional
 	Conditions []metav1.Condition `json:"conditions,omitempty"`
 }
 
+// CNPGClusterStatus reports the state of a CNPG cluster
+type CNPGClusterStatus struct {
+	// Phase of the cluster as reported by CNPG, e.g. "Cluster in healthy state"
+	// +optional
+	Phase string `json:"phase,omitempty"`
+
+	// CurrentPrimary is the instance currently running as primary
+	// +optional
+	CurrentPrimary string `json:"currentPrimary,omitempty"`
+
+	// Ready mirrors the Ready condition of the cluster
+	Ready bool `json:"ready"`
+}
+
+// ConnectionPrivileges describes the role attributes of the connection account
+type ConnectionPrivileges struct {
+	// Superuser indicates the account is a superuser
+	Superuser bool `json:"superuser"`
+
+	// CreateDB indicates the account may create databases
+	CreateDB bool `json:"createDB"`
+
+	// CreateRole indicates the account may create roles
+	CreateRole bool `json:"createRole"`
+
+	// Missing lists privileges the operator needs but the account lacks
+	// +optional
+	Missing []string `json:"missing,omitempty"`
+}
+
 // +kubebuilder:object:root=true
 // +kubebuilder:subresource:status
+// +kubebuilder:resource:categories=postgres
+// +kubebuilder:printcolumn:name="Ready",type=boolean,JSONPath=`.status.ready`
+// +kubebuilder:printcolumn:name="Cluster",type=string,JSONPath=`.spec.clusterName`
+// +kubebuilder:printcolumn:name="Host",type=string,JSONPath=`.spec.host`,priority=1
+// +kubebuilder:printcolumn:name="Message",type=string,JSONPath=`.status.message`,priority=1
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
 
 // PostGresConnection is the Schema for the postgresconnections API
 type PostGresConnection struct {
@@ -0,0 +1,192 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// PostgresRoleSpec defines the desired state of PostgresRole
+type PostgresRoleSpec struct {
+	// ConnectionRef references a PostGresConnection resource
+	// +kubebuilder:validation:Required
+	ConnectionRef ConnectionReference `json:"connectionRef"`
+
+	// RoleName is the name of the role in PostgreSQL
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:Pattern=^[a-zA-Z][a-zA-Z0-9_-]*$
+	// +kubebuilder:validation:MaxLength=63
+	// +kubebuilder:validation:XValidation:rule="!(self in ['postgres', 'public', 'none'])",message="roleName must not be a reserved or system role"
+	// +kubebuilder:validation:XValidation:rule="!self.startsWith('pg_')",message="roleName must not start with pg_"
+	RoleName string `json:"roleName"`
+
+	// Attributes of the role
+	// +optional
+	Attributes RoleAttributes `json:"attributes,omitempty"`
+
+	// MemberOf lists roles this role is granted membership in
+	// +optional
+	// +kubebuilder:validation:items:Pattern=^[a-zA-Z][a-zA-Z0-9_-]*$
+	MemberOf []string `json:"memberOf,omitempty"`
+
+	// Config sets role-level configuration parameters (ALTER ROLE ... SET). Parameters removed from this map are
+	// reset, parameters set on the role by others are left alone.
+	// +optional
+	Config map[string]string `json:"config,omitempty"`
+
+	// ResyncInterval is how often a ready role is verified again, restoring attributes and memberships
+	// changed by hand. Defaults to the operator's --resync-interval.
+	// +optional
+	ResyncInterval *metav1.Duration `json:"resyncInterval,omitempty"`
+
+	// DeletionPolicy decides what happens to the role when the PostgresRole is deleted. Retain leaves the role,
+	// Drop drops it while it still carries the ownership marker of this resource. A role that owns objects or
+	// holds privileges cannot be dropped.
+	// +kubebuilder:validation:Enum=Retain;Drop
+	// +kubebuilder:default=Retain
+	// +optional
+	DeletionPolicy RoleDeletionPolicy `json:"deletionPolicy,omitempty"`
+}
+
+// RoleDeletionPolicy decides what happens in PostgreSQL when a PostgresRole is deleted
+type RoleDeletionPolicy string
+
+const (
+	// RoleDeletionRetain keeps the role
+	RoleDeletionRetain RoleDeletionPolicy = "Retain"
+	// RoleDeletionDrop drops the role
+	RoleDeletionDrop RoleDeletionPolicy = "Drop"
+)
+
+// RoleAttributes defines the attributes of a PostgreSQL role
+type RoleAttributes struct {
+	// Login allows the role to log in
+	// +kubebuilder:default=false
+	// +optional
+	Login bool `json:"login,omitempty"`
+
+	// Superuser makes the role a superuser
+	// +kubebuilder:default=false
+	// +optional
+	Superuser bool `json:"superuser,omitempty"`
+
+	// CreateDB allows the role to create databases
+	// +kubebuilder:default=false
+	// +optional
+	CreateDB bool `json:"createDB,omitempty"`
+
+	// CreateRole allows the role to create roles
+	// +kubebuilder:default=false
+	// +optional
+	CreateRole bool `json:"createRole,omitempty"`
+
+	// Inherit makes the role inherit the privileges of roles it is a member of
+	// +kubebuilder:default=true
+	// +optional
+	Inherit *bool `json:"inherit,omitempty"`
+
+	// Replication allows the role to initiate streaming replication
+	// +kubebuilder:default=false
+	// +optional
+	Replication bool `json:"replication,omitempty"`
+
+	// BypassRLS makes the role bypass row level security policies
+	// +kubebuilder:default=false
+	// +optional
+	BypassRLS bool `json:"bypassRLS,omitempty"`
+
+	// ConnectionLimit limits concurrent connections for the role (-1 means no limit)
+	// +kubebuilder:default=-1
+	// +kubebuilder:validation:Minimum=-1
+	// +optional
+	ConnectionLimit *int32 `json:"connectionLimit,omitempty"`
+}
+
+// PostgresRoleStatus defines the observed state of PostgresRole.
+type PostgresRoleStatus struct {
+	// Ready indicates if the role matches the spec
+	// +optional
+	Ready bool `json:"ready,omitempty"`
+
+	// RoleCreated indicates if the role exists
+	// +optional
+	RoleCreated bool `json:"roleCreated,omitempty"`
+
+	// MemberOf lists the memberships granted by the operator
+	// +optional
+	MemberOf []string `json:"memberOf,omitempty"`
+
+	// ConfigParameters lists the configuration parameters the operator set on the role, only these are reset
+	// once removed from spec.config
+	// +optional
+	ConfigParameters []string `json:"configParameters,omitempty"`
+
+	// DDLHistory lists the newest DDL statements the operator executed for the resource, with passwords masked
+	// +optional
+	DDLHistory []ExecutedStatement `json:"ddlHistory,omitempty"`
+
+	// Message provides human readable status information
+	// +optional
+	Message string `json:"message,omitempty"`
+
+	// ObservedGeneration is the generation of the spec the status was last computed for
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// Conditions represent the latest available observations
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:categories=postgres
+// +kubebuilder:printcolumn:name="Ready",type=boolean,JSONPath=`.status.ready`
+// +kubebuilder:printcolumn:name="Role",type=string,JSONPath=`.spec.roleName`
+// +kubebuilder:printcolumn:name="Connection",type=string,JSONPath=`.spec.connectionRef.name`
+// +kubebuilder:printcolumn:name="Message",type=string,JSONPath=`.status.message`,priority=1
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// PostgresRole is the Schema for the postgresroles API
+type PostgresRole struct {
+	metav1.TypeMeta `json:",inline"`
+
+	// metadata is a standard object metadata
+	// +optional
+	metav1.ObjectMeta `json:"metadata,omitempty,omitzero"`
+
+	// spec defines the desired state of PostgresRole
+	// +required
+	Spec PostgresRoleSpec `json:"spec"`
+
+	// status defines the observed state of PostgresRole
+	// +optional
+	Status PostgresRoleStatus `json:"status,omitempty,omitzero"`
+}
+
+// +kubebuilder:object:root=true
+
+// PostgresRoleList contains a list of PostgresRole
+type PostgresRoleList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []PostgresRole `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&PostgresRole{}, &PostgresRoleList{})
+}
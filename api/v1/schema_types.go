@@ -0,0 +1,169 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// SchemaSpec defines the desired state of Schema
+type SchemaSpec struct {
+	// DatabaseRef references the Database to create the schema in, in the same namespace
+	// +kubebuilder:validation:Required
+	DatabaseRef LocalObjectReference `json:"databaseRef"`
+
+	// SchemaName is the name of the schema in PostgreSQL
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:Pattern=^[a-zA-Z][a-zA-Z0-9_-]*$
+	// +kubebuilder:validation:MaxLength=63
+	SchemaName string `json:"schemaName"`
+
+	// Owner of the schema (defaults to the connecting user)
+	// +kubebuilder:validation:Pattern=^[a-zA-Z][a-zA-Z0-9_-]*$
+	// +optional
+	Owner string `json:"owner,omitempty"`
+
+	// DropCascadeOnDelete drops the schema and every object in it when the resource is deleted, if this
+	// resource created it. When false, or for a schema that already existed, the schema is retained.
+	// +kubebuilder:default=false
+	// +optional
+	DropCascadeOnDelete bool `json:"dropCascadeOnDelete,omitempty"`
+
+	// Grants are privileges on the schema granted to roles
+	// +optional
+	Grants []SchemaGrant `json:"grants,omitempty"`
+
+	// DefaultPrivileges are granted on objects the owner creates in the schema later on
+	// +optional
+	DefaultPrivileges []DefaultPrivilege `json:"defaultPrivileges,omitempty"`
+
+	// ResyncInterval is how often a ready schema, its owner and grants are checked again. Defaults to the
+	// operator's --resync-interval.
+	// +optional
+	ResyncInterval *metav1.Duration `json:"resyncInterval,omitempty"`
+}
+
+// SchemaGrant grants privileges on a schema to a role
+type SchemaGrant struct {
+	// Role that receives the privileges
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:Pattern=^[a-zA-Z][a-zA-Z0-9_-]*$
+	Role string `json:"role"`
+
+	// Privileges on the schema
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinItems=1
+	// +kubebuilder:validation:items:Enum=USAGE;CREATE;ALL
+	Privileges []ObjectPrivilege `json:"privileges"`
+}
+
+// DefaultPrivilege grants privileges on future objects of one type to a role
+type DefaultPrivilege struct {
+	// Role that receives the privileges
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:Pattern=^[a-zA-Z][a-zA-Z0-9_-]*$
+	Role string `json:"role"`
+
+	// ObjectType the privileges apply to
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:Enum=tables;sequences;functions
+	ObjectType string `json:"objectType"`
+
+	// Privileges granted on new objects
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinItems=1
+	Privileges []ObjectPrivilege `json:"privileges"`
+}
+
+// SchemaStatus defines the observed state of Schema.
+type SchemaStatus struct {
+	// Ready indicates if the schema matches the spec
+	// +optional
+	Ready bool `json:"ready,omitempty"`
+
+	// SchemaCreated indicates if the schema exists
+	// +optional
+	SchemaCreated bool `json:"schemaCreated,omitempty"`
+
+	// CreatedByOperator indicates that this resource created the schema, only such schemas are dropped with
+	// dropCascadeOnDelete. Schemas that existed before are adopted and retained.
+	// +optional
+	CreatedByOperator bool `json:"createdByOperator,omitempty"`
+
+	// AppliedGrants are the grants last applied from the spec, privileges removed from the spec are revoked
+	// +optional
+	AppliedGrants []SchemaGrant `json:"appliedGrants,omitempty"`
+
+	// Owner is the current owner of the schema
+	// +optional
+	Owner string `json:"owner,omitempty"`
+
+	// DDLHistory lists the newest DDL statements the operator executed for the resource, with passwords masked
+	// +optional
+	DDLHistory []ExecutedStatement `json:"ddlHistory,omitempty"`
+
+	// Message provides human readable status information
+	// +optional
+	Message string `json:"message,omitempty"`
+
+	// ObservedGeneration is the generation of the spec the status was last computed for
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// Conditions represent the latest available observations
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:categories=postgres
+// +kubebuilder:printcolumn:name="Ready",type=boolean,JSONPath=`.status.ready`
+// +kubebuilder:printcolumn:name="Database",type=string,JSONPath=`.spec.databaseRef.name`
+// +kubebuilder:printcolumn:name="Schema",type=string,JSONPath=`.spec.schemaName`
+// +kubebuilder:printcolumn:name="Message",type=string,JSONPath=`.status.message`,priority=1
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// Schema is the Schema for the schemas API
+type Schema struct {
+	metav1.TypeMeta `json:",inline"`
+
+	// metadata is a standard object metadata
+	// +optional
+	metav1.ObjectMeta `json:"metadata,omitempty,omitzero"`
+
+	// spec defines the desired state of Schema
+	// +required
+	Spec SchemaSpec `json:"spec"`
+
+	// status defines the observed state of Schema
+	// +optional
+	Status SchemaStatus `json:"status,omitempty,omitzero"`
+}
+
+// +kubebuilder:object:root=true
+
+// SchemaList contains a list of Schema
+type SchemaList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []Schema `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&Schema{}, &SchemaList{})
+}
@@ -0,0 +1,130 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// SQLMigrationSpec defines the desired state of SQLMigration
+type SQLMigrationSpec struct {
+	// DatabaseRef references the Database to migrate, in the same namespace
+	// +kubebuilder:validation:Required
+	DatabaseRef LocalObjectReference `json:"databaseRef"`
+
+	// Sources hold the migration scripts. Every key is one script and its name is the
+	// script's version; scripts from all sources run in lexical order of their keys.
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinItems=1
+	Sources []MigrationSource `json:"sources"`
+
+	// TrackingTable records the applied versions and checksums in the database
+	// +kubebuilder:default="pg_operator_migrations"
+	// +kubebuilder:validation:Pattern=^[a-zA-Z][a-zA-Z0-9_]*$
+	// +kubebuilder:validation:MaxLength=63
+	// +optional
+	TrackingTable string `json:"trackingTable,omitempty"`
+}
+
+// MigrationSource references a ConfigMap or Secret in the same namespace holding SQL scripts
+type MigrationSource struct {
+	// ConfigMapRef references a ConfigMap holding scripts
+	// +optional
+	ConfigMapRef *LocalObjectReference `json:"configMapRef,omitempty"`
+
+	// SecretRef references a Secret holding scripts
+	// +optional
+	SecretRef *LocalObjectReference `json:"secretRef,omitempty"`
+}
+
+// AppliedMigration records a script that was executed
+type AppliedMigration struct {
+	// Version of the script
+	Version string `json:"version"`
+
+	// Checksum is the SHA-256 of the script
+	Checksum string `json:"checksum"`
+
+	// AppliedAt is when the script was executed
+	// +optional
+	AppliedAt *metav1.Time `json:"appliedAt,omitempty"`
+}
+
+// SQLMigrationStatus defines the observed state of SQLMigration.
+type SQLMigrationStatus struct {
+	// Ready indicates if every script has been applied
+	// +optional
+	Ready bool `json:"ready,omitempty"`
+
+	// AppliedMigrations lists the scripts recorded in the tracking table
+	// +optional
+	AppliedMigrations []AppliedMigration `json:"appliedMigrations,omitempty"`
+
+	// LastAppliedVersion is the version of the most recent script
+	// +optional
+	LastAppliedVersion string `json:"lastAppliedVersion,omitempty"`
+
+	// Message provides human readable status information
+	// +optional
+	Message string `json:"message,omitempty"`
+
+	// ObservedGeneration is the generation of the spec the status was last computed for
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// Conditions represent the latest available observations
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:categories=postgres
+// +kubebuilder:printcolumn:name="Ready",type=boolean,JSONPath=`.status.ready`
+// +kubebuilder:printcolumn:name="Database",type=string,JSONPath=`.spec.databaseRef.name`
+// +kubebuilder:printcolumn:name="Message",type=string,JSONPath=`.status.message`,priority=1
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// SQLMigration is the Schema for the sqlmigrations API
+type SQLMigration struct {
+	metav1.TypeMeta `json:",inline"`
+
+	// metadata is a standard object metadata
+	// +optional
+	metav1.ObjectMeta `json:"metadata,omitempty,omitzero"`
+
+	// spec defines the desired state of SQLMigration
+	// +required
+	Spec SQLMigrationSpec `json:"spec"`
+
+	// status defines the observed state of SQLMigration
+	// +optional
+	Status SQLMigrationStatus `json:"status,omitempty,omitzero"`
+}
+
+// +kubebuilder:object:root=true
+
+// SQLMigrationList contains a list of SQLMigration
+type SQLMigrationList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []SQLMigration `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&SQLMigration{}, &SQLMigrationList{})
+}
@@ -0,0 +1,169 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// TemporaryAccessRequestSpec defines the desired state of TemporaryAccessRequest
+type TemporaryAccessRequestSpec struct {
+	// DatabaseRef references the Database to grant access to, in the same namespace
+	// +kubebuilder:validation:Required
+	DatabaseRef LocalObjectReference `json:"databaseRef"`
+
+	// RoleName is the name of the temporary role (defaults to tmp_<request name>)
+	// +kubebuilder:validation:Pattern=^[a-zA-Z][a-zA-Z0-9_-]*$
+	// +kubebuilder:validation:MaxLength=63
+	// +optional
+	RoleName string `json:"roleName,omitempty"`
+
+	// Permissions granted to the temporary role
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinItems=1
+	Permissions []Permission `json:"permissions"`
+
+	// TTL is how long the access remains valid after it is granted
+	// +kubebuilder:validation:Required
+	TTL metav1.Duration `json:"ttl"`
+
+	// Reason documents why access is requested, recorded in the audit log
+	// +optional
+	Reason string `json:"reason,omitempty"`
+
+	// SecretName is the name of the secret holding the credentials (defaults to <request name>-credentials)
+	// +optional
+	SecretName string `json:"secretName,omitempty"`
+}
+
+// LocalObjectReference references an object in the same namespace
+type LocalObjectReference struct {
+	// Name of the referenced object
+	// +kubebuilder:validation:Required
+	Name string `json:"name"`
+}
+
+// TemporaryAccessPhase is the lifecycle phase of a TemporaryAccessRequest
+// +kubebuilder:validation:Enum=Pending;Active;Expired;Failed
+type TemporaryAccessPhase string
+
+const (
+	// TemporaryAccessPending means access has not been granted yet
+	TemporaryAccessPending TemporaryAccessPhase = "Pending"
+	// TemporaryAccessActive means the role and secret exist and are valid
+	TemporaryAccessActive TemporaryAccessPhase = "Active"
+	// TemporaryAccessExpired means the TTL elapsed and access was revoked
+	TemporaryAccessExpired TemporaryAccessPhase = "Expired"
+	// TemporaryAccessFailed means access could not be granted
+	TemporaryAccessFailed TemporaryAccessPhase = "Failed"
+)
+
+// AuditEntry records a lifecycle action taken by the operator
+type AuditEntry struct {
+	// Time the action was taken
+	Time metav1.Time `json:"time"`
+
+	// Action that was taken
+	Action string `json:"action"`
+
+	// Message describes the action
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+// TemporaryAccessRequestStatus defines the observed state of TemporaryAccessRequest.
+type TemporaryAccessRequestStatus struct {
+	// Phase of the request
+	// +optional
+	Phase TemporaryAccessPhase `json:"phase,omitempty"`
+
+	// RoleName is the role that was created
+	// +optional
+	RoleName string `json:"roleName,omitempty"`
+
+	// SecretName is the secret holding the credentials
+	// +optional
+	SecretName string `json:"secretName,omitempty"`
+
+	// GrantedAt is when access was granted
+	// +optional
+	GrantedAt *metav1.Time `json:"grantedAt,omitempty"`
+
+	// ExpiresAt is when access will be revoked
+	// +optional
+	ExpiresAt *metav1.Time `json:"expiresAt,omitempty"`
+
+	// RevokedAt is when access was revoked
+	// +optional
+	RevokedAt *metav1.Time `json:"revokedAt,omitempty"`
+
+	// AuditLog records every lifecycle action taken for this request
+	// +optional
+	AuditLog []AuditEntry `json:"auditLog,omitempty"`
+
+	// Message provides human readable status information
+	// +optional
+	Message string `json:"message,omitempty"`
+
+	// ObservedGeneration is the generation of the spec the status was last computed for
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// Conditions represent the latest available observations
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:categories=postgres
+// +kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.status.phase`
+// +kubebuilder:printcolumn:name="Database",type=string,JSONPath=`.spec.databaseRef.name`
+// +kubebuilder:printcolumn:name="Role",type=string,JSONPath=`.status.roleName`
+// +kubebuilder:printcolumn:name="Expires",type=date,JSONPath=`.status.expiresAt`
+// +kubebuilder:printcolumn:name="Message",type=string,JSONPath=`.status.message`,priority=1
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// TemporaryAccessRequest is the Schema for the temporaryaccessrequests API
+type TemporaryAccessRequest struct {
+	metav1.TypeMeta `json:",inline"`
+
+	// metadata is a standard object metadata
+	// +optional
+	metav1.ObjectMeta `json:"metadata,omitempty,omitzero"`
+
+	// spec defines the desired state of TemporaryAccessRequest
+	// +required
+	Spec TemporaryAccessRequestSpec `json:"spec"`
+
+	// status defines the observed state of TemporaryAccessRequest
+	// +optional
+	Status TemporaryAccessRequestStatus `json:"status,omitempty,omitzero"`
+}
+
+// +kubebuilder:object:root=true
+
+// TemporaryAccessRequestList contains a list of TemporaryAccessRequest
+type TemporaryAccessRequestList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []TemporaryAccessRequest `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&TemporaryAccessRequest{}, &TemporaryAccessRequestList{})
+}
@@ -17,10 +17,13 @@ limitations under the License.
 package main
 
 import (
+	"context"
 	"crypto/tls"
 	"flag"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
 	// to ensure that exec-entrypoint and run can make use of them.
@@ -38,7 +41,12 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
 
 	postgresv1 "github.com/silverswarm/pg-operator/api/v1"
+	"github.com/silverswarm/pg-operator/internal/cli"
 	"github.com/silverswarm/pg-operator/internal/controller"
+	"github.com/silverswarm/pg-operator/pkg/credentials"
+	"github.com/silverswarm/pg-operator/pkg/k8s"
+	"github.com/silverswarm/pg-operator/pkg/postgres"
+	"github.com/silverswarm/pg-operator/pkg/utils"
 	// +kubebuilder:scaffold:imports
 )
 
@@ -56,6 +64,10 @@ func init() {
 
 // nolint:gocyclo
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "validate" {
+		os.Exit(cli.RunValidate(os.Args[2:], os.Stdout, os.Stderr))
+	}
+
 	var metricsAddr string
 	var metricsCertPath, metricsCertName, metricsCertKey string
 	var webhookCertPath, webhookCertName, webhookCertKey string
@@ -63,6 +75,17 @@ func main() {
 	var probeAddr string
 	var secureMetrics bool
 	var enableHTTP2 bool
+	var passwordProviderConfig credentials.WebhookProviderConfig
+	var vaultConfig credentials.VaultStoreConfig
+	var azureWorkloadIdentity bool
+	var azureConfig credentials.AzureTokenSourceConfig
+	var azureHosts string
+	var secretNameTemplate string
+	var portForward bool
+	var resyncInterval time.Duration
+	passwordPolicy := utils.DefaultPasswordPolicy
+	poolConfig := postgres.DefaultPoolConfig
+	backupJobConfig := k8s.DefaultBackupJobConfig
 	var tlsOpts []func(*tls.Config)
 	flag.StringVar(&metricsAddr, "metrics-bind-address", "0", "The address the metrics endpoint binds to. "+
 		"Use :8443 for HTTPS or :8080 for HTTP, or leave as 0 to disable the metrics service.")
@@ -81,6 +104,69 @@ func main() {
 	flag.StringVar(&metricsCertKey, "metrics-cert-key", "tls.key", "The name of the metrics server key file.")
 	flag.BoolVar(&enableHTTP2, "enable-http2", false,
 		"If set, HTTP/2 will be enabled for the metrics and webhook servers")
+	flag.StringVar(&passwordProviderConfig.URL, "password-provider-url", "",
+		"HTTPS endpoint that issues passwords for new database users. Passwords are generated locally if not set.")
+	flag.StringVar(&passwordProviderConfig.CAFile, "password-provider-ca-file", "",
+		"PEM bundle used to verify the password provider. Uses the system roots if not set.")
+	flag.StringVar(&passwordProviderConfig.CertFile, "password-provider-cert-file", "",
+		"Client certificate presented to the password provider for mTLS.")
+	flag.StringVar(&passwordProviderConfig.KeyFile, "password-provider-key-file", "",
+		"Client key presented to the password provider for mTLS.")
+	flag.StringVar(&vaultConfig.Address, "vault-address", "",
+		"Vault server that Databases with vault set write user credentials to, and that PostGresConnections "+
+			"with credentialsFrom read their credentials from.")
+	flag.StringVar(&vaultConfig.CAFile, "vault-ca-file", "",
+		"PEM bundle used to verify Vault. Uses the system roots if not set.")
+	flag.StringVar(&vaultConfig.AuthMount, "vault-auth-mount", "kubernetes",
+		"Mount path of the Vault Kubernetes auth method.")
+	flag.StringVar(&vaultConfig.Role, "vault-role", "",
+		"Vault Kubernetes auth role the operator logs in with.")
+	flag.StringVar(&vaultConfig.KVMount, "vault-kv-mount", "secret",
+		"Mount path of the Vault KV version 2 secrets engine.")
+	flag.StringVar(&vaultConfig.PathRoot, "vault-path-root", "pg-operator",
+		"Path in the KV engine below which every namespace has a directory of its own for credentials.")
+	flag.StringVar(&vaultConfig.PathTemplate, "vault-path-template", "{{.Database}}/{{.User}}",
+		"Path of user credentials within their namespace's directory below --vault-path-root, Databases can "+
+			"override it.")
+	flag.BoolVar(&azureWorkloadIdentity, "azure-workload-identity", false,
+		"If set, PostGresConnections with the azure credentials provider authenticate with Microsoft Entra ID "+
+			"access tokens of the operator's workload identity.")
+	flag.StringVar(&azureConfig.TenantID, "azure-tenant-id", "",
+		"Microsoft Entra tenant of the workload identity. Defaults to AZURE_TENANT_ID.")
+	flag.StringVar(&azureConfig.ClientID, "azure-client-id", "",
+		"Client ID of the managed identity federated with the operator's service account. Defaults to AZURE_CLIENT_ID.")
+	flag.StringVar(&azureHosts, "azure-allowed-hosts", strings.Join(postgres.DefaultTokenHosts, ","),
+		"Comma separated hosts PostGresConnections with the azure credentials provider may send access tokens to, "+
+			"*.example.com allows every host below example.com.")
+	flag.StringVar(&secretNameTemplate, "secret-name-template", "",
+		"Go template naming user secrets when neither secretName nor the Database's secretNameTemplate is set, "+
+			"e.g. {{.Database}}-{{.User}}-credentials. Defaults to <database>-<user>.")
+	flag.BoolVar(&portForward, "port-forward", false,
+		"If set, connections to cluster services go through kubectl port-forward, for running the operator "+
+			"outside the cluster during development.")
+	flag.StringVar(&backupJobConfig.Namespace, "backup-namespace", "",
+		"Namespace DatabaseBackup Jobs run in, away from the namespaces of the Databases. Defaults to the operator's namespace.")
+	flag.StringVar(&backupJobConfig.PostgresImage, "backup-postgres-image", backupJobConfig.PostgresImage,
+		"Image providing pg_dump for DatabaseBackup Jobs, its major version must not be older than the servers'.")
+	flag.StringVar(&backupJobConfig.UploaderImage, "backup-uploader-image", backupJobConfig.UploaderImage,
+		"Image providing the aws CLI uploading the dumps of DatabaseBackup Jobs.")
+	flag.DurationVar(&resyncInterval, "resync-interval", 0,
+		"How often ready resources are reconciled again when they set no resyncInterval, e.g. 1h. "+
+			"Zero reconciles them only when they change.")
+	flag.IntVar(&poolConfig.MaxOpenConns, "db-max-open-conns", poolConfig.MaxOpenConns,
+		"Maximum connections the operator keeps open per PostGresConnection and database.")
+	flag.IntVar(&poolConfig.MaxIdleConns, "db-max-idle-conns", poolConfig.MaxIdleConns,
+		"Idle connections the operator keeps open per PostGresConnection and database.")
+	flag.DurationVar(&poolConfig.ConnMaxIdleTime, "db-conn-max-idle-time", poolConfig.ConnMaxIdleTime,
+		"How long an idle connection is kept before it is closed.")
+	flag.IntVar(&passwordPolicy.Length, "password-length", passwordPolicy.Length,
+		"Length of generated passwords (12 to 128).")
+	flag.StringVar(&passwordPolicy.Charset, "password-charset", passwordPolicy.Charset,
+		"Characters generated passwords are drawn from: alphanumeric, urlsafe or ascii.")
+	flag.BoolVar(&passwordPolicy.RequireSpecial, "password-require-special", passwordPolicy.RequireSpecial,
+		"If set, generated passwords contain at least one special character.")
+	flag.BoolVar(&passwordPolicy.ExcludeAmbiguous, "password-exclude-ambiguous", passwordPolicy.ExcludeAmbiguous,
+		"If set, generated passwords leave out easily confused characters such as 0, O, 1 and l.")
 	opts := zap.Options{
 		Development: true,
 	}
@@ -89,6 +175,29 @@ func main() {
 
 	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
 
+	if err := passwordPolicy.Validate(); err != nil {
+		setupLog.Error(err, "invalid password policy")
+		os.Exit(1)
+	}
+
+	if err := k8s.SetSecretNameTemplate(secretNameTemplate); err != nil {
+		setupLog.Error(err, "invalid secret name template")
+		os.Exit(1)
+	}
+	if err := poolConfig.Validate(); err != nil {
+		setupLog.Error(err, "invalid connection pool configuration")
+		os.Exit(1)
+	}
+	postgres.SetPoolConfig(poolConfig)
+	if backupJobConfig.Namespace == "" {
+		// The namespace of the operator's service account, unknown when running outside the cluster
+		if namespace, err := os.ReadFile("/var/run/secrets/kubernetes.io/serviceaccount/namespace"); err == nil {
+			backupJobConfig.Namespace = strings.TrimSpace(string(namespace))
+		}
+	}
+	k8s.SetBackupJobConfig(backupJobConfig)
+	controller.SetResyncInterval(resyncInterval)
+
 	// if the enable-http2 flag is false (the default), http/2 should be disabled
 	// due to its vulnerabilities. More specifically, disabling http/2 will
 	// prevent from being vulnerable to the HTTP/2 Stream Cancellation and
@@ -202,6 +311,18 @@ func main() {
 		os.Exit(1)
 	}
 
+	if portForward {
+		dialer := k8s.NewPortForwardDialer(mgr.GetAPIReader(), "")
+		defer dialer.Close()
+		postgres.SetDialer(dialer)
+		setupLog.Info("reaching cluster services through kubectl port-forward")
+	}
+
+	if err := controller.SetupIndexes(context.Background(), mgr.GetFieldIndexer()); err != nil {
+		setupLog.Error(err, "unable to set up cache indexes")
+		os.Exit(1)
+	}
+
 	if err := controller.NewPostGresConnectionReconciler(
 		mgr.GetClient(),
 		mgr.GetScheme(),
@@ -209,13 +330,101 @@ func main() {
 		setupLog.Error(err, "unable to create controller", "controller", "PostGresConnection")
 		os.Exit(1)
 	}
-	if err := controller.NewDatabaseReconciler(
+	databaseReconciler := controller.NewDatabaseReconciler(
 		mgr.GetClient(),
 		mgr.GetScheme(),
-	).SetupWithManager(mgr); err != nil {
+	)
+	if passwordProviderConfig.URL != "" {
+		setupLog.Info("Using external password provider", "url", passwordProviderConfig.URL)
+		passwordProvider, err := credentials.NewWebhookProvider(passwordProviderConfig)
+		if err != nil {
+			setupLog.Error(err, "unable to configure password provider")
+			os.Exit(1)
+		}
+		databaseReconciler.PasswordProvider = passwordProvider
+	}
+	if vaultConfig.Address != "" {
+		setupLog.Info("Writing credentials to Vault", "address", vaultConfig.Address)
+		vaultStore, err := credentials.NewVaultStore(vaultConfig)
+		if err != nil {
+			setupLog.Error(err, "unable to configure Vault")
+			os.Exit(1)
+		}
+		databaseReconciler.CredentialStore = vaultStore
+		postgres.SetCredentialReader(vaultStore)
+	}
+	if azureWorkloadIdentity {
+		azureTokens, err := credentials.NewAzureTokenSource(azureConfig)
+		if err != nil {
+			setupLog.Error(err, "unable to configure Azure workload identity")
+			os.Exit(1)
+		}
+		setupLog.Info("Authenticating to Azure Database for PostgreSQL with workload identity")
+		postgres.SetTokenSource(azureTokens, strings.Split(azureHosts, ","))
+	}
+	databaseReconciler.PasswordPolicy = passwordPolicy
+	if err := databaseReconciler.SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "Database")
 		os.Exit(1)
 	}
+	temporaryAccessReconciler := controller.NewTemporaryAccessRequestReconciler(
+		mgr.GetClient(),
+		mgr.GetScheme(),
+	)
+	temporaryAccessReconciler.PasswordPolicy = passwordPolicy
+	if err := temporaryAccessReconciler.SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "TemporaryAccessRequest")
+		os.Exit(1)
+	}
+	if err := controller.NewPostgresRoleReconciler(
+		mgr.GetClient(),
+		mgr.GetScheme(),
+	).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "PostgresRole")
+		os.Exit(1)
+	}
+	if err := controller.NewGrantReconciler(
+		mgr.GetClient(),
+		mgr.GetScheme(),
+	).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "Grant")
+		os.Exit(1)
+	}
+	if err := controller.NewSchemaReconciler(
+		mgr.GetClient(),
+		mgr.GetScheme(),
+	).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "Schema")
+		os.Exit(1)
+	}
+	if err := controller.NewForeignServerReconciler(
+		mgr.GetClient(),
+		mgr.GetScheme(),
+	).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "ForeignServer")
+		os.Exit(1)
+	}
+	if err := controller.NewSQLMigrationReconciler(
+		mgr.GetClient(),
+		mgr.GetScheme(),
+	).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "SQLMigration")
+		os.Exit(1)
+	}
+	if err := controller.NewDatabaseBackupReconciler(
+		mgr.GetClient(),
+		mgr.GetScheme(),
+	).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "DatabaseBackup")
+		os.Exit(1)
+	}
+	if err := controller.NewDatabaseClaimReconciler(
+		mgr.GetClient(),
+		mgr.GetScheme(),
+	).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "DatabaseClaim")
+		os.Exit(1)
+	}
 	// +kubebuilder:scaffold:builder
 
 	if metricsCertWatcher != nil {
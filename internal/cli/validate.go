@@ -0,0 +1,215 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"sigs.k8s.io/yaml"
+
+	postgresv1 "github.com/silverswarm/pg-operator/api/v1"
+	"github.com/silverswarm/pg-operator/pkg/validation"
+)
+
+// manifest is a single pg-operator resource decoded from a file
+type manifest struct {
+	source string
+	object interface{}
+}
+
+// RunValidate implements the "validate" subcommand. It lints Database and
+// PostGresConnection manifests without a cluster and returns the process exit code.
+func RunValidate(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("validate", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	namespace := flags.String("namespace", "default", "Namespace assumed for manifests that do not set one.")
+	skipReferences := flags.Bool("skip-references", false,
+		"Do not report connectionRefs to PostGresConnections that are not part of the given files.")
+	flags.Usage = func() {
+		_, _ = fmt.Fprintf(stderr, "Usage: manager validate [flags] <file or directory>...\n")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return 2
+	}
+
+	var manifests []manifest
+	problems := 0
+	for _, path := range flags.Args() {
+		found, err := loadManifests(path, *namespace)
+		if err != nil {
+			_, _ = fmt.Fprintf(stdout, "%s: %v\n", path, err)
+			problems++
+			continue
+		}
+		manifests = append(manifests, found...)
+	}
+
+	connections := make(map[types.NamespacedName]bool)
+	for _, m := range manifests {
+		if pgConn, ok := m.object.(*postgresv1.PostGresConnection); ok {
+			connections[types.NamespacedName{Namespace: pgConn.Namespace, Name: pgConn.Name}] = true
+		}
+	}
+
+	for _, m := range manifests {
+		var errs []string
+		var id string
+
+		switch obj := m.object.(type) {
+		case *postgresv1.Database:
+			id = describe("Database", obj.ObjectMeta)
+			for _, err := range validation.ValidateDatabase(obj) {
+				errs = append(errs, err.Error())
+			}
+			if !*skipReferences {
+				ref := types.NamespacedName{Namespace: obj.Spec.ConnectionRef.Namespace, Name: obj.Spec.ConnectionRef.Name}
+				if ref.Namespace == "" {
+					ref.Namespace = obj.Namespace
+				}
+				if ref.Name != "" && !connections[ref] {
+					errs = append(errs, fmt.Sprintf("spec.connectionRef: PostGresConnection %s not found in the given files", ref))
+				}
+			}
+		case *postgresv1.PostGresConnection:
+			id = describe("PostGresConnection", obj.ObjectMeta)
+			for _, err := range validation.ValidatePostGresConnection(obj) {
+				errs = append(errs, err.Error())
+			}
+		}
+
+		for _, msg := range errs {
+			_, _ = fmt.Fprintf(stdout, "%s: %s: %s\n", m.source, id, msg)
+		}
+		problems += len(errs)
+	}
+
+	if problems > 0 {
+		_, _ = fmt.Fprintf(stdout, "%d problem(s) found in %d resource(s)\n", problems, len(manifests))
+		return 1
+	}
+
+	_, _ = fmt.Fprintf(stdout, "%d resource(s) valid\n", len(manifests))
+	return 0
+}
+
+func loadManifests(path, namespace string) ([]manifest, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if !info.IsDir() {
+		return loadFile(path, namespace)
+	}
+
+	var manifests []manifest
+	err = filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		ext := filepath.Ext(p)
+		if d.IsDir() || (ext != ".yaml" && ext != ".yml" && ext != ".json") {
+			return nil
+		}
+		found, err := loadFile(p, namespace)
+		if err != nil {
+			return fmt.Errorf("%s: %w", p, err)
+		}
+		manifests = append(manifests, found...)
+		return nil
+	})
+
+	return manifests, err
+}
+
+func loadFile(path, namespace string) ([]manifest, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+
+	var manifests []manifest
+	reader := utilyaml.NewYAMLReader(bufio.NewReader(f))
+	for doc := 1; ; doc++ {
+		data, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return manifests, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if strings.TrimSpace(string(data)) == "" {
+			continue
+		}
+
+		source := fmt.Sprintf("%s#%d", path, doc)
+
+		var typeMeta metav1.TypeMeta
+		if err := yaml.Unmarshal(data, &typeMeta); err != nil {
+			return nil, fmt.Errorf("document %d: %w", doc, err)
+		}
+		if typeMeta.APIVersion != postgresv1.GroupVersion.String() {
+			continue
+		}
+
+		var obj interface{}
+		switch typeMeta.Kind {
+		case "Database":
+			database := &postgresv1.Database{}
+			err = yaml.UnmarshalStrict(data, database)
+			if database.Namespace == "" {
+				database.Namespace = namespace
+			}
+			obj = database
+		case "PostGresConnection":
+			pgConn := &postgresv1.PostGresConnection{}
+			err = yaml.UnmarshalStrict(data, pgConn)
+			if pgConn.Namespace == "" {
+				pgConn.Namespace = namespace
+			}
+			obj = pgConn
+		default:
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("document %d: %w", doc, err)
+		}
+
+		manifests = append(manifests, manifest{source: source, object: obj})
+	}
+}
+
+func describe(kind string, meta metav1.ObjectMeta) string {
+	return fmt.Sprintf("%s %s/%s", kind, meta.Namespace, meta.Name)
+}
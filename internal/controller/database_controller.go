@@ -17,33 +17,68 @@ limitations under the License.
 package controller
 
 import (
+	"bytes"
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"maps"
+	"net"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	corev1 "k8s.io/api/core/v1"
 
 	postgresv1 "github.com/silverswarm/pg-operator/api/v1"
+	"github.com/silverswarm/pg-operator/pkg/credentials"
 	"github.com/silverswarm/pg-operator/pkg/k8s"
 	"github.com/silverswarm/pg-operator/pkg/postgres"
 	"github.com/silverswarm/pg-operator/pkg/utils"
 )
 
+const databaseFinalizer = "postgres.silverswarm.io/database"
+
 // DatabaseReconciler reconciles a Database object
 type DatabaseReconciler struct {
 	client.Client
-	Scheme        *runtime.Scheme
-	pgClient      *postgres.Client
-	dbService     *postgres.DatabaseService
-	userService   *postgres.UserService
-	secretService *k8s.SecretService
-	statusService *k8s.StatusService
+	Scheme *runtime.Scheme
+	// PasswordProvider, when set, supplies passwords for new users instead of generating them locally
+	PasswordProvider credentials.PasswordProvider
+	// PasswordPolicy controls locally generated passwords, Databases can override parts of it
+	PasswordPolicy utils.PasswordPolicy
+	// CredentialStore, when set, receives the credentials of Databases that enable it
+	CredentialStore credentials.CredentialStore
+
+	pgClient         *postgres.Client
+	dbService        *postgres.DatabaseService
+	userService      *postgres.UserService
+	extensionService *postgres.ExtensionService
+	secretService    *k8s.SecretService
+	rolloutService   *k8s.RolloutService
+	clusterService   *k8s.ClusterService
+	backupService    *k8s.BackupService
+	statusService    *k8s.StatusService
+	recorder         record.EventRecorder
 }
 
 // +kubebuilder:rbac:groups=postgres.silverswarm.io,resources=databases,verbs=get;list;watch;create;update;patch;delete
@@ -51,103 +86,2041 @@ type DatabaseReconciler struct {
 // +kubebuilder:rbac:groups=postgres.silverswarm.io,resources=databases/finalizers,verbs=update
 // +kubebuilder:rbac:groups=postgres.silverswarm.io,resources=postgresconnections,verbs=get;list;watch
 // +kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups="",resources=configmaps,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=namespaces,verbs=get;list;watch
+// +kubebuilder:rbac:groups=apps,resources=deployments,verbs=get;list;watch;patch
+// +kubebuilder:rbac:groups=postgresql.cnpg.io,resources=poolers,verbs=get;create;update;patch;delete
+// +kubebuilder:rbac:groups=postgres.silverswarm.io,resources=databasebackups,verbs=get;list;watch;create
+// +kubebuilder:rbac:groups="",resources=events,verbs=create;patch
 
 func (r *DatabaseReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 	log := logf.FromContext(ctx)
+	ctx = postgres.WithApplicationName(ctx, "database", req.NamespacedName)
 
 	var database postgresv1.Database
 	if err := r.Get(ctx, req.NamespacedName, &database); err != nil {
 		return utils.HandleReconcileError(err, "Failed to get Database", log)
 	}
+	ctx = k8s.WithStatusBase(ctx, &database)
+	ctx = postgres.WithAudit(ctx, r.recorder, &database)
+	ctx = postgres.WithStepRecorder(ctx, r.recordStep(&database))
+
+	// A paused Database is not finalized either, its deletionPolicy waits until it is resumed
+	if isPaused(&database) {
+		return pause(ctx, r.Client, &database, &database.Status.Conditions)
+	}
+	meta.RemoveStatusCondition(&database.Status.Conditions, "Paused")
+
+	if !database.DeletionTimestamp.IsZero() {
+		return r.finalize(ctx, &database)
+	}
+
+	// Secrets in other namespaces cannot be owned by the Database and Vault knows nothing of owners,
+	// the finalizer deletes them instead, as well as the Pooler living in the cluster's namespace and,
+	// depending on the deletionPolicy, the users and the database in PostgreSQL
+	if (hasForeignSecrets(&database) || database.Spec.Vault != nil || hasPooler(&database) || deprovisions(&database)) &&
+		!controllerutil.ContainsFinalizer(&database, databaseFinalizer) {
+		controllerutil.AddFinalizer(&database, databaseFinalizer)
+		if err := r.Update(ctx, &database); err != nil {
+			return utils.HandleReconcileError(err, "Failed to add finalizer", log)
+		}
+	}
 
 	pgConn, err := r.getPostGresConnection(ctx, &database)
 	if err != nil {
-		return r.statusService.UpdateDatabaseStatus(ctx, &database, false, false, nil, err.Error())
+		return r.statusService.UpdateDatabaseStatus(ctx, &database, false, false, err.Error())
 	}
 
-	if !pgConn.Status.Ready {
-		return r.statusService.UpdateDatabaseStatus(ctx, &database, false, false, nil, "PostgreSQL connection is not ready")
+	connectionWritable := metav1.Condition{
+		Type:    "ConnectionWritable",
+		Status:  metav1.ConditionTrue,
+		Reason:  "Writable",
+		Message: "Connection accepts DDL",
+	}
+	if pgConn.Status.ReadOnly {
+		connectionWritable.Status = metav1.ConditionFalse
+		connectionWritable.Reason = "ReadOnlyConnection"
+		connectionWritable.Message = fmt.Sprintf("PostgreSQL connection %s is read-only", pgConn.Name)
+	}
+	meta.SetStatusCondition(&database.Status.Conditions, connectionWritable)
+
+	if err := r.checkQuota(ctx, pgConn, &database); err != nil {
+		return r.statusService.UpdateDatabaseStatus(ctx, &database, false, database.Status.DatabaseCreated, err.Error())
+	}
+
+	if err := checkConnection(pgConn); err != nil {
+		return r.statusService.UpdateDatabaseStatus(ctx, &database, false, false, err.Error())
 	}
 
 	db, err := r.pgClient.Connect(ctx, pgConn)
 	if err != nil {
-		return r.statusService.UpdateDatabaseStatus(ctx, &database, false, false, nil, fmt.Sprintf("Failed to connect to database: %v", err))
+		return r.statusService.UpdateDatabaseStatus(ctx, &database, false, false, fmt.Sprintf("Failed to connect to database: %v", err))
+	}
+
+	unlock, err := r.pgClient.LockDDL(ctx, pgConn)
+	if err != nil {
+		return r.statusService.UpdateDatabaseStatus(ctx, &database, false, database.Status.DatabaseCreated, err.Error())
 	}
-	defer db.Close()
+	defer unlock()
 
 	databaseCreated, err := r.dbService.EnsureDatabase(ctx, db, &database)
+	if errors.Is(err, postgres.ErrDatabaseOwned) {
+		meta.SetStatusCondition(&database.Status.Conditions, metav1.Condition{
+			Type:    "Conflict",
+			Status:  metav1.ConditionTrue,
+			Reason:  "ManagedByAnotherDatabase",
+			Message: err.Error(),
+		})
+		return r.statusService.UpdateDatabaseStatus(ctx, &database, false, false, err.Error())
+	}
+	meta.RemoveStatusCondition(&database.Status.Conditions, "Conflict")
+	if databaseCreated && !database.Status.DatabaseCreated {
+		recordEvent(r.recorder, &database, corev1.EventTypeNormal, "DatabaseCreated", "Database %s is created", database.Spec.DatabaseName)
+	}
+	if err != nil {
+		// The database may exist while a later step failed, which the status must not hide
+		return r.statusService.UpdateDatabaseStatus(ctx, &database, false, databaseCreated, fmt.Sprintf("Failed to ensure database: %v", err))
+	}
+
+	// Nobody can connect to a frozen database, the steps inside it are skipped until it is thawed
+	if isFrozen(&database) {
+		meta.SetStatusCondition(&database.Status.Conditions, frozenCondition(
+			"Database does not allow connections, extensions, init SQL and grants inside it wait until allowConnections is true"))
+	} else {
+		meta.RemoveStatusCondition(&database.Status.Conditions, conditionFrozen)
+	}
+
+	if err := r.revokePublic(ctx, pgConn, &database); err != nil {
+		return r.statusService.UpdateDatabaseStatus(ctx, &database, false, databaseCreated, fmt.Sprintf("Failed to revoke PUBLIC privileges: %v", err))
+	}
+
+	if err := r.ensureExtensions(ctx, pgConn, &database); err != nil {
+		return r.statusService.UpdateDatabaseStatus(ctx, &database, false, databaseCreated, fmt.Sprintf("Failed to ensure extensions: %v", err))
+	}
+
+	if err := r.runInitSQL(ctx, pgConn, &database); err != nil {
+		return r.statusService.UpdateDatabaseStatus(ctx, &database, false, databaseCreated, fmt.Sprintf("Failed to run init SQL: %v", err))
+	}
+
+	if err := r.ensureGroupRoles(ctx, pgConn, &database); err != nil {
+		return r.statusService.UpdateDatabaseStatus(ctx, &database, false, databaseCreated, fmt.Sprintf("Failed to ensure group roles: %v", err))
+	}
+
+	if err := r.ensurePooler(ctx, pgConn, &database); err != nil {
+		return r.statusService.UpdateDatabaseStatus(ctx, &database, false, databaseCreated, fmt.Sprintf("Failed to ensure pooler: %v", err))
+	}
+
+	if err := r.ensureUsers(ctx, db, pgConn, &database); err != nil {
+		return r.statusService.UpdateDatabaseStatus(ctx, &database, false, databaseCreated, fmt.Sprintf("Failed to ensure users: %v", err))
+	}
+
+	if err := r.publishBinding(ctx, pgConn, &database); err != nil {
+		return r.statusService.UpdateDatabaseStatus(ctx, &database, false, databaseCreated, fmt.Sprintf("Failed to publish service binding: %v", err))
+	}
+
+	result, err := r.statusService.UpdateDatabaseStatus(ctx, &database, true, databaseCreated, "Database and users ready")
+	if err == nil {
+		if next := nextCredentialAction(&database); next != nil {
+			result.RequeueAfter = time.Until(next.Time)
+		}
+	}
+	return resync(result, err, database.Spec.ResyncInterval)
+}
+
+// finalize deprovisions the database as its deletionPolicy asks and deletes the user secrets kept outside
+// the Database's namespace, the credentials in Vault and the provisioned Pooler
+func (r *DatabaseReconciler) finalize(ctx context.Context, database *postgresv1.Database) (ctrl.Result, error) {
+	log := logf.FromContext(ctx)
+
+	if !controllerutil.ContainsFinalizer(database, databaseFinalizer) {
+		return ctrl.Result{}, nil
+	}
+
+	if database.Spec.DeletionPolicy == postgresv1.DatabaseDeletionDropWithBackup && database.Status.DatabaseCreated {
+		backup, err := r.backupService.EnsureFinalBackup(ctx, database)
+		if err != nil {
+			return r.statusService.UpdateDatabaseStatus(ctx, database, false, true, err.Error())
+		}
+		switch backup.Status.Phase {
+		case postgresv1.DatabaseBackupCompleted:
+		case postgresv1.DatabaseBackupFailed:
+			return r.statusService.UpdateDatabaseStatus(ctx, database, false, true,
+				fmt.Sprintf("Final backup %s failed, the database is kept; delete the DatabaseBackup to try again: %s", backup.Name, backup.Status.Message))
+		default:
+			return r.statusService.UpdateDatabaseStatus(ctx, database, false, true,
+				fmt.Sprintf("Waiting for final backup %s before dropping the database", backup.Name))
+		}
+	}
+
+	// The secrets still hold the credentials of the users until PostgreSQL is cleaned up
+	err := r.deprovision(ctx, database)
+	if errors.Is(err, errFrozen) {
+		return waitWhileFrozen(ctx, r.Client, database, &database.Status.Conditions, err)
+	}
+	if err != nil {
+		return r.statusService.UpdateDatabaseStatus(ctx, database, false, database.Status.DatabaseCreated,
+			fmt.Sprintf("Failed to deprovision deleted database: %v", err))
+	}
+
+	if err := r.secretService.DeleteUserSecrets(ctx, database); err != nil {
+		log.Error(err, "Failed to delete secrets of deleted Database")
+		return ctrl.Result{RequeueAfter: k8s.RetryInterval(database)}, nil
+	}
+	for _, user := range database.Status.ManagedUsers {
+		if err := r.deleteFromVault(ctx, database, user.Name); err != nil {
+			log.Error(err, "Failed to delete Vault credentials of deleted Database", "user", user.Name)
+			return ctrl.Result{RequeueAfter: k8s.RetryInterval(database)}, nil
+		}
+	}
+	if pooler := database.Status.Pooler; pooler != nil {
+		if err := r.clusterService.DeletePooler(ctx, database, *pooler); err != nil && !meta.IsNoMatchError(err) {
+			log.Error(err, "Failed to delete pooler of deleted Database")
+			return ctrl.Result{RequeueAfter: k8s.RetryInterval(database)}, nil
+		}
+	}
+
+	controllerutil.RemoveFinalizer(database, databaseFinalizer)
+	if err := r.Update(ctx, database); err != nil {
+		return utils.HandleReconcileError(err, "Failed to remove finalizer", log)
+	}
+
+	return ctrl.Result{}, nil
+}
+
+// deprovisions reports whether deleting the database cleans up PostgreSQL
+func deprovisions(database *postgresv1.Database) bool {
+	policy := database.Spec.DeletionPolicy
+	return policy == postgresv1.DatabaseDeletionDeprovision || drops(database)
+}
+
+// drops reports whether deleting the database drops it
+func drops(database *postgresv1.Database) bool {
+	policy := database.Spec.DeletionPolicy
+	return policy == postgresv1.DatabaseDeletionDrop || policy == postgresv1.DatabaseDeletionDropWithBackup
+}
+
+// deprovision drops the managed users and group roles of a deleted Database and, with the Drop policy, the
+// database itself, which DropWithBackup leaves to finalize until the final backup completed. With Deprovision
+// the objects the users own are handed to the database owner first.
+func (r *DatabaseReconciler) deprovision(ctx context.Context, database *postgresv1.Database) error {
+	log := logf.FromContext(ctx)
+
+	if !deprovisions(database) {
+		return nil
+	}
+
+	pgConn, err := r.getPostGresConnection(ctx, database)
+	if apierrors.IsNotFound(err) {
+		// Connections wait for their Databases, a missing one was never there or had its finalizer removed
+		log.Info("PostGresConnection is gone, leaving PostgreSQL as it is", "deletionPolicy", database.Spec.DeletionPolicy)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := checkConnection(pgConn); err != nil {
+		return err
+	}
+
+	db, err := r.pgClient.Connect(ctx, pgConn)
+	if err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+
+	unlock, err := r.pgClient.LockDDL(ctx, pgConn)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	databaseName := database.Spec.DatabaseName
+	roles := make([]string, 0, len(database.Status.ManagedUsers)+2)
+	for _, user := range database.Status.ManagedUsers {
+		roles = append(roles, user.Name)
+	}
+	if database.Spec.PermissionModel == postgresv1.PermissionModelGroupRoles {
+		roles = append(roles, postgres.GroupRoleName(databaseName, postgresv1.UserGroupReadOnly),
+			postgres.GroupRoleName(databaseName, postgresv1.UserGroupReadWrite))
+	}
+
+	owner, err := r.dbService.DatabaseOwner(ctx, db, databaseName)
 	if err != nil {
-		return r.statusService.UpdateDatabaseStatus(ctx, &database, false, false, nil, fmt.Sprintf("Failed to ensure database: %v", err))
+		return fmt.Errorf("failed to get owner of database %s: %w", databaseName, err)
+	}
+	if owner != "" {
+		err := r.dbService.CheckOwnership(ctx, db, database)
+		if errors.Is(err, postgres.ErrDatabaseOwned) {
+			log.Info("Database belongs to another Database, leaving PostgreSQL as it is", "reason", err.Error())
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if drops(database) || owner == "" {
+		// Nothing is left to hand over once the database is dropped or gone, the roles are dropped from the server
+		if err := r.dbService.DropDatabase(ctx, db, databaseName, database.Spec.ForceDrop); err != nil {
+			return err
+		}
+		for _, role := range roles {
+			if err := r.userService.DropUser(ctx, db, role); err != nil {
+				return fmt.Errorf("failed to drop role %s: %w", role, err)
+			}
+		}
+		return nil
 	}
 
-	usersCreated, err := r.ensureUsers(ctx, db, &database)
+	// Handing objects over happens inside the database, which a frozen database does not allow
+	if err := checkFrozen(database); err != nil {
+		return err
+	}
+	databaseDB, err := r.pgClient.ConnectToDatabase(ctx, pgConn, databaseName)
 	if err != nil {
-		return r.statusService.UpdateDatabaseStatus(ctx, &database, false, databaseCreated, usersCreated, fmt.Sprintf("Failed to ensure users: %v", err))
+		return fmt.Errorf("failed to connect to database %s: %w", databaseName, err)
+	}
+	for _, role := range roles {
+		if role == owner {
+			// The owner keeps the database and everything handed to it
+			continue
+		}
+		if err := r.userService.ReassignAndDropUser(ctx, databaseDB, role, owner); err != nil {
+			return fmt.Errorf("failed to drop role %s: %w", role, err)
+		}
+	}
+	return nil
+}
+
+// hasPooler reports whether the database has or had a provisioned Pooler
+func hasPooler(database *postgresv1.Database) bool {
+	return (database.Spec.Pooler != nil && database.Spec.Pooler.Enabled) || database.Status.Pooler != nil
+}
+
+// hasForeignSecrets reports whether the database keeps or kept a user secret outside its namespace
+func hasForeignSecrets(database *postgresv1.Database) bool {
+	for _, user := range database.Spec.Users {
+		if user.PasswordDelivery != nil && user.PasswordDelivery.Sink != postgresv1.PasswordDeliveryVault &&
+			k8s.UserSecretNamespace(database, user) != database.Namespace {
+			return true
+		}
+		if k8s.HasUserSecret(user) && (k8s.UserSecretNamespace(database, user) != database.Namespace || user.ReplicateTo != nil) {
+			return true
+		}
 	}
+	for _, user := range database.Status.ManagedUsers {
+		if user.SecretName != "" && secretNamespace(database, user) != database.Namespace {
+			return true
+		}
+	}
+	return false
+}
 
-	return r.statusService.UpdateDatabaseStatus(ctx, &database, true, databaseCreated, usersCreated, "Database and users ready")
+// secretNamespace returns the namespace of a managed user's secret. Records written before
+// secretNamespace existed leave it empty.
+func secretNamespace(database *postgresv1.Database, user postgresv1.ManagedUser) string {
+	if user.SecretNamespace != "" {
+		return user.SecretNamespace
+	}
+	return database.Namespace
 }
 
 func (r *DatabaseReconciler) getPostGresConnection(ctx context.Context, database *postgresv1.Database) (*postgresv1.PostGresConnection, error) {
-	connNamespace := database.Spec.ConnectionRef.Namespace
+	return getConnectionForDatabase(ctx, r.Client, database)
+}
+
+// getConnectionForDatabase fetches the PostGresConnection referenced by a Database
+func getConnectionForDatabase(ctx context.Context, c client.Client, database *postgresv1.Database) (*postgresv1.PostGresConnection, error) {
+	return getConnection(ctx, c, database.Spec.ConnectionRef, database.Namespace)
+}
+
+// getConnection fetches a referenced PostGresConnection, defaulting to the referencing object's namespace
+func getConnection(ctx context.Context, c client.Client, ref postgresv1.ConnectionReference, namespace string) (*postgresv1.PostGresConnection, error) {
+	connNamespace := ref.Namespace
 	if connNamespace == "" {
-		connNamespace = database.Namespace
+		connNamespace = namespace
 	}
 
 	var pgConn postgresv1.PostGresConnection
 	connKey := types.NamespacedName{
-		Name:      database.Spec.ConnectionRef.Name,
+		Name:      ref.Name,
 		Namespace: connNamespace,
 	}
 
-	if err := r.Get(ctx, connKey, &pgConn); err != nil {
+	if err := c.Get(ctx, connKey, &pgConn); err != nil {
 		return nil, fmt.Errorf("failed to get PostGresConnection %s: %w", connKey, err)
 	}
 
+	if connNamespace != namespace {
+		allowed, err := namespaceAllowed(ctx, c, pgConn.Spec.AllowedNamespaces, namespace)
+		if err != nil {
+			return nil, err
+		}
+		if !allowed {
+			return nil, fmt.Errorf("PostGresConnection %s does not allow references from namespace %s", connKey, namespace)
+		}
+	}
+
 	return &pgConn, nil
 }
 
-func (r *DatabaseReconciler) ensureUsers(ctx context.Context, db *sql.DB, database *postgresv1.Database) ([]string, error) {
-	usersCreated, err := r.userService.EnsureUsers(ctx, db, database)
+// namespaceAllowed reports whether a connection's allowedNamespaces admits the namespace
+func namespaceAllowed(ctx context.Context, c client.Client, allowed *postgresv1.AllowedNamespaces, namespace string) (bool, error) {
+	if allowed == nil || slices.Contains(allowed.Names, namespace) {
+		return true, nil
+	}
+	if allowed.Selector == nil {
+		return false, nil
+	}
+
+	selector, err := metav1.LabelSelectorAsSelector(allowed.Selector)
 	if err != nil {
-		return usersCreated, err
+		return false, fmt.Errorf("invalid allowedNamespaces selector: %w", err)
+	}
+	var ns corev1.Namespace
+	if err := c.Get(ctx, types.NamespacedName{Name: namespace}, &ns); err != nil {
+		return false, fmt.Errorf("failed to get namespace %s: %w", namespace, err)
 	}
+	return selector.Matches(labels.Set(ns.Labels)), nil
+}
 
-	for _, user := range database.Spec.Users {
-		if user.CreateSecret == nil || *user.CreateSecret {
-			password, err := utils.GenerateSecurePassword()
+// checkQuota enforces the connection's maxDatabases. The oldest bound Databases are within the quota, so a
+// new Database never takes the place of one that is already provisioned. The result is recorded in the
+// QuotaExceeded condition.
+func (r *DatabaseReconciler) checkQuota(ctx context.Context, pgConn *postgresv1.PostGresConnection, database *postgresv1.Database) error {
+	if pgConn.Spec.MaxDatabases == nil {
+		meta.RemoveStatusCondition(&database.Status.Conditions, "QuotaExceeded")
+		return nil
+	}
+	limit := int(*pgConn.Spec.MaxDatabases)
+
+	databases, err := boundDatabases(ctx, r.Client, pgConn)
+	if err != nil {
+		return err
+	}
+	slices.SortFunc(databases, func(a, b postgresv1.Database) int {
+		if c := a.CreationTimestamp.Compare(b.CreationTimestamp.Time); c != 0 {
+			return c
+		}
+		return strings.Compare(client.ObjectKeyFromObject(&a).String(), client.ObjectKeyFromObject(&b).String())
+	})
+	position := slices.IndexFunc(databases, func(other postgresv1.Database) bool { return other.UID == database.UID })
+	if position < 0 {
+		// Not yet in the cache, the Database is the newest
+		position = len(databases)
+	}
+
+	quota := metav1.Condition{
+		Type:    "QuotaExceeded",
+		Status:  metav1.ConditionFalse,
+		Reason:  "WithinQuota",
+		Message: fmt.Sprintf("PostgreSQL connection %s allows %d databases", pgConn.Name, limit),
+	}
+	if position >= limit {
+		quota.Status = metav1.ConditionTrue
+		quota.Reason = "QuotaExceeded"
+		quota.Message = fmt.Sprintf("PostgreSQL connection %s allows %d databases and has %d", pgConn.Name, limit, len(databases))
+	}
+	meta.SetStatusCondition(&database.Status.Conditions, quota)
+
+	if quota.Status == metav1.ConditionTrue {
+		return fmt.Errorf("%s, the database is not provisioned", quota.Message)
+	}
+	return nil
+}
+
+// checkConnection reports why statements changing the server cannot run through a connection
+func checkConnection(pgConn *postgresv1.PostGresConnection) error {
+	if !pgConn.Status.Ready {
+		return fmt.Errorf("PostgreSQL connection is not ready")
+	}
+	if pgConn.Status.ReadOnly {
+		return fmt.Errorf("PostgreSQL connection %s is read-only and cannot run DDL", pgConn.Name)
+	}
+	return nil
+}
+
+// revokePublic locks PUBLIC out of the database through a connection to the database itself, where
+// the privileges on its public schema are stored
+func (r *DatabaseReconciler) revokePublic(ctx context.Context, pgConn *postgresv1.PostGresConnection, database *postgresv1.Database) error {
+	if !database.Spec.RevokePublic || isFrozen(database) {
+		return nil
+	}
+
+	db, err := r.pgClient.ConnectToDatabase(ctx, pgConn, database.Spec.DatabaseName)
+	if err != nil {
+		return fmt.Errorf("failed to connect to database %s: %w", database.Spec.DatabaseName, err)
+	}
+
+	return r.dbService.RevokePublic(ctx, db, database.Spec.DatabaseName)
+}
+
+// ensureExtensions installs the extensions through a connection to the database itself
+func (r *DatabaseReconciler) ensureExtensions(ctx context.Context, pgConn *postgresv1.PostGresConnection, database *postgresv1.Database) error {
+	if isFrozen(database) {
+		return nil
+	}
+	if len(database.Spec.Extensions) == 0 {
+		database.Status.Extensions = nil
+		return nil
+	}
+
+	db, err := r.pgClient.ConnectToDatabase(ctx, pgConn, database.Spec.DatabaseName)
+	if err != nil {
+		return fmt.Errorf("failed to connect to database %s: %w", database.Spec.DatabaseName, err)
+	}
+
+	installed, err := r.extensionService.EnsureExtensions(ctx, db, database.Spec.Extensions)
+	database.Status.Extensions = installed
+	return err
+}
+
+// ensureGroupRoles creates the group roles of the groupRoles permission model through a connection
+// to the database itself, so their grants cover the tables in it
+func (r *DatabaseReconciler) ensureGroupRoles(ctx context.Context, pgConn *postgresv1.PostGresConnection, database *postgresv1.Database) error {
+	if database.Spec.PermissionModel != postgresv1.PermissionModelGroupRoles || isFrozen(database) {
+		return nil
+	}
+
+	db, err := r.pgClient.ConnectToDatabase(ctx, pgConn, database.Spec.DatabaseName)
+	if err != nil {
+		return fmt.Errorf("failed to connect to database %s: %w", database.Spec.DatabaseName, err)
+	}
+
+	return r.userService.EnsureGroupRoles(ctx, db, database)
+}
+
+// recordStep writes a step PostgreSQL cannot roll back to the status right away, so a failure later in the
+// reconcile leaves a record of what was done
+func (r *DatabaseReconciler) recordStep(database *postgresv1.Database) postgres.StepRecorder {
+	return func(ctx context.Context, step, statement string) error {
+		completed := postgresv1.CompletedStep{Name: step, Statement: statement, CompletedAt: metav1.Now()}
+		index := slices.IndexFunc(database.Status.CompletedSteps, func(s postgresv1.CompletedStep) bool { return s.Name == step })
+		if index >= 0 {
+			database.Status.CompletedSteps[index] = completed
+		} else {
+			database.Status.CompletedSteps = append(database.Status.CompletedSteps, completed)
+		}
+		database.Status.DDLHistory = postgres.AuditedStatements(ctx, database, database.Status.DDLHistory)
+		return k8s.PatchStatus(ctx, r.Client, database)
+	}
+}
+
+// runInitSQL runs the init script once. The status records that it ran so resyncs never repeat it.
+func (r *DatabaseReconciler) runInitSQL(ctx context.Context, pgConn *postgresv1.PostGresConnection, database *postgresv1.Database) error {
+	initSQL := database.Spec.InitSQL
+	if initSQL == nil || database.Status.InitSQLApplied || isFrozen(database) {
+		return nil
+	}
+
+	script := initSQL.SQL
+	if initSQL.ConfigMapRef != nil {
+		var configMap corev1.ConfigMap
+		key := types.NamespacedName{Name: initSQL.ConfigMapRef.Name, Namespace: database.Namespace}
+		if err := r.Get(ctx, key, &configMap); err != nil {
+			return fmt.Errorf("failed to get ConfigMap %s: %w", key, err)
+		}
+		var ok bool
+		if script, ok = configMap.Data[initSQL.ConfigMapRef.Key]; !ok {
+			return fmt.Errorf("key %s not found in ConfigMap %s", initSQL.ConfigMapRef.Key, key)
+		}
+	}
+
+	db, err := r.pgClient.ConnectToDatabase(ctx, pgConn, database.Spec.DatabaseName)
+	if err != nil {
+		return fmt.Errorf("failed to connect to database %s: %w", database.Spec.DatabaseName, err)
+	}
+
+	if err := r.dbService.RunScript(ctx, db, database, script); err != nil {
+		return err
+	}
+
+	// Record the run right away, a failure later in the reconcile must not repeat the script
+	database.Status.InitSQLApplied = true
+	database.Status.DDLHistory = postgres.AuditedStatements(ctx, database, database.Status.DDLHistory)
+	if err := k8s.PatchStatus(ctx, r.Client, database); err != nil {
+		return fmt.Errorf("init SQL ran but recording it failed: %w", err)
+	}
+	return nil
+}
+
+func (r *DatabaseReconciler) ensureUsers(ctx context.Context, db *sql.DB, pgConn *postgresv1.PostGresConnection, database *postgresv1.Database) error {
+	// Secrets are not renamed to the default name because of a broken template
+	if database.Spec.SecretNameTemplate != "" {
+		if err := k8s.ValidateSecretNameTemplate(database.Spec.SecretNameTemplate); err != nil {
+			return err
+		}
+	}
+
+	if err := r.renameUsers(ctx, db, pgConn, database); err != nil {
+		return err
+	}
+
+	// Expired users are left out of everything below
+	active, err := r.expireUsers(ctx, database)
+	if err != nil {
+		return err
+	}
+	syncUserStatuses(database)
+	defer setLastRotated(database)
+
+	passwords := make(map[string]string, len(active.Spec.Users))
+	sourceVersions := make(map[string]string)
+	redeliver := active.Annotations[postgresv1.RotateCredentialsAnnotation] != active.Status.CredentialRotationRequest
+	var missingSecrets []string
+	var deliveries []postgresv1.DatabaseUser
+	for _, user := range active.Spec.Users {
+		if user.Login != nil && !*user.Login {
+			// NOLOGIN roles are created without a password
+			continue
+		}
+		if user.PasswordDelivery != nil {
+			// The password is only known in the reconcile that delivers it
+			if credentialsFor(active, user.Name).DeliveredAt != nil && !redeliver {
+				continue
+			}
+			password, err := r.newPassword(ctx, active, user)
+			if err != nil {
+				err = fmt.Errorf("failed to obtain password for user %s: %w", user.Name, err)
+				setUserCondition(database, user.Name, "SecretReady", err)
+				return err
+			}
+			passwords[user.Name] = password
+			missingSecrets = append(missingSecrets, user.Name)
+			deliveries = append(deliveries, user)
+			continue
+		}
+		if user.PasswordSecretRef != nil {
+			password, version, err := r.sourcePassword(ctx, active, user)
+			if err != nil {
+				err = fmt.Errorf("failed to read password for user %s: %w", user.Name, err)
+				setUserCondition(database, user.Name, "SecretReady", err)
+				return err
+			}
+			passwords[user.Name] = password
+			sourceVersions[user.Name] = version
+			continue
+		}
+
+		if !k8s.HasUserSecret(user) && active.Spec.Vault == nil {
+			// The password of a user without a secret is kept nowhere, a new one is only obtained when the
+			// role is created or allowed to log in again
+			needed, err := r.userService.NeedsPassword(ctx, db, user)
 			if err != nil {
-				return usersCreated, fmt.Errorf("failed to generate password for user %s: %w", user.Name, err)
+				setUserCondition(database, user.Name, "SecretReady", err)
+				return err
+			}
+			if !needed {
+				continue
+			}
+		}
+
+		password, stored, err := r.resolvePassword(ctx, active, user)
+		if err != nil {
+			err = fmt.Errorf("failed to obtain password for user %s: %w", user.Name, err)
+			setUserCondition(database, user.Name, "SecretReady", err)
+			return err
+		}
+		passwords[user.Name] = password
+		if !stored && (k8s.HasUserSecret(user) || active.Spec.Vault != nil) {
+			missingSecrets = append(missingSecrets, user.Name)
+		}
+	}
+
+	parameters := make(map[string][]string, len(active.Spec.Users))
+	for _, status := range database.Status.Users {
+		parameters[status.Name] = status.RoleParameters
+	}
+	usersCreated, failures := r.userService.EnsureUsers(ctx, db, active, passwords, parameters)
+	for _, user := range active.Spec.Users {
+		if status := findUserStatus(database, user.Name); status != nil {
+			status.RoleParameters = parameters[user.Name]
+		}
+		if slices.Contains(usersCreated, user.Name) {
+			if setUserCondition(database, user.Name, "Created", nil) {
+				recordEvent(r.recorder, database, corev1.EventTypeNormal, "UserCreated", "User %s is created", user.Name)
+			}
+			if err := failures[user.Name]; err != nil {
+				setUserCondition(database, user.Name, "GrantsApplied", err)
 			}
+		} else {
+			setUserCondition(database, user.Name, "Created", failures[user.Name])
+		}
+		if err := failures[user.Name]; err != nil {
+			recordEvent(r.recorder, database, corev1.EventTypeWarning, "UserFailed", "%v", err)
+		}
+	}
+	if len(failures) > 0 {
+		return joinUserFailures(failures)
+	}
+
+	if err := r.replaceLostPasswords(ctx, db, database, passwords, missingSecrets); err != nil {
+		return err
+	}
+
+	if err := r.deliverPasswords(ctx, pgConn, active, deliveries, passwords); err != nil {
+		return err
+	}
+
+	if err := r.expireDeliveries(ctx, active); err != nil {
+		return err
+	}
+
+	if err := r.syncSourcedPasswords(ctx, db, database, passwords, sourceVersions); err != nil {
+		return err
+	}
 
-			if err := r.secretService.CreateUserSecret(ctx, database, user, password); err != nil {
-				return usersCreated, fmt.Errorf("failed to create secret for user %s: %w", user.Name, err)
+	if err := r.rotatePasswords(ctx, db, pgConn, database, passwords); err != nil {
+		return err
+	}
+
+	if err := r.repairPasswords(ctx, db, active, passwords); err != nil {
+		return err
+	}
+
+	for _, user := range active.Spec.Users {
+		var validUntil *time.Time
+		if expiry := credentialsFor(active, user.Name).ValidUntil; expiry != nil {
+			validUntil = &expiry.Time
+		}
+		if err := r.userService.SetValidUntil(ctx, db, user.Name, validUntil); err != nil {
+			return err
+		}
+	}
+
+	// Grants inside a frozen database wait, the GrantsApplied conditions keep their last state
+	if !isFrozen(database) {
+		err = r.grantInDatabase(ctx, active)
+		if err == nil {
+			err = r.reconcileGrants(ctx, database, active)
+		}
+		for _, user := range active.Spec.Users {
+			if setUserCondition(database, user.Name, "GrantsApplied", err) {
+				recordEvent(r.recorder, database, corev1.EventTypeNormal, "GrantsApplied", "Privileges of user %s are applied", user.Name)
 			}
 		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := r.writeToVault(ctx, active, passwords); err != nil {
+		return err
+	}
+
+	replicas := make(map[types.NamespacedName]bool)
+	var secretErrs []error
+	for _, user := range active.Spec.Users {
+		if !k8s.HasUserSecret(user) {
+			removeUserCondition(database, user.Name, "SecretReady")
+			continue
+		}
+		err := r.publishUserSecret(ctx, pgConn, active, user, passwords[user.Name], replicas)
+		setUserCondition(database, user.Name, "SecretReady", err)
+		if err != nil {
+			secretErrs = append(secretErrs, err)
+		}
 	}
+	// The replicas of a failed user are not all listed, pruning now could delete them
+	if err := errors.Join(secretErrs...); err != nil {
+		return err
+	}
+
+	if err := r.pruneReplicas(ctx, database, replicas); err != nil {
+		return err
+	}
+
+	if err := r.pruneUsers(ctx, database); err != nil {
+		return err
+	}
+
+	if err := r.sweepSecrets(ctx, database); err != nil {
+		return err
+	}
+
+	return nil
+}
 
-	return usersCreated, nil
+// publishUserSecret writes the secret of a user, applied every time so secrets follow passwordSecretRef sources
+// and changes to secretKeys, and passes it on to the user's rollout deployments and replicas
+func (r *DatabaseReconciler) publishUserSecret(ctx context.Context, pgConn *postgresv1.PostGresConnection, database *postgresv1.Database, user postgresv1.DatabaseUser, password string, replicas map[types.NamespacedName]bool) error {
+	data, err := r.userSecretData(ctx, pgConn, database, user, password)
+	if err != nil {
+		return err
+	}
+	if err := r.writeUserSecret(ctx, database, user, data); err != nil {
+		return fmt.Errorf("failed to update secret for user %s: %w", user.Name, err)
+	}
+	for _, deployment := range user.RolloutDeployments {
+		key := postgresv1.PodTemplateChecksumPrefix + k8s.UserSecretName(database, user)
+		if err := r.rolloutService.SetTemplateAnnotation(ctx, k8s.UserSecretNamespace(database, user), deployment, key, k8s.DataChecksum(data)); err != nil {
+			return err
+		}
+	}
+	if err := r.replicateSecret(ctx, database, user, data, replicas); err != nil {
+		return fmt.Errorf("failed to replicate secret for user %s: %w", user.Name, err)
+	}
+	return nil
+}
+
+// sweepSecrets deletes user secrets labelled for the database that its spec no longer references, e.g.
+// secrets left behind by a rename that was never recorded in the status. Secrets of removed users stay
+// with the Retain deletion policy, replicas are left to pruneReplicas.
+func (r *DatabaseReconciler) sweepSecrets(ctx context.Context, database *postgresv1.Database) error {
+	log := logf.FromContext(ctx)
+
+	users := make(map[string]bool, len(database.Spec.Users))
+	referenced := make(map[types.NamespacedName]bool, len(database.Spec.Users))
+	for _, user := range database.Spec.Users {
+		users[user.Name] = true
+		if k8s.HasUserSecret(user) || credentialsFor(database, user.Name).DeliveryExpiresAt != nil {
+			namespace := k8s.UserSecretNamespace(database, user)
+			referenced[types.NamespacedName{Name: k8s.UserSecretName(database, user), Namespace: namespace}] = true
+			if user.ImmutableSecret {
+				referenced[types.NamespacedName{Name: k8s.UserSecretPointerName(database, user), Namespace: namespace}] = true
+				if superseded := supersededSecretName(database, user); superseded != "" {
+					referenced[types.NamespacedName{Name: superseded, Namespace: namespace}] = true
+				}
+			}
+		}
+	}
+	retain := database.Spec.UserDeletionPolicy == "" || database.Spec.UserDeletionPolicy == postgresv1.UserDeletionRetain
+
+	secrets, err := r.secretService.ListUserSecrets(ctx, database)
+	if err != nil {
+		return err
+	}
+	for _, secret := range secrets {
+		username := secret.Annotations[postgresv1.UserAnnotation]
+		if username == "" || secret.Labels[postgresv1.ReplicaOfLabel] != "" ||
+			referenced[types.NamespacedName{Name: secret.Name, Namespace: secret.Namespace}] || (retain && !users[username]) {
+			continue
+		}
+		log.Info("Deleting orphaned user secret", "secret", secret.Namespace+"/"+secret.Name, "user", username)
+		if err := r.secretService.DeleteSecret(ctx, secret.Name, secret.Namespace); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// writeUserSecret applies a user's secret. Immutable secrets cannot change, so when their contents differ
+// the next revision is created and the pointer secret moved to it. The revision it replaced stays for
+// consumers still reading it, sweepSecrets deletes it once the next revision supersedes it as well.
+func (r *DatabaseReconciler) writeUserSecret(ctx context.Context, database *postgresv1.Database, user postgresv1.DatabaseUser, data map[string][]byte) error {
+	name := k8s.UserSecretName(database, user)
+	namespace := k8s.UserSecretNamespace(database, user)
+	if !user.ImmutableSecret {
+		return r.secretService.ApplyUserSecret(ctx, database, user.Name, namespace, name, false, data)
+	}
+
+	current, err := r.secretService.GetSecret(ctx, name, namespace)
+	switch {
+	case apierrors.IsNotFound(err):
+		if err := r.secretService.ApplyUserSecret(ctx, database, user.Name, namespace, name, true, data); err != nil {
+			return err
+		}
+	case err != nil:
+		return err
+	case !maps.EqualFunc(current.Data, data, bytes.Equal):
+		if name, err = r.nextSecretRevision(ctx, database, user); err != nil {
+			return err
+		}
+		if err := r.secretService.ApplyUserSecret(ctx, database, user.Name, namespace, name, true, data); err != nil {
+			return err
+		}
+	}
+
+	return r.secretService.ApplySecretPointer(ctx, database, user.Name, namespace, k8s.UserSecretPointerName(database, user), name)
+}
+
+// nextSecretRevision records the next revision of a user's immutable secret and returns its name. The
+// revision is persisted before its secret is created, so a failure in between is picked up by the next
+// reconcile instead of losing track of the secrets already written.
+func (r *DatabaseReconciler) nextSecretRevision(ctx context.Context, database *postgresv1.Database, user postgresv1.DatabaseUser) (string, error) {
+	record := credentialsRecord(database, user.Name)
+	if record == nil {
+		return "", fmt.Errorf("no credentials recorded for user %s", user.Name)
+	}
+
+	previous := *record
+	record.SecretRevision = k8s.SecretRevision(database, user.Name) + 1
+	// The new revision starts without the rotate-credentials annotation
+	record.RotationRequest = ""
+	// database may be the copy of expireUsers without the expired users, the patch must not reset its spec
+	if err := k8s.PatchStatus(ctx, r.Client, database.DeepCopy()); err != nil {
+		*record = previous
+		return "", fmt.Errorf("failed to record revision %d of the secret: %w", record.SecretRevision, err)
+	}
+	return k8s.UserSecretName(database, user), nil
+}
+
+// supersededSecretName returns the name of the revision the current immutable secret of a user replaced,
+// or "" when there is none
+func supersededSecretName(database *postgresv1.Database, user postgresv1.DatabaseUser) string {
+	revision := k8s.SecretRevision(database, user.Name)
+	if !user.ImmutableSecret || revision < 2 {
+		return ""
+	}
+	return k8s.UserSecretRevisionName(database, user, revision-1)
+}
+
+// deliverPasswords hands the new passwords of users with passwordDelivery to their sink. Nothing else
+// keeps the password, a failed delivery is retried with another new password.
+func (r *DatabaseReconciler) deliverPasswords(ctx context.Context, pgConn *postgresv1.PostGresConnection, database *postgresv1.Database, users []postgresv1.DatabaseUser, passwords map[string]string) error {
+	now := metav1.Now()
+	for _, user := range users {
+		record := credentialsRecord(database, user.Name)
+		if record == nil {
+			continue
+		}
+
+		if user.PasswordDelivery.Sink == postgresv1.PasswordDeliveryVault {
+			if database.Spec.Vault == nil {
+				return fmt.Errorf("user %s delivers its password to Vault but spec.vault is not set", user.Name)
+			}
+			path, err := r.vaultPath(database, user.Name)
+			if err != nil {
+				return err
+			}
+			data := map[string]string{"username": user.Name, "password": passwords[user.Name]}
+			if err := r.CredentialStore.Write(ctx, path, data); err != nil {
+				return fmt.Errorf("failed to deliver password of user %s to Vault: %w", user.Name, err)
+			}
+		} else {
+			ttl := time.Hour
+			if user.PasswordDelivery.TTL != nil {
+				ttl = user.PasswordDelivery.TTL.Duration
+			}
+			data, err := r.userSecretData(ctx, pgConn, database, user, passwords[user.Name])
+			if err != nil {
+				return err
+			}
+			if err := r.secretService.ApplyUserSecret(ctx, database, user.Name, k8s.UserSecretNamespace(database, user), k8s.UserSecretName(database, user), false, data); err != nil {
+				return fmt.Errorf("failed to deliver password of user %s: %w", user.Name, err)
+			}
+			expiry := metav1.NewTime(now.Add(ttl))
+			record.DeliveryExpiresAt = &expiry
+		}
+		record.DeliveredAt = &now
+	}
+
+	return nil
+}
+
+// expireDeliveries deletes the one-time secrets whose ttl has passed
+func (r *DatabaseReconciler) expireDeliveries(ctx context.Context, database *postgresv1.Database) error {
+	now := metav1.Now()
+	for _, user := range database.Spec.Users {
+		record := credentialsRecord(database, user.Name)
+		if user.PasswordDelivery == nil || record == nil || record.DeliveryExpiresAt == nil || now.Before(record.DeliveryExpiresAt) {
+			continue
+		}
+		if err := r.secretService.DeleteSecret(ctx, k8s.UserSecretName(database, user), k8s.UserSecretNamespace(database, user)); err != nil {
+			return fmt.Errorf("failed to delete delivered secret of user %s: %w", user.Name, err)
+		}
+		record.DeliveryExpiresAt = nil
+	}
+
+	return nil
+}
+
+// replicateSecret copies a user's secret to the namespaces selected by replicateTo, recording the copies in replicas
+func (r *DatabaseReconciler) replicateSecret(ctx context.Context, database *postgresv1.Database, user postgresv1.DatabaseUser, data map[string][]byte, replicas map[types.NamespacedName]bool) error {
+	if user.ReplicateTo == nil {
+		return nil
+	}
+
+	namespaces, err := r.replicaNamespaces(ctx, user.ReplicateTo)
+	if err != nil {
+		return err
+	}
+
+	secretName := k8s.UserSecretName(database, user)
+	for _, namespace := range namespaces {
+		if namespace == k8s.UserSecretNamespace(database, user) {
+			continue
+		}
+		err := r.secretService.ApplySecretReplica(ctx, database, user.Name, namespace, secretName, user.ImmutableSecret, data)
+		if apierrors.IsNotFound(err) {
+			// The namespace does not exist (yet)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		replicas[types.NamespacedName{Name: secretName, Namespace: namespace}] = true
+	}
+
+	return nil
+}
+
+// replicaNamespaces returns the active namespaces named or selected by the replication settings
+func (r *DatabaseReconciler) replicaNamespaces(ctx context.Context, replication *postgresv1.SecretReplication) ([]string, error) {
+	namespaces := slices.Clone(replication.Namespaces)
+
+	if replication.NamespaceSelector != nil {
+		selector, err := metav1.LabelSelectorAsSelector(replication.NamespaceSelector)
+		if err != nil {
+			return nil, fmt.Errorf("invalid namespaceSelector: %w", err)
+		}
+		var selected corev1.NamespaceList
+		if err := r.List(ctx, &selected, client.MatchingLabelsSelector{Selector: selector}); err != nil {
+			return nil, fmt.Errorf("failed to list namespaces: %w", err)
+		}
+		for _, namespace := range selected.Items {
+			if namespace.Status.Phase != corev1.NamespaceTerminating {
+				namespaces = append(namespaces, namespace.Name)
+			}
+		}
+	}
+
+	slices.Sort(namespaces)
+	return slices.Compact(namespaces), nil
+}
+
+// pruneReplicas deletes the copies of user secrets that are no longer wanted, because the user, its
+// secret or the namespace is no longer selected
+func (r *DatabaseReconciler) pruneReplicas(ctx context.Context, database *postgresv1.Database, replicas map[types.NamespacedName]bool) error {
+	secrets, err := r.secretService.ListUserSecrets(ctx, database)
+	if err != nil {
+		return err
+	}
+
+	for _, secret := range secrets {
+		if _, ok := secret.Labels[postgresv1.ReplicaOfLabel]; !ok {
+			continue
+		}
+		if replicas[types.NamespacedName{Name: secret.Name, Namespace: secret.Namespace}] {
+			continue
+		}
+		if err := r.secretService.DeleteSecret(ctx, secret.Name, secret.Namespace); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// renameUsers renames the roles of users whose renamedFrom names a managed user, carrying the password
+// over to the secret of the new name. The recorded status follows so the old name is not pruned.
+func (r *DatabaseReconciler) renameUsers(ctx context.Context, db *sql.DB, pgConn *postgresv1.PostGresConnection, database *postgresv1.Database) error {
+	for _, user := range database.Spec.Users {
+		if user.RenamedFrom == "" || user.RenamedFrom == user.Name || managedUser(database, user.Name) != nil {
+			continue
+		}
+		previous := managedUser(database, user.RenamedFrom)
+		if previous == nil {
+			continue
+		}
+
+		if err := r.userService.RenameUser(ctx, db, user.RenamedFrom, user.Name); err != nil {
+			return fmt.Errorf("failed to rename user %s to %s: %w", user.RenamedFrom, user.Name, err)
+		}
+
+		secretName := k8s.UserSecretName(database, user)
+		namespace := k8s.UserSecretNamespace(database, user)
+		previousNamespace := secretNamespace(database, *previous)
+		if previous.SecretName != "" && k8s.HasUserSecret(user) && user.PasswordSecretRef == nil {
+			secret, err := r.secretService.GetSecret(ctx, previous.SecretName, previousNamespace)
+			if err != nil && !apierrors.IsNotFound(err) {
+				return err
+			}
+			if err == nil && len(secret.Data["password"]) > 0 {
+				data, err := r.userSecretData(ctx, pgConn, database, user, string(secret.Data["password"]))
+				if err != nil {
+					return err
+				}
+				if err := r.writeUserSecret(ctx, database, user, data); err != nil {
+					return fmt.Errorf("failed to update secret for user %s: %w", user.Name, err)
+				}
+			}
+		}
+		if previous.SecretName != "" && (previous.SecretName != secretName || previousNamespace != namespace) {
+			if err := r.secretService.DeleteSecret(ctx, previous.SecretName, previousNamespace); err != nil {
+				return err
+			}
+		}
+
+		previous.Name = user.Name
+		previous.SecretName = ""
+		previous.SecretNamespace = ""
+		if k8s.HasUserSecret(user) {
+			previous.SecretName = secretName
+			previous.SecretNamespace = namespace
+		}
+		if record := credentialsRecord(database, user.RenamedFrom); record != nil {
+			record.Name = user.Name
+		}
+	}
+
+	return nil
+}
+
+// managedUser returns the recorded managed user with the given name for updating, or nil
+func managedUser(database *postgresv1.Database, name string) *postgresv1.ManagedUser {
+	for i := range database.Status.ManagedUsers {
+		if database.Status.ManagedUsers[i].Name == name {
+			return &database.Status.ManagedUsers[i]
+		}
+	}
+	return nil
+}
+
+// pruneUsers applies the user deletion policy to managed users no longer in the spec and
+// records the users now managed
+func (r *DatabaseReconciler) pruneUsers(ctx context.Context, database *postgresv1.Database) error {
+	inSpec := make(map[string]bool, len(database.Spec.Users))
+	managed := make([]postgresv1.ManagedUser, 0, len(database.Spec.Users))
+	for _, user := range database.Spec.Users {
+		inSpec[user.Name] = true
+		record := postgresv1.ManagedUser{Name: user.Name}
+		if k8s.HasUserSecret(user) {
+			record.SecretName = k8s.UserSecretName(database, user)
+			record.SecretNamespace = k8s.UserSecretNamespace(database, user)
+		}
+		managed = append(managed, record)
+
+		// The password was carried over to the new secret when the user's secret moved. The revision an
+		// immutable secret replaced is left to sweepSecrets.
+		previous := managedUser(database, user.Name)
+		if previous != nil && previous.SecretName != "" && previous.SecretName != supersededSecretName(database, user) &&
+			(previous.SecretName != record.SecretName || secretNamespace(database, *previous) != record.SecretNamespace) {
+			if err := r.secretService.DeleteSecret(ctx, previous.SecretName, secretNamespace(database, *previous)); err != nil {
+				return err
+			}
+		}
+	}
+
+	var removed []postgresv1.ManagedUser
+	for _, user := range database.Status.ManagedUsers {
+		if !inSpec[user.Name] {
+			removed = append(removed, user)
+		}
+	}
+
+	policy := database.Spec.UserDeletionPolicy
+	if len(removed) > 0 && policy != "" && policy != postgresv1.UserDeletionRetain && isFrozen(database) {
+		// Removed users stay managed and are dropped or revoked once the database allows connections again
+		database.Status.ManagedUsers = append(managed, removed...)
+		return nil
+	}
+	if len(removed) > 0 && policy != "" && policy != postgresv1.UserDeletionRetain {
+		pgConn, err := r.getPostGresConnection(ctx, database)
+		if err != nil {
+			return err
+		}
+
+		db, err := r.pgClient.ConnectToDatabase(ctx, pgConn, database.Spec.DatabaseName)
+		if err != nil {
+			return fmt.Errorf("failed to connect to database %s: %w", database.Spec.DatabaseName, err)
+		}
+
+		for _, user := range removed {
+			if policy == postgresv1.UserDeletionDrop {
+				err = r.userService.DropUser(ctx, db, user.Name)
+			} else {
+				err = r.userService.RevokeUser(ctx, db, database, user.Name)
+			}
+			if err != nil {
+				return fmt.Errorf("failed to remove user %s: %w", user.Name, err)
+			}
+			if user.SecretName != "" {
+				if err := r.secretService.DeleteSecret(ctx, user.SecretName, secretNamespace(database, user)); err != nil {
+					return err
+				}
+			}
+			if err := r.deleteFromVault(ctx, database, user.Name); err != nil {
+				return err
+			}
+		}
+	}
+
+	database.Status.ManagedUsers = managed
+	return nil
+}
+
+// reconcileGrants revokes undeclared privileges of the active users when grant reconciliation is
+// exact, recording the outcome in the GrantsInSync condition of the database
+func (r *DatabaseReconciler) reconcileGrants(ctx context.Context, database, active *postgresv1.Database) error {
+	if database.Spec.GrantReconciliation != postgresv1.GrantReconciliationExact {
+		meta.RemoveStatusCondition(&database.Status.Conditions, "GrantsInSync")
+		return nil
+	}
+
+	condition := metav1.Condition{
+		Type:   "GrantsInSync",
+		Status: metav1.ConditionFalse,
+		Reason: "RevokeFailed",
+	}
+
+	revoked, err := r.revokeUndeclaredPrivileges(ctx, active)
+	switch {
+	case err != nil:
+		condition.Message = err.Error()
+	case len(revoked) > 0:
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = "DriftCorrected"
+		condition.Message = fmt.Sprintf("Revoked %s", strings.Join(revoked, "; "))
+	default:
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = "InSync"
+		condition.Message = "Granted privileges match the spec"
+	}
+	meta.SetStatusCondition(&database.Status.Conditions, condition)
+
+	return err
+}
+
+func (r *DatabaseReconciler) revokeUndeclaredPrivileges(ctx context.Context, database *postgresv1.Database) ([]string, error) {
+	pgConn, err := r.getPostGresConnection(ctx, database)
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := r.pgClient.ConnectToDatabase(ctx, pgConn, database.Spec.DatabaseName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to database %s: %w", database.Spec.DatabaseName, err)
+	}
+
+	var revoked []string
+	for _, user := range database.Spec.Users {
+		userRevoked, err := r.userService.RevokeUndeclaredPrivileges(ctx, db, database.Spec.DatabaseName, user)
+		for _, privilege := range userRevoked {
+			revoked = append(revoked, fmt.Sprintf("%s from %s", privilege, user.Name))
+		}
+		if err != nil {
+			return revoked, fmt.Errorf("failed to revoke undeclared privileges of user %s: %w", user.Name, err)
+		}
+	}
+
+	return revoked, nil
+}
+
+// expireUsers records when each user's password was issued and expires, and drops the users whose
+// password expired with dropAfterExpiry set. It returns a copy of the database holding the remaining
+// users; the credentials are recorded in the status of the original.
+func (r *DatabaseReconciler) expireUsers(ctx context.Context, database *postgresv1.Database) (*postgresv1.Database, error) {
+	now := metav1.Now()
+	active := database.DeepCopy()
+	active.Spec.Users = nil
+
+	credentials := make([]postgresv1.UserCredentials, 0, len(database.Spec.Users))
+	for _, user := range database.Spec.Users {
+		record := credentialsFor(database, user.Name)
+		if record.IssuedAt.IsZero() || (record.Dropped && user.ValidUntil != nil && now.Before(user.ValidUntil)) {
+			// New users and users recreated after validUntil moved get a fresh password
+			record = postgresv1.UserCredentials{Name: user.Name, IssuedAt: now}
+		}
+		record.ValidUntil = credentialExpiry(user, record.IssuedAt)
+
+		if !user.DropAfterExpiry || record.ValidUntil == nil || now.Before(record.ValidUntil) {
+			record.Dropped = false
+			active.Spec.Users = append(active.Spec.Users, user)
+		} else if !record.Dropped && !isFrozen(database) {
+			// Dropping needs a connection to the database, expired users can no longer log in meanwhile
+			if err := r.dropUser(ctx, database, user); err != nil {
+				return nil, fmt.Errorf("failed to drop expired user %s: %w", user.Name, err)
+			}
+			record.Dropped = true
+		}
+		credentials = append(credentials, record)
+	}
+
+	database.Status.Credentials = credentials
+	active.Status.Credentials = credentials
+	return active, nil
+}
+
+// dropUser removes an expired user and its secret. The role is dropped through a connection
+// to the database itself so objects it owns there are dropped with it.
+func (r *DatabaseReconciler) dropUser(ctx context.Context, database *postgresv1.Database, user postgresv1.DatabaseUser) error {
+	pgConn, err := r.getPostGresConnection(ctx, database)
+	if err != nil {
+		return err
+	}
+
+	db, err := r.pgClient.ConnectToDatabase(ctx, pgConn, database.Spec.DatabaseName)
+	if err != nil {
+		return fmt.Errorf("failed to connect to database %s: %w", database.Spec.DatabaseName, err)
+	}
+
+	if err := r.userService.DropUser(ctx, db, user.Name); err != nil {
+		return err
+	}
+
+	if k8s.HasUserSecret(user) {
+		if err := r.secretService.DeleteSecret(ctx, k8s.UserSecretName(database, user), k8s.UserSecretNamespace(database, user)); err != nil {
+			return err
+		}
+	}
+	return r.deleteFromVault(ctx, database, user.Name)
+}
+
+// replaceLostPasswords sets the freshly obtained password of users whose secret or Vault entry does not
+// exist, so that a deleted secret is recreated with working credentials. For users created in this reconcile
+// the password is already set and applying it again is harmless. The new password restarts the ttl.
+func (r *DatabaseReconciler) replaceLostPasswords(ctx context.Context, db *sql.DB, database *postgresv1.Database, passwords map[string]string, names []string) error {
+	now := metav1.Now()
+	for _, user := range database.Spec.Users {
+		if !slices.Contains(names, user.Name) {
+			continue
+		}
+
+		if err := r.userService.SetPassword(ctx, db, user.Name, passwords[user.Name]); err != nil {
+			return err
+		}
+		if record := credentialsRecord(database, user.Name); record != nil {
+			record.IssuedAt = now
+			record.ValidUntil = credentialExpiry(user, now)
+		}
+	}
+
+	return nil
+}
+
+// repairPasswords resets the password of roles that no longer accept the password in their secret,
+// e.g. after a manual ALTER ROLE, so consumers of the secret keep working
+func (r *DatabaseReconciler) repairPasswords(ctx context.Context, db *sql.DB, database *postgresv1.Database, passwords map[string]string) error {
+	log := logf.FromContext(ctx)
+
+	for _, user := range database.Spec.Users {
+		password, ok := passwords[user.Name]
+		if !ok || (user.PasswordSecretRef == nil && !k8s.HasUserSecret(user) && database.Spec.Vault == nil) {
+			// Passwords of users without a secret are not kept anywhere to compare with
+			continue
+		}
+
+		matches, err := r.userService.PasswordMatches(ctx, db, user.Name, password)
+		if errors.Is(err, postgres.ErrPasswordsUnreadable) {
+			// The other roles' passwords cannot be read either
+			log.V(1).Info("Skipping password verification", "reason", err.Error())
+			return nil
+		}
+		if errors.Is(err, postgres.ErrPasswordUnverifiable) {
+			log.V(1).Info("Skipping password verification", "user", user.Name, "reason", err.Error())
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if matches {
+			continue
+		}
+
+		log.Info("Password of role does not match its secret, resetting it", "user", user.Name)
+		if err := r.userService.SetPassword(ctx, db, user.Name, password); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// syncSourcedPasswords applies passwords read from passwordSecretRef Secrets whose resourceVersion
+// changed since they were last applied. The new password restarts the ttl.
+func (r *DatabaseReconciler) syncSourcedPasswords(ctx context.Context, db *sql.DB, database *postgresv1.Database, passwords, versions map[string]string) error {
+	now := metav1.Now()
+	for i := range database.Status.Credentials {
+		record := &database.Status.Credentials[i]
+		version, ok := versions[record.Name]
+		if !ok || record.PasswordSecretVersion == version {
+			continue
+		}
+
+		// Also applied on first sight, the role may predate the passwordSecretRef
+		if err := r.userService.SetPassword(ctx, db, record.Name, passwords[record.Name]); err != nil {
+			return err
+		}
+		if record.PasswordSecretVersion != "" {
+			record.IssuedAt = now
+		}
+		record.PasswordSecretVersion = version
+
+		for _, user := range database.Spec.Users {
+			if user.Name == record.Name {
+				record.ValidUntil = credentialExpiry(user, record.IssuedAt)
+			}
+		}
+	}
+
+	return nil
+}
+
+// sourcePassword reads a user's password from its passwordSecretRef, returning the Secret's resourceVersion
+func (r *DatabaseReconciler) sourcePassword(ctx context.Context, database *postgresv1.Database, user postgresv1.DatabaseUser) (string, string, error) {
+	ref := user.PasswordSecretRef
+	secret, err := r.secretService.GetSecret(ctx, ref.Name, database.Namespace)
+	if err != nil {
+		return "", "", err
+	}
+
+	password := secret.Data[ref.Key]
+	if len(password) == 0 {
+		return "", "", fmt.Errorf("secret %s has no key %s", ref.Name, ref.Key)
+	}
+
+	return string(password), secret.ResourceVersion, nil
+}
+
+// databasesForPasswordSecret maps a Secret to the Databases with users taking their password from it
+func (r *DatabaseReconciler) databasesForPasswordSecret(ctx context.Context, obj client.Object) []reconcile.Request {
+	var databases postgresv1.DatabaseList
+	if err := r.List(ctx, &databases, client.InNamespace(obj.GetNamespace()), client.MatchingFields{passwordSecretField: obj.GetName()}); err != nil {
+		logf.FromContext(ctx).Error(err, "Failed to list Databases")
+		return nil
+	}
+
+	var requests []reconcile.Request
+	for _, database := range databases.Items {
+		requests = append(requests, reconcile.Request{
+			NamespacedName: types.NamespacedName{Name: database.Name, Namespace: database.Namespace},
+		})
+	}
+
+	return requests
+}
+
+// databaseForLabels maps a secret kept outside its Database's namespace or a final backup to the Database
+// named by its labels
+func (r *DatabaseReconciler) databaseForLabels(ctx context.Context, obj client.Object) []reconcile.Request {
+	name, namespace := obj.GetLabels()[postgresv1.DatabaseNameLabel], obj.GetLabels()[postgresv1.DatabaseNamespaceLabel]
+	if name == "" || namespace == "" {
+		return nil
+	}
+	return []reconcile.Request{{NamespacedName: types.NamespacedName{Name: name, Namespace: namespace}}}
+}
+
+// databasesForConnectionSecret maps a secret to the Databases whose connection reads it, so they are
+// reconciled with e.g. a password rotated by CNPG instead of failing until the next change
+func (r *DatabaseReconciler) databasesForConnectionSecret(ctx context.Context, obj client.Object) []reconcile.Request {
+	connections, err := connectionsReferencing(ctx, r.Client, client.ObjectKeyFromObject(obj))
+	if err != nil {
+		logf.FromContext(ctx).Error(err, "Failed to list PostGresConnections")
+		return nil
+	}
+
+	var requests []reconcile.Request
+	for _, pgConn := range connections {
+		requests = append(requests, r.databasesForConnection(ctx, &pgConn)...)
+	}
+	return requests
+}
+
+// databasesForNamespace maps a Namespace to the Databases replicating secrets to it by name or by namespace
+// selector, so copies follow namespaces that are created or relabelled
+func (r *DatabaseReconciler) databasesForNamespace(ctx context.Context, obj client.Object) []reconcile.Request {
+	databases, err := databasesReplicatingTo(ctx, r.Client, obj.GetName())
+	if err != nil {
+		logf.FromContext(ctx).Error(err, "Failed to list Databases replicating to namespace", "namespace", obj.GetName())
+		return nil
+	}
+
+	var requests []reconcile.Request
+	for _, database := range databases {
+		requests = append(requests, reconcile.Request{
+			NamespacedName: types.NamespacedName{Name: database.Name, Namespace: database.Namespace},
+		})
+	}
+
+	return requests
+}
+
+// databasesForConnection maps a PostGresConnection to the Databases bound to it, so they are reconciled as soon
+// as the connection is ready instead of waiting for their next retry
+func (r *DatabaseReconciler) databasesForConnection(ctx context.Context, obj client.Object) []reconcile.Request {
+	pgConn, ok := obj.(*postgresv1.PostGresConnection)
+	if !ok {
+		return nil
+	}
+	databases, err := boundDatabases(ctx, r.Client, pgConn)
+	if err != nil {
+		logf.FromContext(ctx).Error(err, "Failed to list Databases of connection", "connection", pgConn.Name)
+		return nil
+	}
+
+	var requests []reconcile.Request
+	for _, database := range databases {
+		requests = append(requests, reconcile.Request{
+			NamespacedName: types.NamespacedName{Name: database.Name, Namespace: database.Namespace},
+		})
+	}
+	return requests
+}
+
+// connectionBecameReady passes the updates of a PostGresConnection whose status turned ready. Connections seen
+// at startup need no filter, every Database is reconciled then anyway.
+var connectionBecameReady = predicate.Funcs{
+	CreateFunc:  func(event.CreateEvent) bool { return false },
+	DeleteFunc:  func(event.DeleteEvent) bool { return false },
+	GenericFunc: func(event.GenericEvent) bool { return false },
+	UpdateFunc: func(e event.UpdateEvent) bool {
+		previous, ok := e.ObjectOld.(*postgresv1.PostGresConnection)
+		if !ok {
+			return false
+		}
+		current, ok := e.ObjectNew.(*postgresv1.PostGresConnection)
+		return ok && !previous.Status.Ready && current.Status.Ready
+	},
+}
+
+// credentialsFor returns the recorded credentials of a user, or an empty record
+func credentialsFor(database *postgresv1.Database, name string) postgresv1.UserCredentials {
+	for _, record := range database.Status.Credentials {
+		if record.Name == name {
+			return record
+		}
+	}
+	return postgresv1.UserCredentials{Name: name}
+}
+
+// credentialExpiry returns when the user's password expires, validUntil taking precedence over ttl
+func credentialExpiry(user postgresv1.DatabaseUser, issuedAt metav1.Time) *metav1.Time {
+	if user.ValidUntil != nil {
+		return user.ValidUntil.DeepCopy()
+	}
+	if user.TTL != nil {
+		expiry := metav1.NewTime(issuedAt.Add(user.TTL.Duration))
+		return &expiry
+	}
+	return nil
+}
+
+// nextCredentialAction returns when the next user is due to be dropped, to have its password rotated
+// or to have its one-time secret deleted
+func nextCredentialAction(database *postgresv1.Database) *metav1.Time {
+	var next *metav1.Time
+	earliest := func(t *metav1.Time) {
+		if t != nil && (next == nil || t.Before(next)) {
+			next = t
+		}
+	}
+
+	for _, user := range database.Spec.Users {
+		record := credentialsFor(database, user.Name)
+		if user.DropAfterExpiry && !record.Dropped {
+			earliest(record.ValidUntil)
+		}
+		earliest(record.DeliveryExpiresAt)
+		if rotation := rotationFor(database, user); rotation != nil && !record.Dropped && !record.IssuedAt.IsZero() {
+			due := nextRotation(record, rotation)
+			earliest(&due)
+		}
+	}
+	return next
+}
+
+// rotatePasswords replaces the passwords that are due for rotation or whose rotation was requested
+// through the rotate-credentials annotation. The role and its secret change together; when the secret
+// cannot be updated the role gets its previous password back.
+func (r *DatabaseReconciler) rotatePasswords(ctx context.Context, db *sql.DB, pgConn *postgresv1.PostGresConnection, database *postgresv1.Database, passwords map[string]string) error {
+	now := metav1.Now()
+	databaseRequest := database.Annotations[postgresv1.RotateCredentialsAnnotation]
+	rotateAll := databaseRequest != database.Status.CredentialRotationRequest
+
+	for _, user := range database.Spec.Users {
+		record := credentialsRecord(database, user.Name)
+		previous, ok := passwords[user.Name]
+		if record == nil || record.Dropped || !ok || !rotatable(user) {
+			continue
+		}
+
+		secret, err := r.secretService.GetSecret(ctx, k8s.UserSecretName(database, user), k8s.UserSecretNamespace(database, user))
+		if err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+		var userRequest string
+		if err == nil {
+			userRequest = secret.Annotations[postgresv1.RotateCredentialsAnnotation]
+		}
+
+		due := rotateAll || userRequest != record.RotationRequest
+		if rotation := rotationFor(database, user); rotation != nil {
+			next := nextRotation(*record, rotation)
+			due = due || !now.Before(&next)
+		}
+		if !due {
+			continue
+		}
+
+		password, err := r.newPassword(ctx, database, user)
+		if err != nil {
+			return fmt.Errorf("failed to obtain new password for user %s: %w", user.Name, err)
+		}
+		data, err := r.userSecretData(ctx, pgConn, database, user, password)
+		if err != nil {
+			return err
+		}
+
+		if err := r.userService.SetPassword(ctx, db, user.Name, password); err != nil {
+			return err
+		}
+		if err := r.writeUserSecret(ctx, database, user, data); err != nil {
+			if restoreErr := r.userService.SetPassword(ctx, db, user.Name, previous); restoreErr != nil {
+				return fmt.Errorf("failed to update secret for user %s: %w (restoring the password failed: %v)", user.Name, err, restoreErr)
+			}
+			return fmt.Errorf("failed to update secret for user %s: %w", user.Name, err)
+		}
+
+		passwords[user.Name] = password
+		record.LastRotationTime = &now
+		recordEvent(r.recorder, database, corev1.EventTypeNormal, "PasswordRotated", "Password of user %s is rotated", user.Name)
+		if !user.ImmutableSecret {
+			// A new immutable revision starts without the annotation
+			record.RotationRequest = userRequest
+		}
+		record.IssuedAt = now
+		record.ValidUntil = credentialExpiry(user, now)
+	}
+
+	database.Status.CredentialRotationRequest = databaseRequest
+	return nil
+}
+
+// rotatable reports whether the user's password is generated and stored in a secret created by the operator
+func rotatable(user postgresv1.DatabaseUser) bool {
+	return user.PasswordSecretRef == nil && k8s.HasUserSecret(user)
+}
+
+// rotationFor returns the enabled scheduled rotation settings of a user
+func rotationFor(database *postgresv1.Database, user postgresv1.DatabaseUser) *postgresv1.PasswordRotation {
+	if !rotatable(user) {
+		return nil
+	}
+
+	rotation := user.PasswordRotation
+	if rotation == nil {
+		rotation = database.Spec.PasswordRotation
+	}
+	if rotation == nil || !rotation.Enabled || rotation.Interval.Duration <= 0 {
+		return nil
+	}
+	return rotation
+}
+
+// nextRotation returns when a password is due for rotation, counting from its last rotation or issue
+func nextRotation(record postgresv1.UserCredentials, rotation *postgresv1.PasswordRotation) metav1.Time {
+	last := record.IssuedAt
+	if record.LastRotationTime != nil {
+		last = *record.LastRotationTime
+	}
+	return metav1.NewTime(last.Add(rotation.Interval.Duration))
+}
+
+// credentialsRecord returns the recorded credentials of a user for updating, or nil
+func credentialsRecord(database *postgresv1.Database, name string) *postgresv1.UserCredentials {
+	for i := range database.Status.Credentials {
+		if database.Status.Credentials[i].Name == name {
+			return &database.Status.Credentials[i]
+		}
+	}
+	return nil
+}
+
+// grantInDatabase applies the users' role presets and default privileges through a connection to
+// the database itself, where schema and object privileges are stored
+func (r *DatabaseReconciler) grantInDatabase(ctx context.Context, database *postgresv1.Database) error {
+	var users []postgresv1.DatabaseUser
+	for _, user := range database.Spec.Users {
+		if user.Role != "" || len(user.DefaultPrivileges) > 0 {
+			users = append(users, user)
+		}
+	}
+	if len(users) == 0 {
+		return nil
+	}
+
+	pgConn, err := r.getPostGresConnection(ctx, database)
+	if err != nil {
+		return err
+	}
+
+	db, err := r.pgClient.ConnectToDatabase(ctx, pgConn, database.Spec.DatabaseName)
+	if err != nil {
+		return fmt.Errorf("failed to connect to database %s: %w", database.Spec.DatabaseName, err)
+	}
+
+	for _, user := range users {
+		if user.Role != "" && database.Spec.PermissionModel != postgresv1.PermissionModelGroupRoles {
+			if err := r.userService.GrantPreset(ctx, db, database.Spec.DatabaseName, database.Spec.Owner, user); err != nil {
+				return fmt.Errorf("failed to grant role %s to user %s: %w", user.Role, user.Name, err)
+			}
+		}
+		if err := r.userService.GrantDefaultPrivileges(ctx, db, database.Spec.Owner, user); err != nil {
+			return fmt.Errorf("failed to grant default privileges to user %s: %w", user.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// ensurePooler provisions the CNPG Pooler of the database in the cluster's namespace and deletes one that
+// is disabled or moved
+func (r *DatabaseReconciler) ensurePooler(ctx context.Context, pgConn *postgresv1.PostGresConnection, database *postgresv1.Database) error {
+	var wanted *postgresv1.PoolerReference
+	if config := database.Spec.Pooler; config != nil && config.Enabled {
+		if pgConn.Spec.ClusterName == "" {
+			return fmt.Errorf("a pooler needs a PostGresConnection to a CNPG cluster")
+		}
+		wanted = &postgresv1.PoolerReference{Name: database.Name + "-pooler", Namespace: clusterNamespace(pgConn)}
+	}
+
+	if previous := database.Status.Pooler; previous != nil && (wanted == nil || *previous != *wanted) {
+		if err := r.clusterService.DeletePooler(ctx, database, *previous); err != nil && !meta.IsNoMatchError(err) {
+			return err
+		}
+		database.Status.Pooler = nil
+	}
+	if wanted == nil {
+		return nil
+	}
+
+	err := r.clusterService.ApplyPooler(ctx, database, *wanted, pgConn.Spec.ClusterName)
+	if meta.IsNoMatchError(err) {
+		return fmt.Errorf("a pooler is enabled but CNPG is not installed")
+	}
+	if err != nil {
+		return err
+	}
+	database.Status.Pooler = wanted
+	return nil
+}
+
+// databaseEndpoints returns the endpoints published for a database, where the Pooler provisioned for the
+// database replaces the connection's pooler
+func databaseEndpoints(ctx context.Context, pgClient *postgres.Client, pgConn *postgresv1.PostGresConnection, database *postgresv1.Database) (*postgres.Endpoints, error) {
+	endpoints, err := pgClient.Endpoints(ctx, pgConn)
+	if err != nil {
+		return nil, err
+	}
+	if pooler := database.Status.Pooler; pooler != nil {
+		endpoints.PoolerHost, endpoints.PoolerPort = postgres.ServiceHost(pooler.Name, pooler.Namespace), 5432
+	}
+	return endpoints, nil
+}
+
+// publishBinding writes the servicebinding.io binding secret and references it in status.binding,
+// which makes the Database a Provisioned Service that workloads can be bound to
+func (r *DatabaseReconciler) publishBinding(ctx context.Context, pgConn *postgresv1.PostGresConnection, database *postgresv1.Database) error {
+	binding := database.Spec.ServiceBinding
+	var secretName string
+	if binding != nil {
+		secretName = binding.SecretName
+		if secretName == "" {
+			secretName = database.Name + "-binding"
+		}
+	}
+
+	if previous := database.Status.Binding; previous != nil && previous.Name != secretName {
+		if err := r.secretService.DeleteSecret(ctx, previous.Name, database.Namespace); err != nil {
+			return err
+		}
+		database.Status.Binding = nil
+	}
+	if binding == nil {
+		return nil
+	}
+
+	user, ok := bindingUser(database)
+	if !ok {
+		return fmt.Errorf("no user with a secret to publish")
+	}
+	credentials, err := r.secretService.GetSecret(ctx, k8s.UserSecretName(database, user), k8s.UserSecretNamespace(database, user))
+	if err != nil {
+		return err
+	}
+
+	endpoints, err := databaseEndpoints(ctx, r.pgClient, pgConn, database)
+	if err != nil {
+		return err
+	}
+	host, port := endpoints.Host, endpoints.Port
+	if user.PreferReadOnlyEndpoint {
+		host, port = endpoints.ReadOnlyHost, endpoints.ReadOnlyPort
+	}
+	data := map[string][]byte{
+		"type":     []byte("postgresql"),
+		"provider": []byte("pg-operator"),
+		"host":     []byte(host),
+		"port":     []byte(strconv.Itoa(int(port))),
+		"database": []byte(database.Spec.DatabaseName),
+		"username": credentials.Data["username"],
+		"password": credentials.Data["password"],
+		"sslmode":  []byte(endpoints.SSLMode),
+	}
+	if err := r.secretService.ApplySecret(ctx, database, secretName, data); err != nil {
+		return err
+	}
+
+	database.Status.Binding = &postgresv1.LocalObjectReference{Name: secretName}
+	return nil
+}
+
+// bindingUser returns the user whose credentials the binding secret publishes
+func bindingUser(database *postgresv1.Database) (postgresv1.DatabaseUser, bool) {
+	for _, user := range database.Spec.Users {
+		if !k8s.HasUserSecret(user) {
+			continue
+		}
+		if database.Spec.ServiceBinding.User == "" || database.Spec.ServiceBinding.User == user.Name {
+			return user, true
+		}
+	}
+	return postgresv1.DatabaseUser{}, false
+}
+
+// userSecretData returns the data of a user's secret: the credentials plus the connection details
+// selected in secretKeys
+func (r *DatabaseReconciler) userSecretData(ctx context.Context, pgConn *postgresv1.PostGresConnection, database *postgresv1.Database, user postgresv1.DatabaseUser, password string) (map[string][]byte, error) {
+	data := map[string][]byte{"username": []byte(user.Name), "password": []byte(password)}
+	if len(database.Spec.SecretKeys) == 0 {
+		return data, nil
+	}
+
+	endpoints, err := databaseEndpoints(ctx, r.pgClient, pgConn, database)
+	if err != nil {
+		return nil, err
+	}
+	details := connectionSecretData(endpoints, database.Spec.DatabaseName, user.Name, password, user.PreferReadOnlyEndpoint)
+	for _, key := range database.Spec.SecretKeys {
+		data[key] = details[key]
+	}
+	return data, nil
+}
+
+// connectionSecretData returns everything an application needs to connect to a database as the given user.
+// host-ro and uri-ro point at the replicas; with preferReadOnly the other keys point there as well.
+// The pooler keys point at the database's own Pooler, or else the connection's.
+func connectionSecretData(endpoints *postgres.Endpoints, databaseName, username, password string, preferReadOnly bool) map[string][]byte {
+	host, port := endpoints.Host, endpoints.Port
+	readOnlyHost, readOnlyPort := endpoints.ReadOnlyHost, endpoints.ReadOnlyPort
+	poolerHost, poolerPort := endpoints.PoolerHost, endpoints.PoolerPort
+	if preferReadOnly {
+		host, port = readOnlyHost, readOnlyPort
+	}
+	sslMode := endpoints.SSLMode
+	address := net.JoinHostPort(host, strconv.Itoa(int(port)))
+
+	connectionURI := func(address string) string {
+		uri := url.URL{
+			Scheme:   "postgresql",
+			User:     url.UserPassword(username, password),
+			Host:     address,
+			Path:     "/" + databaseName,
+			RawQuery: "sslmode=" + sslMode,
+		}
+		return uri.String()
+	}
+	jdbcURI := url.URL{
+		Scheme:   "postgresql",
+		Host:     address,
+		Path:     "/" + databaseName,
+		RawQuery: url.Values{"user": {username}, "password": {password}, "sslmode": {sslMode}}.Encode(),
+	}
+
+	// .pgpass fields are separated by colons, so colons and backslashes in values are escaped
+	pgpassEscaper := strings.NewReplacer(`\`, `\\`, ":", `\:`)
+	pgpass := strings.Join([]string{
+		pgpassEscaper.Replace(host),
+		strconv.Itoa(int(port)),
+		pgpassEscaper.Replace(databaseName),
+		pgpassEscaper.Replace(username),
+		pgpassEscaper.Replace(password),
+	}, ":") + "\n"
+	serviceFile := fmt.Sprintf("[%s]\nhost=%s\nport=%d\ndbname=%s\nuser=%s\npassword=%s\nsslmode=%s\n",
+		databaseName, host, port, databaseName, username, password, sslMode)
+
+	return map[string][]byte{
+		"host":            []byte(host),
+		"host-ro":         []byte(readOnlyHost),
+		"port":            []byte(strconv.Itoa(int(port))),
+		"dbname":          []byte(databaseName),
+		"username":        []byte(username),
+		"password":        []byte(password),
+		"sslmode":         []byte(sslMode),
+		"uri":             []byte(connectionURI(address)),
+		"uri-ro":          []byte(connectionURI(net.JoinHostPort(readOnlyHost, strconv.Itoa(int(readOnlyPort))))),
+		"pooler-host":     []byte(poolerHost),
+		"pooler-port":     []byte(strconv.Itoa(int(poolerPort))),
+		"pooled-uri":      []byte(connectionURI(net.JoinHostPort(poolerHost, strconv.Itoa(int(poolerPort))))),
+		"jdbc-uri":        []byte("jdbc:" + jdbcURI.String()),
+		"pgpass":          []byte(pgpass),
+		"pg_service.conf": []byte(serviceFile),
+	}
+}
+
+// resolvePassword returns the password stored in the user's secret if one exists, falling back to
+// the secret recorded in the status when the secret moved and then to Vault, otherwise a new password
+// from the configured provider or the local generator. The boolean reports whether the password was stored.
+// Users without a secret or Vault only get here when their role needs a password.
+func (r *DatabaseReconciler) resolvePassword(ctx context.Context, database *postgresv1.Database, user postgresv1.DatabaseUser) (string, bool, error) {
+	if k8s.HasUserSecret(user) {
+		locations := []types.NamespacedName{{Name: k8s.UserSecretName(database, user), Namespace: k8s.UserSecretNamespace(database, user)}}
+		if previous := managedUser(database, user.Name); previous != nil && previous.SecretName != "" {
+			locations = append(locations, types.NamespacedName{Name: previous.SecretName, Namespace: secretNamespace(database, *previous)})
+		}
+
+		for _, location := range locations {
+			secret, err := r.secretService.GetSecret(ctx, location.Name, location.Namespace)
+			if err == nil && len(secret.Data["password"]) > 0 {
+				return string(secret.Data["password"]), true, nil
+			}
+			if err != nil && !apierrors.IsNotFound(err) {
+				return "", false, err
+			}
+		}
+	}
+
+	if database.Spec.Vault != nil {
+		path, err := r.vaultPath(database, user.Name)
+		if err != nil {
+			return "", false, err
+		}
+		stored, err := r.CredentialStore.Read(ctx, path)
+		if err != nil {
+			return "", false, fmt.Errorf("failed to read credentials from Vault: %w", err)
+		}
+		if stored["password"] != "" {
+			return stored["password"], true, nil
+		}
+	}
+
+	password, err := r.newPassword(ctx, database, user)
+	return password, false, err
+}
+
+// writeToVault writes the credentials of the users with a password to Vault when they changed. Users
+// with passwordDelivery are written once by deliverPasswords.
+func (r *DatabaseReconciler) writeToVault(ctx context.Context, database *postgresv1.Database, passwords map[string]string) error {
+	if database.Spec.Vault == nil {
+		return nil
+	}
+
+	for _, user := range database.Spec.Users {
+		password, ok := passwords[user.Name]
+		if !ok || user.PasswordDelivery != nil {
+			continue
+		}
+		path, err := r.vaultPath(database, user.Name)
+		if err != nil {
+			return err
+		}
+
+		// KV keeps every write as a new version, only write what changed
+		stored, err := r.CredentialStore.Read(ctx, path)
+		if err != nil {
+			return fmt.Errorf("failed to read credentials of user %s from Vault: %w", user.Name, err)
+		}
+		if stored["username"] == user.Name && stored["password"] == password {
+			continue
+		}
+		data := map[string]string{"username": user.Name, "password": password}
+		if err := r.CredentialStore.Write(ctx, path, data); err != nil {
+			return fmt.Errorf("failed to write credentials of user %s to Vault: %w", user.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// deleteFromVault deletes a user's credentials from Vault when the database writes them there
+func (r *DatabaseReconciler) deleteFromVault(ctx context.Context, database *postgresv1.Database, name string) error {
+	if database.Spec.Vault == nil {
+		return nil
+	}
+
+	path, err := r.vaultPath(database, name)
+	if err != nil {
+		return err
+	}
+	if err := r.CredentialStore.Delete(ctx, path); err != nil {
+		return fmt.Errorf("failed to delete credentials of user %s from Vault: %w", name, err)
+	}
+	return nil
+}
+
+// vaultPath returns where a user's credentials are kept in Vault
+func (r *DatabaseReconciler) vaultPath(database *postgresv1.Database, name string) (string, error) {
+	if r.CredentialStore == nil {
+		return "", fmt.Errorf("vault is enabled on the Database but not configured in the operator")
+	}
+	return r.CredentialStore.Path(database.Spec.Vault.Path, credentials.PasswordRequest{
+		Namespace:    database.Namespace,
+		Database:     database.Name,
+		DatabaseName: database.Spec.DatabaseName,
+		User:         name,
+	})
+}
+
+// newPassword obtains a fresh password from the configured provider or the local generator
+func (r *DatabaseReconciler) newPassword(ctx context.Context, database *postgresv1.Database, user postgresv1.DatabaseUser) (string, error) {
+	if r.PasswordProvider != nil {
+		return r.PasswordProvider.GetPassword(ctx, credentials.PasswordRequest{
+			Namespace:    database.Namespace,
+			Database:     database.Name,
+			DatabaseName: database.Spec.DatabaseName,
+			User:         user.Name,
+		})
+	}
+
+	return utils.GeneratePassword(passwordPolicyFor(r.PasswordPolicy, database))
+}
+
+// passwordPolicyFor applies the Database's overrides to the operator's password policy
+func passwordPolicyFor(policy utils.PasswordPolicy, database *postgresv1.Database) utils.PasswordPolicy {
+	override := database.Spec.PasswordPolicy
+	if override == nil {
+		return policy
+	}
+
+	if override.Length != nil {
+		policy.Length = int(*override.Length)
+	}
+	if override.Charset != "" {
+		policy.Charset = override.Charset
+	}
+	if override.RequireSpecial != nil {
+		policy.RequireSpecial = *override.RequireSpecial
+	}
+	if override.ExcludeAmbiguous != nil {
+		policy.ExcludeAmbiguous = *override.ExcludeAmbiguous
+	}
+	return policy
 }
 
 // NewDatabaseReconciler creates a new DatabaseReconciler with all required services
 func NewDatabaseReconciler(client client.Client, scheme *runtime.Scheme) *DatabaseReconciler {
 	pgClient := postgres.NewClient(client)
 	return &DatabaseReconciler{
-		Client:        client,
-		Scheme:        scheme,
-		pgClient:      pgClient,
-		dbService:     postgres.NewDatabaseService(pgClient),
-		userService:   postgres.NewUserService(pgClient),
-		secretService: k8s.NewSecretService(client, scheme),
-		statusService: k8s.NewStatusService(client),
+		Client:           client,
+		Scheme:           scheme,
+		PasswordPolicy:   utils.DefaultPasswordPolicy,
+		pgClient:         pgClient,
+		dbService:        postgres.NewDatabaseService(pgClient),
+		userService:      postgres.NewUserService(pgClient),
+		extensionService: postgres.NewExtensionService(pgClient),
+		secretService:    k8s.NewSecretService(client, scheme),
+		rolloutService:   k8s.NewRolloutService(client),
+		clusterService:   k8s.NewClusterService(client),
+		backupService:    k8s.NewBackupService(client, scheme),
+		statusService:    k8s.NewStatusService(client),
 	}
 }
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *DatabaseReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	r.recorder = mgr.GetEventRecorderFor("pg-operator")
+	r.statusService.SetRecorder(r.recorder)
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&postgresv1.Database{}).
 		Owns(&corev1.Secret{}).
+		Watches(&corev1.Secret{}, handler.EnqueueRequestsFromMapFunc(r.databasesForPasswordSecret)).
+		Watches(&corev1.Secret{}, handler.EnqueueRequestsFromMapFunc(r.databaseForLabels)).
+		Watches(&corev1.Secret{}, handler.EnqueueRequestsFromMapFunc(r.databasesForConnectionSecret)).
+		Watches(&corev1.Namespace{}, handler.EnqueueRequestsFromMapFunc(r.databasesForNamespace)).
+		Watches(&postgresv1.DatabaseBackup{}, handler.EnqueueRequestsFromMapFunc(r.databaseForLabels)).
+		Watches(&postgresv1.PostGresConnection{}, handler.EnqueueRequestsFromMapFunc(r.databasesForConnection),
+			builder.WithPredicates(connectionBecameReady)).
 		Named("database").
 		Complete(r)
 }
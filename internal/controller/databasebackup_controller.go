@@ -0,0 +1,231 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	postgresv1 "github.com/silverswarm/pg-operator/api/v1"
+	"github.com/silverswarm/pg-operator/pkg/k8s"
+	"github.com/silverswarm/pg-operator/pkg/postgres"
+	"github.com/silverswarm/pg-operator/pkg/utils"
+)
+
+// backupFinalizer deletes the backup Job, which runs in the operator's namespace and cannot be owned by the
+// DatabaseBackup
+const backupFinalizer = "postgres.silverswarm.io/backup"
+
+// DatabaseBackupReconciler reconciles a DatabaseBackup object
+type DatabaseBackupReconciler struct {
+	client.Client
+	Scheme        *runtime.Scheme
+	pgClient      *postgres.Client
+	backupService *k8s.BackupService
+	statusService *k8s.StatusService
+}
+
+// +kubebuilder:rbac:groups=postgres.silverswarm.io,resources=databasebackups,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=postgres.silverswarm.io,resources=databasebackups/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=postgres.silverswarm.io,resources=databasebackups/finalizers,verbs=update
+// +kubebuilder:rbac:groups=postgres.silverswarm.io,resources=databases,verbs=get;list;watch
+// +kubebuilder:rbac:groups=postgres.silverswarm.io,resources=postgresconnections,verbs=get;list;watch
+// +kubebuilder:rbac:groups=batch,resources=jobs,verbs=get;list;watch;create;delete
+// +kubebuilder:rbac:groups="",resources=pods,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch;create;update;patch;delete
+
+func (r *DatabaseBackupReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := logf.FromContext(ctx)
+	ctx = postgres.WithApplicationName(ctx, "databasebackup", req.NamespacedName)
+
+	var backup postgresv1.DatabaseBackup
+	if err := r.Get(ctx, req.NamespacedName, &backup); err != nil {
+		return utils.HandleReconcileError(err, "Failed to get DatabaseBackup", log)
+	}
+	ctx = k8s.WithStatusBase(ctx, &backup)
+
+	if !backup.DeletionTimestamp.IsZero() {
+		return r.finalize(ctx, &backup)
+	}
+
+	switch backup.Status.Phase {
+	case postgresv1.DatabaseBackupCompleted, postgresv1.DatabaseBackupFailed:
+		return ctrl.Result{}, nil
+	case postgresv1.DatabaseBackupRunning:
+		return r.checkJob(ctx, &backup)
+	}
+
+	backup.Status.Phase = postgresv1.DatabaseBackupPending
+
+	var database postgresv1.Database
+	key := types.NamespacedName{Name: backup.Spec.DatabaseRef.Name, Namespace: backup.Namespace}
+	if err := r.Get(ctx, key, &database); err != nil {
+		return r.statusService.UpdateDatabaseBackupStatus(ctx, &backup, fmt.Sprintf("Failed to get Database %s: %v", key, err))
+	}
+
+	if !database.Status.DatabaseCreated {
+		return r.statusService.UpdateDatabaseBackupStatus(ctx, &backup, "Database has not been created yet")
+	}
+
+	pgConn, err := getConnectionForDatabase(ctx, r.Client, &database)
+	if err != nil {
+		return r.statusService.UpdateDatabaseBackupStatus(ctx, &backup, err.Error())
+	}
+
+	env, caBundle, err := r.pgClient.ConnectionEnv(ctx, pgConn, database.Spec.DatabaseName)
+	if err != nil {
+		return r.statusService.UpdateDatabaseBackupStatus(ctx, &backup, err.Error())
+	}
+
+	if !controllerutil.ContainsFinalizer(&backup, backupFinalizer) {
+		controllerutil.AddFinalizer(&backup, backupFinalizer)
+		if err := r.Update(ctx, &backup); err != nil {
+			return utils.HandleReconcileError(err, "Failed to add finalizer", log)
+		}
+	}
+
+	location := k8s.BackupLocation(&backup, database.Spec.DatabaseName)
+	job, err := r.backupService.CreateBackupJob(ctx, &backup, env, caBundle, location)
+	if err != nil {
+		return r.statusService.UpdateDatabaseBackupStatus(ctx, &backup, err.Error())
+	}
+
+	backup.Status.Phase = postgresv1.DatabaseBackupRunning
+	backup.Status.JobName = job.Name
+	backup.Status.Location = location
+
+	return r.statusService.UpdateDatabaseBackupStatus(ctx, &backup, fmt.Sprintf("Backup job %s started", job.Name))
+}
+
+// checkJob records the outcome of the backup Job once it has finished
+func (r *DatabaseBackupReconciler) checkJob(ctx context.Context, backup *postgresv1.DatabaseBackup) (ctrl.Result, error) {
+	namespace, err := k8s.BackupNamespace()
+	if err != nil {
+		return r.statusService.UpdateDatabaseBackupStatus(ctx, backup, err.Error())
+	}
+	job, err := r.backupService.GetJob(ctx, backup.Status.JobName, namespace)
+	if apierrors.IsNotFound(err) {
+		backup.Status.Phase = postgresv1.DatabaseBackupFailed
+		return r.statusService.UpdateDatabaseBackupStatus(ctx, backup, fmt.Sprintf("Backup job %s no longer exists", backup.Status.JobName))
+	}
+	if err != nil {
+		return r.statusService.UpdateDatabaseBackupStatus(ctx, backup, fmt.Sprintf("Failed to get backup job: %v", err))
+	}
+
+	backup.Status.StartedAt = job.Status.StartTime
+
+	var finished *batchv1.JobCondition
+	for i, condition := range job.Status.Conditions {
+		if condition.Status == corev1.ConditionTrue && (condition.Type == batchv1.JobComplete || condition.Type == batchv1.JobFailed) {
+			finished = &job.Status.Conditions[i]
+		}
+	}
+	if finished == nil {
+		return r.statusService.UpdateDatabaseBackupStatus(ctx, backup, fmt.Sprintf("Backup job %s is running", job.Name))
+	}
+
+	completedAt := finished.LastTransitionTime
+	if job.Status.CompletionTime != nil {
+		completedAt = *job.Status.CompletionTime
+	}
+	backup.Status.CompletedAt = &completedAt
+	if job.Status.StartTime != nil {
+		backup.Status.Duration = &metav1.Duration{Duration: completedAt.Sub(job.Status.StartTime.Time)}
+	}
+
+	// The credentials are only needed while the job runs
+	if err := r.backupService.DeleteBackupSecrets(ctx, backup); err != nil {
+		return r.statusService.UpdateDatabaseBackupStatus(ctx, backup, err.Error())
+	}
+
+	if finished.Type == batchv1.JobFailed {
+		backup.Status.Phase = postgresv1.DatabaseBackupFailed
+		return r.statusService.UpdateDatabaseBackupStatus(ctx, backup, fmt.Sprintf("Backup job failed: %s", finished.Message))
+	}
+
+	size, err := r.backupService.UploadedSize(ctx, job)
+	if err != nil {
+		logf.FromContext(ctx).Error(err, "Failed to determine backup size")
+	}
+	backup.Status.SizeBytes = size
+	backup.Status.Phase = postgresv1.DatabaseBackupCompleted
+
+	return r.statusService.UpdateDatabaseBackupStatus(ctx, backup, fmt.Sprintf("Backup uploaded to %s", backup.Status.Location))
+}
+
+// finalize deletes the backup Job and its secrets from the operator's namespace
+func (r *DatabaseBackupReconciler) finalize(ctx context.Context, backup *postgresv1.DatabaseBackup) (ctrl.Result, error) {
+	log := logf.FromContext(ctx)
+
+	if !controllerutil.ContainsFinalizer(backup, backupFinalizer) {
+		return ctrl.Result{}, nil
+	}
+
+	if err := r.backupService.DeleteBackupJob(ctx, backup); err != nil {
+		log.Error(err, "Failed to delete backup job of deleted DatabaseBackup")
+		return ctrl.Result{RequeueAfter: k8s.RetryInterval(backup)}, nil
+	}
+
+	controllerutil.RemoveFinalizer(backup, backupFinalizer)
+	if err := r.Update(ctx, backup); err != nil {
+		return utils.HandleReconcileError(err, "Failed to remove finalizer", log)
+	}
+
+	return ctrl.Result{}, nil
+}
+
+// backupForJob maps a backup Job to the DatabaseBackup named by its labels
+func (r *DatabaseBackupReconciler) backupForJob(ctx context.Context, obj client.Object) []reconcile.Request {
+	name, namespace := obj.GetLabels()[postgresv1.BackupNameLabel], obj.GetLabels()[postgresv1.BackupNamespaceLabel]
+	if name == "" || namespace == "" {
+		return nil
+	}
+	return []reconcile.Request{{NamespacedName: types.NamespacedName{Name: name, Namespace: namespace}}}
+}
+
+// NewDatabaseBackupReconciler creates a new DatabaseBackupReconciler with all required services
+func NewDatabaseBackupReconciler(client client.Client, scheme *runtime.Scheme) *DatabaseBackupReconciler {
+	return &DatabaseBackupReconciler{
+		Client:        client,
+		Scheme:        scheme,
+		pgClient:      postgres.NewClient(client),
+		backupService: k8s.NewBackupService(client, scheme),
+		statusService: k8s.NewStatusService(client),
+	}
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *DatabaseBackupReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&postgresv1.DatabaseBackup{}).
+		Watches(&batchv1.Job{}, handler.EnqueueRequestsFromMapFunc(r.backupForJob)).
+		Named("databasebackup").
+		Complete(r)
+}
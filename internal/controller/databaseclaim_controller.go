@@ -0,0 +1,236 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	postgresv1 "github.com/silverswarm/pg-operator/api/v1"
+	"github.com/silverswarm/pg-operator/pkg/k8s"
+	"github.com/silverswarm/pg-operator/pkg/postgres"
+	"github.com/silverswarm/pg-operator/pkg/utils"
+)
+
+// DatabaseClaimReconciler reconciles a DatabaseClaim object
+type DatabaseClaimReconciler struct {
+	client.Client
+	Scheme        *runtime.Scheme
+	pgClient      *postgres.Client
+	secretService *k8s.SecretService
+	statusService *k8s.StatusService
+}
+
+// +kubebuilder:rbac:groups=postgres.silverswarm.io,resources=databaseclaims,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=postgres.silverswarm.io,resources=databaseclaims/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=postgres.silverswarm.io,resources=databaseclaims/finalizers,verbs=update
+// +kubebuilder:rbac:groups=postgres.silverswarm.io,resources=postgresclasses,verbs=get;list;watch
+// +kubebuilder:rbac:groups=postgres.silverswarm.io,resources=databases,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=postgres.silverswarm.io,resources=postgresconnections,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch;create;update;patch;delete
+
+func (r *DatabaseClaimReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := logf.FromContext(ctx)
+
+	var claim postgresv1.DatabaseClaim
+	if err := r.Get(ctx, req.NamespacedName, &claim); err != nil {
+		return utils.HandleReconcileError(err, "Failed to get DatabaseClaim", log)
+	}
+	ctx = k8s.WithStatusBase(ctx, &claim)
+
+	if claim.Status.Phase == "" {
+		claim.Status.Phase = postgresv1.DatabaseClaimPending
+	}
+
+	class, err := r.resolveClass(ctx, &claim)
+	if err != nil {
+		return r.statusService.UpdateDatabaseClaimStatus(ctx, &claim, err.Error())
+	}
+	claim.Status.ClassName = class.Name
+	claim.Status.DatabaseName = claimDatabaseName(&claim)
+	claim.Status.SecretName = claimSecretName(&claim)
+
+	database, err := r.ensureDatabase(ctx, &claim, class)
+	if err != nil {
+		return r.statusService.UpdateDatabaseClaimStatus(ctx, &claim, err.Error())
+	}
+
+	if !database.Status.Ready {
+		return r.statusService.UpdateDatabaseClaimStatus(ctx, &claim, fmt.Sprintf("Waiting for database %s: %s", database.Spec.DatabaseName, database.Status.Message))
+	}
+
+	if err := r.writeBindingSecret(ctx, &claim, database); err != nil {
+		return r.statusService.UpdateDatabaseClaimStatus(ctx, &claim, err.Error())
+	}
+
+	claim.Status.Phase = postgresv1.DatabaseClaimBound
+	return r.statusService.UpdateDatabaseClaimStatus(ctx, &claim,
+		fmt.Sprintf("Bound to database %s of class %s", claim.Status.DatabaseName, class.Name))
+}
+
+// resolveClass returns the class the claim is bound to, the class it names, or the default class
+func (r *DatabaseClaimReconciler) resolveClass(ctx context.Context, claim *postgresv1.DatabaseClaim) (*postgresv1.PostgresClass, error) {
+	name := claim.Status.ClassName
+	if name == "" {
+		name = claim.Spec.ClassName
+	}
+
+	if name != "" {
+		var class postgresv1.PostgresClass
+		if err := r.Get(ctx, types.NamespacedName{Name: name}, &class); err != nil {
+			return nil, fmt.Errorf("failed to get PostgresClass %s: %w", name, err)
+		}
+		return &class, nil
+	}
+
+	var classes postgresv1.PostgresClassList
+	if err := r.List(ctx, &classes); err != nil {
+		return nil, fmt.Errorf("failed to list PostgresClasses: %w", err)
+	}
+
+	var defaults []postgresv1.PostgresClass
+	for _, class := range classes.Items {
+		if class.Spec.Default {
+			defaults = append(defaults, class)
+		}
+	}
+
+	switch len(defaults) {
+	case 0:
+		return nil, fmt.Errorf("no className given and no default PostgresClass exists")
+	case 1:
+		return &defaults[0], nil
+	default:
+		return nil, fmt.Errorf("no className given and %d PostgresClasses are marked default", len(defaults))
+	}
+}
+
+// ensureDatabase creates or updates the Database resource that provisions the claim
+func (r *DatabaseClaimReconciler) ensureDatabase(ctx context.Context, claim *postgresv1.DatabaseClaim, class *postgresv1.PostgresClass) (*postgresv1.Database, error) {
+	database := &postgresv1.Database{
+		ObjectMeta: metav1.ObjectMeta{Name: claim.Name, Namespace: claim.Namespace},
+	}
+
+	var existing postgresv1.Database
+	err := r.Get(ctx, client.ObjectKeyFromObject(database), &existing)
+	if err == nil && !metav1.IsControlledBy(&existing, claim) {
+		return nil, fmt.Errorf("a Database named %s already exists and is not managed by this claim", claim.Name)
+	}
+	if err != nil && !apierrors.IsNotFound(err) {
+		return nil, fmt.Errorf("failed to get Database %s: %w", claim.Name, err)
+	}
+
+	permissions := class.Spec.Permissions
+	if len(permissions) == 0 {
+		permissions = []postgresv1.Permission{postgresv1.PermissionAll}
+	}
+
+	_, err = controllerutil.CreateOrUpdate(ctx, r.Client, database, func() error {
+		database.Spec.ConnectionRef = postgresv1.ConnectionReference{
+			Name:      class.Spec.ConnectionRef.Name,
+			Namespace: class.Spec.ConnectionRef.Namespace,
+		}
+		database.Spec.DatabaseName = claim.Status.DatabaseName
+		database.Spec.Encoding = class.Spec.Encoding
+		database.Spec.Extensions = class.Spec.Extensions
+		database.Spec.Users = []postgresv1.DatabaseUser{{
+			Name:        claim.Status.DatabaseName,
+			Permissions: permissions,
+		}}
+		return controllerutil.SetControllerReference(claim, database, r.Scheme)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to apply Database %s: %w", claim.Name, err)
+	}
+
+	return database, nil
+}
+
+// writeBindingSecret publishes everything an application needs to connect in one secret
+func (r *DatabaseClaimReconciler) writeBindingSecret(ctx context.Context, claim *postgresv1.DatabaseClaim, database *postgresv1.Database) error {
+	pgConn, err := getConnectionForDatabase(ctx, r.Client, database)
+	if err != nil {
+		return err
+	}
+
+	user := database.Spec.Users[0]
+	credentials, err := r.secretService.GetSecret(ctx, k8s.UserSecretName(database, user), k8s.UserSecretNamespace(database, user))
+	if err != nil {
+		return err
+	}
+
+	endpoints, err := databaseEndpoints(ctx, r.pgClient, pgConn, database)
+	if err != nil {
+		return err
+	}
+	data := connectionSecretData(endpoints, database.Spec.DatabaseName,
+		string(credentials.Data["username"]), string(credentials.Data["password"]), user.PreferReadOnlyEndpoint)
+	return r.secretService.ApplySecret(ctx, claim, claim.Status.SecretName, data)
+}
+
+func claimDatabaseName(claim *postgresv1.DatabaseClaim) string {
+	if claim.Status.DatabaseName != "" {
+		return claim.Status.DatabaseName
+	}
+	if claim.Spec.DatabaseName != "" {
+		return claim.Spec.DatabaseName
+	}
+	name := strings.NewReplacer("-", "_", ".", "_").Replace(claim.Namespace + "_" + claim.Name)
+	if len(name) > 63 {
+		name = name[:63]
+	}
+	return name
+}
+
+func claimSecretName(claim *postgresv1.DatabaseClaim) string {
+	if claim.Spec.SecretName != "" {
+		return claim.Spec.SecretName
+	}
+	return fmt.Sprintf("%s-db", claim.Name)
+}
+
+// NewDatabaseClaimReconciler creates a new DatabaseClaimReconciler with all required services
+func NewDatabaseClaimReconciler(client client.Client, scheme *runtime.Scheme) *DatabaseClaimReconciler {
+	return &DatabaseClaimReconciler{
+		Client:        client,
+		Scheme:        scheme,
+		pgClient:      postgres.NewClient(client),
+		secretService: k8s.NewSecretService(client, scheme),
+		statusService: k8s.NewStatusService(client),
+	}
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *DatabaseClaimReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&postgresv1.DatabaseClaim{}).
+		Owns(&postgresv1.Database{}).
+		Owns(&corev1.Secret{}).
+		Named("databaseclaim").
+		Complete(r)
+}
@@ -0,0 +1,259 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	postgresv1 "github.com/silverswarm/pg-operator/api/v1"
+	"github.com/silverswarm/pg-operator/pkg/k8s"
+	"github.com/silverswarm/pg-operator/pkg/postgres"
+	"github.com/silverswarm/pg-operator/pkg/utils"
+)
+
+const foreignServerFinalizer = "postgres.silverswarm.io/foreign-server"
+
+// ForeignServerReconciler reconciles a ForeignServer object
+type ForeignServerReconciler struct {
+	client.Client
+	Scheme               *runtime.Scheme
+	pgClient             *postgres.Client
+	foreignServerService *postgres.ForeignServerService
+	secretService        *k8s.SecretService
+	statusService        *k8s.StatusService
+	recorder             record.EventRecorder
+}
+
+// +kubebuilder:rbac:groups=postgres.silverswarm.io,resources=foreignservers,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=postgres.silverswarm.io,resources=foreignservers/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=postgres.silverswarm.io,resources=foreignservers/finalizers,verbs=update
+// +kubebuilder:rbac:groups=postgres.silverswarm.io,resources=databases,verbs=get;list;watch
+// +kubebuilder:rbac:groups=postgres.silverswarm.io,resources=postgresconnections,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+
+func (r *ForeignServerReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := logf.FromContext(ctx)
+	ctx = postgres.WithApplicationName(ctx, "foreignserver", req.NamespacedName)
+
+	var server postgresv1.ForeignServer
+	if err := r.Get(ctx, req.NamespacedName, &server); err != nil {
+		return utils.HandleReconcileError(err, "Failed to get ForeignServer", log)
+	}
+	ctx = k8s.WithStatusBase(ctx, &server)
+	ctx = postgres.WithAudit(ctx, r.recorder, &server)
+
+	if !server.DeletionTimestamp.IsZero() {
+		return r.finalize(ctx, &server)
+	}
+
+	if !controllerutil.ContainsFinalizer(&server, foreignServerFinalizer) {
+		controllerutil.AddFinalizer(&server, foreignServerFinalizer)
+		if err := r.Update(ctx, &server); err != nil {
+			return utils.HandleReconcileError(err, "Failed to add finalizer", log)
+		}
+	}
+
+	database, pgConn, err := r.resolveTarget(ctx, &server)
+	if errors.Is(err, errFrozen) {
+		return waitWhileFrozen(ctx, r.Client, &server, &server.Status.Conditions, err)
+	}
+	if err != nil {
+		return r.statusService.UpdateForeignServerStatus(ctx, &server, false, err.Error())
+	}
+	meta.RemoveStatusCondition(&server.Status.Conditions, conditionFrozen)
+
+	db, err := r.pgClient.ConnectToDatabase(ctx, pgConn, database.Spec.DatabaseName)
+	if err != nil {
+		return r.statusService.UpdateForeignServerStatus(ctx, &server, false, fmt.Sprintf("Failed to connect to database: %v", err))
+	}
+
+	unlock, err := r.pgClient.LockDDL(ctx, pgConn)
+	if err != nil {
+		return r.statusService.UpdateForeignServerStatus(ctx, &server, false, err.Error())
+	}
+	defer unlock()
+
+	created, err := r.foreignServerService.EnsureForeignServer(ctx, db, &server)
+	if err != nil {
+		return r.statusService.UpdateForeignServerStatus(ctx, &server, false, fmt.Sprintf("Failed to ensure foreign server: %v", err))
+	}
+	server.Status.ServerCreated = true
+	server.Status.CreatedByOperator = created
+
+	if err := r.ensureUserMappings(ctx, db, &server); err != nil {
+		return r.statusService.UpdateForeignServerStatus(ctx, &server, false, fmt.Sprintf("Failed to ensure user mappings: %v", err))
+	}
+
+	result, err := r.statusService.UpdateForeignServerStatus(ctx, &server, true, "Foreign server ready")
+	return resync(result, err, server.Spec.ResyncInterval)
+}
+
+// ensureUserMappings applies the mappings of the spec and drops the ones removed from it
+func (r *ForeignServerReconciler) ensureUserMappings(ctx context.Context, db *sql.DB, server *postgresv1.ForeignServer) error {
+	desired := make([]string, 0, len(server.Spec.UserMappings))
+	for _, mapping := range server.Spec.UserMappings {
+		secret, err := r.secretService.GetSecret(ctx, mapping.CredentialsSecret.Name, server.Namespace)
+		if err != nil {
+			return fmt.Errorf("failed to get credentials for %s: %w", mapping.LocalUser, err)
+		}
+
+		username := string(secret.Data["username"])
+		password := string(secret.Data["password"])
+		if username == "" || password == "" {
+			return fmt.Errorf("secret %s must contain username and password", mapping.CredentialsSecret.Name)
+		}
+
+		if err := r.foreignServerService.EnsureUserMapping(ctx, db, server.Spec.ServerName, mapping.LocalUser, username, password); err != nil {
+			return err
+		}
+		desired = append(desired, mapping.LocalUser)
+	}
+
+	for _, localUser := range server.Status.UserMappings {
+		if slices.Contains(desired, localUser) {
+			continue
+		}
+		if err := r.foreignServerService.DropUserMapping(ctx, db, server.Spec.ServerName, localUser); err != nil {
+			return err
+		}
+	}
+
+	server.Status.UserMappings = desired
+	return nil
+}
+
+func (r *ForeignServerReconciler) finalize(ctx context.Context, server *postgresv1.ForeignServer) (ctrl.Result, error) {
+	log := logf.FromContext(ctx)
+
+	if !controllerutil.ContainsFinalizer(server, foreignServerFinalizer) {
+		return ctrl.Result{}, nil
+	}
+
+	if server.Status.CreatedByOperator {
+		err := r.drop(ctx, server)
+		switch {
+		case apierrors.IsNotFound(err):
+			log.Info("Database of deleted ForeignServer no longer exists, skipping drop", "server", server.Spec.ServerName)
+		case errors.Is(err, errFrozen):
+			return waitWhileFrozen(ctx, r.Client, server, &server.Status.Conditions, err)
+		case err != nil:
+			log.Error(err, "Failed to drop foreign server for deleted ForeignServer")
+			return ctrl.Result{RequeueAfter: k8s.RetryInterval(server)}, nil
+		}
+	}
+
+	controllerutil.RemoveFinalizer(server, foreignServerFinalizer)
+	if err := r.Update(ctx, server); err != nil {
+		return utils.HandleReconcileError(err, "Failed to remove finalizer", log)
+	}
+
+	return ctrl.Result{}, nil
+}
+
+func (r *ForeignServerReconciler) drop(ctx context.Context, server *postgresv1.ForeignServer) error {
+	database, pgConn, err := r.resolveTarget(ctx, server)
+	if err != nil {
+		return err
+	}
+
+	db, err := r.pgClient.ConnectToDatabase(ctx, pgConn, database.Spec.DatabaseName)
+	if err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+
+	unlock, err := r.pgClient.LockDDL(ctx, pgConn)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	// The server may have been dropped and recreated by someone else since
+	created, err := r.foreignServerService.CreatedBy(ctx, db, server)
+	if err != nil {
+		return err
+	}
+	if !created {
+		logf.FromContext(ctx).Info("Foreign server was not created by this resource, retaining it", "server", server.Spec.ServerName)
+		return nil
+	}
+
+	return r.foreignServerService.DropForeignServer(ctx, db, server.Spec.ServerName)
+}
+
+func (r *ForeignServerReconciler) resolveTarget(ctx context.Context, server *postgresv1.ForeignServer) (*postgresv1.Database, *postgresv1.PostGresConnection, error) {
+	var database postgresv1.Database
+	key := types.NamespacedName{Name: server.Spec.DatabaseRef.Name, Namespace: server.Namespace}
+	if err := r.Get(ctx, key, &database); err != nil {
+		return nil, nil, fmt.Errorf("failed to get Database %s: %w", key, err)
+	}
+
+	if !database.Status.DatabaseCreated {
+		return nil, nil, fmt.Errorf("database %s has not been created yet", database.Spec.DatabaseName)
+	}
+
+	if err := checkFrozen(&database); err != nil {
+		return nil, nil, err
+	}
+
+	pgConn, err := getConnectionForDatabase(ctx, r.Client, &database)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := checkConnection(pgConn); err != nil {
+		return nil, nil, err
+	}
+
+	return &database, pgConn, nil
+}
+
+// NewForeignServerReconciler creates a new ForeignServerReconciler with all required services
+func NewForeignServerReconciler(client client.Client, scheme *runtime.Scheme) *ForeignServerReconciler {
+	pgClient := postgres.NewClient(client)
+	return &ForeignServerReconciler{
+		Client:               client,
+		Scheme:               scheme,
+		pgClient:             pgClient,
+		foreignServerService: postgres.NewForeignServerService(pgClient),
+		secretService:        k8s.NewSecretService(client, scheme),
+		statusService:        k8s.NewStatusService(client),
+	}
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *ForeignServerReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	r.recorder = mgr.GetEventRecorderFor("pg-operator")
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&postgresv1.ForeignServer{}).
+		Named("foreignserver").
+		Complete(r)
+}
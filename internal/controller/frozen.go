@@ -0,0 +1,71 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	postgresv1 "github.com/silverswarm/pg-operator/api/v1"
+	"github.com/silverswarm/pg-operator/pkg/k8s"
+)
+
+// conditionFrozen is set on resources whose steps inside the database wait for it to allow connections
+const conditionFrozen = "Frozen"
+
+// errFrozen is returned for steps that need to connect to a database that does not allow connections
+var errFrozen = errors.New("database does not allow connections")
+
+// isFrozen reports whether allowConnections freezes a database for maintenance. PostgreSQL then rejects every
+// connection to it, the operator's included, so steps inside the database wait until it is thawed.
+func isFrozen(database *postgresv1.Database) bool {
+	return database.Spec.AllowConnections != nil && !*database.Spec.AllowConnections
+}
+
+// checkFrozen returns errFrozen for a frozen database
+func checkFrozen(database *postgresv1.Database) error {
+	if isFrozen(database) {
+		return fmt.Errorf("%w: %s has allowConnections set to false", errFrozen, database.Spec.DatabaseName)
+	}
+	return nil
+}
+
+// frozenCondition is the Frozen condition of a resource whose steps wait for its database
+func frozenCondition(message string) metav1.Condition {
+	return metav1.Condition{
+		Type:    conditionFrozen,
+		Status:  metav1.ConditionTrue,
+		Reason:  "ConnectionsNotAllowed",
+		Message: message,
+	}
+}
+
+// waitWhileFrozen records the Frozen condition of a resource whose steps failed with errFrozen and checks
+// again after the retry interval. The rest of the status is left as it is, the resource is not failing.
+func waitWhileFrozen(ctx context.Context, c client.Client, obj client.Object, conditions *[]metav1.Condition, err error) (ctrl.Result, error) {
+	meta.SetStatusCondition(conditions, frozenCondition(fmt.Sprintf("Waiting for the database to allow connections: %v", err)))
+	if err := k8s.PatchStatus(ctx, c, obj); err != nil {
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{RequeueAfter: k8s.RetryInterval(obj)}, nil
+}
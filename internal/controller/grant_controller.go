@@ -0,0 +1,208 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	postgresv1 "github.com/silverswarm/pg-operator/api/v1"
+	"github.com/silverswarm/pg-operator/pkg/k8s"
+	"github.com/silverswarm/pg-operator/pkg/postgres"
+	"github.com/silverswarm/pg-operator/pkg/utils"
+)
+
+const grantFinalizer = "postgres.silverswarm.io/grant"
+
+// GrantReconciler reconciles a Grant object
+type GrantReconciler struct {
+	client.Client
+	Scheme        *runtime.Scheme
+	pgClient      *postgres.Client
+	grantService  *postgres.GrantService
+	statusService *k8s.StatusService
+	recorder      record.EventRecorder
+}
+
+// +kubebuilder:rbac:groups=postgres.silverswarm.io,resources=grants,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=postgres.silverswarm.io,resources=grants/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=postgres.silverswarm.io,resources=grants/finalizers,verbs=update
+// +kubebuilder:rbac:groups=postgres.silverswarm.io,resources=databases,verbs=get;list;watch
+// +kubebuilder:rbac:groups=postgres.silverswarm.io,resources=postgresconnections,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+
+func (r *GrantReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := logf.FromContext(ctx)
+	ctx = postgres.WithApplicationName(ctx, "grant", req.NamespacedName)
+
+	var grant postgresv1.Grant
+	if err := r.Get(ctx, req.NamespacedName, &grant); err != nil {
+		return utils.HandleReconcileError(err, "Failed to get Grant", log)
+	}
+	ctx = k8s.WithStatusBase(ctx, &grant)
+	ctx = postgres.WithAudit(ctx, r.recorder, &grant)
+
+	if !grant.DeletionTimestamp.IsZero() {
+		return r.finalize(ctx, &grant)
+	}
+
+	if !controllerutil.ContainsFinalizer(&grant, grantFinalizer) {
+		controllerutil.AddFinalizer(&grant, grantFinalizer)
+		if err := r.Update(ctx, &grant); err != nil {
+			return utils.HandleReconcileError(err, "Failed to add finalizer", log)
+		}
+	}
+
+	database, pgConn, err := r.resolveTarget(ctx, &grant)
+	if errors.Is(err, errFrozen) {
+		return waitWhileFrozen(ctx, r.Client, &grant, &grant.Status.Conditions, err)
+	}
+	if err != nil {
+		return r.statusService.UpdateGrantStatus(ctx, &grant, false, err.Error())
+	}
+	meta.RemoveStatusCondition(&grant.Status.Conditions, conditionFrozen)
+
+	db, err := r.pgClient.ConnectToDatabase(ctx, pgConn, database.Spec.DatabaseName)
+	if err != nil {
+		return r.statusService.UpdateGrantStatus(ctx, &grant, false, fmt.Sprintf("Failed to connect to database: %v", err))
+	}
+
+	unlock, err := r.pgClient.LockDDL(ctx, pgConn)
+	if err != nil {
+		return r.statusService.UpdateGrantStatus(ctx, &grant, false, err.Error())
+	}
+	defer unlock()
+
+	objects, privileges, err := r.grantService.EnsureGrant(ctx, db, &grant)
+	if err != nil {
+		return r.statusService.UpdateGrantStatus(ctx, &grant, false, fmt.Sprintf("Failed to reconcile privileges: %v", err))
+	}
+	grant.Status.Objects = objects
+	grant.Status.Privileges = privileges
+
+	message := fmt.Sprintf("Granted %s on %d object(s) to %s", strings.Join(privileges, ", "), len(objects), grant.Spec.Role)
+	if len(objects) == 0 {
+		message = "No objects match the given patterns"
+	}
+
+	result, err := r.statusService.UpdateGrantStatus(ctx, &grant, true, message)
+	return resync(result, err, grant.Spec.ResyncInterval)
+}
+
+func (r *GrantReconciler) finalize(ctx context.Context, grant *postgresv1.Grant) (ctrl.Result, error) {
+	log := logf.FromContext(ctx)
+
+	if !controllerutil.ContainsFinalizer(grant, grantFinalizer) {
+		return ctrl.Result{}, nil
+	}
+
+	if len(grant.Status.Objects) > 0 {
+		err := r.revoke(ctx, grant)
+		switch {
+		case apierrors.IsNotFound(err):
+			log.Info("Target of deleted Grant no longer exists, skipping revoke", "role", grant.Spec.Role)
+		case errors.Is(err, errFrozen):
+			return waitWhileFrozen(ctx, r.Client, grant, &grant.Status.Conditions, err)
+		case err != nil:
+			log.Error(err, "Failed to revoke privileges for deleted Grant")
+			return ctrl.Result{RequeueAfter: k8s.RetryInterval(grant)}, nil
+		}
+	}
+
+	controllerutil.RemoveFinalizer(grant, grantFinalizer)
+	if err := r.Update(ctx, grant); err != nil {
+		return utils.HandleReconcileError(err, "Failed to remove finalizer", log)
+	}
+
+	return ctrl.Result{}, nil
+}
+
+func (r *GrantReconciler) revoke(ctx context.Context, grant *postgresv1.Grant) error {
+	database, pgConn, err := r.resolveTarget(ctx, grant)
+	if err != nil {
+		return err
+	}
+
+	db, err := r.pgClient.ConnectToDatabase(ctx, pgConn, database.Spec.DatabaseName)
+	if err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+
+	unlock, err := r.pgClient.LockDDL(ctx, pgConn)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	return r.grantService.RevokeGrant(ctx, db, grant)
+}
+
+func (r *GrantReconciler) resolveTarget(ctx context.Context, grant *postgresv1.Grant) (*postgresv1.Database, *postgresv1.PostGresConnection, error) {
+	var database postgresv1.Database
+	key := types.NamespacedName{Name: grant.Spec.DatabaseRef.Name, Namespace: grant.Namespace}
+	if err := r.Get(ctx, key, &database); err != nil {
+		return nil, nil, fmt.Errorf("failed to get Database %s: %w", key, err)
+	}
+
+	if err := checkFrozen(&database); err != nil {
+		return nil, nil, err
+	}
+
+	pgConn, err := getConnectionForDatabase(ctx, r.Client, &database)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := checkConnection(pgConn); err != nil {
+		return nil, nil, err
+	}
+
+	return &database, pgConn, nil
+}
+
+// NewGrantReconciler creates a new GrantReconciler with all required services
+func NewGrantReconciler(client client.Client, scheme *runtime.Scheme) *GrantReconciler {
+	pgClient := postgres.NewClient(client)
+	return &GrantReconciler{
+		Client:        client,
+		Scheme:        scheme,
+		pgClient:      pgClient,
+		grantService:  postgres.NewGrantService(pgClient),
+		statusService: k8s.NewStatusService(client),
+	}
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *GrantReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	r.recorder = mgr.GetEventRecorderFor("pg-operator")
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&postgresv1.Grant{}).
+		Named("grant").
+		Complete(r)
+}
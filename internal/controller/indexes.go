@@ -0,0 +1,131 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	postgresv1 "github.com/silverswarm/pg-operator/api/v1"
+	"github.com/silverswarm/pg-operator/pkg/postgres"
+)
+
+const (
+	// connectionRefField indexes Databases, PostgresRoles and PostgresClasses by the namespace/name of the
+	// connection they reference
+	connectionRefField = "spec.connectionRef"
+	// connectionSecretField indexes PostGresConnections by the namespace/name of every secret they read
+	connectionSecretField = "spec.secrets"
+	// passwordSecretField indexes Databases by the names of the secrets their users take passwords from
+	passwordSecretField = "spec.users.passwordSecretRef"
+	// replicaNamespaceField indexes Databases by the namespaces their users replicate secrets to, and by
+	// anyNamespace when a namespace selector can select any of them
+	replicaNamespaceField = "spec.users.replicateTo"
+	// anyNamespace is not a valid namespace name, so it never collides with a listed namespace
+	anyNamespace = "*"
+)
+
+// SetupIndexes registers the cache indexes the controllers look dependents up by. It must be called once,
+// before the controllers are set up.
+func SetupIndexes(ctx context.Context, indexer client.FieldIndexer) error {
+	indexes := []struct {
+		obj     client.Object
+		field   string
+		extract client.IndexerFunc
+	}{
+		{&postgresv1.Database{}, connectionRefField, func(obj client.Object) []string {
+			database := obj.(*postgresv1.Database)
+			return []string{connectionKey(database.Spec.ConnectionRef, database.Namespace).String()}
+		}},
+		{&postgresv1.Database{}, passwordSecretField, func(obj client.Object) []string {
+			var names []string
+			for _, user := range obj.(*postgresv1.Database).Spec.Users {
+				if user.PasswordSecretRef != nil {
+					names = append(names, user.PasswordSecretRef.Name)
+				}
+			}
+			return names
+		}},
+		{&postgresv1.Database{}, replicaNamespaceField, func(obj client.Object) []string {
+			var namespaces []string
+			for _, user := range obj.(*postgresv1.Database).Spec.Users {
+				if user.ReplicateTo == nil {
+					continue
+				}
+				namespaces = append(namespaces, user.ReplicateTo.Namespaces...)
+				if user.ReplicateTo.NamespaceSelector != nil {
+					namespaces = append(namespaces, anyNamespace)
+				}
+			}
+			slices.Sort(namespaces)
+			return slices.Compact(namespaces)
+		}},
+		{&postgresv1.PostgresRole{}, connectionRefField, func(obj client.Object) []string {
+			role := obj.(*postgresv1.PostgresRole)
+			return []string{connectionKey(role.Spec.ConnectionRef, role.Namespace).String()}
+		}},
+		{&postgresv1.PostgresClass{}, connectionRefField, func(obj client.Object) []string {
+			ref := obj.(*postgresv1.PostgresClass).Spec.ConnectionRef
+			return []string{types.NamespacedName{Name: ref.Name, Namespace: ref.Namespace}.String()}
+		}},
+		{&postgresv1.PostGresConnection{}, connectionSecretField, func(obj client.Object) []string {
+			var keys []string
+			for _, key := range postgres.ReferencedSecrets(obj.(*postgresv1.PostGresConnection)) {
+				keys = append(keys, key.String())
+			}
+			return keys
+		}},
+	}
+
+	for _, index := range indexes {
+		if err := indexer.IndexField(ctx, index.obj, index.field, index.extract); err != nil {
+			return fmt.Errorf("failed to index %T by %s: %w", index.obj, index.field, err)
+		}
+	}
+	return nil
+}
+
+// connectionsReferencing returns the connections that read the secret key
+func connectionsReferencing(ctx context.Context, c client.Client, key types.NamespacedName) ([]postgresv1.PostGresConnection, error) {
+	var connections postgresv1.PostGresConnectionList
+	if err := c.List(ctx, &connections, client.MatchingFields{connectionSecretField: key.String()}); err != nil {
+		return nil, fmt.Errorf("failed to list PostGresConnections: %w", err)
+	}
+	return connections.Items, nil
+}
+
+// databasesReplicatingTo returns the Databases that may replicate secrets to the namespace, by name or by
+// namespace selector
+func databasesReplicatingTo(ctx context.Context, c client.Client, namespace string) ([]postgresv1.Database, error) {
+	var databases []postgresv1.Database
+	for _, key := range []string{namespace, anyNamespace} {
+		var list postgresv1.DatabaseList
+		if err := c.List(ctx, &list, client.MatchingFields{replicaNamespaceField: key}); err != nil {
+			return nil, fmt.Errorf("failed to list Databases: %w", err)
+		}
+		for _, database := range list.Items {
+			if !slices.ContainsFunc(databases, func(d postgresv1.Database) bool { return d.UID == database.UID }) {
+				databases = append(databases, database)
+			}
+		}
+	}
+	return databases, nil
+}
@@ -0,0 +1,54 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	postgresv1 "github.com/silverswarm/pg-operator/api/v1"
+	"github.com/silverswarm/pg-operator/pkg/k8s"
+)
+
+// isPaused reports whether the paused annotation suspends the reconciliation of obj
+func isPaused(obj client.Object) bool {
+	return obj.GetAnnotations()[postgresv1.PausedAnnotation] == "true"
+}
+
+// pause records the Paused condition of a suspended resource and leaves everything else as it is. The status
+// is only written when the resource was not paused before, so a paused resource does not change at all.
+func pause(ctx context.Context, c client.Client, obj client.Object, conditions *[]metav1.Condition) (ctrl.Result, error) {
+	if meta.IsStatusConditionTrue(*conditions, "Paused") {
+		return ctrl.Result{}, nil
+	}
+
+	meta.SetStatusCondition(conditions, metav1.Condition{
+		Type:    "Paused",
+		Status:  metav1.ConditionTrue,
+		Reason:  "Annotated",
+		Message: fmt.Sprintf("Reconciliation is suspended by the %s annotation", postgresv1.PausedAnnotation),
+	})
+	if err := k8s.PatchStatus(ctx, c, obj); err != nil {
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{}, nil
+}
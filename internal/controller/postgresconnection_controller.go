@@ -19,11 +19,27 @@ package controller
 import (
 	"context"
 	"fmt"
+	"slices"
+	"strings"
+	"time"
 
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/workqueue"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	postgresv1 "github.com/silverswarm/pg-operator/api/v1"
 	"github.com/silverswarm/pg-operator/pkg/k8s"
@@ -31,12 +47,20 @@ import (
 	"github.com/silverswarm/pg-operator/pkg/utils"
 )
 
+// connectionFinalizer keeps a PostGresConnection until no Database, PostgresRole or PostgresClass references it
+const connectionFinalizer = "postgres.silverswarm.io/connection"
+
+// maxListedDatabases bounds the Databases listed in a connection's status, all of them are counted
+const maxListedDatabases = 100
+
 // PostGresConnectionReconciler reconciles a PostGresConnection object
 type PostGresConnectionReconciler struct {
 	client.Client
-	Scheme        *runtime.Scheme
-	pgClient      *postgres.Client
-	statusService *k8s.StatusService
+	Scheme         *runtime.Scheme
+	pgClient       *postgres.Client
+	clusterService *k8s.ClusterService
+	statusService  *k8s.StatusService
+	recorder       record.EventRecorder
 }
 
 // +kubebuilder:rbac:groups=postgres.silverswarm.io,resources=postgresconnections,verbs=get;list;watch;create;update;patch;delete
@@ -44,46 +68,445 @@ type PostGresConnectionReconciler struct {
 // +kubebuilder:rbac:groups=postgres.silverswarm.io,resources=postgresconnections/finalizers,verbs=update
 // +kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch
 // +kubebuilder:rbac:groups="",resources=services,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=configmaps,verbs=get;list;watch
+// +kubebuilder:rbac:groups=postgresql.cnpg.io,resources=clusters,verbs=get;list;watch
+// +kubebuilder:rbac:groups=postgresql.cnpg.io,resources=poolers,verbs=get
+// +kubebuilder:rbac:groups=postgres.silverswarm.io,resources=databases,verbs=get;list;watch
+// +kubebuilder:rbac:groups=postgres.silverswarm.io,resources=postgresroles,verbs=get;list;watch
+// +kubebuilder:rbac:groups=postgres.silverswarm.io,resources=postgresclasses,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=events,verbs=create;patch
 
 func (r *PostGresConnectionReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 	log := logf.FromContext(ctx)
+	ctx = postgres.WithApplicationName(ctx, "postgresconnection", req.NamespacedName)
 
 	var pgConn postgresv1.PostGresConnection
 	if err := r.Get(ctx, req.NamespacedName, &pgConn); err != nil {
 		return utils.HandleReconcileError(err, "Failed to get PostGresConnection", log)
 	}
+	ctx = k8s.WithStatusBase(ctx, &pgConn)
+
+	if isPaused(&pgConn) {
+		return pause(ctx, r.Client, &pgConn, &pgConn.Status.Conditions)
+	}
+	meta.RemoveStatusCondition(&pgConn.Status.Conditions, "Paused")
+
+	if !pgConn.DeletionTimestamp.IsZero() {
+		return r.finalize(ctx, &pgConn)
+	}
+
+	if !controllerutil.ContainsFinalizer(&pgConn, connectionFinalizer) {
+		controllerutil.AddFinalizer(&pgConn, connectionFinalizer)
+		if err := r.Update(ctx, &pgConn); err != nil {
+			return utils.HandleReconcileError(err, "Failed to add finalizer", log)
+		}
+	}
+
+	result, err := r.reconcileConnection(ctx, &pgConn)
+	return resync(result, err, pgConn.Spec.RevalidationInterval)
+}
+
+func (r *PostGresConnectionReconciler) reconcileConnection(ctx context.Context, pgConn *postgresv1.PostGresConnection) (ctrl.Result, error) {
+	pgConn.Status.LastChecked = &metav1.Time{Time: time.Now()}
+	pgConn.Status.LatencyMillis = nil
+
+	if err := r.recordDatabases(ctx, pgConn); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if err := r.checkCluster(ctx, pgConn); err != nil {
+		return r.statusService.UpdatePostGresConnectionStatus(ctx, pgConn, false, err.Error())
+	}
+
+	if err := r.checkPooler(ctx, pgConn); err != nil {
+		return r.statusService.UpdatePostGresConnectionStatus(ctx, pgConn, false, err.Error())
+	}
+
+	if err := r.validateConnection(ctx, pgConn); err != nil {
+		return r.statusService.UpdatePostGresConnectionStatus(ctx, pgConn, false, err.Error())
+	}
+
+	message := "Connection validated successfully"
+	if pgConn.Status.ReadOnly {
+		message = "Connection validated, read-only"
+	} else if missing := pgConn.Status.Privileges.Missing; len(missing) > 0 {
+		message = fmt.Sprintf("Connection validated, but the account is missing privileges: %s", strings.Join(missing, ", "))
+	}
+
+	return r.statusService.UpdatePostGresConnectionStatus(ctx, pgConn, true, message)
+}
+
+// finalize releases a deleted connection once nothing references it. Until then the connection stays in
+// use and is validated as before, so dependents can still finish their own cleanup through it.
+func (r *PostGresConnectionReconciler) finalize(ctx context.Context, pgConn *postgresv1.PostGresConnection) (ctrl.Result, error) {
+	log := logf.FromContext(ctx)
+
+	if !controllerutil.ContainsFinalizer(pgConn, connectionFinalizer) {
+		return ctrl.Result{}, nil
+	}
+
+	dependents, err := r.dependents(ctx, pgConn)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if len(dependents) > 0 {
+		meta.SetStatusCondition(&pgConn.Status.Conditions, metav1.Condition{
+			Type:    "DeletionBlocked",
+			Status:  metav1.ConditionTrue,
+			Reason:  "InUse",
+			Message: fmt.Sprintf("Still referenced by %s", strings.Join(dependents, ", ")),
+		})
+		return r.reconcileConnection(ctx, pgConn)
+	}
+
+	controllerutil.RemoveFinalizer(pgConn, connectionFinalizer)
+	if err := r.Update(ctx, pgConn); err != nil {
+		return utils.HandleReconcileError(err, "Failed to remove finalizer", log)
+	}
+
+	return ctrl.Result{}, nil
+}
+
+// recordDatabases lists the Databases bound to a connection in its status
+func (r *PostGresConnectionReconciler) recordDatabases(ctx context.Context, pgConn *postgresv1.PostGresConnection) error {
+	databases, err := boundDatabases(ctx, r.Client, pgConn)
+	if err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(databases))
+	for _, database := range databases {
+		names = append(names, client.ObjectKeyFromObject(&database).String())
+	}
+	slices.Sort(names)
+
+	pgConn.Status.DatabaseCount = int32(len(names))
+	pgConn.Status.Databases = names[:min(len(names), maxListedDatabases)]
+	return nil
+}
+
+// boundDatabases returns the Databases referencing a connection
+func boundDatabases(ctx context.Context, c client.Client, pgConn *postgresv1.PostGresConnection) ([]postgresv1.Database, error) {
+	var databases postgresv1.DatabaseList
+	if err := c.List(ctx, &databases, client.MatchingFields{connectionRefField: client.ObjectKeyFromObject(pgConn).String()}); err != nil {
+		return nil, fmt.Errorf("failed to list Databases: %w", err)
+	}
+	return databases.Items, nil
+}
+
+// dependents lists the Databases, PostgresRoles and PostgresClasses referencing a connection
+func (r *PostGresConnectionReconciler) dependents(ctx context.Context, pgConn *postgresv1.PostGresConnection) ([]string, error) {
+	key := client.ObjectKeyFromObject(pgConn).String()
+	var dependents []string
+
+	databases, err := boundDatabases(ctx, r.Client, pgConn)
+	if err != nil {
+		return nil, err
+	}
+	for _, database := range databases {
+		dependents = append(dependents, fmt.Sprintf("Database %s/%s", database.Namespace, database.Name))
+	}
+
+	var roles postgresv1.PostgresRoleList
+	if err := r.List(ctx, &roles, client.MatchingFields{connectionRefField: key}); err != nil {
+		return nil, fmt.Errorf("failed to list PostgresRoles: %w", err)
+	}
+	for _, role := range roles.Items {
+		dependents = append(dependents, fmt.Sprintf("PostgresRole %s/%s", role.Namespace, role.Name))
+	}
 
-	if err := r.validateConnection(ctx, &pgConn); err != nil {
-		return r.statusService.UpdatePostGresConnectionStatus(ctx, &pgConn, false, err.Error())
+	var classes postgresv1.PostgresClassList
+	if err := r.List(ctx, &classes, client.MatchingFields{connectionRefField: key}); err != nil {
+		return nil, fmt.Errorf("failed to list PostgresClasses: %w", err)
+	}
+	for _, class := range classes.Items {
+		dependents = append(dependents, fmt.Sprintf("PostgresClass %s", class.Name))
 	}
 
-	return r.statusService.UpdatePostGresConnectionStatus(ctx, &pgConn, true, "Connection validated successfully")
+	return dependents, nil
 }
 
 func (r *PostGresConnectionReconciler) validateConnection(ctx context.Context, pgConn *postgresv1.PostGresConnection) error {
 	db, err := r.pgClient.Connect(ctx, pgConn)
+	if err != nil && pgConn.Spec.Proxy != nil {
+		// Tell a proxy that is down or cannot reach its instance from a database refusing the connection
+		if proxyErr := r.pgClient.CheckProxy(ctx, pgConn); proxyErr != nil {
+			return proxyErr
+		}
+		return fmt.Errorf("failed to connect to database behind the auth proxy: %w", err)
+	}
 	if err != nil {
 		return fmt.Errorf("failed to connect to database: %w", err)
 	}
-	defer db.Close()
 
+	// Connect already pinged, this ping reuses the open connection and measures a single round trip
+	start := time.Now()
+	if err := db.PingContext(ctx); err != nil {
+		return fmt.Errorf("failed to ping database: %w", err)
+	}
+	latency := time.Since(start).Milliseconds()
+	pgConn.Status.LatencyMillis = &latency
+
+	server, err := r.pgClient.DescribeServer(ctx, db)
+	if err != nil {
+		return err
+	}
+	pgConn.Status.ServerVersion = server.Version
+	pgConn.Status.ServerVersionNum = server.VersionNum
+	pgConn.Status.Encryption = server.Encryption
+	pgConn.Status.ConnectionEndpoint = server.Endpoint
+	pgConn.Status.ReadOnly = pgConn.Spec.ReadOnly || server.ReadOnly
+
+	databases, roles, err := r.privilegeTargets(ctx, pgConn)
+	if err != nil {
+		return err
+	}
+	names := make([]string, 0, len(databases))
+	for _, database := range databases {
+		names = append(names, database.Spec.DatabaseName)
+	}
+
+	privileges, err := r.pgClient.CheckPrivileges(ctx, db, names, roles)
+	if err != nil {
+		return err
+	}
+	if !privileges.Superuser {
+		for _, database := range databases {
+			// Databases that do not exist yet or refuse connections have nothing to probe
+			if !database.Status.DatabaseCreated || isFrozen(&database) {
+				continue
+			}
+			target, err := r.pgClient.ConnectToDatabase(ctx, pgConn, database.Spec.DatabaseName)
+			if err != nil {
+				logf.FromContext(ctx).V(1).Info("Skipping schema privilege check", "database", database.Spec.DatabaseName, "reason", err.Error())
+				continue
+			}
+			missing, err := r.pgClient.CheckSchemaPrivileges(ctx, target, database.Spec.DatabaseName)
+			if err != nil {
+				return err
+			}
+			privileges.Missing = append(privileges.Missing, missing...)
+		}
+	}
+	pgConn.Status.Privileges = privileges
+
+	return nil
+}
+
+// privilegeTargets returns the Databases bound to a connection and the roles the connection account manages
+// through it that may exist already: the users of those Databases and the roles PostgresRoles grant membership in
+func (r *PostGresConnectionReconciler) privilegeTargets(ctx context.Context, pgConn *postgresv1.PostGresConnection) ([]postgresv1.Database, []string, error) {
+	databases, err := boundDatabases(ctx, r.Client, pgConn)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var roles []string
+	for _, database := range databases {
+		for _, user := range database.Spec.Users {
+			roles = append(roles, user.Name)
+		}
+	}
+
+	// Databases of different namespaces may share a databaseName
+	slices.SortFunc(databases, func(a, b postgresv1.Database) int {
+		return strings.Compare(a.Spec.DatabaseName, b.Spec.DatabaseName)
+	})
+	databases = slices.CompactFunc(databases, func(a, b postgresv1.Database) bool {
+		return a.Spec.DatabaseName == b.Spec.DatabaseName
+	})
+
+	var postgresRoles postgresv1.PostgresRoleList
+	if err := r.List(ctx, &postgresRoles, client.MatchingFields{connectionRefField: client.ObjectKeyFromObject(pgConn).String()}); err != nil {
+		return nil, nil, fmt.Errorf("failed to list PostgresRoles: %w", err)
+	}
+	for _, role := range postgresRoles.Items {
+		roles = append(roles, role.Spec.MemberOf...)
+	}
+
+	slices.Sort(roles)
+	return databases, slices.Compact(roles), nil
+}
+
+// checkCluster records the state of the CNPG cluster of connections with waitForCluster, failing while it
+// is not ready
+func (r *PostGresConnectionReconciler) checkCluster(ctx context.Context, pgConn *postgresv1.PostGresConnection) error {
+	if !pgConn.Spec.WaitForCluster || pgConn.Spec.ClusterName == "" {
+		pgConn.Status.Cluster = nil
+		return nil
+	}
+
+	name, namespace := pgConn.Spec.ClusterName, clusterNamespace(pgConn)
+	status, err := r.clusterService.GetClusterStatus(ctx, name, namespace)
+	switch {
+	case apierrors.IsNotFound(err):
+		pgConn.Status.Cluster = nil
+		return fmt.Errorf("CNPG cluster %s/%s not found", namespace, name)
+	case meta.IsNoMatchError(err):
+		return fmt.Errorf("waitForCluster is set but CNPG is not installed")
+	case err != nil:
+		return fmt.Errorf("failed to get CNPG cluster %s/%s: %w", namespace, name, err)
+	}
+
+	pgConn.Status.Cluster = status
+	if !status.Ready {
+		return fmt.Errorf("waiting for CNPG cluster %s/%s to become ready: %s", namespace, name, status.Phase)
+	}
+	return nil
+}
+
+// checkPooler refuses a named Pooler that management connections cannot go through
+func (r *PostGresConnectionReconciler) checkPooler(ctx context.Context, pgConn *postgresv1.PostGresConnection) error {
+	pooler := pgConn.Spec.Pooler
+	if pooler == nil || !pooler.Management || pooler.Name == "" || pooler.Host != "" {
+		return nil
+	}
+
+	namespace := clusterNamespace(pgConn)
+	mode, err := r.clusterService.GetPoolMode(ctx, pooler.Name, namespace)
+	switch {
+	case apierrors.IsNotFound(err):
+		return fmt.Errorf("CNPG pooler %s/%s not found", namespace, pooler.Name)
+	case meta.IsNoMatchError(err):
+		return fmt.Errorf("pooler management is set but CNPG is not installed")
+	case err != nil:
+		return fmt.Errorf("failed to get CNPG pooler %s/%s: %w", namespace, pooler.Name, err)
+	}
+
+	if mode != "session" {
+		return fmt.Errorf("CNPG pooler %s/%s uses %s pooling, management connections need session pooling", namespace, pooler.Name, mode)
+	}
 	return nil
 }
 
+// connectionsForCluster maps a CNPG Cluster to the connections waiting for it
+func (r *PostGresConnectionReconciler) connectionsForCluster(ctx context.Context, obj client.Object) []reconcile.Request {
+	var connections postgresv1.PostGresConnectionList
+	if err := r.List(ctx, &connections); err != nil {
+		logf.FromContext(ctx).Error(err, "Failed to list PostGresConnections")
+		return nil
+	}
+
+	var requests []reconcile.Request
+	for _, pgConn := range connections.Items {
+		if pgConn.Spec.WaitForCluster && pgConn.Spec.ClusterName == obj.GetName() && clusterNamespace(&pgConn) == obj.GetNamespace() {
+			requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&pgConn)})
+		}
+	}
+	return requests
+}
+
+// connectionsForSecret maps a secret to the connections reading their credentials, URI or CA bundle from it,
+// so e.g. a password rotated by CNPG is picked up right away
+func (r *PostGresConnectionReconciler) connectionsForSecret(ctx context.Context, obj client.Object) []reconcile.Request {
+	connections, err := connectionsReferencing(ctx, r.Client, client.ObjectKeyFromObject(obj))
+	if err != nil {
+		logf.FromContext(ctx).Error(err, "Failed to list PostGresConnections")
+		return nil
+	}
+
+	var requests []reconcile.Request
+	for _, pgConn := range connections {
+		requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&pgConn)})
+	}
+	return requests
+}
+
+// databaseEventHandler enqueues the connections a Database binds to or leaves, keeping the Databases in
+// their status current. Status updates of Databases leave the reference alone and are ignored.
+func databaseEventHandler() handler.EventHandler {
+	enqueue := func(q workqueue.TypedRateLimitingInterface[reconcile.Request], obj client.Object) {
+		if database, ok := obj.(*postgresv1.Database); ok {
+			q.Add(reconcile.Request{NamespacedName: connectionKey(database.Spec.ConnectionRef, database.Namespace)})
+		}
+	}
+
+	return handler.Funcs{
+		CreateFunc: func(_ context.Context, e event.CreateEvent, q workqueue.TypedRateLimitingInterface[reconcile.Request]) {
+			enqueue(q, e.Object)
+		},
+		UpdateFunc: func(_ context.Context, e event.UpdateEvent, q workqueue.TypedRateLimitingInterface[reconcile.Request]) {
+			oldDatabase, oldOK := e.ObjectOld.(*postgresv1.Database)
+			newDatabase, newOK := e.ObjectNew.(*postgresv1.Database)
+			if oldOK && newOK && connectionKey(oldDatabase.Spec.ConnectionRef, oldDatabase.Namespace) ==
+				connectionKey(newDatabase.Spec.ConnectionRef, newDatabase.Namespace) {
+				return
+			}
+			enqueue(q, e.ObjectOld)
+			enqueue(q, e.ObjectNew)
+		},
+		DeleteFunc: func(_ context.Context, e event.DeleteEvent, q workqueue.TypedRateLimitingInterface[reconcile.Request]) {
+			enqueue(q, e.Object)
+		},
+	}
+}
+
+// connectionForDependent maps a PostgresRole or PostgresClass to its connection while that is
+// being deleted, so the deletion finishes as soon as the last dependent is gone
+func (r *PostGresConnectionReconciler) connectionForDependent(ctx context.Context, obj client.Object) []reconcile.Request {
+	var key types.NamespacedName
+	switch dependent := obj.(type) {
+	case *postgresv1.PostgresRole:
+		key = connectionKey(dependent.Spec.ConnectionRef, dependent.Namespace)
+	case *postgresv1.PostgresClass:
+		key = types.NamespacedName{Name: dependent.Spec.ConnectionRef.Name, Namespace: dependent.Spec.ConnectionRef.Namespace}
+	default:
+		return nil
+	}
+
+	var pgConn postgresv1.PostGresConnection
+	if err := r.Get(ctx, key, &pgConn); err != nil || pgConn.DeletionTimestamp.IsZero() {
+		return nil
+	}
+	return []reconcile.Request{{NamespacedName: key}}
+}
+
+// connectionKey resolves a connection reference, defaulting to the referencing object's namespace
+func connectionKey(ref postgresv1.ConnectionReference, namespace string) types.NamespacedName {
+	if ref.Namespace != "" {
+		namespace = ref.Namespace
+	}
+	return types.NamespacedName{Name: ref.Name, Namespace: namespace}
+}
+
+func clusterNamespace(pgConn *postgresv1.PostGresConnection) string {
+	if pgConn.Spec.ClusterNamespace != "" {
+		return pgConn.Spec.ClusterNamespace
+	}
+	return pgConn.Namespace
+}
+
 // NewPostGresConnectionReconciler creates a new PostGresConnectionReconciler with all required services
 func NewPostGresConnectionReconciler(client client.Client, scheme *runtime.Scheme) *PostGresConnectionReconciler {
 	return &PostGresConnectionReconciler{
-		Client:        client,
-		Scheme:        scheme,
-		pgClient:      postgres.NewClient(client),
-		statusService: k8s.NewStatusService(client),
+		Client:         client,
+		Scheme:         scheme,
+		pgClient:       postgres.NewClient(client),
+		clusterService: k8s.NewClusterService(client),
+		statusService:  k8s.NewStatusService(client),
 	}
 }
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *PostGresConnectionReconciler) SetupWithManager(mgr ctrl.Manager) error {
-	return ctrl.NewControllerManagedBy(mgr).
-		For(&postgresv1.PostGresConnection{}).
-		Named("postgresconnection").
-		Complete(r)
+	r.recorder = mgr.GetEventRecorderFor("pg-operator")
+	r.statusService.SetRecorder(r.recorder)
+
+	// Every check records lastChecked, status updates must not trigger the next one. Annotations such as
+	// the paused annotation leave the generation alone.
+	controller := ctrl.NewControllerManagedBy(mgr).
+		For(&postgresv1.PostGresConnection{}, builder.WithPredicates(
+			predicate.Or(predicate.GenerationChangedPredicate{}, predicate.AnnotationChangedPredicate{}))).
+		Watches(&corev1.Secret{}, handler.EnqueueRequestsFromMapFunc(r.connectionsForSecret)).
+		Watches(&postgresv1.Database{}, databaseEventHandler()).
+		Watches(&postgresv1.PostgresRole{}, handler.EnqueueRequestsFromMapFunc(r.connectionForDependent)).
+		Watches(&postgresv1.PostgresClass{}, handler.EnqueueRequestsFromMapFunc(r.connectionForDependent)).
+		Named("postgresconnection")
+
+	// Clusters are only watched where CNPG is installed, connections to other servers work without it
+	if _, err := mgr.GetRESTMapper().RESTMapping(k8s.ClusterGVK.GroupKind(), k8s.ClusterGVK.Version); err == nil {
+		controller = controller.Watches(k8s.NewCluster(), handler.EnqueueRequestsFromMapFunc(r.connectionsForCluster))
+	}
+
+	return controller.Complete(r)
 }
@@ -0,0 +1,193 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	postgresv1 "github.com/silverswarm/pg-operator/api/v1"
+	"github.com/silverswarm/pg-operator/pkg/k8s"
+	"github.com/silverswarm/pg-operator/pkg/postgres"
+	"github.com/silverswarm/pg-operator/pkg/utils"
+)
+
+const roleFinalizer = "postgres.silverswarm.io/role"
+
+// PostgresRoleReconciler reconciles a PostgresRole object
+type PostgresRoleReconciler struct {
+	client.Client
+	Scheme        *runtime.Scheme
+	pgClient      *postgres.Client
+	roleService   *postgres.RoleService
+	statusService *k8s.StatusService
+	recorder      record.EventRecorder
+}
+
+// +kubebuilder:rbac:groups=postgres.silverswarm.io,resources=postgresroles,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=postgres.silverswarm.io,resources=postgresroles/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=postgres.silverswarm.io,resources=postgresroles/finalizers,verbs=update
+// +kubebuilder:rbac:groups=postgres.silverswarm.io,resources=postgresconnections,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+
+func (r *PostgresRoleReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := logf.FromContext(ctx)
+	ctx = postgres.WithApplicationName(ctx, "postgresrole", req.NamespacedName)
+
+	var role postgresv1.PostgresRole
+	if err := r.Get(ctx, req.NamespacedName, &role); err != nil {
+		return utils.HandleReconcileError(err, "Failed to get PostgresRole", log)
+	}
+	ctx = k8s.WithStatusBase(ctx, &role)
+	ctx = postgres.WithAudit(ctx, r.recorder, &role)
+
+	if !role.DeletionTimestamp.IsZero() {
+		return r.finalize(ctx, &role)
+	}
+
+	if !controllerutil.ContainsFinalizer(&role, roleFinalizer) {
+		controllerutil.AddFinalizer(&role, roleFinalizer)
+		if err := r.Update(ctx, &role); err != nil {
+			return utils.HandleReconcileError(err, "Failed to add finalizer", log)
+		}
+	}
+
+	pgConn, err := getConnection(ctx, r.Client, role.Spec.ConnectionRef, role.Namespace)
+	if err != nil {
+		return r.statusService.UpdatePostgresRoleStatus(ctx, &role, false, err.Error())
+	}
+
+	if err := checkConnection(pgConn); err != nil {
+		return r.statusService.UpdatePostgresRoleStatus(ctx, &role, false, err.Error())
+	}
+
+	db, err := r.pgClient.Connect(ctx, pgConn)
+	if err != nil {
+		return r.statusService.UpdatePostgresRoleStatus(ctx, &role, false, fmt.Sprintf("Failed to connect to database: %v", err))
+	}
+
+	unlock, err := r.pgClient.LockDDL(ctx, pgConn)
+	if err != nil {
+		return r.statusService.UpdatePostgresRoleStatus(ctx, &role, false, err.Error())
+	}
+	defer unlock()
+
+	parameters, err := r.roleService.EnsureRole(ctx, db, &role)
+	role.Status.ConfigParameters = parameters
+	if err != nil {
+		return r.statusService.UpdatePostgresRoleStatus(ctx, &role, false, fmt.Sprintf("Failed to ensure role: %v", err))
+	}
+	role.Status.RoleCreated = true
+
+	memberOf, err := r.roleService.EnsureMemberships(ctx, db, role.Spec.RoleName, role.Spec.MemberOf, role.Status.MemberOf)
+	if err != nil {
+		return r.statusService.UpdatePostgresRoleStatus(ctx, &role, false, fmt.Sprintf("Failed to ensure memberships: %v", err))
+	}
+	role.Status.MemberOf = memberOf
+
+	result, err := r.statusService.UpdatePostgresRoleStatus(ctx, &role, true, "Role ready")
+	return resync(result, err, role.Spec.ResyncInterval)
+}
+
+func (r *PostgresRoleReconciler) finalize(ctx context.Context, role *postgresv1.PostgresRole) (ctrl.Result, error) {
+	log := logf.FromContext(ctx)
+
+	if !controllerutil.ContainsFinalizer(role, roleFinalizer) {
+		return ctrl.Result{}, nil
+	}
+
+	if role.Spec.DeletionPolicy == postgresv1.RoleDeletionDrop && role.Status.RoleCreated {
+		err := r.drop(ctx, role)
+		switch {
+		case apierrors.IsNotFound(err):
+			log.Info("Connection of deleted PostgresRole no longer exists, skipping drop", "role", role.Spec.RoleName)
+		case err != nil:
+			log.Error(err, "Failed to drop role for deleted PostgresRole")
+			return ctrl.Result{RequeueAfter: k8s.RetryInterval(role)}, nil
+		}
+	}
+
+	controllerutil.RemoveFinalizer(role, roleFinalizer)
+	if err := r.Update(ctx, role); err != nil {
+		return utils.HandleReconcileError(err, "Failed to remove finalizer", log)
+	}
+
+	return ctrl.Result{}, nil
+}
+
+// drop drops the role unless it no longer carries the ownership marker of the resource
+func (r *PostgresRoleReconciler) drop(ctx context.Context, role *postgresv1.PostgresRole) error {
+	pgConn, err := getConnection(ctx, r.Client, role.Spec.ConnectionRef, role.Namespace)
+	if err != nil {
+		return err
+	}
+
+	if err := checkConnection(pgConn); err != nil {
+		return err
+	}
+
+	db, err := r.pgClient.Connect(ctx, pgConn)
+	if err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+
+	unlock, err := r.pgClient.LockDDL(ctx, pgConn)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	managed, err := r.roleService.ManagedBy(ctx, db, role)
+	if err != nil {
+		return err
+	}
+	if !managed {
+		logf.FromContext(ctx).Info("Role is not managed by the deleted PostgresRole, retaining it", "role", role.Spec.RoleName)
+		return nil
+	}
+
+	return r.roleService.DropRole(ctx, db, role.Spec.RoleName)
+}
+
+// NewPostgresRoleReconciler creates a new PostgresRoleReconciler with all required services
+func NewPostgresRoleReconciler(client client.Client, scheme *runtime.Scheme) *PostgresRoleReconciler {
+	pgClient := postgres.NewClient(client)
+	return &PostgresRoleReconciler{
+		Client:        client,
+		Scheme:        scheme,
+		pgClient:      pgClient,
+		roleService:   postgres.NewRoleService(pgClient),
+		statusService: k8s.NewStatusService(client),
+	}
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *PostgresRoleReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	r.recorder = mgr.GetEventRecorderFor("pg-operator")
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&postgresv1.PostgresRole{}).
+		Named("postgresrole").
+		Complete(r)
+}
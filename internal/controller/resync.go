@@ -0,0 +1,46 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+// defaultResyncInterval is how often ready resources without a resyncInterval of their own are reconciled
+// again. Zero reconciles them only when they or what they watch change.
+var defaultResyncInterval time.Duration
+
+// SetResyncInterval sets the operator-wide default of resyncInterval
+func SetResyncInterval(interval time.Duration) {
+	defaultResyncInterval = interval
+}
+
+// resync requeues a resource that was reconciled successfully after its resyncInterval, falling back to the
+// operator-wide default, unless the result already asks to come back sooner
+func resync(result ctrl.Result, err error, interval *metav1.Duration) (ctrl.Result, error) {
+	resyncAfter := defaultResyncInterval
+	if interval != nil {
+		resyncAfter = interval.Duration
+	}
+	if err == nil && resyncAfter > 0 && (result.RequeueAfter == 0 || resyncAfter < result.RequeueAfter) {
+		result.RequeueAfter = resyncAfter
+	}
+	return result, err
+}
@@ -0,0 +1,224 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	postgresv1 "github.com/silverswarm/pg-operator/api/v1"
+	"github.com/silverswarm/pg-operator/pkg/k8s"
+	"github.com/silverswarm/pg-operator/pkg/postgres"
+	"github.com/silverswarm/pg-operator/pkg/utils"
+)
+
+const schemaFinalizer = "postgres.silverswarm.io/schema"
+
+// SchemaReconciler reconciles a Schema object
+type SchemaReconciler struct {
+	client.Client
+	Scheme        *runtime.Scheme
+	pgClient      *postgres.Client
+	schemaService *postgres.SchemaService
+	statusService *k8s.StatusService
+	recorder      record.EventRecorder
+}
+
+// +kubebuilder:rbac:groups=postgres.silverswarm.io,resources=schemas,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=postgres.silverswarm.io,resources=schemas/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=postgres.silverswarm.io,resources=schemas/finalizers,verbs=update
+// +kubebuilder:rbac:groups=postgres.silverswarm.io,resources=databases,verbs=get;list;watch
+// +kubebuilder:rbac:groups=postgres.silverswarm.io,resources=postgresconnections,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+
+func (r *SchemaReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := logf.FromContext(ctx)
+	ctx = postgres.WithApplicationName(ctx, "schema", req.NamespacedName)
+
+	var schema postgresv1.Schema
+	if err := r.Get(ctx, req.NamespacedName, &schema); err != nil {
+		return utils.HandleReconcileError(err, "Failed to get Schema", log)
+	}
+	ctx = k8s.WithStatusBase(ctx, &schema)
+	ctx = postgres.WithAudit(ctx, r.recorder, &schema)
+
+	if !schema.DeletionTimestamp.IsZero() {
+		return r.finalize(ctx, &schema)
+	}
+
+	if !controllerutil.ContainsFinalizer(&schema, schemaFinalizer) {
+		controllerutil.AddFinalizer(&schema, schemaFinalizer)
+		if err := r.Update(ctx, &schema); err != nil {
+			return utils.HandleReconcileError(err, "Failed to add finalizer", log)
+		}
+	}
+
+	database, pgConn, err := r.resolveTarget(ctx, &schema)
+	if errors.Is(err, errFrozen) {
+		return waitWhileFrozen(ctx, r.Client, &schema, &schema.Status.Conditions, err)
+	}
+	if err != nil {
+		return r.statusService.UpdateSchemaStatus(ctx, &schema, false, err.Error())
+	}
+	meta.RemoveStatusCondition(&schema.Status.Conditions, conditionFrozen)
+
+	db, err := r.pgClient.ConnectToDatabase(ctx, pgConn, database.Spec.DatabaseName)
+	if err != nil {
+		return r.statusService.UpdateSchemaStatus(ctx, &schema, false, fmt.Sprintf("Failed to connect to database: %v", err))
+	}
+
+	unlock, err := r.pgClient.LockDDL(ctx, pgConn)
+	if err != nil {
+		return r.statusService.UpdateSchemaStatus(ctx, &schema, false, err.Error())
+	}
+	defer unlock()
+
+	owner, created, err := r.schemaService.EnsureSchema(ctx, db, &schema)
+	if owner != "" {
+		schema.Status.SchemaCreated = true
+		schema.Status.CreatedByOperator = created
+		schema.Status.Owner = owner
+	}
+	if err != nil {
+		return r.statusService.UpdateSchemaStatus(ctx, &schema, false, fmt.Sprintf("Failed to ensure schema: %v", err))
+	}
+	schema.Status.AppliedGrants = schema.Spec.Grants
+
+	result, err := r.statusService.UpdateSchemaStatus(ctx, &schema, true, "Schema ready")
+	return resync(result, err, schema.Spec.ResyncInterval)
+}
+
+func (r *SchemaReconciler) finalize(ctx context.Context, schema *postgresv1.Schema) (ctrl.Result, error) {
+	log := logf.FromContext(ctx)
+
+	if !controllerutil.ContainsFinalizer(schema, schemaFinalizer) {
+		return ctrl.Result{}, nil
+	}
+
+	if schema.Spec.DropCascadeOnDelete && schema.Status.CreatedByOperator {
+		err := r.drop(ctx, schema)
+		switch {
+		case apierrors.IsNotFound(err):
+			log.Info("Database of deleted Schema no longer exists, skipping drop", "schema", schema.Spec.SchemaName)
+		case errors.Is(err, errFrozen):
+			return waitWhileFrozen(ctx, r.Client, schema, &schema.Status.Conditions, err)
+		case err != nil:
+			log.Error(err, "Failed to drop schema for deleted Schema")
+			return ctrl.Result{RequeueAfter: k8s.RetryInterval(schema)}, nil
+		}
+	}
+
+	controllerutil.RemoveFinalizer(schema, schemaFinalizer)
+	if err := r.Update(ctx, schema); err != nil {
+		return utils.HandleReconcileError(err, "Failed to remove finalizer", log)
+	}
+
+	return ctrl.Result{}, nil
+}
+
+func (r *SchemaReconciler) drop(ctx context.Context, schema *postgresv1.Schema) error {
+	database, pgConn, err := r.resolveTarget(ctx, schema)
+	if err != nil {
+		return err
+	}
+
+	db, err := r.pgClient.ConnectToDatabase(ctx, pgConn, database.Spec.DatabaseName)
+	if err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+
+	unlock, err := r.pgClient.LockDDL(ctx, pgConn)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	// The schema may have been dropped and recreated by someone else since
+	created, err := r.schemaService.CreatedBy(ctx, db, schema)
+	if err != nil {
+		return err
+	}
+	if !created {
+		logf.FromContext(ctx).Info("Schema was not created by this resource, retaining it", "schema", schema.Spec.SchemaName)
+		return nil
+	}
+
+	if err := r.schemaService.DropSchema(ctx, db, schema.Spec.SchemaName); err != nil {
+		return err
+	}
+	logf.FromContext(ctx).Info("Dropped schema", "schema", schema.Spec.SchemaName)
+	return nil
+}
+
+func (r *SchemaReconciler) resolveTarget(ctx context.Context, schema *postgresv1.Schema) (*postgresv1.Database, *postgresv1.PostGresConnection, error) {
+	var database postgresv1.Database
+	key := types.NamespacedName{Name: schema.Spec.DatabaseRef.Name, Namespace: schema.Namespace}
+	if err := r.Get(ctx, key, &database); err != nil {
+		return nil, nil, fmt.Errorf("failed to get Database %s: %w", key, err)
+	}
+
+	if !database.Status.DatabaseCreated {
+		return nil, nil, fmt.Errorf("database %s has not been created yet", database.Spec.DatabaseName)
+	}
+
+	if err := checkFrozen(&database); err != nil {
+		return nil, nil, err
+	}
+
+	pgConn, err := getConnectionForDatabase(ctx, r.Client, &database)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := checkConnection(pgConn); err != nil {
+		return nil, nil, err
+	}
+
+	return &database, pgConn, nil
+}
+
+// NewSchemaReconciler creates a new SchemaReconciler with all required services
+func NewSchemaReconciler(client client.Client, scheme *runtime.Scheme) *SchemaReconciler {
+	pgClient := postgres.NewClient(client)
+	return &SchemaReconciler{
+		Client:        client,
+		Scheme:        scheme,
+		pgClient:      pgClient,
+		schemaService: postgres.NewSchemaService(pgClient),
+		statusService: k8s.NewStatusService(client),
+	}
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *SchemaReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	r.recorder = mgr.GetEventRecorderFor("pg-operator")
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&postgresv1.Schema{}).
+		Named("schema").
+		Complete(r)
+}
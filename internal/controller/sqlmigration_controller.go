@@ -0,0 +1,220 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	postgresv1 "github.com/silverswarm/pg-operator/api/v1"
+	"github.com/silverswarm/pg-operator/pkg/k8s"
+	"github.com/silverswarm/pg-operator/pkg/postgres"
+	"github.com/silverswarm/pg-operator/pkg/utils"
+)
+
+// SQLMigrationReconciler reconciles a SQLMigration object
+type SQLMigrationReconciler struct {
+	client.Client
+	Scheme           *runtime.Scheme
+	pgClient         *postgres.Client
+	migrationService *postgres.MigrationService
+	statusService    *k8s.StatusService
+}
+
+// +kubebuilder:rbac:groups=postgres.silverswarm.io,resources=sqlmigrations,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=postgres.silverswarm.io,resources=sqlmigrations/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=postgres.silverswarm.io,resources=sqlmigrations/finalizers,verbs=update
+// +kubebuilder:rbac:groups=postgres.silverswarm.io,resources=databases,verbs=get;list;watch
+// +kubebuilder:rbac:groups=postgres.silverswarm.io,resources=postgresconnections,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=configmaps,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch
+
+func (r *SQLMigrationReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := logf.FromContext(ctx)
+	ctx = postgres.WithApplicationName(ctx, "sqlmigration", req.NamespacedName)
+
+	var migration postgresv1.SQLMigration
+	if err := r.Get(ctx, req.NamespacedName, &migration); err != nil {
+		return utils.HandleReconcileError(err, "Failed to get SQLMigration", log)
+	}
+	ctx = k8s.WithStatusBase(ctx, &migration)
+
+	scripts, err := r.loadScripts(ctx, &migration)
+	if err != nil {
+		return r.statusService.UpdateSQLMigrationStatus(ctx, &migration, false, err.Error())
+	}
+
+	var database postgresv1.Database
+	key := types.NamespacedName{Name: migration.Spec.DatabaseRef.Name, Namespace: migration.Namespace}
+	if err := r.Get(ctx, key, &database); err != nil {
+		return r.statusService.UpdateSQLMigrationStatus(ctx, &migration, false, fmt.Sprintf("Failed to get Database %s: %v", key, err))
+	}
+
+	if !database.Status.DatabaseCreated {
+		return r.statusService.UpdateSQLMigrationStatus(ctx, &migration, false, "Database has not been created yet")
+	}
+	if err := checkFrozen(&database); err != nil {
+		return waitWhileFrozen(ctx, r.Client, &migration, &migration.Status.Conditions, err)
+	}
+	meta.RemoveStatusCondition(&migration.Status.Conditions, conditionFrozen)
+
+	pgConn, err := getConnectionForDatabase(ctx, r.Client, &database)
+	if err != nil {
+		return r.statusService.UpdateSQLMigrationStatus(ctx, &migration, false, err.Error())
+	}
+
+	if err := checkConnection(pgConn); err != nil {
+		return r.statusService.UpdateSQLMigrationStatus(ctx, &migration, false, err.Error())
+	}
+
+	db, err := r.pgClient.ConnectToDatabase(ctx, pgConn, database.Spec.DatabaseName)
+	if err != nil {
+		return r.statusService.UpdateSQLMigrationStatus(ctx, &migration, false, fmt.Sprintf("Failed to connect to database: %v", err))
+	}
+
+	unlock, err := r.pgClient.LockDDL(ctx, pgConn)
+	if err != nil {
+		return r.statusService.UpdateSQLMigrationStatus(ctx, &migration, false, err.Error())
+	}
+	defer unlock()
+
+	trackingTable := migration.Spec.TrackingTable
+	if trackingTable == "" {
+		trackingTable = "pg_operator_migrations"
+	}
+
+	applied, err := r.migrationService.ApplyMigrations(ctx, db, postgres.DatabaseOwner(&database), trackingTable, scripts)
+	migration.Status.AppliedMigrations = applied
+	if len(applied) > 0 {
+		migration.Status.LastAppliedVersion = applied[len(applied)-1].Version
+	}
+	if err != nil {
+		return r.statusService.UpdateSQLMigrationStatus(ctx, &migration, false, err.Error())
+	}
+
+	return r.statusService.UpdateSQLMigrationStatus(ctx, &migration, true,
+		fmt.Sprintf("%d migration(s) applied", len(applied)))
+}
+
+// loadScripts collects the scripts of every source, ordered by version
+func (r *SQLMigrationReconciler) loadScripts(ctx context.Context, migration *postgresv1.SQLMigration) ([]postgres.MigrationScript, error) {
+	sources := make(map[string]string)
+	var scripts []postgres.MigrationScript
+
+	add := func(source, version, sql string) error {
+		if existing, ok := sources[version]; ok {
+			return fmt.Errorf("migration %s is defined in both %s and %s", version, existing, source)
+		}
+		sources[version] = source
+		scripts = append(scripts, postgres.MigrationScript{Version: version, SQL: sql})
+		return nil
+	}
+
+	for _, source := range migration.Spec.Sources {
+		switch {
+		case source.ConfigMapRef != nil:
+			var configMap corev1.ConfigMap
+			key := types.NamespacedName{Name: source.ConfigMapRef.Name, Namespace: migration.Namespace}
+			if err := r.Get(ctx, key, &configMap); err != nil {
+				return nil, fmt.Errorf("failed to get ConfigMap %s: %w", key, err)
+			}
+			for version, sql := range configMap.Data {
+				if err := add("ConfigMap "+configMap.Name, version, sql); err != nil {
+					return nil, err
+				}
+			}
+		case source.SecretRef != nil:
+			var secret corev1.Secret
+			key := types.NamespacedName{Name: source.SecretRef.Name, Namespace: migration.Namespace}
+			if err := r.Get(ctx, key, &secret); err != nil {
+				return nil, fmt.Errorf("failed to get Secret %s: %w", key, err)
+			}
+			for version, sql := range secret.Data {
+				if err := add("Secret "+secret.Name, version, string(sql)); err != nil {
+					return nil, err
+				}
+			}
+		default:
+			return nil, fmt.Errorf("every source must set configMapRef or secretRef")
+		}
+	}
+
+	sort.Slice(scripts, func(i, j int) bool { return scripts[i].Version < scripts[j].Version })
+	return scripts, nil
+}
+
+// migrationsForSource maps a ConfigMap or Secret to the SQLMigrations that read scripts from it
+func (r *SQLMigrationReconciler) migrationsForSource(ctx context.Context, obj client.Object) []reconcile.Request {
+	var migrations postgresv1.SQLMigrationList
+	if err := r.List(ctx, &migrations, client.InNamespace(obj.GetNamespace())); err != nil {
+		logf.FromContext(ctx).Error(err, "Failed to list SQLMigrations")
+		return nil
+	}
+
+	_, isSecret := obj.(*corev1.Secret)
+
+	var requests []reconcile.Request
+	for _, migration := range migrations.Items {
+		for _, source := range migration.Spec.Sources {
+			ref := source.ConfigMapRef
+			if isSecret {
+				ref = source.SecretRef
+			}
+			if ref != nil && ref.Name == obj.GetName() {
+				requests = append(requests, reconcile.Request{
+					NamespacedName: types.NamespacedName{Name: migration.Name, Namespace: migration.Namespace},
+				})
+				break
+			}
+		}
+	}
+
+	return requests
+}
+
+// NewSQLMigrationReconciler creates a new SQLMigrationReconciler with all required services
+func NewSQLMigrationReconciler(client client.Client, scheme *runtime.Scheme) *SQLMigrationReconciler {
+	pgClient := postgres.NewClient(client)
+	return &SQLMigrationReconciler{
+		Client:           client,
+		Scheme:           scheme,
+		pgClient:         pgClient,
+		migrationService: postgres.NewMigrationService(pgClient),
+		statusService:    k8s.NewStatusService(client),
+	}
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *SQLMigrationReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&postgresv1.SQLMigration{}).
+		Watches(&corev1.ConfigMap{}, handler.EnqueueRequestsFromMapFunc(r.migrationsForSource)).
+		Watches(&corev1.Secret{}, handler.EnqueueRequestsFromMapFunc(r.migrationsForSource)).
+		Named("sqlmigration").
+		Complete(r)
+}
@@ -0,0 +1,339 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	postgresv1 "github.com/silverswarm/pg-operator/api/v1"
+	"github.com/silverswarm/pg-operator/pkg/k8s"
+	"github.com/silverswarm/pg-operator/pkg/postgres"
+	"github.com/silverswarm/pg-operator/pkg/utils"
+)
+
+const temporaryAccessFinalizer = "postgres.silverswarm.io/temporary-access"
+
+// TemporaryAccessRequestReconciler reconciles a TemporaryAccessRequest object
+type TemporaryAccessRequestReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+	// PasswordPolicy controls the generated passwords
+	PasswordPolicy utils.PasswordPolicy
+
+	pgClient      *postgres.Client
+	userService   *postgres.UserService
+	secretService *k8s.SecretService
+	statusService *k8s.StatusService
+}
+
+// +kubebuilder:rbac:groups=postgres.silverswarm.io,resources=temporaryaccessrequests,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=postgres.silverswarm.io,resources=temporaryaccessrequests/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=postgres.silverswarm.io,resources=temporaryaccessrequests/finalizers,verbs=update
+// +kubebuilder:rbac:groups=postgres.silverswarm.io,resources=databases,verbs=get;list;watch
+// +kubebuilder:rbac:groups=postgres.silverswarm.io,resources=postgresconnections,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch;create;update;patch;delete
+
+func (r *TemporaryAccessRequestReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := logf.FromContext(ctx)
+	ctx = postgres.WithApplicationName(ctx, "temporaryaccessrequest", req.NamespacedName)
+
+	var request postgresv1.TemporaryAccessRequest
+	if err := r.Get(ctx, req.NamespacedName, &request); err != nil {
+		return utils.HandleReconcileError(err, "Failed to get TemporaryAccessRequest", log)
+	}
+	ctx = k8s.WithStatusBase(ctx, &request)
+
+	if !request.DeletionTimestamp.IsZero() {
+		return r.finalize(ctx, &request)
+	}
+
+	if !controllerutil.ContainsFinalizer(&request, temporaryAccessFinalizer) {
+		controllerutil.AddFinalizer(&request, temporaryAccessFinalizer)
+		if err := r.Update(ctx, &request); err != nil {
+			return utils.HandleReconcileError(err, "Failed to add finalizer", log)
+		}
+	}
+
+	switch request.Status.Phase {
+	case postgresv1.TemporaryAccessExpired, postgresv1.TemporaryAccessFailed:
+		return ctrl.Result{}, nil
+	case postgresv1.TemporaryAccessActive:
+		if time.Now().Before(request.Status.ExpiresAt.Time) {
+			return ctrl.Result{RequeueAfter: time.Until(request.Status.ExpiresAt.Time)}, nil
+		}
+		if err := r.revoke(ctx, &request); errors.Is(err, errFrozen) {
+			// The role expired through VALID UNTIL already, it is dropped once the database is thawed
+			return waitWhileFrozen(ctx, r.Client, &request, &request.Status.Conditions, err)
+		} else if err != nil {
+			return r.statusService.UpdateTemporaryAccessRequestStatus(ctx, &request, fmt.Sprintf("Failed to revoke expired access: %v", err))
+		}
+		meta.RemoveStatusCondition(&request.Status.Conditions, conditionFrozen)
+		request.Status.Phase = postgresv1.TemporaryAccessExpired
+		request.Status.RevokedAt = ptrNow()
+		r.audit(ctx, &request, "Revoked", fmt.Sprintf("TTL expired, dropped role %s and deleted secret %s", request.Status.RoleName, request.Status.SecretName))
+		return r.statusService.UpdateTemporaryAccessRequestStatus(ctx, &request, "Access expired and was revoked")
+	}
+
+	if request.Spec.TTL.Duration <= 0 {
+		request.Status.Phase = postgresv1.TemporaryAccessFailed
+		r.audit(ctx, &request, "Rejected", "ttl must be greater than zero")
+		return r.statusService.UpdateTemporaryAccessRequestStatus(ctx, &request, "ttl must be greater than zero")
+	}
+
+	if request.Status.Phase != postgresv1.TemporaryAccessPending {
+		request.Status.Phase = postgresv1.TemporaryAccessPending
+		request.Status.RoleName = temporaryRoleName(&request)
+		request.Status.SecretName = temporarySecretName(&request)
+		// Recorded before the role is created, so a retry adopts and the finalizer drops a role created by an
+		// attempt whose status update was lost
+		if err := k8s.PatchStatus(ctx, r.Client, &request); err != nil {
+			return utils.HandleReconcileError(err, "Failed to update TemporaryAccessRequest status", log)
+		}
+	}
+
+	if err := r.grant(ctx, &request); errors.Is(err, errFrozen) {
+		return waitWhileFrozen(ctx, r.Client, &request, &request.Status.Conditions, err)
+	} else if err != nil {
+		return r.statusService.UpdateTemporaryAccessRequestStatus(ctx, &request, err.Error())
+	}
+	meta.RemoveStatusCondition(&request.Status.Conditions, conditionFrozen)
+
+	return r.statusService.UpdateTemporaryAccessRequestStatus(ctx, &request,
+		fmt.Sprintf("Access granted until %s", request.Status.ExpiresAt.UTC().Format(time.RFC3339)))
+}
+
+func (r *TemporaryAccessRequestReconciler) grant(ctx context.Context, request *postgresv1.TemporaryAccessRequest) error {
+	database, pgConn, err := r.resolveTarget(ctx, request)
+	if err != nil {
+		return err
+	}
+
+	db, err := r.pgClient.ConnectToDatabase(ctx, pgConn, database.Spec.DatabaseName)
+	if err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+
+	unlock, err := r.pgClient.LockDDL(ctx, pgConn)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	password, err := utils.GeneratePassword(r.PasswordPolicy)
+	if err != nil {
+		return fmt.Errorf("failed to generate password: %w", err)
+	}
+
+	grantedAt := metav1.Now()
+	expiresAt := metav1.NewTime(grantedAt.Add(request.Spec.TTL.Duration))
+
+	if err := r.userService.CreateTemporaryUser(ctx, db, request, password, expiresAt.Time); err != nil {
+		if errors.Is(err, postgres.ErrRoleExists) {
+			request.Status.Phase = postgresv1.TemporaryAccessFailed
+			r.audit(ctx, request, "Rejected", err.Error())
+		}
+		return err
+	}
+
+	user := postgresv1.DatabaseUser{Name: request.Status.RoleName, Permissions: request.Spec.Permissions}
+	if err := r.userService.GrantPermissions(ctx, db, database.Spec.DatabaseName, user); err != nil {
+		if dropErr := r.userService.DropTemporaryUser(ctx, db, request); dropErr != nil {
+			return fmt.Errorf("failed to grant permissions: %w (cleanup failed: %v)", err, dropErr)
+		}
+		return fmt.Errorf("failed to grant permissions: %w", err)
+	}
+
+	// Applied rather than created so a secret left over from an earlier attempt gets the new password
+	data := map[string][]byte{"username": []byte(request.Status.RoleName), "password": []byte(password)}
+	if err := r.secretService.ApplySecret(ctx, request, request.Status.SecretName, data); err != nil {
+		if dropErr := r.userService.DropTemporaryUser(ctx, db, request); dropErr != nil {
+			return fmt.Errorf("failed to create secret: %w (cleanup failed: %v)", err, dropErr)
+		}
+		return fmt.Errorf("failed to create secret: %w", err)
+	}
+
+	request.Status.Phase = postgresv1.TemporaryAccessActive
+	request.Status.GrantedAt = &grantedAt
+	request.Status.ExpiresAt = &expiresAt
+
+	message := fmt.Sprintf("Created role %s with %s on %s until %s", request.Status.RoleName,
+		joinPermissions(request.Spec.Permissions), database.Spec.DatabaseName, expiresAt.UTC().Format(time.RFC3339))
+	if request.Spec.Reason != "" {
+		message = fmt.Sprintf("%s (reason: %s)", message, request.Spec.Reason)
+	}
+	r.audit(ctx, request, "Granted", message)
+
+	return nil
+}
+
+func (r *TemporaryAccessRequestReconciler) revoke(ctx context.Context, request *postgresv1.TemporaryAccessRequest) error {
+	database, pgConn, err := r.resolveTarget(ctx, request)
+	if err != nil {
+		return err
+	}
+
+	db, err := r.pgClient.ConnectToDatabase(ctx, pgConn, database.Spec.DatabaseName)
+	if err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+
+	unlock, err := r.pgClient.LockDDL(ctx, pgConn)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if err := r.userService.DropTemporaryUser(ctx, db, request); err != nil {
+		return err
+	}
+
+	return r.secretService.DeleteSecret(ctx, request.Status.SecretName, request.Namespace)
+}
+
+func (r *TemporaryAccessRequestReconciler) finalize(ctx context.Context, request *postgresv1.TemporaryAccessRequest) (ctrl.Result, error) {
+	log := logf.FromContext(ctx)
+
+	if !controllerutil.ContainsFinalizer(request, temporaryAccessFinalizer) {
+		return ctrl.Result{}, nil
+	}
+
+	// A pending request may have created its role before its status update was lost
+	if request.Status.Phase == postgresv1.TemporaryAccessActive || request.Status.Phase == postgresv1.TemporaryAccessPending {
+		err := r.revoke(ctx, request)
+		switch {
+		case apierrors.IsNotFound(err):
+			// The role still expires through VALID UNTIL once the Database is gone
+			log.Info("Target of deleted TemporaryAccessRequest no longer exists, skipping revoke", "role", request.Status.RoleName)
+		case errors.Is(err, errFrozen):
+			return waitWhileFrozen(ctx, r.Client, request, &request.Status.Conditions, err)
+		case err != nil:
+			log.Error(err, "Failed to revoke access for deleted TemporaryAccessRequest")
+			return ctrl.Result{RequeueAfter: k8s.RetryInterval(request)}, nil
+		default:
+			log.Info("Audit", "action", "Revoked", "role", request.Status.RoleName, "message", "request deleted before expiry")
+		}
+	}
+
+	controllerutil.RemoveFinalizer(request, temporaryAccessFinalizer)
+	if err := r.Update(ctx, request); err != nil {
+		return utils.HandleReconcileError(err, "Failed to remove finalizer", log)
+	}
+
+	return ctrl.Result{}, nil
+}
+
+func (r *TemporaryAccessRequestReconciler) resolveTarget(ctx context.Context, request *postgresv1.TemporaryAccessRequest) (*postgresv1.Database, *postgresv1.PostGresConnection, error) {
+	var database postgresv1.Database
+	key := types.NamespacedName{Name: request.Spec.DatabaseRef.Name, Namespace: request.Namespace}
+	if err := r.Get(ctx, key, &database); err != nil {
+		return nil, nil, fmt.Errorf("failed to get Database %s: %w", key, err)
+	}
+
+	if err := checkFrozen(&database); err != nil {
+		return nil, nil, err
+	}
+
+	pgConn, err := getConnectionForDatabase(ctx, r.Client, &database)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := checkConnection(pgConn); err != nil {
+		return nil, nil, err
+	}
+
+	return &database, pgConn, nil
+}
+
+// audit appends an entry to the request's audit log and mirrors it to the operator log
+func (r *TemporaryAccessRequestReconciler) audit(ctx context.Context, request *postgresv1.TemporaryAccessRequest, action, message string) {
+	logf.FromContext(ctx).Info("Audit", "action", action, "role", request.Status.RoleName, "message", message)
+	request.Status.AuditLog = append(request.Status.AuditLog, postgresv1.AuditEntry{
+		Time:    metav1.Now(),
+		Action:  action,
+		Message: message,
+	})
+}
+
+func temporaryRoleName(request *postgresv1.TemporaryAccessRequest) string {
+	if request.Spec.RoleName != "" {
+		return request.Spec.RoleName
+	}
+	name := "tmp_" + strings.ReplaceAll(strings.ReplaceAll(request.Name, "-", "_"), ".", "_")
+	if len(name) > 63 {
+		name = name[:63]
+	}
+	return name
+}
+
+func temporarySecretName(request *postgresv1.TemporaryAccessRequest) string {
+	if request.Spec.SecretName != "" {
+		return request.Spec.SecretName
+	}
+	return fmt.Sprintf("%s-credentials", request.Name)
+}
+
+func joinPermissions(permissions []postgresv1.Permission) string {
+	names := make([]string, 0, len(permissions))
+	for _, permission := range permissions {
+		names = append(names, string(permission))
+	}
+	return strings.Join(names, ", ")
+}
+
+func ptrNow() *metav1.Time {
+	now := metav1.Now()
+	return &now
+}
+
+// NewTemporaryAccessRequestReconciler creates a new TemporaryAccessRequestReconciler with all required services
+func NewTemporaryAccessRequestReconciler(client client.Client, scheme *runtime.Scheme) *TemporaryAccessRequestReconciler {
+	pgClient := postgres.NewClient(client)
+	return &TemporaryAccessRequestReconciler{
+		Client:         client,
+		Scheme:         scheme,
+		PasswordPolicy: utils.DefaultPasswordPolicy,
+		pgClient:       pgClient,
+		userService:    postgres.NewUserService(pgClient),
+		secretService:  k8s.NewSecretService(client, scheme),
+		statusService:  k8s.NewStatusService(client),
+	}
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *TemporaryAccessRequestReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&postgresv1.TemporaryAccessRequest{}).
+		Owns(&corev1.Secret{}).
+		Named("temporaryaccessrequest").
+		Complete(r)
+}
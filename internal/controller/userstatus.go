@@ -0,0 +1,136 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"errors"
+	"maps"
+	"slices"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	postgresv1 "github.com/silverswarm/pg-operator/api/v1"
+)
+
+// userConditionReasons are the reasons of each user condition when it is True and when it is False
+var userConditionReasons = map[string]struct{ ok, failed string }{
+	"Created":       {"RoleExists", "CreateFailed"},
+	"SecretReady":   {"CredentialsWritten", "WriteFailed"},
+	"GrantsApplied": {"PrivilegesGranted", "GrantFailed"},
+}
+
+// syncUserStatuses keeps an entry in status.users for every user in the spec, in spec order, and marks the
+// users dropped after their expiry
+func syncUserStatuses(database *postgresv1.Database) {
+	statuses := make([]postgresv1.UserStatus, 0, len(database.Spec.Users))
+	for _, user := range database.Spec.Users {
+		status := postgresv1.UserStatus{Name: user.Name}
+		if existing := findUserStatus(database, user.Name); existing != nil {
+			status = *existing
+		}
+		if credentialsFor(database, user.Name).Dropped {
+			meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+				Type:               "Created",
+				Status:             metav1.ConditionFalse,
+				Reason:             "Expired",
+				Message:            "The user was dropped after its credentials expired",
+				ObservedGeneration: database.Generation,
+			})
+		}
+		statuses = append(statuses, status)
+	}
+	database.Status.Users = statuses
+}
+
+func findUserStatus(database *postgresv1.Database, name string) *postgresv1.UserStatus {
+	for i := range database.Status.Users {
+		if database.Status.Users[i].Name == name {
+			return &database.Status.Users[i]
+		}
+	}
+	return nil
+}
+
+// setUserCondition records the outcome of one step for a user, True when err is nil, and reports whether the
+// condition just became True
+func setUserCondition(database *postgresv1.Database, name, conditionType string, err error) bool {
+	status := findUserStatus(database, name)
+	if status == nil {
+		return false
+	}
+	wasTrue := meta.IsStatusConditionTrue(status.Conditions, conditionType)
+
+	reasons := userConditionReasons[conditionType]
+	condition := metav1.Condition{
+		Type:               conditionType,
+		Status:             metav1.ConditionTrue,
+		Reason:             reasons.ok,
+		ObservedGeneration: database.Generation,
+	}
+	if err != nil {
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = reasons.failed
+		condition.Message = err.Error()
+	}
+	meta.SetStatusCondition(&status.Conditions, condition)
+	return err == nil && !wasTrue
+}
+
+func removeUserCondition(database *postgresv1.Database, name, conditionType string) {
+	if status := findUserStatus(database, name); status != nil {
+		meta.RemoveStatusCondition(&status.Conditions, conditionType)
+	}
+}
+
+// setLastRotated records when each user's password was last issued or rotated as the lastTransitionTime of
+// its LastRotated condition
+func setLastRotated(database *postgresv1.Database) {
+	for i := range database.Status.Users {
+		status := &database.Status.Users[i]
+		record := credentialsFor(database, status.Name)
+		if record.IssuedAt.IsZero() {
+			continue
+		}
+
+		condition := metav1.Condition{
+			Type:               "LastRotated",
+			Status:             metav1.ConditionTrue,
+			Reason:             "Issued",
+			Message:            "Password issued",
+			LastTransitionTime: record.IssuedAt,
+			ObservedGeneration: database.Generation,
+		}
+		if record.LastRotationTime != nil {
+			condition.Reason = "Rotated"
+			condition.Message = "Password rotated"
+			condition.LastTransitionTime = *record.LastRotationTime
+		}
+		// SetStatusCondition keeps the previous lastTransitionTime while the status stays True
+		meta.RemoveStatusCondition(&status.Conditions, condition.Type)
+		meta.SetStatusCondition(&status.Conditions, condition)
+	}
+}
+
+// joinUserFailures joins the failures of several users in a stable order
+func joinUserFailures(failures map[string]error) error {
+	errs := make([]error, 0, len(failures))
+	for _, name := range slices.Sorted(maps.Keys(failures)) {
+		errs = append(errs, failures[name])
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,123 @@
+package credentials
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	defaultAzureAuthorityHost = "https://login.microsoftonline.com/"
+	// azurePostgresScope is the resource Azure Database for PostgreSQL accepts access tokens for
+	azurePostgresScope = "https://ossrdbms-aad.database.windows.net/.default"
+)
+
+// AzureTokenSourceConfig configures an AzureTokenSource. Empty fields are read from the environment the
+// Azure workload identity webhook injects into the operator's pod.
+type AzureTokenSourceConfig struct {
+	// TenantID is the Microsoft Entra tenant (defaults to AZURE_TENANT_ID)
+	TenantID string
+	// ClientID is the managed identity or app registration federated with the operator's service
+	// account (defaults to AZURE_CLIENT_ID)
+	ClientID string
+	// AuthorityHost is the Microsoft Entra endpoint (defaults to AZURE_AUTHORITY_HOST, then the public cloud)
+	AuthorityHost string
+	// TokenFile holds the projected service account token exchanged for an access token
+	// (defaults to AZURE_FEDERATED_TOKEN_FILE)
+	TokenFile string
+	Timeout   time.Duration
+}
+
+// AzureTokenSource obtains Microsoft Entra ID access tokens for Azure Database for PostgreSQL, exchanging
+// the operator's federated service account token through workload identity
+type AzureTokenSource struct {
+	tokenURL   string
+	clientID   string
+	tokenFile  string
+	httpClient *http.Client
+}
+
+func NewAzureTokenSource(config AzureTokenSourceConfig) (*AzureTokenSource, error) {
+	tenantID := withDefault(config.TenantID, os.Getenv("AZURE_TENANT_ID"))
+	clientID := withDefault(config.ClientID, os.Getenv("AZURE_CLIENT_ID"))
+	tokenFile := withDefault(config.TokenFile, os.Getenv("AZURE_FEDERATED_TOKEN_FILE"))
+	switch {
+	case tenantID == "":
+		return nil, fmt.Errorf("an Azure tenant ID is required")
+	case clientID == "":
+		return nil, fmt.Errorf("an Azure client ID is required")
+	case tokenFile == "":
+		return nil, fmt.Errorf("a federated token file is required, is workload identity enabled for the operator?")
+	}
+
+	authorityHost := withDefault(config.AuthorityHost, withDefault(os.Getenv("AZURE_AUTHORITY_HOST"), defaultAzureAuthorityHost))
+	authority, err := url.Parse(authorityHost)
+	if err != nil || authority.Host == "" {
+		return nil, fmt.Errorf("invalid Azure authority host %q", authorityHost)
+	}
+
+	timeout := config.Timeout
+	if timeout == 0 {
+		timeout = 10 * time.Second
+	}
+
+	return &AzureTokenSource{
+		tokenURL:   strings.TrimSuffix(authority.String(), "/") + "/" + url.PathEscape(tenantID) + "/oauth2/v2.0/token",
+		clientID:   clientID,
+		tokenFile:  tokenFile,
+		httpClient: &http.Client{Timeout: timeout},
+	}, nil
+}
+
+// Token returns a new access token for Azure Database for PostgreSQL and when it expires
+func (s *AzureTokenSource) Token(ctx context.Context) (string, time.Time, error) {
+	// The projected token is rotated by the kubelet, read it for every exchange
+	assertion, err := os.ReadFile(s.tokenFile)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to read federated token: %w", err)
+	}
+
+	form := url.Values{
+		"grant_type":            {"client_credentials"},
+		"client_id":             {s.clientID},
+		"scope":                 {azurePostgresScope},
+		"client_assertion_type": {"urn:ietf:params:oauth:client-assertion-type:jwt-bearer"},
+		"client_assertion":      {strings.TrimSpace(string(assertion))},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	requestedAt := time.Now()
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("azure token request failed: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	var result struct {
+		AccessToken      string `json:"access_token"`
+		ExpiresIn        int    `json:"expires_in"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 64*1024)).Decode(&result); err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to decode Azure token response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", time.Time{}, fmt.Errorf("azure returned %s: %s %s", resp.Status, result.Error, result.ErrorDescription)
+	}
+	if result.AccessToken == "" {
+		return "", time.Time{}, fmt.Errorf("azure returned no access token")
+	}
+
+	return result.AccessToken, requestedAt.Add(time.Duration(result.ExpiresIn) * time.Second), nil
+}
@@ -0,0 +1,140 @@
+package credentials
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+)
+
+// PasswordRequest describes the user a password is requested for
+type PasswordRequest struct {
+	Namespace    string `json:"namespace"`
+	Database     string `json:"database"`
+	DatabaseName string `json:"databaseName"`
+	User         string `json:"user"`
+}
+
+type passwordResponse struct {
+	Password string `json:"password"`
+}
+
+// PasswordProvider obtains passwords from an external credential issuance system
+type PasswordProvider interface {
+	GetPassword(ctx context.Context, req PasswordRequest) (string, error)
+}
+
+// WebhookProviderConfig configures a WebhookProvider
+type WebhookProviderConfig struct {
+	// URL of the provider endpoint, must use https
+	URL string
+	// CAFile is a PEM bundle used to verify the provider (system roots when empty)
+	CAFile string
+	// CertFile and KeyFile hold the client certificate presented for mTLS
+	CertFile string
+	KeyFile  string
+	Timeout  time.Duration
+}
+
+// WebhookProvider requests passwords by POSTing a PasswordRequest to an HTTPS endpoint
+type WebhookProvider struct {
+	url        string
+	httpClient *http.Client
+}
+
+func NewWebhookProvider(config WebhookProviderConfig) (*WebhookProvider, error) {
+	endpoint, err := url.Parse(config.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid password provider URL: %w", err)
+	}
+	if endpoint.Scheme != "https" {
+		return nil, fmt.Errorf("password provider URL must use https")
+	}
+
+	tlsConfig, err := newTLSConfig(config.CAFile, config.CertFile, config.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	timeout := config.Timeout
+	if timeout == 0 {
+		timeout = 10 * time.Second
+	}
+
+	return &WebhookProvider{
+		url: endpoint.String(),
+		httpClient: &http.Client{
+			Timeout:   timeout,
+			Transport: &http.Transport{TLSClientConfig: tlsConfig},
+		},
+	}, nil
+}
+
+func (p *WebhookProvider) GetPassword(ctx context.Context, req PasswordRequest) (string, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return "", err
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, p.url, bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	resp, err := p.httpClient.Do(httpReq)
+	if err != nil {
+		return "", fmt.Errorf("password provider request failed: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return "", fmt.Errorf("password provider returned %s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+
+	var result passwordResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 64*1024)).Decode(&result); err != nil {
+		return "", fmt.Errorf("failed to decode password provider response: %w", err)
+	}
+	if result.Password == "" {
+		return "", fmt.Errorf("password provider returned an empty password")
+	}
+
+	return result.Password, nil
+}
+
+// newTLSConfig returns a client TLS configuration trusting caFile (system roots when empty) and
+// presenting the client certificate when one is given
+func newTLSConfig(caFile, certFile, keyFile string) (*tls.Config, error) {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if caFile != "" {
+		caData, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caData) {
+			return nil, fmt.Errorf("no certificates found in %s", caFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if certFile != "" || keyFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
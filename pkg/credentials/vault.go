@@ -0,0 +1,301 @@
+package credentials
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+const (
+	defaultVaultAuthMount    = "kubernetes"
+	defaultVaultKVMount      = "secret"
+	defaultVaultPathRoot     = "pg-operator"
+	defaultVaultPathTemplate = "{{.Database}}/{{.User}}"
+	serviceAccountTokenFile  = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+)
+
+// CredentialStore keeps user credentials in an external secret store
+type CredentialStore interface {
+	// Path returns where a user's credentials are kept, pathTemplate overrides the default template when set.
+	// Paths always lie below the namespace of the request.
+	Path(pathTemplate string, req PasswordRequest) (string, error)
+	// Read returns the credentials at path, or nil when there are none
+	Read(ctx context.Context, path string) (map[string]string, error)
+	Write(ctx context.Context, path string, data map[string]string) error
+	Delete(ctx context.Context, path string) error
+}
+
+// VaultStoreConfig configures a VaultStore
+type VaultStoreConfig struct {
+	// Address of the Vault server, e.g. https://vault.vault.svc:8200
+	Address string
+	// CAFile is a PEM bundle used to verify Vault (system roots when empty)
+	CAFile string
+	// AuthMount is the mount path of the Kubernetes auth method (defaults to kubernetes)
+	AuthMount string
+	// Role is the Kubernetes auth role the operator logs in with
+	Role string
+	// KVMount is the mount path of the KV version 2 secrets engine (defaults to secret)
+	KVMount string
+	// PathRoot is the operator's part of the KV engine (defaults to pg-operator). Every namespace has its own
+	// directory below it, which the paths of its credentials cannot leave.
+	PathRoot string
+	// PathTemplate is the default path of a user's credentials within their namespace's directory, a Go
+	// template over PasswordRequest
+	PathTemplate string
+	// TokenFile holds the service account token presented to Vault (defaults to the mounted token)
+	TokenFile string
+	Timeout   time.Duration
+}
+
+// VaultStore writes credentials to a HashiCorp Vault KV version 2 engine, logging in with the
+// operator's service account through the Kubernetes auth method
+type VaultStore struct {
+	address      string
+	authMount    string
+	role         string
+	kvMount      string
+	pathRoot     string
+	tokenFile    string
+	pathTemplate *template.Template
+	httpClient   *http.Client
+
+	mu          sync.Mutex
+	token       string
+	tokenExpiry time.Time
+}
+
+func NewVaultStore(config VaultStoreConfig) (*VaultStore, error) {
+	address, err := url.Parse(config.Address)
+	if err != nil || address.Host == "" {
+		return nil, fmt.Errorf("invalid Vault address %q", config.Address)
+	}
+	if config.Role == "" {
+		return nil, fmt.Errorf("a Vault role is required")
+	}
+
+	pathTemplate := config.PathTemplate
+	if pathTemplate == "" {
+		pathTemplate = defaultVaultPathTemplate
+	}
+	tmpl, err := template.New("path").Option("missingkey=error").Parse(pathTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Vault path template: %w", err)
+	}
+
+	tlsConfig, err := newTLSConfig(config.CAFile, "", "")
+	if err != nil {
+		return nil, err
+	}
+
+	timeout := config.Timeout
+	if timeout == 0 {
+		timeout = 10 * time.Second
+	}
+
+	return &VaultStore{
+		address:      strings.TrimSuffix(address.String(), "/"),
+		authMount:    withDefault(strings.Trim(config.AuthMount, "/"), defaultVaultAuthMount),
+		role:         config.Role,
+		kvMount:      withDefault(strings.Trim(config.KVMount, "/"), defaultVaultKVMount),
+		pathRoot:     withDefault(strings.Trim(config.PathRoot, "/"), defaultVaultPathRoot),
+		tokenFile:    withDefault(config.TokenFile, serviceAccountTokenFile),
+		pathTemplate: tmpl,
+		httpClient: &http.Client{
+			Timeout:   timeout,
+			Transport: &http.Transport{TLSClientConfig: tlsConfig},
+		},
+	}, nil
+}
+
+func (s *VaultStore) Path(pathTemplate string, req PasswordRequest) (string, error) {
+	tmpl := s.pathTemplate
+	if pathTemplate != "" {
+		var err error
+		if tmpl, err = template.New("path").Option("missingkey=error").Parse(pathTemplate); err != nil {
+			return "", fmt.Errorf("invalid Vault path template: %w", err)
+		}
+	}
+
+	var path strings.Builder
+	if err := tmpl.Execute(&path, req); err != nil {
+		return "", fmt.Errorf("failed to render Vault path: %w", err)
+	}
+	return s.scopedPath(req.Namespace, path.String())
+}
+
+// scopedPath places path in the directory of a namespace, so that one namespace cannot read or overwrite
+// the credentials of another
+func (s *VaultStore) scopedPath(namespace, path string) (string, error) {
+	path = strings.Trim(path, "/")
+	for _, segment := range strings.Split(path, "/") {
+		if segment == "" || segment == "." || segment == ".." || strings.ContainsAny(segment, `?#%\`) {
+			return "", fmt.Errorf("invalid Vault path %q", path)
+		}
+	}
+	if namespace == "" {
+		return "", fmt.Errorf("no namespace for Vault path %q", path)
+	}
+	return s.pathRoot + "/" + namespace + "/" + path, nil
+}
+
+func (s *VaultStore) Read(ctx context.Context, path string) (map[string]string, error) {
+	var result struct {
+		Data struct {
+			Data map[string]string `json:"data"`
+		} `json:"data"`
+	}
+	found, err := s.do(ctx, http.MethodGet, "/v1/"+s.kvMount+"/data/"+path, nil, &result)
+	if err != nil || !found {
+		return nil, err
+	}
+	return result.Data.Data, nil
+}
+
+// ReadInNamespace reads the credentials at path within the directory of a namespace, see VaultStoreConfig.PathRoot
+func (s *VaultStore) ReadInNamespace(ctx context.Context, namespace, path string) (map[string]string, error) {
+	scoped, err := s.scopedPath(namespace, path)
+	if err != nil {
+		return nil, err
+	}
+	return s.Read(ctx, scoped)
+}
+
+func (s *VaultStore) Write(ctx context.Context, path string, data map[string]string) error {
+	_, err := s.do(ctx, http.MethodPost, "/v1/"+s.kvMount+"/data/"+path, map[string]any{"data": data}, nil)
+	return err
+}
+
+// Delete removes every version of the credentials at path
+func (s *VaultStore) Delete(ctx context.Context, path string) error {
+	_, err := s.do(ctx, http.MethodDelete, "/v1/"+s.kvMount+"/metadata/"+path, nil, nil)
+	return err
+}
+
+// do sends an authenticated request to Vault, decoding the response into out. It reports false when
+// Vault answers 404.
+func (s *VaultStore) do(ctx context.Context, method, path string, body, out any) (bool, error) {
+	token, err := s.login(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	resp, err := s.request(ctx, method, path, token, body)
+	if err != nil {
+		return false, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return false, nil
+	case resp.StatusCode == http.StatusForbidden:
+		// The token may have been revoked, log in again on the next request
+		s.mu.Lock()
+		s.token = ""
+		s.mu.Unlock()
+		return false, vaultError(resp)
+	case resp.StatusCode >= 300:
+		return false, vaultError(resp)
+	}
+
+	if out != nil {
+		if err := json.NewDecoder(io.LimitReader(resp.Body, 64*1024)).Decode(out); err != nil {
+			return false, fmt.Errorf("failed to decode Vault response: %w", err)
+		}
+	}
+	return true, nil
+}
+
+// login returns a Vault token, logging in with the service account token when the cached one expired
+func (s *VaultStore) login(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.token != "" && time.Now().Before(s.tokenExpiry) {
+		return s.token, nil
+	}
+
+	jwt, err := os.ReadFile(s.tokenFile)
+	if err != nil {
+		return "", fmt.Errorf("failed to read service account token: %w", err)
+	}
+
+	resp, err := s.request(ctx, http.MethodPost, "/v1/auth/"+s.authMount+"/login", "",
+		map[string]string{"role": s.role, "jwt": strings.TrimSpace(string(jwt))})
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("vault login failed: %w", vaultError(resp))
+	}
+
+	var result struct {
+		Auth struct {
+			ClientToken   string `json:"client_token"`
+			LeaseDuration int    `json:"lease_duration"`
+		} `json:"auth"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 64*1024)).Decode(&result); err != nil {
+		return "", fmt.Errorf("failed to decode Vault login response: %w", err)
+	}
+	if result.Auth.ClientToken == "" {
+		return "", fmt.Errorf("vault login returned no token")
+	}
+
+	// Renew well before the lease runs out
+	s.token = result.Auth.ClientToken
+	s.tokenExpiry = time.Now().Add(time.Duration(result.Auth.LeaseDuration) * time.Second * 4 / 5)
+	return s.token, nil
+}
+
+func (s *VaultStore) request(ctx context.Context, method, path, token string, body any) (*http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, s.address+path, reader)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		req.Header.Set("X-Vault-Token", token)
+	}
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("vault request failed: %w", err)
+	}
+	return resp, nil
+}
+
+func vaultError(resp *http.Response) error {
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return fmt.Errorf("vault returned %s: %s", resp.Status, bytes.TrimSpace(msg))
+}
+
+func withDefault(value, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+	return value
+}
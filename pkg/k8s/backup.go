@@ -0,0 +1,343 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	postgresv1 "github.com/silverswarm/pg-operator/api/v1"
+)
+
+const (
+	backupVolumePath = "/backup"
+	backupCAPath     = "/etc/pg-operator/ca"
+	uploadContainer  = "upload"
+)
+
+// BackupJobConfig places the backup Jobs. They run in the operator's namespace with the images configured here,
+// as pg_dump gets the connection's credentials, which must not end up in a namespace of the Database or in an
+// image its authors choose.
+type BackupJobConfig struct {
+	// Namespace the Jobs and their secrets are created in
+	Namespace string
+	// PostgresImage provides pg_dump, its major version must not be older than the servers'
+	PostgresImage string
+	// UploaderImage provides the aws CLI used for the upload
+	UploaderImage string
+}
+
+// DefaultBackupJobConfig is the configuration used until SetBackupJobConfig is called
+var DefaultBackupJobConfig = BackupJobConfig{
+	PostgresImage: "postgres:17",
+	UploaderImage: "amazon/aws-cli:2.22.0",
+}
+
+var backupJobConfig = DefaultBackupJobConfig
+
+// SetBackupJobConfig sets where backup Jobs run and which images they use. It must be called before the
+// controllers are started.
+func SetBackupJobConfig(config BackupJobConfig) {
+	backupJobConfig = config
+}
+
+type BackupService struct {
+	client client.Client
+	scheme *runtime.Scheme
+}
+
+func NewBackupService(client client.Client, scheme *runtime.Scheme) *BackupService {
+	return &BackupService{
+		client: client,
+		scheme: scheme,
+	}
+}
+
+// BackupJobName returns the name of the Job and the connection secret of a backup. Jobs of all namespaces
+// share the operator's namespace, the UID keeps their names apart.
+func BackupJobName(backup *postgresv1.DatabaseBackup) string {
+	return fmt.Sprintf("backup-%s", backup.UID)
+}
+
+// BackupNamespace returns the namespace backup Jobs run in
+func BackupNamespace() (string, error) {
+	if backupJobConfig.Namespace == "" {
+		return "", fmt.Errorf("no namespace configured for backup jobs, set --backup-namespace")
+	}
+	return backupJobConfig.Namespace, nil
+}
+
+// BackupLocation returns the URL the dump of a backup is uploaded to
+func BackupLocation(backup *postgresv1.DatabaseBackup, databaseName string) string {
+	extension := "dump"
+	if backup.Spec.Format == "plain" {
+		extension = "sql"
+	}
+
+	key := fmt.Sprintf("%s/%s-%s.%s", databaseName, backup.Name, backup.CreationTimestamp.UTC().Format("20060102T150405Z"), extension)
+	if prefix := strings.Trim(backup.Spec.Destination.Prefix, "/"); prefix != "" {
+		key = prefix + "/" + key
+	}
+
+	return fmt.Sprintf("s3://%s/%s", backup.Spec.Destination.Bucket, key)
+}
+
+// CreateBackupJob creates the Job that dumps the database and uploads the dump, along with a secret holding the
+// connection's credentials and one holding a copy of the destination's credentials. pg_dump runs in an init
+// container writing to a shared volume, the upload container then copies the file and reports its size through
+// the termination message. Everything is created in the backup namespace, away from the DatabaseBackup.
+func (s *BackupService) CreateBackupJob(ctx context.Context, backup *postgresv1.DatabaseBackup, env map[string]string, caBundle, location string) (*batchv1.Job, error) {
+	namespace, err := BackupNamespace()
+	if err != nil {
+		return nil, err
+	}
+	name := BackupJobName(backup)
+
+	var destination corev1.Secret
+	key := types.NamespacedName{Name: backup.Spec.Destination.CredentialsSecret.Name, Namespace: backup.Namespace}
+	if err := s.client.Get(ctx, key, &destination); err != nil {
+		return nil, fmt.Errorf("failed to get destination credentials %s: %w", key, err)
+	}
+
+	connectionData := make(map[string][]byte, len(env)+1)
+	for key, value := range env {
+		connectionData[key] = []byte(value)
+	}
+	if caBundle != "" {
+		connectionData["ca.crt"] = []byte(caBundle)
+	}
+
+	secrets := map[string]map[string][]byte{name: connectionData, uploadSecretName(name): destination.Data}
+	for secretName, data := range secrets {
+		secret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: secretName, Namespace: namespace, Labels: backupLabels(backup)},
+			Type:       corev1.SecretTypeOpaque,
+			Data:       data,
+		}
+		if err := s.client.Create(ctx, secret); err != nil && !errors.IsAlreadyExists(err) {
+			return nil, fmt.Errorf("failed to create secret %s: %w", secretName, err)
+		}
+	}
+
+	job := s.buildBackupJob(backup, namespace, name, env, caBundle != "", location)
+	if err := s.client.Create(ctx, job); err != nil && !errors.IsAlreadyExists(err) {
+		return nil, fmt.Errorf("failed to create job: %w", err)
+	}
+
+	return job, nil
+}
+
+// DeleteBackupSecrets deletes the secrets of a backup Job, which are only needed while it runs
+func (s *BackupService) DeleteBackupSecrets(ctx context.Context, backup *postgresv1.DatabaseBackup) error {
+	namespace, err := BackupNamespace()
+	if err != nil {
+		return err
+	}
+
+	name := BackupJobName(backup)
+	for _, secretName := range []string{name, uploadSecretName(name)} {
+		secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: secretName, Namespace: namespace}}
+		if err := s.client.Delete(ctx, secret); err != nil && !errors.IsNotFound(err) {
+			return fmt.Errorf("failed to delete secret %s/%s: %w", namespace, secretName, err)
+		}
+	}
+	return nil
+}
+
+// DeleteBackupJob deletes the Job of a backup along with its pods and secrets
+func (s *BackupService) DeleteBackupJob(ctx context.Context, backup *postgresv1.DatabaseBackup) error {
+	if err := s.DeleteBackupSecrets(ctx, backup); err != nil {
+		return err
+	}
+
+	job := &batchv1.Job{ObjectMeta: metav1.ObjectMeta{Name: BackupJobName(backup), Namespace: backupJobConfig.Namespace}}
+	if err := s.client.Delete(ctx, job, client.PropagationPolicy(metav1.DeletePropagationBackground)); err != nil && !errors.IsNotFound(err) {
+		return fmt.Errorf("failed to delete job %s: %w", job.Name, err)
+	}
+	return nil
+}
+
+func uploadSecretName(jobName string) string {
+	return jobName + "-upload"
+}
+
+func backupLabels(backup *postgresv1.DatabaseBackup) map[string]string {
+	return map[string]string{
+		"app.kubernetes.io/managed-by":  "pg-operator",
+		postgresv1.BackupNameLabel:      backup.Name,
+		postgresv1.BackupNamespaceLabel: backup.Namespace,
+	}
+}
+
+// FinalBackupName returns the name of the DatabaseBackup taken before a Database is dropped
+func FinalBackupName(database *postgresv1.Database) string {
+	return fmt.Sprintf("%s-final", database.Name)
+}
+
+// EnsureFinalBackup returns the final DatabaseBackup of a deleted Database, creating it from spec.finalBackup.
+// It has no owner, so the record of where the dump went outlives the Database.
+func (s *BackupService) EnsureFinalBackup(ctx context.Context, database *postgresv1.Database) (*postgresv1.DatabaseBackup, error) {
+	backup := &postgresv1.DatabaseBackup{}
+	key := types.NamespacedName{Name: FinalBackupName(database), Namespace: database.Namespace}
+	err := s.client.Get(ctx, key, backup)
+	if err == nil || !errors.IsNotFound(err) {
+		return backup, err
+	}
+
+	final := database.Spec.FinalBackup
+	if final == nil {
+		return nil, fmt.Errorf("finalBackup is required with the DropWithBackup deletionPolicy")
+	}
+	backup = &postgresv1.DatabaseBackup{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      key.Name,
+			Namespace: key.Namespace,
+			Labels: map[string]string{
+				"app.kubernetes.io/managed-by":    "pg-operator",
+				postgresv1.DatabaseNameLabel:      database.Name,
+				postgresv1.DatabaseNamespaceLabel: database.Namespace,
+			},
+		},
+		Spec: postgresv1.DatabaseBackupSpec{
+			DatabaseRef: postgresv1.LocalObjectReference{Name: database.Name},
+			Destination: final.Destination,
+			Format:      final.Format,
+		},
+	}
+	if err := s.client.Create(ctx, backup); err != nil {
+		return nil, fmt.Errorf("failed to create final backup: %w", err)
+	}
+	return backup, nil
+}
+
+func (s *BackupService) GetJob(ctx context.Context, name, namespace string) (*batchv1.Job, error) {
+	var job batchv1.Job
+	if err := s.client.Get(ctx, types.NamespacedName{Name: name, Namespace: namespace}, &job); err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// UploadedSize reads the dump size reported by the upload container of a finished Job
+func (s *BackupService) UploadedSize(ctx context.Context, job *batchv1.Job) (int64, error) {
+	var pods corev1.PodList
+	if err := s.client.List(ctx, &pods, client.InNamespace(job.Namespace), client.MatchingLabels{"job-name": job.Name}); err != nil {
+		return 0, fmt.Errorf("failed to list pods of job %s: %w", job.Name, err)
+	}
+
+	for _, pod := range pods.Items {
+		for _, status := range pod.Status.ContainerStatuses {
+			if status.Name != uploadContainer || status.State.Terminated == nil || status.State.Terminated.ExitCode != 0 {
+				continue
+			}
+			size, err := strconv.ParseInt(strings.TrimSpace(status.State.Terminated.Message), 10, 64)
+			if err != nil {
+				return 0, fmt.Errorf("unexpected termination message %q: %w", status.State.Terminated.Message, err)
+			}
+			return size, nil
+		}
+	}
+
+	return 0, fmt.Errorf("no successful upload found for job %s", job.Name)
+}
+
+func (s *BackupService) buildBackupJob(backup *postgresv1.DatabaseBackup, namespace, name string, env map[string]string, hasCA bool, location string) *batchv1.Job {
+	format := backup.Spec.Format
+	if format == "" {
+		format = "custom"
+	}
+	region := backup.Spec.Destination.Region
+	if region == "" {
+		region = "us-east-1"
+	}
+
+	volumes := []corev1.Volume{{
+		Name:         "backup",
+		VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}},
+	}}
+	dumpMounts := []corev1.VolumeMount{{Name: "backup", MountPath: backupVolumePath}}
+	keys := make([]string, 0, len(env))
+	for key := range env {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	dumpEnv := make([]corev1.EnvVar, 0, len(keys)+1)
+	for _, key := range keys {
+		dumpEnv = append(dumpEnv, corev1.EnvVar{
+			Name: key,
+			ValueFrom: &corev1.EnvVarSource{SecretKeyRef: &corev1.SecretKeySelector{
+				LocalObjectReference: corev1.LocalObjectReference{Name: name},
+				Key:                  key,
+			}},
+		})
+	}
+	if hasCA {
+		volumes = append(volumes, corev1.Volume{
+			Name: "ca",
+			VolumeSource: corev1.VolumeSource{Secret: &corev1.SecretVolumeSource{
+				SecretName: name,
+				Items:      []corev1.KeyToPath{{Key: "ca.crt", Path: "ca.crt"}},
+			}},
+		})
+		dumpMounts = append(dumpMounts, corev1.VolumeMount{Name: "ca", MountPath: backupCAPath, ReadOnly: true})
+		dumpEnv = append(dumpEnv, corev1.EnvVar{Name: "PGSSLROOTCERT", Value: backupCAPath + "/ca.crt"})
+	}
+
+	uploadEnv := []corev1.EnvVar{
+		{Name: "DESTINATION", Value: location},
+		{Name: "AWS_DEFAULT_REGION", Value: region},
+	}
+	uploadScript := `aws s3 cp /backup/dump "$DESTINATION"`
+	if endpoint := backup.Spec.Destination.Endpoint; endpoint != "" {
+		uploadEnv = append(uploadEnv, corev1.EnvVar{Name: "ENDPOINT", Value: endpoint})
+		uploadScript += ` --endpoint-url "$ENDPOINT"`
+	}
+	uploadScript += " && stat -c %s /backup/dump > /dev/termination-log"
+
+	return &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+			Labels:    backupLabels(backup),
+		},
+		Spec: batchv1.JobSpec{
+			BackoffLimit: ptr.To[int32](1),
+			Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{
+					RestartPolicy: corev1.RestartPolicyNever,
+					// The pods run in the operator's namespace and need nothing from the API server
+					AutomountServiceAccountToken: ptr.To(false),
+					Volumes:                      volumes,
+					InitContainers: []corev1.Container{{
+						Name:         "pg-dump",
+						Image:        backupJobConfig.PostgresImage,
+						Command:      []string{"pg_dump", "--format=" + format, "--file=" + backupVolumePath + "/dump"},
+						Env:          dumpEnv,
+						VolumeMounts: dumpMounts,
+					}},
+					Containers: []corev1.Container{{
+						Name:    uploadContainer,
+						Image:   backupJobConfig.UploaderImage,
+						Command: []string{"/bin/sh", "-c", uploadScript},
+						EnvFrom: []corev1.EnvFromSource{{SecretRef: &corev1.SecretEnvSource{
+							LocalObjectReference: corev1.LocalObjectReference{Name: uploadSecretName(name)},
+						}}},
+						Env:          uploadEnv,
+						VolumeMounts: []corev1.VolumeMount{{Name: "backup", MountPath: backupVolumePath, ReadOnly: true}},
+					}},
+				},
+			},
+		},
+	}
+}
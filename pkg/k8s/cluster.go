@@ -0,0 +1,166 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	postgresv1 "github.com/silverswarm/pg-operator/api/v1"
+)
+
+// ClusterGVK is the CNPG Cluster kind, read as unstructured so the operator does not depend on the CNPG API
+var ClusterGVK = schema.GroupVersionKind{Group: "postgresql.cnpg.io", Version: "v1", Kind: "Cluster"}
+
+// PoolerGVK is the CNPG Pooler kind
+var PoolerGVK = schema.GroupVersionKind{Group: "postgresql.cnpg.io", Version: "v1", Kind: "Pooler"}
+
+type ClusterService struct {
+	client client.Client
+}
+
+func NewClusterService(client client.Client) *ClusterService {
+	return &ClusterService{
+		client: client,
+	}
+}
+
+// GetClusterStatus reads the phase, primary and Ready condition of a CNPG cluster
+func (s *ClusterService) GetClusterStatus(ctx context.Context, name, namespace string) (*postgresv1.CNPGClusterStatus, error) {
+	cluster := NewCluster()
+	if err := s.client.Get(ctx, types.NamespacedName{Name: name, Namespace: namespace}, cluster); err != nil {
+		return nil, err
+	}
+
+	status := &postgresv1.CNPGClusterStatus{}
+	var err error
+	if status.Phase, _, err = unstructured.NestedString(cluster.Object, "status", "phase"); err != nil {
+		return nil, fmt.Errorf("invalid status in cluster %s/%s: %w", namespace, name, err)
+	}
+	if status.CurrentPrimary, _, err = unstructured.NestedString(cluster.Object, "status", "currentPrimary"); err != nil {
+		return nil, fmt.Errorf("invalid status in cluster %s/%s: %w", namespace, name, err)
+	}
+
+	conditions, _, err := unstructured.NestedSlice(cluster.Object, "status", "conditions")
+	if err != nil {
+		return nil, fmt.Errorf("invalid status in cluster %s/%s: %w", namespace, name, err)
+	}
+	for _, condition := range conditions {
+		fields, ok := condition.(map[string]any)
+		if ok && fields["type"] == "Ready" {
+			status.Ready = fields["status"] == "True"
+		}
+	}
+
+	return status, nil
+}
+
+// GetPoolMode reads the PgBouncer pool mode of a CNPG Pooler, which CNPG defaults to session
+func (s *ClusterService) GetPoolMode(ctx context.Context, name, namespace string) (string, error) {
+	pooler := &unstructured.Unstructured{}
+	pooler.SetGroupVersionKind(PoolerGVK)
+	if err := s.client.Get(ctx, types.NamespacedName{Name: name, Namespace: namespace}, pooler); err != nil {
+		return "", err
+	}
+
+	mode, _, err := unstructured.NestedString(pooler.Object, "spec", "pgbouncer", "poolMode")
+	if err != nil {
+		return "", fmt.Errorf("invalid spec in pooler %s/%s: %w", namespace, name, err)
+	}
+	if mode == "" {
+		mode = "session"
+	}
+	return mode, nil
+}
+
+// ApplyPooler creates or updates the CNPG Pooler provisioned for a Database. The Pooler is labelled with
+// the Database, an existing Pooler of the same name without these labels is refused.
+func (s *ClusterService) ApplyPooler(ctx context.Context, database *postgresv1.Database, ref postgresv1.PoolerReference, clusterName string) error {
+	config := database.Spec.Pooler
+	mode := config.Mode
+	if mode == "" {
+		mode = "session"
+	}
+	instances := max(config.Instances, 1)
+
+	pooler := &unstructured.Unstructured{}
+	pooler.SetGroupVersionKind(PoolerGVK)
+	pooler.SetName(ref.Name)
+	pooler.SetNamespace(ref.Namespace)
+
+	_, err := controllerutil.CreateOrUpdate(ctx, s.client, pooler, func() error {
+		if pooler.GetResourceVersion() != "" && !provisionedFor(pooler, database) {
+			return fmt.Errorf("CNPG pooler %s/%s already exists and was not provisioned for this Database", ref.Namespace, ref.Name)
+		}
+
+		labels := pooler.GetLabels()
+		if labels == nil {
+			labels = make(map[string]string)
+		}
+		labels["app.kubernetes.io/managed-by"] = "pg-operator"
+		labels[postgresv1.DatabaseNameLabel] = database.Name
+		labels[postgresv1.DatabaseNamespaceLabel] = database.Namespace
+		pooler.SetLabels(labels)
+
+		// Single fields are set so defaults CNPG adds to the spec are kept
+		fields := []struct {
+			value any
+			path  []string
+		}{
+			{clusterName, []string{"spec", "cluster", "name"}},
+			{int64(instances), []string{"spec", "instances"}},
+			{"rw", []string{"spec", "type"}},
+			{mode, []string{"spec", "pgbouncer", "poolMode"}},
+		}
+		for _, field := range fields {
+			if err := unstructured.SetNestedField(pooler.Object, field.value, field.path...); err != nil {
+				return err
+			}
+		}
+
+		if config.MaxConnections > 0 {
+			return unstructured.SetNestedField(pooler.Object, fmt.Sprint(config.MaxConnections), "spec", "pgbouncer", "parameters", "max_db_connections")
+		}
+		unstructured.RemoveNestedField(pooler.Object, "spec", "pgbouncer", "parameters", "max_db_connections")
+		return nil
+	})
+	return err
+}
+
+// DeletePooler deletes the CNPG Pooler provisioned for a Database, Poolers it did not provision are left alone
+func (s *ClusterService) DeletePooler(ctx context.Context, database *postgresv1.Database, ref postgresv1.PoolerReference) error {
+	pooler := &unstructured.Unstructured{}
+	pooler.SetGroupVersionKind(PoolerGVK)
+	err := s.client.Get(ctx, types.NamespacedName{Name: ref.Name, Namespace: ref.Namespace}, pooler)
+	if errors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get CNPG pooler %s/%s: %w", ref.Namespace, ref.Name, err)
+	}
+	if !provisionedFor(pooler, database) {
+		return nil
+	}
+
+	if err := s.client.Delete(ctx, pooler); err != nil && !errors.IsNotFound(err) {
+		return fmt.Errorf("failed to delete CNPG pooler %s/%s: %w", ref.Namespace, ref.Name, err)
+	}
+	return nil
+}
+
+func provisionedFor(pooler *unstructured.Unstructured, database *postgresv1.Database) bool {
+	labels := pooler.GetLabels()
+	return labels[postgresv1.DatabaseNameLabel] == database.Name && labels[postgresv1.DatabaseNamespaceLabel] == database.Namespace
+}
+
+// NewCluster returns an empty CNPG Cluster to read or watch
+func NewCluster() *unstructured.Unstructured {
+	cluster := &unstructured.Unstructured{}
+	cluster.SetGroupVersionKind(ClusterGVK)
+	return cluster
+}
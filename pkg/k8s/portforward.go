@@ -0,0 +1,190 @@
+package k8s
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// forwardingPattern matches the line kubectl port-forward prints once the local port listens
+var forwardingPattern = regexp.MustCompile(`^Forwarding from 127\.0\.0\.1:(\d+) ->`)
+
+// PortForwardDialer reaches cluster services from outside the cluster, e.g. while the operator runs with
+// make run. Connections to <service>.<namespace>.svc hosts go through kubectl port-forward to a ready pod
+// behind the service, which for a CNPG -rw service is the primary. Other hosts are dialed directly.
+type PortForwardDialer struct {
+	// reader should not be cached, the dialer only looks up the few services it connects to
+	reader  client.Reader
+	kubectl string
+	dialer  net.Dialer
+
+	mu      sync.Mutex
+	tunnels map[string]*tunnel
+}
+
+// tunnel is a running kubectl port-forward to one pod port
+type tunnel struct {
+	localAddress string
+	cmd          *exec.Cmd
+	done         chan struct{}
+}
+
+func NewPortForwardDialer(reader client.Reader, kubectl string) *PortForwardDialer {
+	if kubectl == "" {
+		kubectl = "kubectl"
+	}
+	return &PortForwardDialer{
+		reader:  reader,
+		kubectl: kubectl,
+		tunnels: make(map[string]*tunnel),
+	}
+}
+
+func (d *PortForwardDialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+	service, namespace, ok := parseServiceHost(host)
+	if !ok {
+		return d.dialer.DialContext(ctx, network, address)
+	}
+	servicePort, err := strconv.Atoi(port)
+	if err != nil {
+		return nil, fmt.Errorf("invalid port %q", port)
+	}
+
+	pod, podPort, err := d.resolve(ctx, service, namespace, int32(servicePort))
+	if err != nil {
+		return nil, err
+	}
+	tunnel, err := d.tunnel(ctx, pod, podPort)
+	if err != nil {
+		return nil, err
+	}
+	return d.dialer.DialContext(ctx, network, tunnel.localAddress)
+}
+
+// Close stops all port-forwards
+func (d *PortForwardDialer) Close() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for key, tunnel := range d.tunnels {
+		_ = tunnel.cmd.Process.Kill()
+		delete(d.tunnels, key)
+	}
+}
+
+// resolve picks a ready pod behind a service and the pod port the service port targets
+func (d *PortForwardDialer) resolve(ctx context.Context, name, namespace string, port int32) (types.NamespacedName, int32, error) {
+	var service corev1.Service
+	if err := d.reader.Get(ctx, types.NamespacedName{Name: name, Namespace: namespace}, &service); err != nil {
+		return types.NamespacedName{}, 0, fmt.Errorf("failed to get service %s/%s: %w", namespace, name, err)
+	}
+	var portName string
+	for _, servicePort := range service.Spec.Ports {
+		if servicePort.Port == port {
+			portName = servicePort.Name
+		}
+	}
+
+	var endpointSlices discoveryv1.EndpointSliceList
+	if err := d.reader.List(ctx, &endpointSlices, client.InNamespace(namespace), client.MatchingLabels{discoveryv1.LabelServiceName: name}); err != nil {
+		return types.NamespacedName{}, 0, fmt.Errorf("failed to list endpoints of service %s/%s: %w", namespace, name, err)
+	}
+	for _, slice := range endpointSlices.Items {
+		podPort := port
+		for _, slicePort := range slice.Ports {
+			if slicePort.Name != nil && *slicePort.Name == portName && slicePort.Port != nil {
+				podPort = *slicePort.Port
+			}
+		}
+		for _, endpoint := range slice.Endpoints {
+			ready := endpoint.Conditions.Ready == nil || *endpoint.Conditions.Ready
+			if ready && endpoint.TargetRef != nil && endpoint.TargetRef.Kind == "Pod" {
+				return types.NamespacedName{Name: endpoint.TargetRef.Name, Namespace: namespace}, podPort, nil
+			}
+		}
+	}
+	return types.NamespacedName{}, 0, fmt.Errorf("service %s/%s has no ready pod", namespace, name)
+}
+
+// tunnel returns the port-forward to a pod port, starting it if needed
+func (d *PortForwardDialer) tunnel(ctx context.Context, pod types.NamespacedName, port int32) (*tunnel, error) {
+	key := fmt.Sprintf("%s/%d", pod, port)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if existing, ok := d.tunnels[key]; ok {
+		select {
+		case <-existing.done:
+			delete(d.tunnels, key)
+		default:
+			return existing, nil
+		}
+	}
+
+	cmd := exec.Command(d.kubectl, "port-forward", "--namespace", pod.Namespace, "--address", "127.0.0.1",
+		"pod/"+pod.Name, fmt.Sprintf(":%d", port))
+	stdout, output := io.Pipe()
+	cmd.Stdout = output
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start kubectl port-forward: %w", err)
+	}
+
+	started := &tunnel{cmd: cmd, done: make(chan struct{})}
+	ready := make(chan string, 1)
+	go func() {
+		scanner := bufio.NewScanner(stdout)
+		for scanner.Scan() {
+			if match := forwardingPattern.FindStringSubmatch(scanner.Text()); match != nil {
+				ready <- net.JoinHostPort("127.0.0.1", match[1])
+				break
+			}
+		}
+		_, _ = io.Copy(io.Discard, stdout)
+	}()
+	go func() {
+		err := cmd.Wait()
+		_ = output.Close()
+		logf.Log.Info("Port-forward stopped", "pod", pod, "port", port, "error", err)
+		close(started.done)
+	}()
+
+	select {
+	case started.localAddress = <-ready:
+	case <-started.done:
+		return nil, fmt.Errorf("kubectl port-forward to %s exited", pod)
+	case <-ctx.Done():
+		_ = cmd.Process.Kill()
+		return nil, ctx.Err()
+	}
+
+	logf.Log.Info("Port-forward started", "pod", pod, "port", port, "local", started.localAddress)
+	d.tunnels[key] = started
+	return started, nil
+}
+
+// parseServiceHost splits a <service>.<namespace>.svc[.<cluster domain>] host
+func parseServiceHost(host string) (string, string, bool) {
+	parts := strings.Split(strings.TrimSuffix(host, "."), ".")
+	if len(parts) < 3 || parts[2] != "svc" {
+		return "", "", false
+	}
+	return parts[0], parts[1], true
+}
@@ -0,0 +1,48 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+type RolloutService struct {
+	client client.Client
+}
+
+func NewRolloutService(client client.Client) *RolloutService {
+	return &RolloutService{
+		client: client,
+	}
+}
+
+// SetTemplateAnnotation sets an annotation on the pod template of a Deployment, which rolls out new
+// pods when the value changes. Deployments that do not exist are skipped.
+func (s *RolloutService) SetTemplateAnnotation(ctx context.Context, namespace, name, key, value string) error {
+	var deployment appsv1.Deployment
+	if err := s.client.Get(ctx, types.NamespacedName{Name: name, Namespace: namespace}, &deployment); err != nil {
+		if errors.IsNotFound(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to get deployment %s/%s: %w", namespace, name, err)
+	}
+
+	if deployment.Spec.Template.Annotations[key] == value {
+		return nil
+	}
+
+	patch := client.MergeFrom(deployment.DeepCopy())
+	if deployment.Spec.Template.Annotations == nil {
+		deployment.Spec.Template.Annotations = make(map[string]string)
+	}
+	deployment.Spec.Template.Annotations[key] = value
+	if err := s.client.Patch(ctx, &deployment, patch); err != nil {
+		return fmt.Errorf("failed to annotate deployment %s/%s: %w", namespace, name, err)
+	}
+
+	return nil
+}
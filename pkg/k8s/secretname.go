@@ -0,0 +1,105 @@
+package k8s
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"text/template"
+
+	postgresv1 "github.com/silverswarm/pg-operator/api/v1"
+)
+
+// SecretNameData is what secret name templates are rendered with. Database names and user names are
+// lowercased with underscores replaced by dashes, as object names require.
+type SecretNameData struct {
+	Namespace    string
+	Database     string
+	DatabaseName string
+	User         string
+}
+
+// sampleSecretNameData renders templates once when they are configured, so missing fields are reported early
+var sampleSecretNameData = SecretNameData{Namespace: "namespace", Database: "database", DatabaseName: "database", User: "user"}
+
+var secretNameTemplate struct {
+	mu   sync.RWMutex
+	tmpl *template.Template
+}
+
+// SetSecretNameTemplate sets the operator-wide template naming user secrets of Databases that set
+// neither secretName nor secretNameTemplate. An empty template restores <database>-<user>.
+func SetSecretNameTemplate(text string) error {
+	var tmpl *template.Template
+	if text != "" {
+		var err error
+		if tmpl, err = parseSecretNameTemplate(text); err != nil {
+			return err
+		}
+		if _, err := renderSecretName(tmpl, sampleSecretNameData); err != nil {
+			return err
+		}
+	}
+
+	secretNameTemplate.mu.Lock()
+	defer secretNameTemplate.mu.Unlock()
+	secretNameTemplate.tmpl = tmpl
+	return nil
+}
+
+// ValidateSecretNameTemplate reports whether text parses and renders as a secret name template
+func ValidateSecretNameTemplate(text string) error {
+	tmpl, err := parseSecretNameTemplate(text)
+	if err != nil {
+		return err
+	}
+	_, err = renderSecretName(tmpl, sampleSecretNameData)
+	return err
+}
+
+// defaultSecretName returns the name of a user's secret when secretName is not set, falling back to
+// <database>-<user> when the template cannot be rendered
+func defaultSecretName(database *postgresv1.Database, user postgresv1.DatabaseUser) string {
+	data := SecretNameData{
+		Namespace:    database.Namespace,
+		Database:     database.Name,
+		DatabaseName: objectNamePart(database.Spec.DatabaseName),
+		User:         objectNamePart(user.Name),
+	}
+
+	tmpl := operatorSecretNameTemplate()
+	if database.Spec.SecretNameTemplate != "" {
+		tmpl, _ = parseSecretNameTemplate(database.Spec.SecretNameTemplate)
+	}
+	if tmpl != nil {
+		if name, err := renderSecretName(tmpl, data); err == nil && name != "" {
+			return name
+		}
+	}
+	return fmt.Sprintf("%s-%s", data.Database, data.User)
+}
+
+func operatorSecretNameTemplate() *template.Template {
+	secretNameTemplate.mu.RLock()
+	defer secretNameTemplate.mu.RUnlock()
+	return secretNameTemplate.tmpl
+}
+
+func parseSecretNameTemplate(text string) (*template.Template, error) {
+	tmpl, err := template.New("secretName").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid secret name template: %w", err)
+	}
+	return tmpl, nil
+}
+
+func renderSecretName(tmpl *template.Template, data SecretNameData) (string, error) {
+	var name strings.Builder
+	if err := tmpl.Execute(&name, data); err != nil {
+		return "", fmt.Errorf("failed to render secret name template: %w", err)
+	}
+	return strings.TrimSpace(name.String()), nil
+}
+
+func objectNamePart(name string) string {
+	return strings.ReplaceAll(strings.ToLower(name), "_", "-")
+}
@@ -2,7 +2,11 @@ package k8s
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"maps"
+	"slices"
 
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
@@ -27,38 +31,199 @@ func NewSecretService(client client.Client, scheme *runtime.Scheme) *SecretServi
 	}
 }
 
-func (s *SecretService) CreateUserSecret(ctx context.Context, database *postgresv1.Database, user postgresv1.DatabaseUser, password string) error {
-	secretName := user.SecretName
-	if secretName == "" {
-		secretName = fmt.Sprintf("%s-%s", database.Name, user.Name)
+// UserSecretName returns the name of the secret holding a user's credentials. Defaults to the
+// Database's or the operator's secret name template, or <database>-<user> with the user name converted
+// to a valid object name. Immutable secrets get the current revision appended.
+func UserSecretName(database *postgresv1.Database, user postgresv1.DatabaseUser) string {
+	if user.ImmutableSecret {
+		return UserSecretRevisionName(database, user, SecretRevision(database, user.Name))
 	}
+	return UserSecretPointerName(database, user)
+}
+
+// UserSecretPointerName returns a user's secret name without revision. For immutable secrets it names the
+// secret pointing to the current revision.
+func UserSecretPointerName(database *postgresv1.Database, user postgresv1.DatabaseUser) string {
+	if user.SecretName != "" {
+		return user.SecretName
+	}
+	return defaultSecretName(database, user)
+}
+
+// UserSecretRevisionName returns the name of a revision of a user's immutable secret
+func UserSecretRevisionName(database *postgresv1.Database, user postgresv1.DatabaseUser, revision int64) string {
+	return fmt.Sprintf("%s-%d", UserSecretPointerName(database, user), revision)
+}
+
+// SecretRevision returns the revision of a user's immutable secret recorded in the status
+func SecretRevision(database *postgresv1.Database, username string) int64 {
+	for _, record := range database.Status.Credentials {
+		if record.Name == username {
+			return max(record.SecretRevision, 1)
+		}
+	}
+	return 1
+}
+
+// UserSecretNamespace returns the namespace of the secret holding a user's credentials
+func UserSecretNamespace(database *postgresv1.Database, user postgresv1.DatabaseUser) string {
+	if user.SecretNamespace != "" {
+		return user.SecretNamespace
+	}
+	return database.Namespace
+}
+
+// HasUserSecret reports whether the operator keeps a credentials secret for the user.
+// Users that cannot log in have no password and therefore no secret, users with passwordDelivery
+// only get a one-time secret.
+func HasUserSecret(user postgresv1.DatabaseUser) bool {
+	if (user.Login != nil && !*user.Login) || user.PasswordDelivery != nil {
+		return false
+	}
+	return user.CreateSecret == nil || *user.CreateSecret
+}
 
+// ApplySecret creates or updates a secret owned by owner so its data, labels and owner reference
+// match. Keys that are no longer part of data are removed.
+func (s *SecretService) ApplySecret(ctx context.Context, owner client.Object, name string, data map[string][]byte) error {
 	secret := &corev1.Secret{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      secretName,
-			Namespace: database.Namespace,
+			Name:      name,
+			Namespace: owner.GetNamespace(),
 		},
-		Type: corev1.SecretTypeOpaque,
-		Data: map[string][]byte{
-			"username": []byte(user.Name),
-			"password": []byte(password),
+	}
+
+	_, err := controllerutil.CreateOrUpdate(ctx, s.client, secret, func() error {
+		setManagedByLabel(secret)
+		metav1.SetMetaDataAnnotation(&secret.ObjectMeta, postgresv1.SecretChecksumAnnotation, DataChecksum(data))
+		secret.Type = corev1.SecretTypeOpaque
+		secret.Data = data
+		return controllerutil.SetControllerReference(owner, secret, s.scheme)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to apply secret %s: %w", name, err)
+	}
+
+	return nil
+}
+
+// ApplyUserSecret creates or updates a user secret of the database. Secrets in the database's namespace
+// are owned by it; secrets elsewhere are never taken over unless the database manages them. Every user
+// secret carries labels naming the database. Immutable secrets cannot be applied again with different data.
+func (s *SecretService) ApplyUserSecret(ctx context.Context, database *postgresv1.Database, username, namespace, name string, immutable bool, data map[string][]byte) error {
+	return s.applyUserSecret(ctx, database, username, namespace, name, namespace == database.Namespace, nil, immutable, data, "")
+}
+
+// ApplySecretPointer creates or updates the secret without data that points to the current revision of a
+// user's immutable secret
+func (s *SecretService) ApplySecretPointer(ctx context.Context, database *postgresv1.Database, username, namespace, name, current string) error {
+	return s.applyUserSecret(ctx, database, username, namespace, name, namespace == database.Namespace, nil, false, nil, current)
+}
+
+// ApplySecretReplica creates or updates a copy of a user's secret in another namespace
+func (s *SecretService) ApplySecretReplica(ctx context.Context, database *postgresv1.Database, username, namespace, name string, immutable bool, data map[string][]byte) error {
+	return s.applyUserSecret(ctx, database, username, namespace, name, false, map[string]string{postgresv1.ReplicaOfLabel: username}, immutable, data, "")
+}
+
+func (s *SecretService) applyUserSecret(ctx context.Context, database *postgresv1.Database, username, namespace, name string, owned bool,
+	labels map[string]string, immutable bool, data map[string][]byte, current string) error {
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
 		},
 	}
 
-	if err := controllerutil.SetControllerReference(database, secret, s.scheme); err != nil {
-		return fmt.Errorf("failed to set controller reference: %w", err)
+	_, err := controllerutil.CreateOrUpdate(ctx, s.client, secret, func() error {
+		setManagedByLabel(secret)
+		if !owned && !secret.CreationTimestamp.IsZero() && !managedBy(secret, database) {
+			return fmt.Errorf("secret exists and is not managed by Database %s/%s", database.Namespace, database.Name)
+		}
+
+		metav1.SetMetaDataAnnotation(&secret.ObjectMeta, postgresv1.SecretChecksumAnnotation, DataChecksum(data))
+		metav1.SetMetaDataAnnotation(&secret.ObjectMeta, postgresv1.UserAnnotation, username)
+		// A pointer that became the user's own secret again no longer points anywhere
+		delete(secret.Annotations, postgresv1.CurrentSecretAnnotation)
+		if current != "" {
+			metav1.SetMetaDataAnnotation(&secret.ObjectMeta, postgresv1.CurrentSecretAnnotation, current)
+		}
+		secret.Type = corev1.SecretTypeOpaque
+		secret.Data = data
+		if immutable {
+			secret.Immutable = &immutable
+		}
+
+		// A replica that became the user's own secret must not be pruned as a replica
+		delete(secret.Labels, postgresv1.ReplicaOfLabel)
+		for key, value := range labels {
+			secret.Labels[key] = value
+		}
+		secret.Labels[postgresv1.DatabaseNameLabel] = database.Name
+		secret.Labels[postgresv1.DatabaseNamespaceLabel] = database.Namespace
+		if owned {
+			return controllerutil.SetControllerReference(database, secret, s.scheme)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to apply secret %s/%s: %w", namespace, name, err)
+	}
+
+	return nil
+}
+
+// ListUserSecrets returns the user secrets labelled for the database in any namespace
+func (s *SecretService) ListUserSecrets(ctx context.Context, database *postgresv1.Database) ([]corev1.Secret, error) {
+	var secrets corev1.SecretList
+	if err := s.client.List(ctx, &secrets, client.MatchingLabels{
+		postgresv1.DatabaseNameLabel:      database.Name,
+		postgresv1.DatabaseNamespaceLabel: database.Namespace,
+	}); err != nil {
+		return nil, fmt.Errorf("failed to list secrets of Database %s/%s: %w", database.Namespace, database.Name, err)
+	}
+	return secrets.Items, nil
+}
+
+// DeleteUserSecrets deletes the user secrets labelled for the database
+func (s *SecretService) DeleteUserSecrets(ctx context.Context, database *postgresv1.Database) error {
+	secrets, err := s.ListUserSecrets(ctx, database)
+	if err != nil {
+		return err
 	}
 
-	if err := s.client.Create(ctx, secret); err != nil {
-		if errors.IsAlreadyExists(err) {
-			return nil
+	for _, secret := range secrets {
+		if err := s.DeleteSecret(ctx, secret.Name, secret.Namespace); err != nil {
+			return err
 		}
-		return fmt.Errorf("failed to create secret: %w", err)
 	}
 
 	return nil
 }
 
+// DataChecksum returns a hash of secret data that changes whenever a key or value changes
+func DataChecksum(data map[string][]byte) string {
+	hash := sha256.New()
+	for _, key := range slices.Sorted(maps.Keys(data)) {
+		hash.Write([]byte(key))
+		hash.Write([]byte{0})
+		hash.Write(data[key])
+		hash.Write([]byte{0})
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+func setManagedByLabel(secret *corev1.Secret) {
+	if secret.Labels == nil {
+		secret.Labels = make(map[string]string)
+	}
+	secret.Labels["app.kubernetes.io/managed-by"] = "pg-operator"
+}
+
+func managedBy(secret *corev1.Secret, database *postgresv1.Database) bool {
+	return secret.Labels[postgresv1.DatabaseNameLabel] == database.Name &&
+		secret.Labels[postgresv1.DatabaseNamespaceLabel] == database.Namespace
+}
+
 func (s *SecretService) GetSecret(ctx context.Context, name, namespace string) (*corev1.Secret, error) {
 	var secret corev1.Secret
 	key := types.NamespacedName{
@@ -72,3 +237,19 @@ func (s *SecretService) GetSecret(ctx context.Context, name, namespace string) (
 
 	return &secret, nil
 }
+
+// DeleteSecret deletes a secret, ignoring secrets that no longer exist
+func (s *SecretService) DeleteSecret(ctx context.Context, name, namespace string) error {
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+		},
+	}
+
+	if err := s.client.Delete(ctx, secret); err != nil && !errors.IsNotFound(err) {
+		return fmt.Errorf("failed to delete secret %s/%s: %w", namespace, name, err)
+	}
+
+	return nil
+}
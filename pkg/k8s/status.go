@@ -2,18 +2,31 @@ package k8s
 
 import (
 	"context"
+	"fmt"
+	"strings"
 	"time"
 
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	postgresv1 "github.com/silverswarm/pg-operator/api/v1"
+	"github.com/silverswarm/pg-operator/pkg/postgres"
+)
+
+// defaultRetryInterval and minRetryInterval bound how soon a resource that is not ready is reconciled again
+const (
+	defaultRetryInterval = time.Minute
+	minRetryInterval     = time.Second
 )
 
 type StatusService struct {
 	client client.Client
+	// recorder, when set, receives an Event whenever a resource becomes ready or fails to reconcile
+	recorder record.EventRecorder
 }
 
 func NewStatusService(client client.Client) *StatusService {
@@ -22,66 +35,429 @@ func NewStatusService(client client.Client) *StatusService {
 	}
 }
 
-func (s *StatusService) UpdateDatabaseStatus(ctx context.Context, database *postgresv1.Database, ready, databaseCreated bool, usersCreated []string, message string) (ctrl.Result, error) {
+// SetRecorder makes the service report readiness as Events on the resources whose status it updates
+func (s *StatusService) SetRecorder(recorder record.EventRecorder) {
+	s.recorder = recorder
+}
+
+func (s *StatusService) UpdateDatabaseStatus(ctx context.Context, database *postgresv1.Database, ready, databaseCreated bool, message string) (ctrl.Result, error) {
+	wasReady := database.Status.Ready
+	database.Status.ObservedGeneration = database.Generation
 	database.Status.Ready = ready
 	database.Status.DatabaseCreated = databaseCreated
-	database.Status.UsersCreated = usersCreated
 	database.Status.Message = message
+	database.Status.DDLHistory = postgres.AuditedStatements(ctx, database, database.Status.DDLHistory)
 
-	condition := metav1.Condition{
-		Type:               "Ready",
-		Status:             metav1.ConditionFalse,
-		Reason:             "Reconciling",
-		Message:            message,
-		LastTransitionTime: metav1.Now(),
+	setStandardConditions(&database.Status.Conditions, database.Generation, resourceState{
+		ready:        ready,
+		readyMessage: "Database and users are ready",
+		message:      message,
+		stalled:      databaseStalledReason(database),
+	})
+
+	if err := PatchStatus(ctx, s.client, database); err != nil {
+		return ctrl.Result{}, err
 	}
+	s.recordReadiness(database, wasReady, database.Status.Conditions, "ReconcileFailed")
 
-	if ready {
-		condition.Status = metav1.ConditionTrue
-		condition.Reason = "Ready"
-		condition.Message = "Database and users are ready"
+	if !ready {
+		return ctrl.Result{RequeueAfter: RetryInterval(database)}, nil
 	}
 
-	meta.SetStatusCondition(&database.Status.Conditions, condition)
+	return ctrl.Result{}, nil
+}
+
+func (s *StatusService) UpdatePostGresConnectionStatus(ctx context.Context, pgConn *postgresv1.PostGresConnection, ready bool, message string) (ctrl.Result, error) {
+	wasReady := pgConn.Status.Ready
+	pgConn.Status.ObservedGeneration = pgConn.Generation
+	pgConn.Status.Ready = ready
+	pgConn.Status.Message = message
+
+	setStandardConditions(&pgConn.Status.Conditions, pgConn.Generation, resourceState{
+		ready:        ready,
+		readyMessage: "Connection is ready",
+		message:      message,
+	})
+
+	if privileges := pgConn.Status.Privileges; privileges != nil {
+		privilegeCondition := metav1.Condition{
+			Type:               "PrivilegesSufficient",
+			Status:             metav1.ConditionTrue,
+			Reason:             "PrivilegesGranted",
+			Message:            "Connection account can create databases and roles",
+			LastTransitionTime: metav1.Now(),
+			ObservedGeneration: pgConn.Generation,
+		}
+		if len(privileges.Missing) > 0 {
+			privilegeCondition.Status = metav1.ConditionFalse
+			privilegeCondition.Reason = "MissingPrivileges"
+			privilegeCondition.Message = fmt.Sprintf("Connection account lacks %s", strings.Join(privileges.Missing, ", "))
+		}
+		meta.SetStatusCondition(&pgConn.Status.Conditions, privilegeCondition)
+	}
 
-	if err := s.client.Status().Update(ctx, database); err != nil {
+	if err := PatchStatus(ctx, s.client, pgConn); err != nil {
 		return ctrl.Result{}, err
 	}
+	s.recordReadiness(pgConn, wasReady, pgConn.Status.Conditions, "ConnectionFailed")
 
 	if !ready {
-		return ctrl.Result{RequeueAfter: time.Minute}, nil
+		return ctrl.Result{RequeueAfter: RetryInterval(pgConn)}, nil
 	}
 
 	return ctrl.Result{}, nil
 }
 
-func (s *StatusService) UpdatePostGresConnectionStatus(ctx context.Context, pgConn *postgresv1.PostGresConnection, ready bool, message string) (ctrl.Result, error) {
-	pgConn.Status.Ready = ready
-	pgConn.Status.Message = message
+func (s *StatusService) UpdateTemporaryAccessRequestStatus(ctx context.Context, request *postgresv1.TemporaryAccessRequest, message string) (ctrl.Result, error) {
+	request.Status.ObservedGeneration = request.Generation
+	request.Status.Message = message
+
+	setStandardConditions(&request.Status.Conditions, request.Generation, resourceState{
+		ready:        request.Status.Phase == postgresv1.TemporaryAccessActive,
+		readyMessage: message,
+		message:      message,
+		stalled:      phaseReason(request.Status.Phase == postgresv1.TemporaryAccessFailed, "AccessFailed"),
+		finished:     phaseReason(request.Status.Phase == postgresv1.TemporaryAccessExpired, "Expired"),
+	})
 
-	condition := metav1.Condition{
-		Type:               "Ready",
-		Status:             metav1.ConditionFalse,
-		Reason:             "Reconciling",
-		Message:            message,
-		LastTransitionTime: metav1.Now(),
+	if err := PatchStatus(ctx, s.client, request); err != nil {
+		return ctrl.Result{}, err
 	}
 
-	if ready {
-		condition.Status = metav1.ConditionTrue
-		condition.Reason = "Ready"
-		condition.Message = "Connection is ready"
+	switch request.Status.Phase {
+	case postgresv1.TemporaryAccessActive:
+		if until := time.Until(request.Status.ExpiresAt.Time); until > 0 {
+			return ctrl.Result{RequeueAfter: until}, nil
+		}
+		return ctrl.Result{RequeueAfter: RetryInterval(request)}, nil
+	case postgresv1.TemporaryAccessPending:
+		return ctrl.Result{RequeueAfter: RetryInterval(request)}, nil
 	}
 
-	meta.SetStatusCondition(&pgConn.Status.Conditions, condition)
+	return ctrl.Result{}, nil
+}
+
+func (s *StatusService) UpdatePostgresRoleStatus(ctx context.Context, role *postgresv1.PostgresRole, ready bool, message string) (ctrl.Result, error) {
+	role.Status.ObservedGeneration = role.Generation
+	role.Status.Ready = ready
+	role.Status.Message = message
+	role.Status.DDLHistory = postgres.AuditedStatements(ctx, role, role.Status.DDLHistory)
 
-	if err := s.client.Status().Update(ctx, pgConn); err != nil {
+	setStandardConditions(&role.Status.Conditions, role.Generation, resourceState{
+		ready:        ready,
+		readyMessage: "Role is ready",
+		message:      message,
+	})
+
+	if err := PatchStatus(ctx, s.client, role); err != nil {
 		return ctrl.Result{}, err
 	}
 
 	if !ready {
-		return ctrl.Result{RequeueAfter: time.Minute}, nil
+		return ctrl.Result{RequeueAfter: RetryInterval(role)}, nil
 	}
 
 	return ctrl.Result{}, nil
 }
+
+func (s *StatusService) UpdateGrantStatus(ctx context.Context, grant *postgresv1.Grant, ready bool, message string) (ctrl.Result, error) {
+	grant.Status.ObservedGeneration = grant.Generation
+	grant.Status.Ready = ready
+	grant.Status.Message = message
+	grant.Status.DDLHistory = postgres.AuditedStatements(ctx, grant, grant.Status.DDLHistory)
+
+	setStandardConditions(&grant.Status.Conditions, grant.Generation, resourceState{
+		ready:        ready,
+		readyMessage: "Privileges are granted",
+		message:      message,
+	})
+
+	if err := PatchStatus(ctx, s.client, grant); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if !ready {
+		return ctrl.Result{RequeueAfter: RetryInterval(grant)}, nil
+	}
+
+	// Wildcard patterns can match objects created after the last reconcile
+	return ctrl.Result{RequeueAfter: 10 * time.Minute}, nil
+}
+
+func (s *StatusService) UpdateSchemaStatus(ctx context.Context, schema *postgresv1.Schema, ready bool, message string) (ctrl.Result, error) {
+	schema.Status.ObservedGeneration = schema.Generation
+	schema.Status.Ready = ready
+	schema.Status.Message = message
+	schema.Status.DDLHistory = postgres.AuditedStatements(ctx, schema, schema.Status.DDLHistory)
+
+	setStandardConditions(&schema.Status.Conditions, schema.Generation, resourceState{
+		ready:        ready,
+		readyMessage: "Schema is ready",
+		message:      message,
+	})
+
+	if err := PatchStatus(ctx, s.client, schema); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if !ready {
+		return ctrl.Result{RequeueAfter: RetryInterval(schema)}, nil
+	}
+
+	return ctrl.Result{}, nil
+}
+
+func (s *StatusService) UpdateForeignServerStatus(ctx context.Context, server *postgresv1.ForeignServer, ready bool, message string) (ctrl.Result, error) {
+	server.Status.ObservedGeneration = server.Generation
+	server.Status.Ready = ready
+	server.Status.Message = message
+	server.Status.DDLHistory = postgres.AuditedStatements(ctx, server, server.Status.DDLHistory)
+
+	setStandardConditions(&server.Status.Conditions, server.Generation, resourceState{
+		ready:        ready,
+		readyMessage: "Foreign server and user mappings are ready",
+		message:      message,
+	})
+
+	if err := PatchStatus(ctx, s.client, server); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if !ready {
+		return ctrl.Result{RequeueAfter: RetryInterval(server)}, nil
+	}
+
+	return ctrl.Result{}, nil
+}
+
+func (s *StatusService) UpdateSQLMigrationStatus(ctx context.Context, migration *postgresv1.SQLMigration, ready bool, message string) (ctrl.Result, error) {
+	migration.Status.ObservedGeneration = migration.Generation
+	migration.Status.Ready = ready
+	migration.Status.Message = message
+
+	setStandardConditions(&migration.Status.Conditions, migration.Generation, resourceState{
+		ready:        ready,
+		readyMessage: "All migrations are applied",
+		message:      message,
+	})
+
+	if err := PatchStatus(ctx, s.client, migration); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if !ready {
+		return ctrl.Result{RequeueAfter: RetryInterval(migration)}, nil
+	}
+
+	return ctrl.Result{}, nil
+}
+
+func (s *StatusService) UpdateDatabaseBackupStatus(ctx context.Context, backup *postgresv1.DatabaseBackup, message string) (ctrl.Result, error) {
+	backup.Status.ObservedGeneration = backup.Generation
+	backup.Status.Message = message
+
+	setStandardConditions(&backup.Status.Conditions, backup.Generation, resourceState{
+		ready:        backup.Status.Phase == postgresv1.DatabaseBackupCompleted,
+		readyMessage: message,
+		message:      message,
+		stalled:      phaseReason(backup.Status.Phase == postgresv1.DatabaseBackupFailed, "BackupFailed"),
+	})
+
+	if err := PatchStatus(ctx, s.client, backup); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	switch backup.Status.Phase {
+	case postgresv1.DatabaseBackupPending, postgresv1.DatabaseBackupRunning:
+		return ctrl.Result{RequeueAfter: RetryInterval(backup)}, nil
+	}
+
+	return ctrl.Result{}, nil
+}
+
+func (s *StatusService) UpdateDatabaseClaimStatus(ctx context.Context, claim *postgresv1.DatabaseClaim, message string) (ctrl.Result, error) {
+	claim.Status.ObservedGeneration = claim.Generation
+	claim.Status.Message = message
+
+	setStandardConditions(&claim.Status.Conditions, claim.Generation, resourceState{
+		ready:        claim.Status.Phase == postgresv1.DatabaseClaimBound,
+		readyMessage: message,
+		message:      message,
+	})
+
+	if err := PatchStatus(ctx, s.client, claim); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if claim.Status.Phase != postgresv1.DatabaseClaimBound {
+		return ctrl.Result{RequeueAfter: RetryInterval(claim)}, nil
+	}
+
+	return ctrl.Result{}, nil
+}
+
+type statusBaseKey struct{}
+
+// WithStatusBase returns a context remembering obj as the reconcile read it, see PatchStatus
+func WithStatusBase(ctx context.Context, obj client.Object) context.Context {
+	return context.WithValue(ctx, statusBaseKey{}, &statusBase{object: obj.DeepCopyObject().(client.Object)})
+}
+
+// statusBase is the last state of the reconciled object the status patches are computed against
+type statusBase struct {
+	object client.Object
+}
+
+// PatchStatus writes the changes the reconcile made to the status of obj since it read obj, or since its last
+// patch, as a merge patch. Status fields the reconcile left alone keep what others wrote meanwhile, so status
+// writes of different controllers and reconciles do not fail each other with conflicts. Without a base from
+// WithStatusBase the whole status is updated.
+func PatchStatus(ctx context.Context, c client.Client, obj client.Object) error {
+	base, ok := ctx.Value(statusBaseKey{}).(*statusBase)
+	if !ok || base.object.GetUID() != obj.GetUID() {
+		return c.Status().Update(ctx, obj)
+	}
+
+	// The resourceVersion of obj moves on with every write of the reconcile, leaving it out of the patch keeps
+	// the patch unconditional
+	from := base.object.DeepCopyObject().(client.Object)
+	from.SetResourceVersion(obj.GetResourceVersion())
+	if err := c.Status().Patch(ctx, obj, client.MergeFrom(from)); err != nil {
+		return err
+	}
+	base.object = obj.DeepCopyObject().(client.Object)
+	return nil
+}
+
+// Conditions every resource reports, so kstatus, Argo CD, Flux and kubectl wait can tell its state. Ready is True
+// once the resource matches its spec, Progressing while the operator works towards that, Degraded while a resource
+// that was ready fails, and Stalled when it cannot become ready until its spec or environment changes.
+const (
+	ConditionReady       = "Ready"
+	ConditionProgressing = "Progressing"
+	ConditionDegraded    = "Degraded"
+	ConditionStalled     = "Stalled"
+)
+
+// Reasons of the standard conditions, stalled and finished resources use their own
+const (
+	ReasonReady           = "Ready"
+	ReasonReconciling     = "Reconciling"
+	ReasonReconcileFailed = "ReconcileFailed"
+)
+
+// resourceState is what the standard conditions of a resource report
+type resourceState struct {
+	ready bool
+	// readyMessage is the message of the conditions while the resource is ready
+	readyMessage string
+	// message explains why the resource is not ready
+	message string
+	// stalled is the reason the resource cannot become ready without a change, e.g. Conflict
+	stalled string
+	// finished is the reason of a resource that stopped for good without being ready, e.g. Expired
+	finished string
+}
+
+// setStandardConditions sets the Ready, Progressing, Degraded and Stalled conditions of a resource
+func setStandardConditions(conditions *[]metav1.Condition, generation int64, state resourceState) {
+	wasReady := meta.IsStatusConditionTrue(*conditions, ConditionReady) || meta.IsStatusConditionTrue(*conditions, ConditionDegraded)
+	set := func(conditionType string, status bool, reason, message string) {
+		condition := metav1.Condition{
+			Type:               conditionType,
+			Status:             metav1.ConditionFalse,
+			Reason:             reason,
+			Message:            message,
+			ObservedGeneration: generation,
+		}
+		if status {
+			condition.Status = metav1.ConditionTrue
+		}
+		meta.SetStatusCondition(conditions, condition)
+	}
+
+	switch {
+	case state.ready:
+		set(ConditionReady, true, ReasonReady, state.readyMessage)
+		set(ConditionProgressing, false, ReasonReady, state.readyMessage)
+		set(ConditionDegraded, false, ReasonReady, state.readyMessage)
+		set(ConditionStalled, false, ReasonReady, state.readyMessage)
+	case state.stalled != "":
+		set(ConditionReady, false, state.stalled, state.message)
+		set(ConditionProgressing, false, state.stalled, state.message)
+		set(ConditionDegraded, wasReady, state.stalled, state.message)
+		set(ConditionStalled, true, state.stalled, state.message)
+	case state.finished != "":
+		set(ConditionReady, false, state.finished, state.message)
+		set(ConditionProgressing, false, state.finished, state.message)
+		set(ConditionDegraded, false, state.finished, state.message)
+		set(ConditionStalled, false, state.finished, state.message)
+	case wasReady:
+		set(ConditionReady, false, ReasonReconcileFailed, state.message)
+		set(ConditionProgressing, true, ReasonReconcileFailed, state.message)
+		set(ConditionDegraded, true, ReasonReconcileFailed, state.message)
+		set(ConditionStalled, false, ReasonReconcileFailed, state.message)
+	default:
+		set(ConditionReady, false, ReasonReconciling, state.message)
+		set(ConditionProgressing, true, ReasonReconciling, state.message)
+		set(ConditionDegraded, false, ReasonReconciling, state.message)
+		set(ConditionStalled, false, ReasonReconciling, state.message)
+	}
+}
+
+// RetryInterval is how soon a resource that is not ready is reconciled again: a minute, unless its requeue-after
+// annotation holds a valid duration of at least a second
+func RetryInterval(obj client.Object) time.Duration {
+	value, ok := obj.GetAnnotations()[postgresv1.RequeueAfterAnnotation]
+	if !ok {
+		return defaultRetryInterval
+	}
+	interval, err := time.ParseDuration(value)
+	if err != nil || interval < minRetryInterval {
+		return defaultRetryInterval
+	}
+	return interval
+}
+
+// recordReadiness emits a Normal Event when a resource becomes ready and a Warning for every reconcile that
+// leaves it not ready. Warnings use the stalled reason or, for other failures, failure.
+func (s *StatusService) recordReadiness(obj client.Object, wasReady bool, conditions []metav1.Condition, failure string) {
+	ready := meta.FindStatusCondition(conditions, ConditionReady)
+	if s.recorder == nil || ready == nil {
+		return
+	}
+
+	if ready.Status == metav1.ConditionTrue {
+		if !wasReady {
+			s.recorder.Event(obj, corev1.EventTypeNormal, ready.Reason, ready.Message)
+		}
+		return
+	}
+	reason := ready.Reason
+	if reason == ReasonReconciling || reason == ReasonReconcileFailed {
+		reason = failure
+	}
+	s.recorder.Event(obj, corev1.EventTypeWarning, reason, ready.Message)
+}
+
+// databaseStalledReason names the condition that keeps a Database from becoming ready until it, or its
+// connection, changes
+func databaseStalledReason(database *postgresv1.Database) string {
+	switch {
+	case meta.IsStatusConditionTrue(database.Status.Conditions, "Conflict"):
+		return "Conflict"
+	case meta.IsStatusConditionTrue(database.Status.Conditions, "QuotaExceeded"):
+		return "QuotaExceeded"
+	case meta.IsStatusConditionFalse(database.Status.Conditions, "ConnectionWritable"):
+		return "ReadOnlyConnection"
+	}
+	return ""
+}
+
+// phaseReason returns reason when a phase applies
+func phaseReason(applies bool, reason string) string {
+	if !applies {
+		return ""
+	}
+	return reason
+}
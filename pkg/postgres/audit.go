@@ -0,0 +1,128 @@
+package postgres
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	postgresv1 "github.com/silverswarm/pg-operator/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// MaxDDLHistory bounds the statements kept in the status of a resource, the newest are kept
+	MaxDDLHistory = 20
+	// maxAuditedLength truncates long statements such as init scripts in the status and in events
+	maxAuditedLength = 1024
+)
+
+// ddlKeywords start the statements that change the schema, roles or privileges
+var ddlKeywords = []string{"ALTER", "COMMENT", "CREATE", "DROP", "GRANT", "REASSIGN", "REVOKE", "SECURITY"}
+
+// passwordPattern finds password literals of CREATE and ALTER ROLE and of user mapping and server options
+var passwordPattern = regexp.MustCompile(`(?i)((?:^|[\s(,])"?password"?\s+)E?'(?:[^'\\]|''|\\.)*'`)
+
+type auditKey struct{}
+
+// audit collects the DDL statements run for one resource and reports each of them as an Event on it
+type audit struct {
+	recorder record.EventRecorder
+	object   client.Object
+
+	mu         sync.Mutex
+	statements []postgresv1.ExecutedStatement
+}
+
+// WithAudit returns a context whose DDL statements are recorded for object, see AuditedStatements
+func WithAudit(ctx context.Context, recorder record.EventRecorder, object client.Object) context.Context {
+	return context.WithValue(ctx, auditKey{}, &audit{recorder: recorder, object: object})
+}
+
+// AuditedStatements appends the DDL statements recorded for object since the last call to history, keeping
+// the newest MaxDDLHistory
+func AuditedStatements(ctx context.Context, object client.Object, history []postgresv1.ExecutedStatement) []postgresv1.ExecutedStatement {
+	a, ok := ctx.Value(auditKey{}).(*audit)
+	if !ok || a.object.GetUID() != object.GetUID() {
+		return history
+	}
+
+	a.mu.Lock()
+	history = append(history, a.statements...)
+	a.statements = nil
+	a.mu.Unlock()
+
+	if len(history) > MaxDDLHistory {
+		history = history[len(history)-MaxDDLHistory:]
+	}
+	return history
+}
+
+// record keeps a statement that ran with ctx when it is DDL
+func (a *audit) record(statement string, err error) {
+	if !isDDL(statement) {
+		return
+	}
+
+	executed := postgresv1.ExecutedStatement{
+		Statement:  truncate(maskPasswords(strings.TrimSpace(statement))),
+		ExecutedAt: metav1.NewTime(time.Now()),
+	}
+	if err != nil {
+		executed.Error = truncate(err.Error())
+	}
+
+	a.mu.Lock()
+	a.statements = append(a.statements, executed)
+	a.mu.Unlock()
+
+	if a.recorder == nil {
+		return
+	}
+	if err != nil {
+		a.recorder.Eventf(a.object, corev1.EventTypeWarning, "DDLFailed", "%s: %s", executed.Statement, executed.Error)
+	} else {
+		a.recorder.Event(a.object, corev1.EventTypeNormal, "DDLExecuted", executed.Statement)
+	}
+}
+
+func isDDL(statement string) bool {
+	keyword, _, _ := strings.Cut(strings.TrimSpace(statement), " ")
+	for _, ddl := range ddlKeywords {
+		if strings.EqualFold(keyword, ddl) {
+			return true
+		}
+	}
+	return false
+}
+
+// maskPasswords replaces password literals, including SCRAM verifiers, so statements can be shown
+func maskPasswords(statement string) string {
+	return passwordPattern.ReplaceAllString(statement, "${1}'***'")
+}
+
+func truncate(text string) string {
+	if len(text) <= maxAuditedLength {
+		return text
+	}
+	return text[:maxAuditedLength] + "..."
+}
+
+// auditedConn records the DDL statements run on a connection with the audit of their context
+type auditedConn struct {
+	pqConn
+}
+
+func (c *auditedConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	result, err := c.pqConn.ExecContext(ctx, query, args)
+	if a, ok := ctx.Value(auditKey{}).(*audit); ok && !errors.Is(err, driver.ErrSkip) {
+		a.record(query, err)
+	}
+	return result, err
+}
@@ -3,8 +3,15 @@ package postgres
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"maps"
+	"math"
+	"net"
 	"os"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 
 	_ "github.com/lib/pq"
@@ -15,6 +22,8 @@ import (
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 )
 
+const defaultConnectTimeout = 30 * time.Second
+
 type Client struct {
 	k8sClient client.Client
 }
@@ -25,43 +34,419 @@ func NewClient(k8sClient client.Client) *Client {
 	}
 }
 
+// Connect returns a pool of connections to the server. Pools are shared across reconciles, callers must
+// not close them.
 func (c *Client) Connect(ctx context.Context, pgConn *postgresv1.PostGresConnection) (*sql.DB, error) {
-	host, port, username, password, err := c.getConnectionDetails(ctx, pgConn)
+	return c.connect(ctx, pgConn, "")
+}
+
+// ConnectToDatabase connects to a specific database, which is required for
+// schema and object level statements.
+func (c *Client) ConnectToDatabase(ctx context.Context, pgConn *postgresv1.PostGresConnection, databaseName string) (*sql.DB, error) {
+	return c.connect(ctx, pgConn, databaseName)
+}
+
+func (c *Client) connect(ctx context.Context, pgConn *postgresv1.PostGresConnection, databaseName string) (*sql.DB, error) {
+	target, err := c.getTarget(ctx, pgConn)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get connection details: %w", err)
 	}
 
 	log := logf.FromContext(ctx)
-	log.Info("Attempting PostgreSQL connection", "host", host, "port", port, "user", username)
 
-	sslMode := pgConn.Spec.SSLMode
-	if sslMode == "" {
-		sslMode = "require"
+	params := map[string]string{
+		"user":     target.username,
+		"password": target.password,
+		"sslmode":  target.sslMode,
+	}
+	for key, value := range target.params {
+		params[key] = value
+	}
+	if databaseName == "" {
+		databaseName = target.database
+	}
+	if databaseName != "" {
+		params["dbname"] = databaseName
+	}
+
+	caBundle, err := c.getCABundle(ctx, pgConn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get CA bundle: %w", err)
+	}
+	if caBundle != "" {
+		params["sslrootcert"] = caBundle
+		params["sslinline"] = "true"
+	}
+	if timeout := pgConn.Spec.ConnectTimeout; timeout != nil && timeout.Duration > 0 {
+		params["connect_timeout"] = strconv.Itoa(int(math.Ceil(timeout.Duration.Seconds())))
+	}
+
+	// Pools are kept across reconciles; a pool whose server went away or turned standby is replaced
+	key, fingerprint := poolKey(pgConn, databaseName), poolFingerprint(pgConn, params, target)
+	if db := pools.get(key, fingerprint); db != nil {
+		if err := c.check(ctx, pgConn, db, target.sessionAttrs); err == nil {
+			return db, nil
+		}
+		pools.evict(key)
 	}
 
-	connStr := fmt.Sprintf("host=%s port=%d user=%s password=%s sslmode=%s",
-		host, port, username, password, sslMode)
+	// lib/pq connects to a single host, several hosts are tried in order like libpq does
+	var lastErr error
+	for _, server := range target.hosts {
+		log.Info("Attempting PostgreSQL connection", "host", server.host, "port", server.port, "user", target.username, "database", databaseName)
+		params["host"] = server.host
+		params["port"] = strconv.Itoa(int(server.port))
 
-	db, err := sql.Open("postgres", connStr)
+		db, err := c.open(ctx, pgConn, params, target.sessionAttrs)
+		if err == nil {
+			log.Info("Successfully connected to database")
+			pools.put(key, fingerprint, db)
+			return db, nil
+		}
+		lastErr = err
+	}
+
+	return nil, lastErr
+}
+
+// open connects to the host in params, checking that it accepts writes when sessionAttrs asks for it
+func (c *Client) open(ctx context.Context, pgConn *postgresv1.PostGresConnection, params map[string]string, sessionAttrs string) (*sql.DB, error) {
+	connector := &connector{params: maps.Clone(params), settings: sessionSettings(pgConn)}
+	keepAlive, err := keepAliveConfig(connector.params)
 	if err != nil {
-		log.Error(err, "Failed to open database connection")
-		return nil, fmt.Errorf("failed to open database connection: %w", err)
+		return nil, err
+	}
+	connector.keepAlive = keepAlive
+	// An application_name from connectionOptions is kept
+	_, fixedName := params["application_name"]
+	connector.nameSessions = !fixedName
+	if usesToken(pgConn) {
+		connector.credentials = pgConn.Spec.CredentialsFrom
+		connector.namespace = pgConn.Namespace
+	}
+	if serverName := pgConn.Spec.SSLServerName; serverName != "" {
+		connector.dialAddress = net.JoinHostPort(params["host"], params["port"])
+		connector.params["host"] = serverName
 	}
+	db := sql.OpenDB(connector)
+
+	if err := c.check(ctx, pgConn, db, sessionAttrs); err != nil {
+		logf.FromContext(ctx).Error(err, "Failed to connect", "host", params["host"])
+		_ = db.Close()
+		return nil, err
+	}
+	return db, nil
+}
 
-	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+// check pings a pool's server, checking that it accepts writes when sessionAttrs asks for it
+func (c *Client) check(ctx context.Context, pgConn *postgresv1.PostGresConnection, db *sql.DB, sessionAttrs string) error {
+	ctx, cancel := context.WithTimeout(ctx, connectTimeout(pgConn))
 	defer cancel()
 
 	if err := db.PingContext(ctx); err != nil {
-		log.Error(err, "Failed to ping database")
-		db.Close()
-		return nil, fmt.Errorf("failed to ping database: %w", err)
+		if pgConn.Spec.CredentialsFrom != nil && isAuthenticationFailure(err) {
+			// The credentials may have been rotated, read them again on the next attempt
+			externalCredentials.invalidate(pgConn.Namespace, pgConn.Spec.CredentialsFrom)
+		}
+		return fmt.Errorf("failed to ping database: %w", err)
 	}
 
-	log.Info("Successfully connected to database")
-	return db, nil
+	if sessionAttrs == "read-write" || sessionAttrs == "primary" {
+		var inRecovery bool
+		if err := db.QueryRowContext(ctx, "SELECT pg_is_in_recovery()").Scan(&inRecovery); err != nil {
+			return fmt.Errorf("failed to check recovery state: %w", err)
+		}
+		if inRecovery {
+			return fmt.Errorf("server is a standby")
+		}
+	}
+
+	return nil
+}
+
+// connectTimeout is how long connecting to one host may take, 30 seconds unless configured otherwise
+func connectTimeout(pgConn *postgresv1.PostGresConnection) time.Duration {
+	if timeout := pgConn.Spec.ConnectTimeout; timeout != nil && timeout.Duration > 0 {
+		return timeout.Duration
+	}
+	return defaultConnectTimeout
+}
+
+// sessionSettings returns the settings applied to every session of a connection
+func sessionSettings(pgConn *postgresv1.PostGresConnection) map[string]string {
+	settings := make(map[string]string)
+	if timeout := pgConn.Spec.StatementTimeout; timeout != nil {
+		settings["statement_timeout"] = fmt.Sprintf("%dms", timeout.Duration.Milliseconds())
+	}
+	if timeout := pgConn.Spec.LockTimeout; timeout != nil {
+		settings["lock_timeout"] = fmt.Sprintf("%dms", timeout.Duration.Milliseconds())
+	}
+	return settings
+}
+
+// ConnectionEnv returns the libpq environment variables for connecting to a database,
+// for tools such as pg_dump that run outside the operator. The CA bundle is returned
+// separately since libpq only reads it from a file.
+func (c *Client) ConnectionEnv(ctx context.Context, pgConn *postgresv1.PostGresConnection, databaseName string) (map[string]string, string, error) {
+	target, err := c.getTarget(ctx, pgConn)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to get connection details: %w", err)
+	}
+
+	caBundle, err := c.getCABundle(ctx, pgConn)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to get CA bundle: %w", err)
+	}
+
+	hosts, ports := joinHosts(target.hosts)
+	env := map[string]string{
+		"PGHOST":     hosts,
+		"PGPORT":     ports,
+		"PGUSER":     target.username,
+		"PGPASSWORD": target.password,
+		"PGSSLMODE":  target.sslMode,
+		"PGDATABASE": databaseName,
+	}
+	if target.sessionAttrs != "" {
+		env["PGTARGETSESSIONATTRS"] = target.sessionAttrs
+	}
+	if serverName := pgConn.Spec.SSLServerName; serverName != "" {
+		// libpq verifies the certificate against PGHOST and dials PGHOSTADDR, which must be numeric
+		addresses, err := hostAddresses(ctx, target.hosts)
+		if err != nil {
+			return nil, "", err
+		}
+		names := make([]string, len(target.hosts))
+		for i := range names {
+			names[i] = serverName
+		}
+		env["PGHOST"] = strings.Join(names, ",")
+		env["PGHOSTADDR"] = addresses
+	}
+	for param, variable := range map[string]string{"options": "PGOPTIONS", "application_name": "PGAPPNAME", "connect_timeout": "PGCONNECT_TIMEOUT"} {
+		if value := target.params[param]; value != "" {
+			env[variable] = value
+		}
+	}
+	if _, ok := env["PGAPPNAME"]; !ok && applicationName(ctx) != "" {
+		env["PGAPPNAME"] = applicationName(ctx)
+	}
+	if timeout := pgConn.Spec.ConnectTimeout; timeout != nil && timeout.Duration > 0 {
+		env["PGCONNECT_TIMEOUT"] = strconv.Itoa(int(math.Ceil(timeout.Duration.Seconds())))
+	}
+
+	return env, caBundle, nil
+}
+
+// databaseGrantOptions are the database privileges the operator grants to users, which a non-owner can only pass on
+// with the grant option
+var databaseGrantOptions = []string{"CONNECT", "CREATE", "TEMPORARY"}
+
+// schemaGrantOptions are the privileges on the public schema the operator grants to users
+var schemaGrantOptions = []string{"USAGE", "CREATE"}
+
+// CheckPrivileges reports the role attributes of the connected account and which of the privileges needed to
+// manage databases and users are missing. Besides CREATEDB and CREATEROLE these are the grant options on those of
+// the given databases that exist and, since PostgreSQL 16 where CREATEROLE only covers roles the account holds
+// ADMIN OPTION on, ADMIN OPTION on those of the given roles that exist.
+func (c *Client) CheckPrivileges(ctx context.Context, db *sql.DB, databases, roles []string) (*postgresv1.ConnectionPrivileges, error) {
+	var privileges postgresv1.ConnectionPrivileges
+	var version int32
+	query := "SELECT rolsuper, rolcreatedb, rolcreaterole, current_setting('server_version_num')::int FROM pg_roles WHERE rolname = current_user"
+	if err := db.QueryRowContext(ctx, query).Scan(&privileges.Superuser, &privileges.CreateDB, &privileges.CreateRole, &version); err != nil {
+		return nil, fmt.Errorf("failed to query role attributes: %w", err)
+	}
+
+	if privileges.Superuser {
+		return &privileges, nil
+	}
+	if !privileges.CreateDB {
+		privileges.Missing = append(privileges.Missing, "CREATEDB")
+	}
+	if !privileges.CreateRole {
+		privileges.Missing = append(privileges.Missing, "CREATEROLE")
+	}
+
+	for _, name := range databases {
+		var missing []string
+		for _, privilege := range databaseGrantOptions {
+			var granted bool
+			query := "SELECT has_database_privilege(oid, $2) FROM pg_database WHERE datname = $1"
+			err := db.QueryRowContext(ctx, query, name, privilege+" WITH GRANT OPTION").Scan(&granted)
+			if errors.Is(err, sql.ErrNoRows) {
+				break
+			}
+			if err != nil {
+				return nil, fmt.Errorf("failed to check privileges on database %s: %w", name, err)
+			}
+			if !granted {
+				missing = append(missing, privilege)
+			}
+		}
+		if len(missing) > 0 {
+			privileges.Missing = append(privileges.Missing, fmt.Sprintf("%s WITH GRANT OPTION on database %s", strings.Join(missing, ", "), name))
+		}
+	}
+
+	if version < 160000 || !privileges.CreateRole {
+		return &privileges, nil
+	}
+	for _, name := range roles {
+		var admin bool
+		// Roles the account created carry ADMIN OPTION for it, superusers cannot be managed either way
+		query := `SELECT r.rolsuper OR EXISTS(SELECT 1 FROM pg_auth_members m WHERE m.roleid = r.oid AND m.admin_option
+			AND pg_has_role(current_user, m.member, 'USAGE')) FROM pg_roles r WHERE r.rolname = $1`
+		err := db.QueryRowContext(ctx, query, name).Scan(&admin)
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to check ADMIN OPTION on role %s: %w", name, err)
+		}
+		if !admin {
+			privileges.Missing = append(privileges.Missing, fmt.Sprintf("ADMIN OPTION on role %s", name))
+		}
+	}
+
+	return &privileges, nil
 }
 
-func (c *Client) getConnectionDetails(ctx context.Context, pgConn *postgresv1.PostGresConnection) (string, int32, string, string, error) {
+// CheckSchemaPrivileges reports the grant options on the public schema the account lacks in the database db is
+// connected to, which is named database
+func (c *Client) CheckSchemaPrivileges(ctx context.Context, db *sql.DB, database string) ([]string, error) {
+	var missing []string
+	for _, privilege := range schemaGrantOptions {
+		var granted bool
+		query := "SELECT has_schema_privilege(oid, $1) FROM pg_namespace WHERE nspname = 'public'"
+		err := db.QueryRowContext(ctx, query, privilege+" WITH GRANT OPTION").Scan(&granted)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to check privileges on schema public of database %s: %w", database, err)
+		}
+		if !granted {
+			missing = append(missing, privilege)
+		}
+	}
+
+	if len(missing) == 0 {
+		return nil, nil
+	}
+	return []string{fmt.Sprintf("%s WITH GRANT OPTION on schema public of database %s", strings.Join(missing, ", "), database)}, nil
+}
+
+// ServerInfo describes the server at the other end of a connection
+type ServerInfo struct {
+	Version string
+	// VersionNum is server_version_num, e.g. 160004 for 16.4
+	VersionNum int32
+	// Encryption is the TLS version and cipher of the connection, or none
+	Encryption string
+	// Endpoint is the address and port of the server that answered, empty over a Unix socket
+	Endpoint string
+	// ReadOnly is set when the server does not accept writes, as a standby or with default_transaction_read_only
+	ReadOnly bool
+}
+
+// DescribeServer reports the version of the server, how the connection is encrypted and which server answered
+func (c *Client) DescribeServer(ctx context.Context, db *sql.DB) (*ServerInfo, error) {
+	query := `SELECT current_setting('server_version'), current_setting('server_version_num')::int, coalesce(host(inet_server_addr()), ''), coalesce(inet_server_port(), 0),
+		coalesce(s.ssl, false), coalesce(s.version, ''), coalesce(s.cipher, ''),
+		pg_is_in_recovery() OR current_setting('default_transaction_read_only') = 'on'
+		FROM (SELECT pg_backend_pid() AS pid) b LEFT JOIN pg_stat_ssl s ON s.pid = b.pid`
+
+	var info ServerInfo
+	var address, tlsVersion, cipher string
+	var port int
+	var ssl bool
+	if err := db.QueryRowContext(ctx, query).Scan(&info.Version, &info.VersionNum, &address, &port, &ssl, &tlsVersion, &cipher, &info.ReadOnly); err != nil {
+		return nil, fmt.Errorf("failed to describe server: %w", err)
+	}
+
+	info.Encryption = "none"
+	if ssl {
+		info.Encryption = strings.TrimSpace(tlsVersion + " " + cipher)
+	}
+	if address != "" {
+		info.Endpoint = net.JoinHostPort(address, strconv.Itoa(port))
+	}
+	return &info, nil
+}
+
+// Endpoints are the addresses applications reach the server behind a connection at
+type Endpoints struct {
+	Host         string
+	Port         int32
+	ReadOnlyHost string
+	ReadOnlyPort int32
+	PoolerHost   string
+	PoolerPort   int32
+	SSLMode      string
+}
+
+// Endpoints returns the primary's endpoint, the CNPG -ro service balancing connections over the replicas
+// and the connection's pooler. Connections with a custom host or a URI have no -ro service, and connections
+// without a pooler get the primary's endpoint in its place. URIs listing several hosts publish the first.
+// Connections through an auth proxy publish its secretHost, with sslmode require unless set otherwise.
+func (c *Client) Endpoints(ctx context.Context, pgConn *postgresv1.PostGresConnection) (*Endpoints, error) {
+	endpoints := &Endpoints{SSLMode: sslMode(pgConn)}
+	if usesURI(pgConn) {
+		target, err := c.getURITarget(ctx, pgConn)
+		if err != nil {
+			return nil, err
+		}
+		endpoints.Host, endpoints.Port = target.hosts[0].host, target.hosts[0].port
+		endpoints.ReadOnlyHost, endpoints.ReadOnlyPort = endpoints.Host, endpoints.Port
+		if target.sslMode != "" {
+			endpoints.SSLMode = target.sslMode
+		}
+	} else if proxy := pgConn.Spec.Proxy; proxy != nil {
+		// Applications cannot reach the proxy on the operator's loopback interface
+		endpoints.Host, endpoints.Port = proxy.SecretHost, proxy.SecretPort
+		if endpoints.Port == 0 {
+			endpoints.Port = 5432
+		}
+		endpoints.ReadOnlyHost, endpoints.ReadOnlyPort = endpoints.Host, endpoints.Port
+		if pgConn.Spec.SSLMode == "" {
+			endpoints.SSLMode = "require"
+		}
+	} else {
+		endpoints.Host, endpoints.Port = endpoint(pgConn, "rw")
+		endpoints.ReadOnlyHost, endpoints.ReadOnlyPort = endpoint(pgConn, "ro")
+	}
+
+	endpoints.PoolerHost, endpoints.PoolerPort = endpoints.Host, endpoints.Port
+	if host, port, ok := poolerEndpoint(pgConn); ok {
+		endpoints.PoolerHost, endpoints.PoolerPort = host, port
+	}
+
+	return endpoints, nil
+}
+
+// poolerEndpoint returns the host and port of the connection's pooler, reporting false without one
+func poolerEndpoint(pgConn *postgresv1.PostGresConnection) (string, int32, bool) {
+	pooler := pgConn.Spec.Pooler
+	if pooler == nil || (pooler.Name == "" && pooler.Host == "") {
+		return "", 0, false
+	}
+
+	host := pooler.Host
+	if host == "" {
+		host = serviceHost(pgConn, pooler.Name)
+	}
+	port := pooler.Port
+	if port == 0 {
+		port = 5432
+	}
+	return host, port, true
+}
+
+func endpoint(pgConn *postgresv1.PostGresConnection, service string) (string, int32) {
+	if proxy := pgConn.Spec.Proxy; proxy != nil {
+		return proxyHost, proxy.Port
+	}
+
 	host := pgConn.Spec.Host
 	port := pgConn.Spec.Port
 	if port == 0 {
@@ -69,42 +454,224 @@ func (c *Client) getConnectionDetails(ctx context.Context, pgConn *postgresv1.Po
 	}
 
 	if host == "" {
-		clusterNamespace := pgConn.Spec.ClusterNamespace
-		if clusterNamespace == "" {
-			clusterNamespace = pgConn.Namespace
+		host = serviceHost(pgConn, pgConn.Spec.ClusterName+"-"+service)
+	}
+
+	return host, port
+}
+
+// serviceHost returns the cluster DNS name of a service in the CNPG cluster's namespace
+func serviceHost(pgConn *postgresv1.PostGresConnection, service string) string {
+	clusterNamespace := pgConn.Spec.ClusterNamespace
+	if clusterNamespace == "" {
+		clusterNamespace = pgConn.Namespace
+	}
+
+	return ServiceHost(service, clusterNamespace)
+}
+
+// ServiceHost returns the cluster DNS name of a service
+func ServiceHost(service, namespace string) string {
+	clusterDomain := os.Getenv("KUBERNETES_CLUSTER_DOMAIN")
+	if clusterDomain == "" {
+		clusterDomain = "cluster.local"
+	}
+
+	return fmt.Sprintf("%s.%s.svc.%s", service, namespace, clusterDomain)
+}
+
+// hostAddresses resolves hosts to the comma separated numeric addresses libpq accepts in PGHOSTADDR
+func hostAddresses(ctx context.Context, hosts []hostPort) (string, error) {
+	addresses := make([]string, 0, len(hosts))
+	for _, server := range hosts {
+		if net.ParseIP(server.host) != nil {
+			addresses = append(addresses, server.host)
+			continue
+		}
+		resolved, err := net.DefaultResolver.LookupHost(ctx, server.host)
+		if err != nil {
+			return "", fmt.Errorf("failed to resolve %s: %w", server.host, err)
 		}
+		addresses = append(addresses, resolved[0])
+	}
+	return strings.Join(addresses, ","), nil
+}
 
-		clusterDomain := os.Getenv("KUBERNETES_CLUSTER_DOMAIN")
-		if clusterDomain == "" {
-			clusterDomain = "cluster.local"
+// sslMode returns the sslmode of a connection, require unless configured otherwise. Connections through an
+// auth proxy default to disable, the proxy encrypts the connection to the instance itself.
+func sslMode(pgConn *postgresv1.PostGresConnection) string {
+	if pgConn.Spec.SSLMode == "" {
+		if pgConn.Spec.Proxy != nil {
+			return "disable"
+		}
+		return "require"
+	}
+	return pgConn.Spec.SSLMode
+}
+
+// usesURI reports whether a connection is described by a connection URI instead of a CNPG cluster
+func usesURI(pgConn *postgresv1.PostGresConnection) bool {
+	return pgConn.Spec.URI != "" || pgConn.Spec.URISecretRef != nil
+}
+
+// getTarget resolves the hosts, credentials and parameters of a connection. Credentials missing from
+// a connection URI come from superUserSecret or credentialsFrom.
+func (c *Client) getTarget(ctx context.Context, pgConn *postgresv1.PostGresConnection) (*connectionTarget, error) {
+	target := &connectionTarget{params: make(map[string]string)}
+	if usesURI(pgConn) {
+		var err error
+		if target, err = c.getURITarget(ctx, pgConn); err != nil {
+			return nil, err
+		}
+	} else {
+		service := "rw"
+		if pgConn.Spec.ReadOnly {
+			service = "ro"
+		}
+		host, port := endpoint(pgConn, service)
+		if pooler := pgConn.Spec.Pooler; pooler != nil && pooler.Management && pgConn.Spec.Proxy == nil {
+			if poolerHost, poolerPort, ok := poolerEndpoint(pgConn); ok {
+				host, port = poolerHost, poolerPort
+			}
 		}
+		target.hosts = []hostPort{{host: host, port: port}}
+		for _, fallback := range pgConn.Spec.FallbackHosts {
+			port := fallback.Port
+			if port == 0 {
+				port = 5432
+			}
+			target.hosts = append(target.hosts, hostPort{host: fallback.Host, port: port})
+		}
+		if len(pgConn.Spec.FallbackHosts) > 0 {
+			target.sessionAttrs = "read-write"
+		}
+	}
+	if target.sslMode == "" {
+		target.sslMode = sslMode(pgConn)
+	}
+	// Credentials of external providers are only sent to a server whose certificate names it. An auth proxy
+	// listens on the loopback interface and verifies the server itself.
+	if pgConn.Spec.CredentialsFrom != nil && (pgConn.Spec.Proxy == nil || usesToken(pgConn)) && target.sslMode != "verify-full" {
+		return nil, fmt.Errorf("credentialsFrom requires sslMode verify-full, got %s", target.sslMode)
+	}
+	if usesToken(pgConn) {
+		if err := checkTokenHosts(pgConn, target); err != nil {
+			return nil, err
+		}
+	}
 
-		host = fmt.Sprintf("%s-rw.%s.svc.%s", pgConn.Spec.ClusterName, clusterNamespace, clusterDomain)
+	// The API server does not reject reserved options, a connection could otherwise swap its own credentials
+	if err := ValidateConnectionOptions(pgConn.Spec.ConnectionOptions); err != nil {
+		return nil, err
 	}
+	maps.Copy(target.params, pgConn.Spec.ConnectionOptions)
+
+	if target.password == "" {
+		username, password, err := c.getCredentials(ctx, pgConn)
+		if err != nil {
+			return nil, err
+		}
+		if target.username == "" {
+			target.username = username
+		}
+		target.password = password
+	}
+
+	return target, nil
+}
 
-	username, password, err := c.getCredentials(ctx, pgConn)
+// checkTokenHosts makes sure a connection only sends access tokens to allowed servers. The certificate is
+// verified against sslServerName when it is set, otherwise against every host.
+func checkTokenHosts(pgConn *postgresv1.PostGresConnection, target *connectionTarget) error {
+	if pgConn.Spec.SSLServerName != "" {
+		return externalCredentials.checkTokenHost(pgConn.Spec.SSLServerName)
+	}
+	for _, server := range target.hosts {
+		if err := externalCredentials.checkTokenHost(server.host); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// getURITarget reads and parses the connection URI of a connection
+func (c *Client) getURITarget(ctx context.Context, pgConn *postgresv1.PostGresConnection) (*connectionTarget, error) {
+	uri := pgConn.Spec.URI
+	if ref := pgConn.Spec.URISecretRef; ref != nil {
+		var secret corev1.Secret
+		key := types.NamespacedName{Name: ref.Name, Namespace: pgConn.Namespace}
+		if err := c.k8sClient.Get(ctx, key, &secret); err != nil {
+			return nil, fmt.Errorf("failed to get secret %s: %w", key, err)
+		}
+		uri = string(secret.Data[ref.Key])
+		if uri == "" {
+			return nil, fmt.Errorf("secret %s is missing key %s", key, ref.Key)
+		}
+	}
+
+	target, err := parseURIConnection(uri)
 	if err != nil {
-		return "", 0, "", "", err
+		return nil, fmt.Errorf("invalid connection URI: %w", err)
+	}
+	return target, nil
+}
+
+// ReferencedSecrets lists the secrets a connection reads its credentials, URI and CA bundle from
+func ReferencedSecrets(pgConn *postgresv1.PostGresConnection) []types.NamespacedName {
+	var secrets []types.NamespacedName
+	clusterNamespace := withDefault(pgConn.Spec.ClusterNamespace, pgConn.Namespace)
+
+	switch {
+	case pgConn.Spec.CredentialsFrom != nil:
+	case pgConn.Spec.CredentialsSecretRef != nil:
+		ref := pgConn.Spec.CredentialsSecretRef
+		secrets = append(secrets, types.NamespacedName{Name: ref.Name, Namespace: withDefault(ref.Namespace, pgConn.Namespace)})
+	case pgConn.Spec.SuperUserSecret != nil:
+		ref := pgConn.Spec.SuperUserSecret
+		secrets = append(secrets, types.NamespacedName{Name: ref.Name, Namespace: withDefault(ref.Namespace, pgConn.Namespace)})
+	case pgConn.Spec.ClusterName != "":
+		secrets = append(secrets, types.NamespacedName{Name: pgConn.Spec.ClusterName + "-superuser", Namespace: clusterNamespace})
 	}
 
-	return host, port, username, password, nil
+	if ref := pgConn.Spec.URISecretRef; ref != nil {
+		secrets = append(secrets, types.NamespacedName{Name: ref.Name, Namespace: pgConn.Namespace})
+	}
+
+	if tls := pgConn.Spec.TLS; tls != nil {
+		switch {
+		case tls.CASecretRef != nil:
+			secrets = append(secrets, types.NamespacedName{Name: tls.CASecretRef.Name, Namespace: withDefault(tls.CASecretRef.Namespace, pgConn.Namespace)})
+		case tls.UseClusterCA && pgConn.Spec.ClusterName != "":
+			secrets = append(secrets, types.NamespacedName{Name: pgConn.Spec.ClusterName + "-ca", Namespace: clusterNamespace})
+		}
+	}
+
+	return secrets
 }
 
 func (c *Client) getCredentials(ctx context.Context, pgConn *postgresv1.PostGresConnection) (string, string, error) {
+	if pgConn.Spec.CredentialsFrom != nil {
+		return externalCredentials.get(ctx, pgConn.Namespace, pgConn.Spec.CredentialsFrom)
+	}
+
 	var secretName, secretNamespace string
+	usernameKey, passwordKey := "username", "password"
 
-	if pgConn.Spec.SuperUserSecret != nil {
+	switch {
+	case pgConn.Spec.CredentialsSecretRef != nil:
+		ref := pgConn.Spec.CredentialsSecretRef
+		secretName = ref.Name
+		secretNamespace = withDefault(ref.Namespace, pgConn.Namespace)
+		usernameKey = withDefault(ref.UsernameKey, usernameKey)
+		passwordKey = withDefault(ref.PasswordKey, passwordKey)
+	case pgConn.Spec.SuperUserSecret != nil:
 		secretName = pgConn.Spec.SuperUserSecret.Name
-		secretNamespace = pgConn.Spec.SuperUserSecret.Namespace
-		if secretNamespace == "" {
-			secretNamespace = pgConn.Namespace
-		}
-	} else {
+		secretNamespace = withDefault(pgConn.Spec.SuperUserSecret.Namespace, pgConn.Namespace)
+	case pgConn.Spec.ClusterName == "":
+		return "", "", fmt.Errorf("no credentials configured, set credentialsSecretRef, credentialsFrom or the connection URI")
+	default:
 		secretName = fmt.Sprintf("%s-superuser", pgConn.Spec.ClusterName)
-		secretNamespace = pgConn.Spec.ClusterNamespace
-		if secretNamespace == "" {
-			secretNamespace = pgConn.Namespace
-		}
+		secretNamespace = withDefault(pgConn.Spec.ClusterNamespace, pgConn.Namespace)
 	}
 
 	var secret corev1.Secret
@@ -117,12 +684,93 @@ func (c *Client) getCredentials(ctx context.Context, pgConn *postgresv1.PostGres
 		return "", "", fmt.Errorf("failed to get secret %s: %w", secretKey, err)
 	}
 
-	username := string(secret.Data["username"])
-	password := string(secret.Data["password"])
+	username := string(secret.Data[usernameKey])
+	password := string(secret.Data[passwordKey])
 
 	if username == "" || password == "" {
-		return "", "", fmt.Errorf("secret %s is missing username or password", secretKey)
+		return "", "", fmt.Errorf("secret %s is missing %s or %s", secretKey, usernameKey, passwordKey)
 	}
 
 	return username, password, nil
 }
+
+func (c *Client) getCABundle(ctx context.Context, pgConn *postgresv1.PostGresConnection) (string, error) {
+	tls := pgConn.Spec.TLS
+	if tls == nil {
+		return "", nil
+	}
+
+	if (tls.CASecretRef != nil && tls.CAConfigMapRef != nil) || (tls.UseClusterCA && (tls.CASecretRef != nil || tls.CAConfigMapRef != nil)) {
+		return "", fmt.Errorf("only one of caSecretRef, caConfigMapRef and useClusterCA may be set")
+	}
+
+	var ref *postgresv1.KeyReference
+	switch {
+	case tls.CASecretRef != nil:
+		ref = tls.CASecretRef
+	case tls.CAConfigMapRef != nil:
+		ref = tls.CAConfigMapRef
+	case tls.UseClusterCA:
+		if pgConn.Spec.ClusterName == "" {
+			return "", fmt.Errorf("useClusterCA requires clusterName")
+		}
+		ref = &postgresv1.KeyReference{
+			Name:      fmt.Sprintf("%s-ca", pgConn.Spec.ClusterName),
+			Namespace: pgConn.Spec.ClusterNamespace,
+		}
+	default:
+		return "", nil
+	}
+
+	key := types.NamespacedName{
+		Name:      ref.Name,
+		Namespace: ref.Namespace,
+	}
+	if key.Namespace == "" {
+		key.Namespace = pgConn.Namespace
+	}
+
+	dataKey := ref.Key
+	if dataKey == "" {
+		dataKey = "ca.crt"
+	}
+
+	var bundle string
+	if tls.CAConfigMapRef == nil {
+		var secret corev1.Secret
+		if err := c.k8sClient.Get(ctx, key, &secret); err != nil {
+			return "", fmt.Errorf("failed to get secret %s: %w", key, err)
+		}
+		bundle = string(secret.Data[dataKey])
+	} else {
+		var configMap corev1.ConfigMap
+		if err := c.k8sClient.Get(ctx, key, &configMap); err != nil {
+			return "", fmt.Errorf("failed to get config map %s: %w", key, err)
+		}
+		bundle = configMap.Data[dataKey]
+	}
+
+	if bundle == "" {
+		return "", fmt.Errorf("%s is missing key %s", key, dataKey)
+	}
+
+	return bundle, nil
+}
+
+// buildConnString renders libpq key/value parameters, quoting every value so
+// passwords and inline certificates may contain spaces or quotes.
+func buildConnString(params map[string]string) string {
+	keys := make([]string, 0, len(params))
+	for k := range params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	escaper := strings.NewReplacer(`\`, `\\`, `'`, `\'`)
+	parts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		parts = append(parts, fmt.Sprintf("%s='%s'", k, escaper.Replace(params[k])))
+	}
+
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,77 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"regexp"
+
+	"github.com/lib/pq"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// markerPattern finds the ownership marker in a comment, markers written before the UID was recorded have none
+var markerPattern = regexp.MustCompile(`Managed by pg-operator \((\w+) ([^/\s]+)/([^,\s)]+)(?:, uid ([0-9a-f-]+))?\)`)
+
+// ownershipMarker is the resource named by the marker in a comment
+type ownershipMarker struct {
+	kind string
+	key  types.NamespacedName
+	uid  types.UID
+}
+
+// parseMarker returns the ownership marker of a comment, reporting false without one
+func parseMarker(comment string) (ownershipMarker, bool) {
+	match := markerPattern.FindStringSubmatch(comment)
+	if match == nil {
+		return ownershipMarker{}, false
+	}
+	return ownershipMarker{
+		kind: match[1],
+		key:  types.NamespacedName{Namespace: match[2], Name: match[3]},
+		uid:  types.UID(match[4]),
+	}, true
+}
+
+// sharedObjectQueries read the comment of cluster-wide objects, which live in pg_shdescription
+var sharedObjectQueries = map[string]string{
+	"DATABASE": "SELECT COALESCE(shobj_description(oid, 'pg_database'), '') FROM pg_database WHERE datname = $1",
+	"ROLE":     "SELECT COALESCE(shobj_description(oid, 'pg_authid'), '') FROM pg_roles WHERE rolname = $1",
+}
+
+// managedComment appends the ownership marker naming the managing resource to a user supplied comment
+func managedComment(comment, kind, namespace, name string, uid types.UID) string {
+	marker := fmt.Sprintf("Managed by pg-operator (%s %s/%s, uid %s)", kind, namespace, name, uid)
+	if comment == "" {
+		return marker
+	}
+	return comment + "\n\n" + marker
+}
+
+// sharedComment returns the comment of a database or role
+func sharedComment(ctx context.Context, db *sql.DB, objectType, name string) (string, error) {
+	var current string
+	if err := db.QueryRowContext(ctx, sharedObjectQueries[objectType], name).Scan(&current); err != nil {
+		return "", fmt.Errorf("failed to read comment: %w", err)
+	}
+	return current, nil
+}
+
+// ensureSharedComment sets COMMENT ON DATABASE or ROLE when the current comment differs
+func ensureSharedComment(ctx context.Context, db *sql.DB, objectType, name, comment string) error {
+	current, err := sharedComment(ctx, db, objectType, name)
+	if err != nil {
+		return err
+	}
+
+	if current == comment {
+		return nil
+	}
+
+	query := fmt.Sprintf("COMMENT ON %s %s IS %s", objectType, pq.QuoteIdentifier(name), pq.QuoteLiteral(comment))
+	if _, err := db.ExecContext(ctx, query); err != nil {
+		return fmt.Errorf("failed to set comment: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,234 @@
+package postgres
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"maps"
+	"net"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/lib/pq"
+	postgresv1 "github.com/silverswarm/pg-operator/api/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// Dialer opens the network connections to servers
+type Dialer interface {
+	DialContext(ctx context.Context, network, address string) (net.Conn, error)
+}
+
+// dialer replaces dialing servers directly when set
+var dialer Dialer
+
+// SetDialer routes all connections opened from now on through d, e.g. to tunnel them into the cluster
+// while the operator runs on a developer machine
+func SetDialer(d Dialer) {
+	dialer = d
+}
+
+// connector opens the connections of a pool
+type connector struct {
+	params map[string]string
+	// credentials is set for connections authenticating with an access token. Every connection gets the
+	// current token, so a pool outliving the token it was opened with can still connect.
+	credentials *postgresv1.ExternalCredentials
+	// namespace of the connection the credentials belong to
+	namespace string
+	// dialAddress is dialed instead of the host in params, which then only names the server whose
+	// certificate is verified
+	dialAddress string
+	// settings are SET on every new session, so they also hold behind a pooler that rejects startup parameters
+	settings map[string]string
+	// keepAlive configures TCP keepalives from the libpq keepalives parameters, which lib/pq does not know
+	keepAlive *net.KeepAliveConfig
+	// nameSessions sets application_name to the resource each statement runs for, see WithApplicationName
+	nameSessions bool
+}
+
+type applicationNameKey struct{}
+
+// WithApplicationName returns a context whose statements run with application_name
+// pg-operator/<namespace>/<kind>/<name>, attributing them to the reconciled resource in pg_stat_activity
+// and the server log
+func WithApplicationName(ctx context.Context, kind string, key types.NamespacedName) context.Context {
+	name := "pg-operator/"
+	if key.Namespace != "" {
+		name += key.Namespace + "/"
+	}
+	return context.WithValue(ctx, applicationNameKey{}, name+kind+"/"+key.Name)
+}
+
+func applicationName(ctx context.Context) string {
+	name, _ := ctx.Value(applicationNameKey{}).(string)
+	return name
+}
+
+func (c *connector) Connect(ctx context.Context) (driver.Conn, error) {
+	params := c.params
+	if c.credentials != nil {
+		_, token, err := externalCredentials.get(ctx, c.namespace, c.credentials)
+		if err != nil {
+			return nil, err
+		}
+		params = maps.Clone(c.params)
+		params["password"] = token
+	}
+
+	pqConnector, err := pq.NewConnector(buildConnString(params))
+	if err != nil {
+		return nil, err
+	}
+	if c.dialAddress != "" || c.keepAlive != nil || dialer != nil {
+		tcp := &tcpDialer{address: c.dialAddress, base: dialer}
+		if c.keepAlive != nil {
+			tcp.dialer.KeepAliveConfig = *c.keepAlive
+			if !c.keepAlive.Enable {
+				tcp.dialer.KeepAlive = -1
+			}
+		}
+		pqConnector.Dialer(tcp)
+	}
+	conn, err := pqConnector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(c.settings)) {
+		statement := fmt.Sprintf("SET %s = %s", pq.QuoteIdentifier(name), pq.QuoteLiteral(c.settings[name]))
+		if _, err := conn.(driver.ExecerContext).ExecContext(ctx, statement, nil); err != nil {
+			_ = conn.Close()
+			return nil, fmt.Errorf("failed to set %s: %w", name, err)
+		}
+	}
+	audited := &auditedConn{pqConn: conn.(pqConn)}
+	if !c.nameSessions {
+		return audited, nil
+	}
+
+	named := &namedConn{pqConn: audited}
+	if err := named.setApplicationName(ctx); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	return named, nil
+}
+
+// pqConn is what a lib/pq connection implements
+type pqConn interface {
+	driver.Conn
+	driver.ConnBeginTx
+	driver.ConnPrepareContext
+	driver.ExecerContext
+	driver.QueryerContext
+	driver.Pinger
+	driver.SessionResetter
+	driver.Validator
+}
+
+// namedConn keeps application_name on the resource whose statements run on the connection. database/sql
+// resets a session with the context of its next user whenever the pool hands the connection out again.
+type namedConn struct {
+	pqConn
+	applicationName string
+}
+
+func (c *namedConn) ResetSession(ctx context.Context) error {
+	if err := c.pqConn.ResetSession(ctx); err != nil {
+		return err
+	}
+	if err := c.setApplicationName(ctx); err != nil {
+		// The connection is in an unknown state, the pool replaces it
+		return driver.ErrBadConn
+	}
+	return nil
+}
+
+func (c *namedConn) setApplicationName(ctx context.Context) error {
+	name := applicationName(ctx)
+	if name == "" || name == c.applicationName {
+		return nil
+	}
+	if _, err := c.ExecContext(ctx, "SET application_name = "+pq.QuoteLiteral(name), nil); err != nil {
+		return fmt.Errorf("failed to set application_name: %w", err)
+	}
+	c.applicationName = name
+	return nil
+}
+
+func (c *connector) Driver() driver.Driver {
+	return &pq.Driver{}
+}
+
+// tcpDialer dials with its own dialer settings, or through base when set. When address is set it dials
+// that address whatever host lib/pq asks for.
+type tcpDialer struct {
+	address string
+	dialer  net.Dialer
+	base    Dialer
+}
+
+func (d *tcpDialer) Dial(network, address string) (net.Conn, error) {
+	return d.DialContext(context.Background(), network, address)
+}
+
+func (d *tcpDialer) DialTimeout(network, address string, timeout time.Duration) (net.Conn, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return d.DialContext(ctx, network, address)
+}
+
+func (d *tcpDialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	if d.base != nil {
+		return d.base.DialContext(ctx, network, d.target(address))
+	}
+	return d.dialer.DialContext(ctx, network, d.target(address))
+}
+
+func (d *tcpDialer) target(address string) string {
+	if d.address != "" {
+		return d.address
+	}
+	return address
+}
+
+// keepAliveConfig takes the libpq keepalives parameters out of params, lib/pq would send them to the
+// server as settings it does not know. It returns nil when params holds none of them.
+func keepAliveConfig(params map[string]string) (*net.KeepAliveConfig, error) {
+	values := make(map[string]int)
+	for _, name := range []string{"keepalives", "keepalives_idle", "keepalives_interval", "keepalives_count"} {
+		value, ok := params[name]
+		if !ok {
+			continue
+		}
+		delete(params, name)
+
+		number, err := strconv.Atoi(value)
+		if err != nil || number < 0 {
+			return nil, fmt.Errorf("invalid %s %q", name, value)
+		}
+		values[name] = number
+	}
+	if len(values) == 0 {
+		return nil, nil
+	}
+
+	// Like in libpq, zero keeps the system default
+	config := &net.KeepAliveConfig{Enable: true, Idle: -1, Interval: -1, Count: -1}
+	if enabled, ok := values["keepalives"]; ok && enabled == 0 {
+		config.Enable = false
+		return config, nil
+	}
+	if idle := values["keepalives_idle"]; idle > 0 {
+		config.Idle = time.Duration(idle) * time.Second
+	}
+	if interval := values["keepalives_interval"]; interval > 0 {
+		config.Interval = time.Duration(interval) * time.Second
+	}
+	if count := values["keepalives_count"]; count > 0 {
+		config.Count = count
+	}
+	return config, nil
+}
@@ -3,11 +3,18 @@ package postgres
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 
+	"github.com/lib/pq"
 	postgresv1 "github.com/silverswarm/pg-operator/api/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
+// ErrDatabaseOwned is returned for a database whose ownership marker names another existing Database
+var ErrDatabaseOwned = errors.New("database is managed by another resource")
+
 type DatabaseService struct {
 	client *Client
 }
@@ -25,16 +32,64 @@ func (s *DatabaseService) EnsureDatabase(ctx context.Context, db *sql.DB, databa
 	}
 
 	if exists {
-		return true, nil
+		if err := s.CheckOwnership(ctx, db, database); err != nil {
+			return true, err
+		}
+	}
+
+	if err := s.ensureOwnerRole(ctx, db, database); err != nil {
+		return exists, err
+	}
+
+	if !exists {
+		if err := s.createDatabase(ctx, db, database); err != nil {
+			return false, fmt.Errorf("failed to create database: %w", err)
+		}
+	} else if err := s.ensureOwnership(ctx, db, database); err != nil {
+		return true, fmt.Errorf("failed to change owner: %w", err)
+	}
+
+	if err := s.checkLocale(ctx, db, database); err != nil {
+		return true, err
+	}
+
+	if err := s.ensureAllowConnections(ctx, db, database); err != nil {
+		return true, fmt.Errorf("failed to update allowConnections: %w", err)
 	}
 
-	if err := s.createDatabase(ctx, db, database); err != nil {
-		return false, fmt.Errorf("failed to create database: %w", err)
+	if err := s.ensureLimits(ctx, db, database); err != nil {
+		return true, fmt.Errorf("failed to update connection limit and template flag: %w", err)
+	}
+
+	if err := s.ensureTablespace(ctx, db, database); err != nil {
+		return true, fmt.Errorf("failed to update tablespace: %w", err)
+	}
+
+	comment := managedComment(database.Spec.Comment, "Database", database.Namespace, database.Name, database.UID)
+	if err := ensureSharedComment(ctx, db, "DATABASE", database.Spec.DatabaseName, comment); err != nil {
+		return true, fmt.Errorf("failed to comment on database: %w", err)
 	}
 
 	return true, nil
 }
 
+// RevokePublic removes the privileges PUBLIC holds by default on the database and its public schema.
+// db must be connected to the database itself.
+func (s *DatabaseService) RevokePublic(ctx context.Context, db *sql.DB, databaseName string) error {
+	if _, err := db.ExecContext(ctx, fmt.Sprintf("REVOKE ALL ON DATABASE %s FROM PUBLIC", pq.QuoteIdentifier(databaseName))); err != nil {
+		return fmt.Errorf("failed to revoke database privileges from PUBLIC: %w", err)
+	}
+	if _, err := db.ExecContext(ctx, "REVOKE CREATE ON SCHEMA public FROM PUBLIC"); err != nil {
+		return fmt.Errorf("failed to revoke CREATE on schema public from PUBLIC: %w", err)
+	}
+	return nil
+}
+
+// RunScript executes a SQL script in a single transaction as the database owner
+func (s *DatabaseService) RunScript(ctx context.Context, db *sql.DB, database *postgresv1.Database, script string) error {
+	return runScriptAs(ctx, db, DatabaseOwner(database), script, nil)
+}
+
 func (s *DatabaseService) databaseExists(ctx context.Context, db *sql.DB, databaseName string) (bool, error) {
 	var exists bool
 	query := "SELECT EXISTS(SELECT 1 FROM pg_database WHERE datname = $1)"
@@ -43,19 +98,352 @@ func (s *DatabaseService) databaseExists(ctx context.Context, db *sql.DB, databa
 }
 
 func (s *DatabaseService) createDatabase(ctx context.Context, db *sql.DB, database *postgresv1.Database) error {
-	owner := database.Spec.Owner
-	if owner == "" {
-		owner = "postgres"
+	if database.Spec.LocaleProvider != "" || database.Spec.IcuLocale != "" {
+		if err := requireVersion(ctx, db, postgres15, "localeProvider and icuLocale"); err != nil {
+			return err
+		}
 	}
 
+	if database.Spec.TemplateDatabase != "" {
+		if err := s.checkTemplate(ctx, db, database); err != nil {
+			return err
+		}
+	}
+
+	return execStep(ctx, db, StepCreateDatabase, createDatabaseQuery(database))
+}
+
+// checkTemplate makes sure a database may be copied into a new one. Copying reads every object in it, so only
+// databases marked as templates and databases of Databases in the same namespace can be used, never the
+// database of another tenant.
+func (s *DatabaseService) checkTemplate(ctx context.Context, db *sql.DB, database *postgresv1.Database) error {
+	template := database.Spec.TemplateDatabase
+
+	var isTemplate bool
+	var comment string
+	query := "SELECT datistemplate, COALESCE(shobj_description(oid, 'pg_database'), '') FROM pg_database WHERE datname = $1"
+	err := db.QueryRowContext(ctx, query, template).Scan(&isTemplate, &comment)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("template database %s does not exist", template)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read template database %s: %w", template, err)
+	}
+	if isTemplate {
+		return nil
+	}
+
+	marker, ok := parseMarker(comment)
+	if ok && marker.kind == "Database" && marker.key.Namespace == database.Namespace {
+		var other postgresv1.Database
+		err := s.client.k8sClient.Get(ctx, marker.key, &other)
+		if err != nil && !apierrors.IsNotFound(err) {
+			return fmt.Errorf("failed to get Database %s named by the ownership marker of %s: %w", marker.key, template, err)
+		}
+		if err == nil && (marker.uid == "" || marker.uid == other.UID) {
+			return nil
+		}
+	}
+	return fmt.Errorf("template database %s is not a template (isTemplate) and not managed by a Database in namespace %s",
+		template, database.Namespace)
+}
+
+// createDatabaseQuery returns the CREATE DATABASE statement of a database
+func createDatabaseQuery(database *postgresv1.Database) string {
+	owner := DatabaseOwner(database)
+
 	encoding := database.Spec.Encoding
 	if encoding == "" {
 		encoding = "UTF8"
 	}
 
-	createQuery := fmt.Sprintf("CREATE DATABASE %s WITH OWNER %s ENCODING '%s'",
-		database.Spec.DatabaseName, owner, encoding)
+	createQuery := fmt.Sprintf("CREATE DATABASE %s WITH OWNER %s ENCODING %s",
+		pq.QuoteIdentifier(database.Spec.DatabaseName), pq.QuoteIdentifier(owner), pq.QuoteLiteral(encoding))
+	if database.Spec.LcCollate != "" {
+		createQuery = fmt.Sprintf("%s LC_COLLATE %s", createQuery, pq.QuoteLiteral(database.Spec.LcCollate))
+	}
+	if database.Spec.LcCtype != "" {
+		createQuery = fmt.Sprintf("%s LC_CTYPE %s", createQuery, pq.QuoteLiteral(database.Spec.LcCtype))
+	}
+	if database.Spec.LocaleProvider != "" {
+		createQuery = fmt.Sprintf("%s LOCALE_PROVIDER %s", createQuery, pq.QuoteLiteral(database.Spec.LocaleProvider))
+	}
+	if database.Spec.IcuLocale != "" {
+		createQuery = fmt.Sprintf("%s ICU_LOCALE %s", createQuery, pq.QuoteLiteral(database.Spec.IcuLocale))
+	}
+
+	switch {
+	case database.Spec.TemplateDatabase != "":
+		createQuery = fmt.Sprintf("%s TEMPLATE %s", createQuery, pq.QuoteIdentifier(database.Spec.TemplateDatabase))
+	case hasLocaleOptions(database):
+		// template1 may contain data sorted under its own locale, so a different locale needs template0
+		createQuery += " TEMPLATE template0"
+	}
+	if database.Spec.Tablespace != "" {
+		createQuery = fmt.Sprintf("%s TABLESPACE %s", createQuery, pq.QuoteIdentifier(database.Spec.Tablespace))
+	}
+	return fmt.Sprintf("%s CONNECTION LIMIT %d IS_TEMPLATE %t", createQuery,
+		connectionLimit(database), database.Spec.IsTemplate)
+}
+
+// DatabaseOwner returns the role owning a database, postgres unless spec.owner names another
+func DatabaseOwner(database *postgresv1.Database) string {
+	if database.Spec.Owner == "" {
+		return "postgres"
+	}
+	return database.Spec.Owner
+}
+
+// ensureOwnerRole makes sure the owner exists before the database references it, creating
+// a NOLOGIN role when createOwner is set
+func (s *DatabaseService) ensureOwnerRole(ctx context.Context, db *sql.DB, database *postgresv1.Database) error {
+	owner := database.Spec.Owner
+	if owner == "" {
+		return nil
+	}
+
+	var exists bool
+	query := "SELECT EXISTS(SELECT 1 FROM pg_roles WHERE rolname = $1)"
+	if err := db.QueryRowContext(ctx, query, owner).Scan(&exists); err != nil {
+		return fmt.Errorf("failed to check if owner exists: %w", err)
+	}
+
+	if exists {
+		return nil
+	}
+	if !database.Spec.CreateOwner {
+		return fmt.Errorf("owner role %s does not exist; create it or set createOwner to true", owner)
+	}
+
+	if _, err := db.ExecContext(ctx, fmt.Sprintf("CREATE ROLE %s NOLOGIN", pq.QuoteIdentifier(owner))); err != nil {
+		return fmt.Errorf("failed to create owner role: %w", err)
+	}
+
+	return nil
+}
+
+// ensureOwnership transfers an existing database to spec.owner when it is owned by another role
+func (s *DatabaseService) ensureOwnership(ctx context.Context, db *sql.DB, database *postgresv1.Database) error {
+	if database.Spec.Owner == "" {
+		return nil
+	}
+
+	var current string
+	query := "SELECT pg_get_userbyid(datdba) FROM pg_database WHERE datname = $1"
+	if err := db.QueryRowContext(ctx, query, database.Spec.DatabaseName).Scan(&current); err != nil {
+		return err
+	}
+
+	if current == database.Spec.Owner {
+		return nil
+	}
 
-	_, err := db.ExecContext(ctx, createQuery)
+	alterQuery := fmt.Sprintf("ALTER DATABASE %s OWNER TO %s", pq.QuoteIdentifier(database.Spec.DatabaseName), pq.QuoteIdentifier(database.Spec.Owner))
+	_, err := db.ExecContext(ctx, alterQuery)
 	return err
 }
+
+// CheckOwnership refuses an existing database whose ownership marker names another Database that still
+// exists, e.g. one in another namespace using the same databaseName. Databases without a marker and
+// markers of Databases that were deleted, or deleted and recreated under the same name, are taken over.
+func (s *DatabaseService) CheckOwnership(ctx context.Context, db *sql.DB, database *postgresv1.Database) error {
+	comment, err := sharedComment(ctx, db, "DATABASE", database.Spec.DatabaseName)
+	if err != nil {
+		return err
+	}
+	marker, ok := parseMarker(comment)
+	if !ok || marker.kind != "Database" || marker.uid == database.UID {
+		return nil
+	}
+	if marker.uid == "" && marker.key == client.ObjectKeyFromObject(database) {
+		return nil
+	}
+
+	var other postgresv1.Database
+	err = s.client.k8sClient.Get(ctx, marker.key, &other)
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get Database %s named by the ownership marker: %w", marker.key, err)
+	}
+	if marker.uid != "" && other.UID != marker.uid {
+		return nil
+	}
+	return fmt.Errorf("%w: database %s belongs to Database %s", ErrDatabaseOwned, database.Spec.DatabaseName, marker.key)
+}
+
+// DatabaseOwner returns the role owning a database, or "" when the database does not exist
+func (s *DatabaseService) DatabaseOwner(ctx context.Context, db *sql.DB, databaseName string) (string, error) {
+	var owner string
+	query := "SELECT pg_get_userbyid(datdba) FROM pg_database WHERE datname = $1"
+	err := db.QueryRowContext(ctx, query, databaseName).Scan(&owner)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	return owner, err
+}
+
+// DropDatabase drops a database with everything in it. Other sessions still connected make it fail unless
+// force is set, which terminates them, with WITH (FORCE) on servers supporting it. Missing databases are ignored.
+func (s *DatabaseService) DropDatabase(ctx context.Context, db *sql.DB, databaseName string, force bool) error {
+	// The operator's own pools must not count as sessions
+	pools.closeDatabase(databaseName)
+
+	dropQuery := fmt.Sprintf("DROP DATABASE IF EXISTS %s", pq.QuoteIdentifier(databaseName))
+	if force {
+		version, err := serverVersionNum(ctx, db)
+		if err != nil {
+			return err
+		}
+		if version >= postgres13 {
+			dropQuery += " WITH (FORCE)"
+		} else if err := s.terminateSessions(ctx, db, databaseName); err != nil {
+			return err
+		}
+	} else {
+		var sessions int
+		countQuery := "SELECT count(*) FROM pg_stat_activity WHERE datname = $1 AND pid <> pg_backend_pid()"
+		if err := db.QueryRowContext(ctx, countQuery, databaseName).Scan(&sessions); err != nil {
+			return err
+		}
+		if sessions > 0 {
+			return fmt.Errorf("database %s has %d connected sessions; set forceDrop to true to terminate them", databaseName, sessions)
+		}
+	}
+
+	if _, err := db.ExecContext(ctx, dropQuery); err != nil {
+		return fmt.Errorf("failed to drop database: %w", err)
+	}
+	return nil
+}
+
+// checkLocale reports locale settings that differ from the spec. They are fixed at creation,
+// so a mismatch can only be resolved by recreating the database or changing the spec.
+func (s *DatabaseService) checkLocale(ctx context.Context, db *sql.DB, database *postgresv1.Database) error {
+	if database.Spec.LcCollate == "" && database.Spec.LcCtype == "" {
+		return nil
+	}
+
+	var collate, ctype string
+	query := "SELECT datcollate, datctype FROM pg_database WHERE datname = $1"
+	if err := db.QueryRowContext(ctx, query, database.Spec.DatabaseName).Scan(&collate, &ctype); err != nil {
+		return fmt.Errorf("failed to read locale: %w", err)
+	}
+
+	if database.Spec.LcCollate != "" && collate != database.Spec.LcCollate {
+		return fmt.Errorf("database has lcCollate %s but the spec requires %s; locale settings cannot be changed after creation", collate, database.Spec.LcCollate)
+	}
+	if database.Spec.LcCtype != "" && ctype != database.Spec.LcCtype {
+		return fmt.Errorf("database has lcCtype %s but the spec requires %s; locale settings cannot be changed after creation", ctype, database.Spec.LcCtype)
+	}
+
+	return nil
+}
+
+func hasLocaleOptions(database *postgresv1.Database) bool {
+	return database.Spec.LcCollate != "" || database.Spec.LcCtype != "" ||
+		database.Spec.LocaleProvider != "" || database.Spec.IcuLocale != ""
+}
+
+func (s *DatabaseService) ensureAllowConnections(ctx context.Context, db *sql.DB, database *postgresv1.Database) error {
+	allow := database.Spec.AllowConnections == nil || *database.Spec.AllowConnections
+
+	var current bool
+	query := "SELECT datallowconn FROM pg_database WHERE datname = $1"
+	if err := db.QueryRowContext(ctx, query, database.Spec.DatabaseName).Scan(&current); err != nil {
+		return err
+	}
+
+	if current != allow {
+		alterQuery := fmt.Sprintf("ALTER DATABASE %s WITH ALLOW_CONNECTIONS %t", pq.QuoteIdentifier(database.Spec.DatabaseName), allow)
+		if _, err := db.ExecContext(ctx, alterQuery); err != nil {
+			return err
+		}
+	}
+
+	if !allow && database.Spec.TerminateSessions {
+		return s.terminateSessions(ctx, db, database.Spec.DatabaseName)
+	}
+
+	return nil
+}
+
+// ensureLimits keeps the connection limit and the template flag in line with the spec
+func (s *DatabaseService) ensureLimits(ctx context.Context, db *sql.DB, database *postgresv1.Database) error {
+	limit := connectionLimit(database)
+
+	var currentLimit int32
+	var currentTemplate bool
+	query := "SELECT datconnlimit, datistemplate FROM pg_database WHERE datname = $1"
+	if err := db.QueryRowContext(ctx, query, database.Spec.DatabaseName).Scan(&currentLimit, &currentTemplate); err != nil {
+		return err
+	}
+
+	if currentLimit != limit {
+		alterQuery := fmt.Sprintf("ALTER DATABASE %s WITH CONNECTION LIMIT %d", pq.QuoteIdentifier(database.Spec.DatabaseName), limit)
+		if _, err := db.ExecContext(ctx, alterQuery); err != nil {
+			return err
+		}
+	}
+
+	if currentTemplate != database.Spec.IsTemplate {
+		alterQuery := fmt.Sprintf("ALTER DATABASE %s WITH IS_TEMPLATE %t", pq.QuoteIdentifier(database.Spec.DatabaseName), database.Spec.IsTemplate)
+		if _, err := db.ExecContext(ctx, alterQuery); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func connectionLimit(database *postgresv1.Database) int32 {
+	if database.Spec.ConnectionLimit == nil {
+		return -1
+	}
+	return *database.Spec.ConnectionLimit
+}
+
+// ensureTablespace moves the database to the desired tablespace. PostgreSQL refuses the move while
+// sessions are connected, so the move waits until the database is idle, e.g. through allowConnections.
+func (s *DatabaseService) ensureTablespace(ctx context.Context, db *sql.DB, database *postgresv1.Database) error {
+	if database.Spec.Tablespace == "" {
+		return nil
+	}
+
+	var current string
+	query := `SELECT t.spcname FROM pg_database d
+		JOIN pg_tablespace t ON t.oid = d.dattablespace
+		WHERE d.datname = $1`
+	if err := db.QueryRowContext(ctx, query, database.Spec.DatabaseName).Scan(&current); err != nil {
+		return err
+	}
+
+	if current == database.Spec.Tablespace {
+		return nil
+	}
+
+	// The operator's own pools must not count as sessions
+	pools.closeDatabase(database.Spec.DatabaseName)
+
+	var sessions int
+	countQuery := "SELECT count(*) FROM pg_stat_activity WHERE datname = $1 AND pid <> pg_backend_pid()"
+	if err := db.QueryRowContext(ctx, countQuery, database.Spec.DatabaseName).Scan(&sessions); err != nil {
+		return err
+	}
+	if sessions > 0 {
+		return fmt.Errorf("moving from tablespace %s to %s requires no connected sessions but %d are connected; "+
+			"set allowConnections to false and terminateSessions to true to move the database", current, database.Spec.Tablespace, sessions)
+	}
+
+	alterQuery := fmt.Sprintf("ALTER DATABASE %s SET TABLESPACE %s", pq.QuoteIdentifier(database.Spec.DatabaseName), pq.QuoteIdentifier(database.Spec.Tablespace))
+	return execStep(ctx, db, StepMoveTablespace, alterQuery)
+}
+
+func (s *DatabaseService) terminateSessions(ctx context.Context, db *sql.DB, databaseName string) error {
+	query := "SELECT pg_terminate_backend(pid) FROM pg_stat_activity WHERE datname = $1 AND pid <> pg_backend_pid()"
+	if _, err := db.ExecContext(ctx, query, databaseName); err != nil {
+		return fmt.Errorf("failed to terminate sessions: %w", err)
+	}
+	return nil
+}
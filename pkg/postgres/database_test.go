@@ -0,0 +1,51 @@
+package postgres
+
+import (
+	"testing"
+
+	postgresv1 "github.com/silverswarm/pg-operator/api/v1"
+)
+
+func TestCreateDatabaseQuery(t *testing.T) {
+	tests := []struct {
+		name string
+		spec postgresv1.DatabaseSpec
+		want string
+	}{
+		{
+			name: "defaults",
+			spec: postgresv1.DatabaseSpec{DatabaseName: "orders"},
+			want: `CREATE DATABASE "orders" WITH OWNER "postgres" ENCODING 'UTF8' CONNECTION LIMIT -1 IS_TEMPLATE false`,
+		},
+		{
+			name: "hostile names",
+			spec: postgresv1.DatabaseSpec{
+				DatabaseName: `orders"; DROP DATABASE postgres; --`,
+				Owner:        `app"owner`,
+				Tablespace:   `fast"; --`,
+			},
+			want: `CREATE DATABASE "orders""; DROP DATABASE postgres; --" WITH OWNER "app""owner" ENCODING 'UTF8'` +
+				` TABLESPACE "fast""; --" CONNECTION LIMIT -1 IS_TEMPLATE false`,
+		},
+		{
+			name: "hostile locale and template",
+			spec: postgresv1.DatabaseSpec{
+				DatabaseName:     "orders",
+				Encoding:         "UTF8'; DROP DATABASE postgres; --",
+				LcCollate:        "en_US.UTF-8'",
+				IcuLocale:        "de-DE'--",
+				TemplateDatabase: `template"1`,
+			},
+			want: `CREATE DATABASE "orders" WITH OWNER "postgres" ENCODING 'UTF8''; DROP DATABASE postgres; --'` +
+				` LC_COLLATE 'en_US.UTF-8''' ICU_LOCALE 'de-DE''--' TEMPLATE "template""1" CONNECTION LIMIT -1 IS_TEMPLATE false`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := createDatabaseQuery(&postgresv1.Database{Spec: tt.spec}); got != tt.want {
+				t.Errorf("createDatabaseQuery() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
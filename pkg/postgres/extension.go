@@ -0,0 +1,80 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/lib/pq"
+	postgresv1 "github.com/silverswarm/pg-operator/api/v1"
+)
+
+type ExtensionService struct {
+	client *Client
+}
+
+func NewExtensionService(client *Client) *ExtensionService {
+	return &ExtensionService{
+		client: client,
+	}
+}
+
+// EnsureExtensions installs the extensions of a database and updates those pinned to another version.
+// db must be connected to the target database, since extensions are installed per database.
+func (s *ExtensionService) EnsureExtensions(ctx context.Context, db *sql.DB, extensions []postgresv1.DatabaseExtension) ([]postgresv1.InstalledExtension, error) {
+	installed := make([]postgresv1.InstalledExtension, 0, len(extensions))
+
+	for _, extension := range extensions {
+		version, err := s.installedVersion(ctx, db, extension.Name)
+		if err != nil {
+			return installed, fmt.Errorf("failed to check extension %s: %w", extension.Name, err)
+		}
+
+		switch {
+		case version == "":
+			if err := s.createExtension(ctx, db, extension); err != nil {
+				return installed, fmt.Errorf("failed to create extension %s: %w", extension.Name, err)
+			}
+		case extension.Version != "" && version != extension.Version:
+			query := fmt.Sprintf("ALTER EXTENSION %s UPDATE TO %s", pq.QuoteIdentifier(extension.Name), pq.QuoteLiteral(extension.Version))
+			if _, err := db.ExecContext(ctx, query); err != nil {
+				return installed, fmt.Errorf("failed to update extension %s to %s: %w", extension.Name, extension.Version, err)
+			}
+		}
+
+		if version, err = s.installedVersion(ctx, db, extension.Name); err != nil {
+			return installed, fmt.Errorf("failed to check extension %s: %w", extension.Name, err)
+		}
+		installed = append(installed, postgresv1.InstalledExtension{Name: extension.Name, Version: version})
+	}
+
+	return installed, nil
+}
+
+func (s *ExtensionService) createExtension(ctx context.Context, db *sql.DB, extension postgresv1.DatabaseExtension) error {
+	// Extension names such as uuid-ossp are not valid bare identifiers
+	query := fmt.Sprintf("CREATE EXTENSION IF NOT EXISTS %s", pq.QuoteIdentifier(extension.Name))
+	if extension.Schema != "" {
+		query = fmt.Sprintf("%s SCHEMA %s", query, pq.QuoteIdentifier(extension.Schema))
+	}
+	if extension.Version != "" {
+		query = fmt.Sprintf("%s VERSION %s", query, pq.QuoteLiteral(extension.Version))
+	}
+	if extension.Cascade {
+		query += " CASCADE"
+	}
+
+	_, err := db.ExecContext(ctx, query)
+	return err
+}
+
+// installedVersion returns the installed version of an extension, or an empty string if it is not installed
+func (s *ExtensionService) installedVersion(ctx context.Context, db *sql.DB, name string) (string, error) {
+	var version string
+	err := db.QueryRowContext(ctx, "SELECT extversion FROM pg_extension WHERE extname = $1", name).Scan(&version)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	return version, err
+}
@@ -0,0 +1,206 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+	postgresv1 "github.com/silverswarm/pg-operator/api/v1"
+)
+
+const (
+	defaultCredentialRefresh = 5 * time.Minute
+	// tokenRefreshMargin is how long before expiry an access token is replaced, so that no connection is
+	// opened with a token about to run out
+	tokenRefreshMargin = 5 * time.Minute
+)
+
+// CredentialReader reads credentials kept outside Kubernetes, such as credentials.VaultStore.
+// ReadInNamespace returns nil when there is nothing at path, which lies within the directory of a namespace.
+type CredentialReader interface {
+	ReadInNamespace(ctx context.Context, namespace, path string) (map[string]string, error)
+}
+
+// DefaultTokenHosts are the servers access tokens are sent to unless the operator allows others
+var DefaultTokenHosts = []string{"*.postgres.database.azure.com"}
+
+// TokenSource issues short-lived access tokens used as passwords, such as credentials.AzureTokenSource
+type TokenSource interface {
+	Token(ctx context.Context) (string, time.Time, error)
+}
+
+// externalCredentials caches the credentials of connections using credentialsFrom. It is shared by
+// every Client so a rotated credential is read once for all controllers.
+var externalCredentials = &credentialCache{tokenHosts: DefaultTokenHosts, entries: make(map[string]cachedCredentials)}
+
+// SetCredentialReader configures where connections using credentialsFrom read their credentials
+func SetCredentialReader(reader CredentialReader) {
+	externalCredentials.mu.Lock()
+	defer externalCredentials.mu.Unlock()
+
+	externalCredentials.reader = reader
+	clear(externalCredentials.entries)
+}
+
+// SetTokenSource configures where connections using the azure provider get their access tokens, and the
+// hosts they may send them to. A host pattern starting with *. matches every host below it.
+func SetTokenSource(tokens TokenSource, hosts []string) {
+	externalCredentials.mu.Lock()
+	defer externalCredentials.mu.Unlock()
+
+	externalCredentials.tokens = tokens
+	externalCredentials.tokenHosts = hosts
+	clear(externalCredentials.entries)
+}
+
+type cachedCredentials struct {
+	username  string
+	password  string
+	expiresAt time.Time
+}
+
+type credentialCache struct {
+	mu     sync.Mutex
+	reader CredentialReader
+	tokens TokenSource
+	// tokenHosts are the host patterns access tokens may be sent to
+	tokenHosts []string
+	entries    map[string]cachedCredentials
+}
+
+// get returns the credentials of a connection in namespace
+func (c *credentialCache) get(ctx context.Context, namespace string, source *postgresv1.ExternalCredentials) (string, string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if cached, ok := c.entries[cacheKey(namespace, source)]; ok && time.Now().Before(cached.expiresAt) {
+		return cached.username, cached.password, nil
+	}
+
+	var entry cachedCredentials
+	var err error
+	switch provider(source) {
+	case postgresv1.CredentialsProviderAzure:
+		entry, err = c.getToken(ctx, source)
+	default:
+		entry, err = c.read(ctx, namespace, source)
+	}
+	if err != nil {
+		return "", "", err
+	}
+
+	c.entries[cacheKey(namespace, source)] = entry
+	return entry.username, entry.password, nil
+}
+
+// read reads credentials from the Vault KV engine, caching them for the connection's refresh interval. The
+// path is confined to the namespace of the connection, so it cannot read the credentials of other tenants.
+func (c *credentialCache) read(ctx context.Context, namespace string, source *postgresv1.ExternalCredentials) (cachedCredentials, error) {
+	if c.reader == nil {
+		return cachedCredentials{}, fmt.Errorf("credentialsFrom uses %s, which is not configured in the operator", provider(source))
+	}
+	if source.Vault == nil {
+		return cachedCredentials{}, fmt.Errorf("credentialsFrom uses %s but vault is not set", provider(source))
+	}
+
+	refresh := defaultCredentialRefresh
+	if source.RefreshInterval != nil && source.RefreshInterval.Duration > 0 {
+		refresh = source.RefreshInterval.Duration
+	}
+
+	path := source.Vault.Path
+	data, err := c.reader.ReadInNamespace(ctx, namespace, path)
+	if err != nil {
+		return cachedCredentials{}, fmt.Errorf("failed to read credentials from %s: %w", provider(source), err)
+	}
+
+	username := data[withDefault(source.Vault.UsernameKey, "username")]
+	password := data[withDefault(source.Vault.PasswordKey, "password")]
+	if username == "" || password == "" {
+		return cachedCredentials{}, fmt.Errorf("%s secret %s is missing username or password", provider(source), path)
+	}
+
+	return cachedCredentials{username: username, password: password, expiresAt: time.Now().Add(refresh)}, nil
+}
+
+// getToken obtains an access token used as the password, cached until shortly before it expires
+func (c *credentialCache) getToken(ctx context.Context, source *postgresv1.ExternalCredentials) (cachedCredentials, error) {
+	if c.tokens == nil {
+		return cachedCredentials{}, fmt.Errorf("credentialsFrom uses %s, which is not configured in the operator", provider(source))
+	}
+	if source.Azure == nil {
+		return cachedCredentials{}, fmt.Errorf("credentialsFrom uses %s but azure is not set", provider(source))
+	}
+
+	token, expiresAt, err := c.tokens.Token(ctx)
+	if err != nil {
+		return cachedCredentials{}, fmt.Errorf("failed to get access token from %s: %w", provider(source), err)
+	}
+
+	return cachedCredentials{username: source.Azure.Username, password: token, expiresAt: expiresAt.Add(-tokenRefreshMargin)}, nil
+}
+
+// checkTokenHost rejects servers the operator's access tokens may not be sent to. The token of the
+// operator's identity is valid for every server it has a role on, any other server could replay it.
+func (c *credentialCache) checkTokenHost(host string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	for _, pattern := range c.tokenHosts {
+		pattern = strings.ToLower(pattern)
+		if suffix, ok := strings.CutPrefix(pattern, "*"); ok && strings.HasPrefix(suffix, ".") {
+			if strings.HasSuffix(host, suffix) && len(host) > len(suffix) {
+				return nil
+			}
+		} else if host == pattern {
+			return nil
+		}
+	}
+	return fmt.Errorf("access tokens are not sent to %s, allowed hosts are %s", host, strings.Join(c.tokenHosts, ", "))
+}
+
+// invalidate drops cached credentials so the next connection reads them again
+func (c *credentialCache) invalidate(namespace string, source *postgresv1.ExternalCredentials) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.entries, cacheKey(namespace, source))
+}
+
+func cacheKey(namespace string, source *postgresv1.ExternalCredentials) string {
+	switch {
+	case source.Azure != nil && provider(source) == postgresv1.CredentialsProviderAzure:
+		// Every connection shares the operator's identity, only the role differs
+		return fmt.Sprintf("%s:%s", provider(source), source.Azure.Username)
+	case source.Vault != nil:
+		return fmt.Sprintf("%s:%s:%s:%s:%s", provider(source), namespace, source.Vault.Path, source.Vault.UsernameKey, source.Vault.PasswordKey)
+	}
+	return provider(source)
+}
+
+// isAuthenticationFailure reports whether the server rejected the credentials
+func isAuthenticationFailure(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code.Class() == "28"
+}
+
+func provider(source *postgresv1.ExternalCredentials) string {
+	return withDefault(source.Provider, postgresv1.CredentialsProviderVault)
+}
+
+func withDefault(value, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+	return value
+}
+
+// usesToken reports whether a connection authenticates with an access token
+func usesToken(pgConn *postgresv1.PostGresConnection) bool {
+	return pgConn.Spec.CredentialsFrom != nil && provider(pgConn.Spec.CredentialsFrom) == postgresv1.CredentialsProviderAzure
+}
@@ -0,0 +1,26 @@
+package postgres
+
+import "testing"
+
+func TestCheckTokenHost(t *testing.T) {
+	cache := &credentialCache{tokenHosts: append([]string{"db.internal.example.com"}, DefaultTokenHosts...)}
+
+	tests := []struct {
+		host    string
+		allowed bool
+	}{
+		{host: "payments.postgres.database.azure.com", allowed: true},
+		{host: "Payments.Postgres.Database.Azure.com.", allowed: true},
+		{host: "db.internal.example.com", allowed: true},
+		{host: "postgres.database.azure.com"},
+		{host: "evilpostgres.database.azure.com"},
+		{host: "payments.postgres.database.azure.com.attacker.example"},
+		{host: "other.internal.example.com"},
+	}
+
+	for _, tt := range tests {
+		if err := cache.checkTokenHost(tt.host); (err == nil) != tt.allowed {
+			t.Errorf("checkTokenHost(%s) = %v, want allowed %v", tt.host, err, tt.allowed)
+		}
+	}
+}
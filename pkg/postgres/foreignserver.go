@@ -0,0 +1,166 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/lib/pq"
+	postgresv1 "github.com/silverswarm/pg-operator/api/v1"
+)
+
+type ForeignServerService struct {
+	client *Client
+}
+
+func NewForeignServerService(client *Client) *ForeignServerService {
+	return &ForeignServerService{
+		client: client,
+	}
+}
+
+// EnsureForeignServer installs the wrapper extension and creates the server or aligns its options with the spec.
+// Reports whether this resource created the server.
+func (s *ForeignServerService) EnsureForeignServer(ctx context.Context, db *sql.DB, server *postgresv1.ForeignServer) (bool, error) {
+	name := server.Spec.ServerName
+	wrapper := server.Spec.Wrapper
+	if wrapper == "" {
+		wrapper = "postgres_fdw"
+	}
+
+	for key := range server.Spec.Options {
+		if !configParameterPattern.MatchString(key) {
+			return false, fmt.Errorf("invalid option name %q", key)
+		}
+	}
+
+	if _, err := db.ExecContext(ctx, fmt.Sprintf("CREATE EXTENSION IF NOT EXISTS %s", pq.QuoteIdentifier(wrapper))); err != nil {
+		return false, fmt.Errorf("failed to create extension %s: %w", wrapper, err)
+	}
+
+	var currentOptions []string
+	query := "SELECT COALESCE(srvoptions, '{}') FROM pg_foreign_server WHERE srvname = $1"
+	err := db.QueryRowContext(ctx, query, name).Scan(pq.Array(&currentOptions))
+	if errors.Is(err, sql.ErrNoRows) {
+		createQuery := fmt.Sprintf("CREATE SERVER %s FOREIGN DATA WRAPPER %s", pq.QuoteIdentifier(name), pq.QuoteIdentifier(wrapper))
+		if len(server.Spec.Options) > 0 {
+			createQuery = fmt.Sprintf("%s OPTIONS (%s)", createQuery, optionList(server.Spec.Options, nil))
+		}
+		// The marker is set together with the server, so a server that existed before is never mistaken for
+		// one this resource created
+		comment := managedComment("", "ForeignServer", server.Namespace, server.Name, server.UID)
+		if err := execInTransaction(ctx, db, []string{
+			createQuery,
+			fmt.Sprintf("COMMENT ON SERVER %s IS %s", pq.QuoteIdentifier(name), pq.QuoteLiteral(comment)),
+		}); err != nil {
+			return false, fmt.Errorf("failed to create server: %w", err)
+		}
+		return true, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to read server %s: %w", name, err)
+	}
+
+	current := make(map[string]string, len(currentOptions))
+	for _, option := range currentOptions {
+		key, value, _ := strings.Cut(option, "=")
+		current[key] = value
+	}
+
+	if changes := optionList(server.Spec.Options, current); changes != "" {
+		if _, err := db.ExecContext(ctx, fmt.Sprintf("ALTER SERVER %s OPTIONS (%s)", pq.QuoteIdentifier(name), changes)); err != nil {
+			return false, fmt.Errorf("failed to update server options: %w", err)
+		}
+	}
+
+	return s.CreatedBy(ctx, db, server)
+}
+
+// CreatedBy reports whether the server carries the ownership marker of the resource, which is set when the
+// resource creates it
+func (s *ForeignServerService) CreatedBy(ctx context.Context, db *sql.DB, server *postgresv1.ForeignServer) (bool, error) {
+	var comment string
+	query := "SELECT COALESCE(obj_description(oid, 'pg_foreign_server'), '') FROM pg_foreign_server WHERE srvname = $1"
+	err := db.QueryRowContext(ctx, query, server.Spec.ServerName).Scan(&comment)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to read comment of server %s: %w", server.Spec.ServerName, err)
+	}
+
+	marker, ok := parseMarker(comment)
+	return ok && marker.kind == "ForeignServer" && marker.uid == server.UID, nil
+}
+
+// EnsureUserMapping creates the user mapping or refreshes its credentials
+func (s *ForeignServerService) EnsureUserMapping(ctx context.Context, db *sql.DB, serverName, localUser, remoteUser, remotePassword string) error {
+	createQuery := fmt.Sprintf("CREATE USER MAPPING IF NOT EXISTS FOR %s SERVER %s OPTIONS (user %s, password %s)",
+		pq.QuoteIdentifier(localUser), pq.QuoteIdentifier(serverName), pq.QuoteLiteral(remoteUser), pq.QuoteLiteral(remotePassword))
+	if _, err := db.ExecContext(ctx, createQuery); err != nil {
+		return fmt.Errorf("failed to create user mapping for %s: %w", localUser, err)
+	}
+
+	// IF NOT EXISTS leaves an existing mapping alone, so rotated credentials are applied here
+	alterQuery := fmt.Sprintf("ALTER USER MAPPING FOR %s SERVER %s OPTIONS (SET user %s, SET password %s)",
+		pq.QuoteIdentifier(localUser), pq.QuoteIdentifier(serverName), pq.QuoteLiteral(remoteUser), pq.QuoteLiteral(remotePassword))
+	if _, err := db.ExecContext(ctx, alterQuery); err != nil {
+		return fmt.Errorf("failed to update user mapping for %s: %w", localUser, err)
+	}
+
+	return nil
+}
+
+func (s *ForeignServerService) DropUserMapping(ctx context.Context, db *sql.DB, serverName, localUser string) error {
+	if _, err := db.ExecContext(ctx, fmt.Sprintf("DROP USER MAPPING IF EXISTS FOR %s SERVER %s", pq.QuoteIdentifier(localUser), pq.QuoteIdentifier(serverName))); err != nil {
+		return fmt.Errorf("failed to drop user mapping for %s: %w", localUser, err)
+	}
+	return nil
+}
+
+// DropForeignServer drops the server together with its user mappings and foreign tables
+func (s *ForeignServerService) DropForeignServer(ctx context.Context, db *sql.DB, serverName string) error {
+	if _, err := db.ExecContext(ctx, fmt.Sprintf("DROP SERVER IF EXISTS %s CASCADE", pq.QuoteIdentifier(serverName))); err != nil {
+		return fmt.Errorf("failed to drop server: %w", err)
+	}
+	return nil
+}
+
+// optionList renders an OPTIONS clause. With current set it only lists the ADD, SET and DROP
+// actions needed to turn current into desired.
+func optionList(desired, current map[string]string) string {
+	keys := make([]string, 0, len(desired))
+	for key := range desired {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var options []string
+	for _, key := range keys {
+		name, value := pq.QuoteIdentifier(key), pq.QuoteLiteral(desired[key])
+		switch existing, ok := current[key]; {
+		case current == nil:
+			options = append(options, fmt.Sprintf("%s %s", name, value))
+		case !ok:
+			options = append(options, fmt.Sprintf("ADD %s %s", name, value))
+		case existing != desired[key]:
+			options = append(options, fmt.Sprintf("SET %s %s", name, value))
+		}
+	}
+
+	removed := make([]string, 0, len(current))
+	for key := range current {
+		if _, ok := desired[key]; !ok {
+			removed = append(removed, key)
+		}
+	}
+	sort.Strings(removed)
+	for _, key := range removed {
+		options = append(options, "DROP "+pq.QuoteIdentifier(key))
+	}
+
+	return strings.Join(options, ", ")
+}
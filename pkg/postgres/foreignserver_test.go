@@ -0,0 +1,37 @@
+package postgres
+
+import "testing"
+
+func TestOptionList(t *testing.T) {
+	tests := []struct {
+		name    string
+		desired map[string]string
+		current map[string]string
+		want    string
+	}{
+		{
+			name:    "create",
+			desired: map[string]string{"host": "db.example.com", "port": "5432"},
+			want:    `"host" 'db.example.com', "port" '5432'`,
+		},
+		{
+			name:    "quotes in values",
+			desired: map[string]string{"dbname": "orders'); DROP SERVER remote; --"},
+			want:    `"dbname" 'orders''); DROP SERVER remote; --'`,
+		},
+		{
+			name:    "changes",
+			desired: map[string]string{"host": "new.example.com", "port": "5432"},
+			current: map[string]string{"host": "old.example.com", `fetch"size`: "100"},
+			want:    `SET "host" 'new.example.com', ADD "port" '5432', DROP "fetch""size"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := optionList(tt.desired, tt.current); got != tt.want {
+				t.Errorf("optionList() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,258 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"path"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/lib/pq"
+	postgresv1 "github.com/silverswarm/pg-operator/api/v1"
+)
+
+// objectKind describes how to look up and grant privileges on one type of object
+type objectKind struct {
+	// keyword is used in GRANT ... ON <keyword> <object>
+	keyword string
+	// privileges lists the privileges valid for the object type, ALL expands to these
+	privileges []string
+	// query lists the objects together with the privileges the role holds on them
+	query string
+	// schemaScoped is set when the query filters on a schema
+	schemaScoped bool
+}
+
+// The ACL columns are NULL until privileges are first changed, acldefault supplies the implicit owner privileges
+var objectKinds = map[postgresv1.GrantObjectType]objectKind{
+	postgresv1.GrantObjectSchema: {
+		keyword:    "SCHEMA",
+		privileges: []string{"CREATE", "USAGE"},
+		query: `SELECT n.nspname, quote_ident(n.nspname),
+			ARRAY(SELECT a.privilege_type FROM aclexplode(COALESCE(n.nspacl, acldefault('n', n.nspowner))) a WHERE a.grantee = r.oid)
+			FROM pg_namespace n CROSS JOIN pg_roles r
+			WHERE r.rolname = $1 AND n.nspname NOT LIKE 'pg\_%' AND n.nspname <> 'information_schema'`,
+	},
+	postgresv1.GrantObjectTable: {
+		keyword:    "TABLE",
+		privileges: []string{"DELETE", "INSERT", "REFERENCES", "SELECT", "TRIGGER", "TRUNCATE", "UPDATE"},
+		query: `SELECT c.relname, format('%I.%I', n.nspname, c.relname),
+			ARRAY(SELECT a.privilege_type FROM aclexplode(COALESCE(c.relacl, acldefault('r', c.relowner))) a WHERE a.grantee = r.oid)
+			FROM pg_class c JOIN pg_namespace n ON n.oid = c.relnamespace CROSS JOIN pg_roles r
+			WHERE r.rolname = $1 AND n.nspname = $2 AND c.relkind IN ('r', 'p', 'v', 'm', 'f')`,
+		schemaScoped: true,
+	},
+	postgresv1.GrantObjectSequence: {
+		keyword:    "SEQUENCE",
+		privileges: []string{"SELECT", "UPDATE", "USAGE"},
+		query: `SELECT c.relname, format('%I.%I', n.nspname, c.relname),
+			ARRAY(SELECT a.privilege_type FROM aclexplode(COALESCE(c.relacl, acldefault('s', c.relowner))) a WHERE a.grantee = r.oid)
+			FROM pg_class c JOIN pg_namespace n ON n.oid = c.relnamespace CROSS JOIN pg_roles r
+			WHERE r.rolname = $1 AND n.nspname = $2 AND c.relkind = 'S'`,
+		schemaScoped: true,
+	},
+	postgresv1.GrantObjectFunction: {
+		keyword:    "ROUTINE",
+		privileges: []string{"EXECUTE"},
+		query: `SELECT p.proname, format('%I.%I(%s)', n.nspname, p.proname, pg_get_function_identity_arguments(p.oid)),
+			ARRAY(SELECT a.privilege_type FROM aclexplode(COALESCE(p.proacl, acldefault('f', p.proowner))) a WHERE a.grantee = r.oid)
+			FROM pg_proc p JOIN pg_namespace n ON n.oid = p.pronamespace CROSS JOIN pg_roles r
+			WHERE r.rolname = $1 AND n.nspname = $2`,
+		schemaScoped: true,
+	},
+}
+
+// catalogObject is an object found in pg_catalog and the privileges the grantee holds on it
+type catalogObject struct {
+	name     string
+	identity string
+	held     []string
+}
+
+type GrantService struct {
+	client *Client
+}
+
+func NewGrantService(client *Client) *GrantService {
+	return &GrantService{
+		client: client,
+	}
+}
+
+// EnsureGrant grants the privileges of the spec on every matching object and revokes the
+// privileges this Grant handed out previously that are no longer wanted. Privileges granted
+// outside the operator are left alone. Returns the objects and privileges now managed.
+func (s *GrantService) EnsureGrant(ctx context.Context, db *sql.DB, grant *postgresv1.Grant) ([]string, []string, error) {
+	kind, ok := objectKinds[grant.Spec.ObjectType]
+	if !ok {
+		return nil, nil, fmt.Errorf("unsupported object type %q", grant.Spec.ObjectType)
+	}
+
+	desired, err := expandPrivileges(kind, grant.Spec.Privileges)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	objects, err := s.listObjects(ctx, db, kind, grant)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	role := grant.Spec.Role
+	matched := make([]string, 0, len(objects))
+	for _, object := range objects {
+		if !matchesAny(grant.Spec.Objects, object.name) {
+			continue
+		}
+		matched = append(matched, object.identity)
+
+		if missing := difference(desired, object.held); len(missing) > 0 {
+			query := fmt.Sprintf("GRANT %s ON %s %s TO %s", strings.Join(missing, ", "), kind.keyword, object.identity, pq.QuoteIdentifier(role))
+			if _, err := db.ExecContext(ctx, query); err != nil {
+				return nil, nil, fmt.Errorf("failed to grant %s on %s: %w", strings.Join(missing, ", "), object.identity, err)
+			}
+		}
+
+		stale := intersection(difference(grant.Status.Privileges, desired), object.held)
+		if err := s.revoke(ctx, db, kind, object.identity, role, stale); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	// Objects that no longer match keep nothing this Grant handed out
+	for _, object := range objects {
+		if slices.Contains(matched, object.identity) || !slices.Contains(grant.Status.Objects, object.identity) {
+			continue
+		}
+		stale := intersection(grant.Status.Privileges, object.held)
+		if err := s.revoke(ctx, db, kind, object.identity, role, stale); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	sort.Strings(matched)
+	return matched, desired, nil
+}
+
+// RevokeGrant revokes the privileges recorded in the status from the objects that still exist
+func (s *GrantService) RevokeGrant(ctx context.Context, db *sql.DB, grant *postgresv1.Grant) error {
+	kind, ok := objectKinds[grant.Spec.ObjectType]
+	if !ok {
+		return fmt.Errorf("unsupported object type %q", grant.Spec.ObjectType)
+	}
+
+	objects, err := s.listObjects(ctx, db, kind, grant)
+	if err != nil {
+		return err
+	}
+
+	for _, object := range objects {
+		if !slices.Contains(grant.Status.Objects, object.identity) {
+			continue
+		}
+		stale := intersection(grant.Status.Privileges, object.held)
+		if err := s.revoke(ctx, db, kind, object.identity, grant.Spec.Role, stale); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *GrantService) revoke(ctx context.Context, db *sql.DB, kind objectKind, identity, role string, privileges []string) error {
+	if len(privileges) == 0 {
+		return nil
+	}
+	query := fmt.Sprintf("REVOKE %s ON %s %s FROM %s", strings.Join(privileges, ", "), kind.keyword, identity, pq.QuoteIdentifier(role))
+	if _, err := db.ExecContext(ctx, query); err != nil {
+		return fmt.Errorf("failed to revoke %s on %s: %w", strings.Join(privileges, ", "), identity, err)
+	}
+	return nil
+}
+
+func (s *GrantService) listObjects(ctx context.Context, db *sql.DB, kind objectKind, grant *postgresv1.Grant) ([]catalogObject, error) {
+	var exists bool
+	if err := db.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM pg_roles WHERE rolname = $1)", grant.Spec.Role).Scan(&exists); err != nil {
+		return nil, fmt.Errorf("failed to check if role exists: %w", err)
+	}
+	if !exists {
+		return nil, fmt.Errorf("role %s does not exist", grant.Spec.Role)
+	}
+
+	args := []interface{}{grant.Spec.Role}
+	if kind.schemaScoped {
+		schema := grant.Spec.Schema
+		if schema == "" {
+			schema = "public"
+		}
+		args = append(args, schema)
+	}
+
+	rows, err := db.QueryContext(ctx, kind.query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list objects: %w", err)
+	}
+	defer rows.Close()
+
+	var objects []catalogObject
+	for rows.Next() {
+		var object catalogObject
+		if err := rows.Scan(&object.name, &object.identity, pq.Array(&object.held)); err != nil {
+			return nil, fmt.Errorf("failed to read object: %w", err)
+		}
+		objects = append(objects, object)
+	}
+
+	return objects, rows.Err()
+}
+
+// expandPrivileges resolves ALL and checks every privilege applies to the object type
+func expandPrivileges(kind objectKind, privileges []postgresv1.ObjectPrivilege) ([]string, error) {
+	var expanded []string
+	for _, privilege := range privileges {
+		if privilege == "ALL" {
+			expanded = append(expanded, kind.privileges...)
+			continue
+		}
+		if !slices.Contains(kind.privileges, string(privilege)) {
+			return nil, fmt.Errorf("privilege %s does not apply to %s objects", privilege, strings.ToLower(kind.keyword))
+		}
+		expanded = append(expanded, string(privilege))
+	}
+
+	sort.Strings(expanded)
+	return slices.Compact(expanded), nil
+}
+
+func matchesAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if matched, err := path.Match(pattern, name); err == nil && matched {
+			return true
+		}
+	}
+	return false
+}
+
+// difference returns the entries of a that are not in b
+func difference(a, b []string) []string {
+	var result []string
+	for _, entry := range a {
+		if !slices.Contains(b, entry) {
+			result = append(result, entry)
+		}
+	}
+	return result
+}
+
+// intersection returns the entries of a that are also in b
+func intersection(a, b []string) []string {
+	var result []string
+	for _, entry := range a {
+		if slices.Contains(b, entry) {
+			result = append(result, entry)
+		}
+	}
+	return result
+}
@@ -0,0 +1,72 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/lib/pq"
+	postgresv1 "github.com/silverswarm/pg-operator/api/v1"
+)
+
+// groupPresets maps the group roles, in creation order, to the preset holding their privileges
+var groupPresets = []struct {
+	group  postgresv1.UserGroup
+	preset postgresv1.RolePreset
+}{
+	{postgresv1.UserGroupReadOnly, postgresv1.RolePresetReadOnly},
+	{postgresv1.UserGroupReadWrite, postgresv1.RolePresetReadWrite},
+}
+
+// GroupRoleName returns the name of the group role of a database
+func GroupRoleName(databaseName string, group postgresv1.UserGroup) string {
+	return fmt.Sprintf("%s_%s", databaseName, group)
+}
+
+// EnsureGroupRoles creates the NOLOGIN group roles of a database and grants them their privileges,
+// including default privileges so tables created later by the owner are covered. db must be
+// connected to the database itself.
+func (s *UserService) EnsureGroupRoles(ctx context.Context, db *sql.DB, database *postgresv1.Database) error {
+	for _, group := range groupPresets {
+		role := GroupRoleName(database.Spec.DatabaseName, group.group)
+
+		var exists bool
+		query := "SELECT EXISTS(SELECT 1 FROM pg_roles WHERE rolname = $1)"
+		if err := db.QueryRowContext(ctx, query, role).Scan(&exists); err != nil {
+			return fmt.Errorf("failed to check if group role %s exists: %w", role, err)
+		}
+		if !exists {
+			if _, err := db.ExecContext(ctx, fmt.Sprintf("CREATE ROLE %s NOLOGIN", pq.QuoteIdentifier(role))); err != nil {
+				return fmt.Errorf("failed to create group role %s: %w", role, err)
+			}
+		}
+
+		comment := managedComment("", "Database", database.Namespace, database.Name, database.UID)
+		if err := ensureSharedComment(ctx, db, "ROLE", role, comment); err != nil {
+			return fmt.Errorf("failed to comment on group role %s: %w", role, err)
+		}
+
+		if err := grantBundle(ctx, db, database.Spec.DatabaseName, database.Spec.Owner, role, rolePresets[group.preset]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ensureGroupMembership makes the user a member of its group role only
+func (s *UserService) ensureGroupMembership(ctx context.Context, db *sql.DB, databaseName string, user postgresv1.DatabaseUser) error {
+	for _, group := range groupPresets {
+		role := GroupRoleName(databaseName, group.group)
+
+		query := fmt.Sprintf("REVOKE %s FROM %s", pq.QuoteIdentifier(role), pq.QuoteIdentifier(user.Name))
+		if group.group == user.Group {
+			query = fmt.Sprintf("GRANT %s TO %s", pq.QuoteIdentifier(role), pq.QuoteIdentifier(user.Name))
+		}
+		if _, err := db.ExecContext(ctx, query); err != nil {
+			return fmt.Errorf("failed to update membership in %s: %w", role, err)
+		}
+	}
+
+	return nil
+}
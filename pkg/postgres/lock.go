@@ -0,0 +1,72 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	postgresv1 "github.com/silverswarm/pg-operator/api/v1"
+)
+
+const (
+	// ddlLockTimeout is how long a reconcile waits for the DDL of others on the same connection before it gives
+	// up and retries later
+	ddlLockTimeout = 2 * time.Minute
+	// ddlLockPollInterval is how often a waiting reconcile tries the lock again. Waiters do not keep a
+	// connection of the pool in between, the holder still needs connections for its own statements.
+	ddlLockPollInterval = time.Second
+)
+
+// LockDDL serializes the DDL and GRANT statements run through a connection. Rather than waiting, PostgreSQL
+// fails a CREATE DATABASE while another one copies the template and a GRANT racing another on the same object
+// with "tuple concurrently updated", so the reconciles of one connection take turns. The lock is a transaction
+// level advisory lock on the server, which holds across operator replicas and leader election handovers, and
+// which the server releases when the operator goes away. The returned function releases the lock.
+func (c *Client) LockDDL(ctx context.Context, pgConn *postgresv1.PostGresConnection) (func(), error) {
+	db, err := c.Connect(ctx, pgConn)
+	if err != nil {
+		return nil, err
+	}
+	key := "pg-operator/" + pgConn.Namespace + "/" + pgConn.Name
+
+	ctx, cancel := context.WithTimeout(ctx, ddlLockTimeout)
+	defer cancel()
+
+	for {
+		tx, err := tryAdvisoryLock(ctx, db, key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to lock connection %s for DDL: %w", pgConn.Name, err)
+		}
+		if tx != nil {
+			// Committing ends the transaction and with it the lock, also when ctx is done by then
+			return func() { _ = tx.Commit() }, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("timed out waiting for other resources to finish their changes through connection %s", pgConn.Name)
+		case <-time.After(ddlLockPollInterval):
+		}
+	}
+}
+
+// tryAdvisoryLock returns the transaction holding the lock on key, or nil when another session holds it
+func tryAdvisoryLock(ctx context.Context, db *sql.DB, key string) (*sql.Tx, error) {
+	// The transaction must outlive ctx, whose cancellation would roll it back and release the lock
+	tx, err := db.BeginTx(context.WithoutCancel(ctx), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var locked bool
+	if err := tx.QueryRowContext(ctx, "SELECT pg_try_advisory_xact_lock(hashtext($1))", key).Scan(&locked); err != nil {
+		_ = tx.Rollback()
+		return nil, err
+	}
+	if !locked {
+		_ = tx.Rollback()
+		return nil, nil
+	}
+	return tx, nil
+}
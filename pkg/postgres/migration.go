@@ -0,0 +1,159 @@
+package postgres
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	postgresv1 "github.com/silverswarm/pg-operator/api/v1"
+)
+
+// MigrationScript is a versioned SQL script
+type MigrationScript struct {
+	Version string
+	SQL     string
+}
+
+type MigrationService struct {
+	client *Client
+}
+
+func NewMigrationService(client *Client) *MigrationService {
+	return &MigrationService{
+		client: client,
+	}
+}
+
+// ApplyMigrations runs the scripts that are not yet recorded in the tracking table, in the given order.
+// Each script runs as owner in its own transaction together with its tracking row. A script that was applied
+// with different content stops the run. Returns every migration recorded in the tracking table.
+func (s *MigrationService) ApplyMigrations(ctx context.Context, db *sql.DB, owner, trackingTable string, scripts []MigrationScript) ([]postgresv1.AppliedMigration, error) {
+	createQuery := fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+		version TEXT PRIMARY KEY,
+		checksum TEXT NOT NULL,
+		applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+	)`, pq.QuoteIdentifier(trackingTable))
+	if _, err := db.ExecContext(ctx, createQuery); err != nil {
+		return nil, fmt.Errorf("failed to create tracking table: %w", err)
+	}
+
+	applied, err := s.appliedMigrations(ctx, db, trackingTable)
+	if err != nil {
+		return nil, err
+	}
+	checksums := make(map[string]string, len(applied))
+	for _, migration := range applied {
+		checksums[migration.Version] = migration.Checksum
+	}
+
+	for _, script := range scripts {
+		checksum := scriptChecksum(script.SQL)
+		if existing, ok := checksums[script.Version]; ok {
+			if existing != checksum {
+				return applied, fmt.Errorf("migration %s was changed after it was applied (checksum %s, recorded %s)", script.Version, checksum, existing)
+			}
+			continue
+		}
+
+		if err := s.applyScript(ctx, db, owner, trackingTable, script, checksum); err != nil {
+			return applied, fmt.Errorf("migration %s failed: %w", script.Version, err)
+		}
+		applied = append(applied, postgresv1.AppliedMigration{
+			Version:   script.Version,
+			Checksum:  checksum,
+			AppliedAt: &metav1.Time{Time: time.Now()},
+		})
+	}
+
+	return applied, nil
+}
+
+// scriptChecksum returns the hex encoded SHA-256 of a script
+func scriptChecksum(script string) string {
+	sum := sha256.Sum256([]byte(script))
+	return hex.EncodeToString(sum[:])
+}
+
+func (s *MigrationService) applyScript(ctx context.Context, db *sql.DB, owner, trackingTable string, script MigrationScript, checksum string) error {
+	// The tracking row is written before the script can change the search path or add triggers
+	insertQuery := fmt.Sprintf("INSERT INTO %s (version, checksum) VALUES ($1, $2)", pq.QuoteIdentifier(trackingTable))
+	record := func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, insertQuery, script.Version, checksum); err != nil {
+			return fmt.Errorf("failed to record migration: %w", err)
+		}
+		return nil
+	}
+	return runScriptAs(ctx, db, owner, script.SQL, record)
+}
+
+// runScriptAs runs a script in a transaction with the privileges of role, after prepare ran with the
+// operator's. Scripts come from tenants: they never run as a superuser, and their connection is closed
+// afterwards instead of going back to the pool with whatever session state they left behind.
+func runScriptAs(ctx context.Context, db *sql.DB, role, script string, prepare func(tx *sql.Tx) error) error {
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	// database/sql closes a connection that reports ErrBadConn
+	defer func() { _ = conn.Raw(func(any) error { return driver.ErrBadConn }) }()
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var superuser bool
+	if err := tx.QueryRowContext(ctx, "SELECT rolsuper FROM pg_roles WHERE rolname = $1", role).Scan(&superuser); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("role %s does not exist", role)
+		}
+		return fmt.Errorf("failed to check role %s: %w", role, err)
+	}
+	if superuser {
+		return fmt.Errorf("scripts run as the database owner, which must not be a superuser: set spec.owner to a role other than %s", role)
+	}
+
+	if prepare != nil {
+		if err := prepare(tx); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.ExecContext(ctx, "SET LOCAL ROLE "+pq.QuoteIdentifier(role)); err != nil {
+		return fmt.Errorf("failed to switch to role %s: %w", role, err)
+	}
+	if _, err := tx.ExecContext(ctx, script); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *MigrationService) appliedMigrations(ctx context.Context, db *sql.DB, trackingTable string) ([]postgresv1.AppliedMigration, error) {
+	rows, err := db.QueryContext(ctx, fmt.Sprintf("SELECT version, checksum, applied_at FROM %s ORDER BY version", pq.QuoteIdentifier(trackingTable)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tracking table: %w", err)
+	}
+	defer rows.Close()
+
+	var applied []postgresv1.AppliedMigration
+	for rows.Next() {
+		var migration postgresv1.AppliedMigration
+		var appliedAt time.Time
+		if err := rows.Scan(&migration.Version, &migration.Checksum, &appliedAt); err != nil {
+			return nil, fmt.Errorf("failed to read tracking table: %w", err)
+		}
+		migration.AppliedAt = &metav1.Time{Time: appliedAt}
+		applied = append(applied, migration)
+	}
+
+	return applied, rows.Err()
+}
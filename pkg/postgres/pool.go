@@ -0,0 +1,173 @@
+package postgres
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	postgresv1 "github.com/silverswarm/pg-operator/api/v1"
+)
+
+const (
+	// retiredPoolGrace is how long a replaced pool stays open for statements other reconciles already started
+	retiredPoolGrace = time.Minute
+	// unusedPoolTimeout is how long a pool nobody asked for is kept, e.g. of a deleted connection
+	unusedPoolTimeout = time.Hour
+)
+
+// PoolConfig sizes the connection pools kept for every PostGresConnection and database
+type PoolConfig struct {
+	// MaxOpenConns limits the connections of one pool
+	MaxOpenConns int
+	// MaxIdleConns is how many unused connections a pool keeps open
+	MaxIdleConns int
+	// ConnMaxIdleTime closes connections unused for longer
+	ConnMaxIdleTime time.Duration
+	// ConnMaxLifetime replaces connections older than this, so they move to a new primary after a switchover
+	ConnMaxLifetime time.Duration
+}
+
+// DefaultPoolConfig keeps a few connections per pool and closes them after five idle minutes
+var DefaultPoolConfig = PoolConfig{
+	MaxOpenConns:    5,
+	MaxIdleConns:    2,
+	ConnMaxIdleTime: 5 * time.Minute,
+	ConnMaxLifetime: 30 * time.Minute,
+}
+
+// Validate rejects pools of a single connection, LockDDL keeps one open while the reconcile holding the lock
+// runs its statements on others
+func (c PoolConfig) Validate() error {
+	if c.MaxOpenConns == 1 {
+		return fmt.Errorf("at least 2 connections per pool are needed, got 1")
+	}
+	return nil
+}
+
+// pools caches connection pools across reconciles. It is shared by every Client so that all controllers
+// reconciling objects of one database use the same connections.
+var pools = &poolCache{config: DefaultPoolConfig, entries: make(map[string]*cachedPool)}
+
+// SetPoolConfig configures the pools opened from now on
+func SetPoolConfig(config PoolConfig) {
+	pools.mu.Lock()
+	defer pools.mu.Unlock()
+
+	pools.config = config
+}
+
+type cachedPool struct {
+	db *sql.DB
+	// fingerprint identifies the parameters the pool was opened with, a pool is replaced when they change
+	fingerprint string
+	lastUsed    time.Time
+}
+
+type poolCache struct {
+	mu      sync.Mutex
+	config  PoolConfig
+	entries map[string]*cachedPool
+}
+
+// get returns the pool opened for key with the same parameters, or nil
+func (c *poolCache) get(key, fingerprint string) *sql.DB {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.sweep()
+
+	pool, ok := c.entries[key]
+	if !ok {
+		return nil
+	}
+	if pool.fingerprint != fingerprint {
+		delete(c.entries, key)
+		retire(pool.db)
+		return nil
+	}
+	pool.lastUsed = time.Now()
+	return pool.db
+}
+
+// put sizes db and keeps it for key, retiring the pool it replaces
+func (c *poolCache) put(key, fingerprint string, db *sql.DB) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	db.SetMaxOpenConns(c.config.MaxOpenConns)
+	db.SetMaxIdleConns(c.config.MaxIdleConns)
+	db.SetConnMaxIdleTime(c.config.ConnMaxIdleTime)
+	db.SetConnMaxLifetime(c.config.ConnMaxLifetime)
+
+	if pool, ok := c.entries[key]; ok && pool.db != db {
+		retire(pool.db)
+	}
+	c.entries[key] = &cachedPool{db: db, fingerprint: fingerprint, lastUsed: time.Now()}
+}
+
+// evict retires the pool kept for key
+func (c *poolCache) evict(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if pool, ok := c.entries[key]; ok {
+		delete(c.entries, key)
+		retire(pool.db)
+	}
+}
+
+// closeDatabase closes the pools connected to a database right away, their sessions would keep the
+// database busy. The database may have the same name on other servers, whose pools simply reconnect.
+func (c *poolCache) closeDatabase(databaseName string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for key, pool := range c.entries {
+		if strings.HasSuffix(key, "/"+databaseName) {
+			delete(c.entries, key)
+			_ = pool.db.Close()
+		}
+	}
+}
+
+// sweep closes pools that have not been used for a while, the caller holds the lock
+func (c *poolCache) sweep() {
+	for key, pool := range c.entries {
+		if time.Since(pool.lastUsed) > unusedPoolTimeout {
+			delete(c.entries, key)
+			retire(pool.db)
+		}
+	}
+}
+
+// retire closes a pool once statements other reconciles started on it had time to finish
+func retire(db *sql.DB) {
+	time.AfterFunc(retiredPoolGrace, func() { _ = db.Close() })
+}
+
+func poolKey(pgConn *postgresv1.PostGresConnection, databaseName string) string {
+	return fmt.Sprintf("%s/%s/%s", pgConn.Namespace, pgConn.Name, databaseName)
+}
+
+// poolFingerprint hashes what a pool was opened with, leaving out access tokens, which the connector
+// fetches for every connection
+func poolFingerprint(pgConn *postgresv1.PostGresConnection, params map[string]string, target *connectionTarget) string {
+	params = maps.Clone(params)
+	if usesToken(pgConn) {
+		delete(params, "password")
+	}
+
+	hash := sha256.New()
+	fmt.Fprintf(hash, "%s\n%v\n%s\n%s\n", buildConnString(params), target.hosts, target.sessionAttrs, pgConn.Spec.SSLServerName)
+	settings := sessionSettings(pgConn)
+	for _, name := range slices.Sorted(maps.Keys(settings)) {
+		fmt.Fprintf(hash, "%s=%s\n", name, settings[name])
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
@@ -0,0 +1,95 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/lib/pq"
+	postgresv1 "github.com/silverswarm/pg-operator/api/v1"
+)
+
+// privilegeBundle lists the privileges granted on the database and on the public schema and its objects.
+// Empty entries are not granted.
+type privilegeBundle struct {
+	database  string
+	schema    string
+	tables    string
+	sequences string
+	functions string
+}
+
+// rolePresets are the privilege bundles behind the user role presets
+var rolePresets = map[postgresv1.RolePreset]privilegeBundle{
+	postgresv1.RolePresetReadOnly: {
+		database:  "CONNECT",
+		schema:    "USAGE",
+		tables:    "SELECT",
+		sequences: "SELECT",
+	},
+	postgresv1.RolePresetReadWrite: {
+		database:  "CONNECT, TEMPORARY",
+		schema:    "USAGE",
+		tables:    "SELECT, INSERT, UPDATE, DELETE",
+		sequences: "USAGE, SELECT, UPDATE",
+		functions: "EXECUTE",
+	},
+	postgresv1.RolePresetDDL: {
+		database:  "CONNECT, TEMPORARY",
+		schema:    "USAGE, CREATE",
+		tables:    "SELECT, INSERT, UPDATE, DELETE, TRUNCATE, REFERENCES, TRIGGER",
+		sequences: "USAGE, SELECT, UPDATE",
+		functions: "EXECUTE",
+	},
+	postgresv1.RolePresetAdmin: {
+		database:  "ALL",
+		schema:    "ALL",
+		tables:    "ALL",
+		sequences: "ALL",
+		functions: "ALL",
+	},
+}
+
+// GrantPreset grants the privilege bundle of the user's role preset. db must be connected to the
+// target database. Default privileges cover objects created later by owner, or by the connecting
+// user when owner is empty.
+func (s *UserService) GrantPreset(ctx context.Context, db *sql.DB, databaseName, owner string, user postgresv1.DatabaseUser) error {
+	bundle, ok := rolePresets[user.Role]
+	if !ok {
+		return fmt.Errorf("unsupported role preset: %s", user.Role)
+	}
+	return grantBundle(ctx, db, databaseName, owner, user.Name, bundle)
+}
+
+func grantBundle(ctx context.Context, db *sql.DB, databaseName, owner, grantee string, bundle privilegeBundle) error {
+	defaultPrivileges := "ALTER DEFAULT PRIVILEGES"
+	if owner != "" {
+		defaultPrivileges = fmt.Sprintf("%s FOR ROLE %s", defaultPrivileges, pq.QuoteIdentifier(owner))
+	}
+	role := pq.QuoteIdentifier(grantee)
+
+	var grants []string
+	if bundle.database != "" {
+		grants = append(grants, fmt.Sprintf("GRANT %s ON DATABASE %s TO %s", bundle.database, pq.QuoteIdentifier(databaseName), role))
+	}
+	if bundle.schema != "" {
+		grants = append(grants, fmt.Sprintf("GRANT %s ON SCHEMA public TO %s", bundle.schema, role))
+	}
+	for _, objects := range []struct{ kind, privileges string }{
+		{"TABLES", bundle.tables},
+		{"SEQUENCES", bundle.sequences},
+		{"FUNCTIONS", bundle.functions},
+	} {
+		if objects.privileges == "" {
+			continue
+		}
+		grants = append(grants,
+			fmt.Sprintf("GRANT %s ON ALL %s IN SCHEMA public TO %s", objects.privileges, objects.kind, role),
+			fmt.Sprintf("%s IN SCHEMA public GRANT %s ON %s TO %s", defaultPrivileges, objects.privileges, objects.kind, role))
+	}
+
+	if err := execInTransaction(ctx, db, grants); err != nil {
+		return fmt.Errorf("failed to grant privileges to %s: %w", grantee, err)
+	}
+	return nil
+}
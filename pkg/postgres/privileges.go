@@ -0,0 +1,179 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/lib/pq"
+	postgresv1 "github.com/silverswarm/pg-operator/api/v1"
+)
+
+// databasePrivileges are the privileges that can be granted on a database, ALL expands to these
+var databasePrivileges = []string{"CONNECT", "CREATE", "TEMPORARY"}
+
+// permissionPrivileges maps the legacy user permissions to what GrantPermissions grants
+var permissionPrivileges = map[postgresv1.Permission]struct {
+	objectType postgresv1.GrantObjectType
+	privilege  string
+}{
+	postgresv1.PermissionAll:     {"", "ALL"},
+	postgresv1.PermissionConnect: {"", "CONNECT"},
+	postgresv1.PermissionCreate:  {"", "CREATE"},
+	postgresv1.PermissionUsage:   {postgresv1.GrantObjectSchema, "USAGE"},
+	postgresv1.PermissionSelect:  {postgresv1.GrantObjectTable, "SELECT"},
+	postgresv1.PermissionInsert:  {postgresv1.GrantObjectTable, "INSERT"},
+	postgresv1.PermissionUpdate:  {postgresv1.GrantObjectTable, "UPDATE"},
+	postgresv1.PermissionDelete:  {postgresv1.GrantObjectTable, "DELETE"},
+}
+
+// defaultPrivilegeObjects maps the object types of default privileges to grant object types
+var defaultPrivilegeObjects = map[string]postgresv1.GrantObjectType{
+	"tables":    postgresv1.GrantObjectTable,
+	"sequences": postgresv1.GrantObjectSequence,
+	"functions": postgresv1.GrantObjectFunction,
+}
+
+// heldPrivilegeQueries list the objects in the public schema with the privileges explicitly granted to
+// the role. Objects owned by the role are skipped, their owner privileges are never revoked.
+var heldPrivilegeQueries = map[postgresv1.GrantObjectType]string{
+	postgresv1.GrantObjectSchema: `SELECT quote_ident(n.nspname),
+		ARRAY(SELECT a.privilege_type FROM aclexplode(n.nspacl) a WHERE a.grantee = r.oid)
+		FROM pg_namespace n CROSS JOIN pg_roles r
+		WHERE r.rolname = $1 AND n.nspname = 'public' AND n.nspowner <> r.oid`,
+	postgresv1.GrantObjectTable: `SELECT format('%I.%I', n.nspname, c.relname),
+		ARRAY(SELECT a.privilege_type FROM aclexplode(c.relacl) a WHERE a.grantee = r.oid)
+		FROM pg_class c JOIN pg_namespace n ON n.oid = c.relnamespace CROSS JOIN pg_roles r
+		WHERE r.rolname = $1 AND n.nspname = 'public' AND c.relkind IN ('r', 'p', 'v', 'm', 'f') AND c.relowner <> r.oid`,
+	postgresv1.GrantObjectSequence: `SELECT format('%I.%I', n.nspname, c.relname),
+		ARRAY(SELECT a.privilege_type FROM aclexplode(c.relacl) a WHERE a.grantee = r.oid)
+		FROM pg_class c JOIN pg_namespace n ON n.oid = c.relnamespace CROSS JOIN pg_roles r
+		WHERE r.rolname = $1 AND n.nspname = 'public' AND c.relkind = 'S' AND c.relowner <> r.oid`,
+	postgresv1.GrantObjectFunction: `SELECT format('%I.%I(%s)', n.nspname, p.proname, pg_get_function_identity_arguments(p.oid)),
+		ARRAY(SELECT a.privilege_type FROM aclexplode(p.proacl) a WHERE a.grantee = r.oid)
+		FROM pg_proc p JOIN pg_namespace n ON n.oid = p.pronamespace CROSS JOIN pg_roles r
+		WHERE r.rolname = $1 AND n.nspname = 'public' AND p.prokind IN ('f', 'p') AND p.proowner <> r.oid`,
+}
+
+// RevokeUndeclaredPrivileges revokes the privileges a user holds on the database and on objects in the
+// public schema that its permissions, role preset and default privileges do not declare. db must be
+// connected to the database itself. Returns a description of every revoked privilege.
+func (s *UserService) RevokeUndeclaredPrivileges(ctx context.Context, db *sql.DB, databaseName string, user postgresv1.DatabaseUser) ([]string, error) {
+	declared := declaredPrivileges(user)
+	var revoked []string
+
+	var held []string
+	query := `SELECT ARRAY(SELECT a.privilege_type FROM pg_database d, aclexplode(d.datacl) a
+		JOIN pg_roles r ON r.oid = a.grantee
+		WHERE d.datname = $1 AND r.rolname = $2 AND d.datdba <> r.oid)`
+	if err := db.QueryRowContext(ctx, query, databaseName, user.Name).Scan(pq.Array(&held)); err != nil {
+		return nil, fmt.Errorf("failed to read database privileges: %w", err)
+	}
+	if stale := difference(held, declared[""]); len(stale) > 0 {
+		revokeQuery := fmt.Sprintf("REVOKE %s ON DATABASE %s FROM %s", strings.Join(stale, ", "), pq.QuoteIdentifier(databaseName), pq.QuoteIdentifier(user.Name))
+		if _, err := db.ExecContext(ctx, revokeQuery); err != nil {
+			return revoked, fmt.Errorf("failed to revoke %s on database %s: %w", strings.Join(stale, ", "), databaseName, err)
+		}
+		revoked = append(revoked, fmt.Sprintf("%s on database %s", strings.Join(stale, ", "), databaseName))
+	}
+
+	for _, objectType := range []postgresv1.GrantObjectType{
+		postgresv1.GrantObjectSchema, postgresv1.GrantObjectTable, postgresv1.GrantObjectSequence, postgresv1.GrantObjectFunction,
+	} {
+		objects, err := heldPrivileges(ctx, db, heldPrivilegeQueries[objectType], user.Name)
+		if err != nil {
+			return revoked, fmt.Errorf("failed to read %s privileges: %w", objectType, err)
+		}
+		for _, object := range objects {
+			stale := difference(object.held, declared[objectType])
+			if len(stale) == 0 {
+				continue
+			}
+			revokeQuery := fmt.Sprintf("REVOKE %s ON %s %s FROM %s", strings.Join(stale, ", "), objectKinds[objectType].keyword, object.identity, pq.QuoteIdentifier(user.Name))
+			if _, err := db.ExecContext(ctx, revokeQuery); err != nil {
+				return revoked, fmt.Errorf("failed to revoke %s on %s: %w", strings.Join(stale, ", "), object.identity, err)
+			}
+			revoked = append(revoked, fmt.Sprintf("%s on %s %s", strings.Join(stale, ", "), objectType, object.identity))
+		}
+	}
+
+	return revoked, nil
+}
+
+// declaredPrivileges collects the privileges declared for a user per object type,
+// the empty object type standing for the database itself
+func declaredPrivileges(user postgresv1.DatabaseUser) map[postgresv1.GrantObjectType][]string {
+	declared := make(map[postgresv1.GrantObjectType][]string)
+	add := func(objectType postgresv1.GrantObjectType, privileges ...string) {
+		for _, privilege := range privileges {
+			if privilege != "ALL" {
+				declared[objectType] = append(declared[objectType], privilege)
+			} else if objectType == "" {
+				declared[objectType] = append(declared[objectType], databasePrivileges...)
+			} else {
+				declared[objectType] = append(declared[objectType], objectKinds[objectType].privileges...)
+			}
+		}
+	}
+
+	for _, permission := range user.Permissions {
+		if mapped, ok := permissionPrivileges[permission]; ok {
+			add(mapped.objectType, mapped.privilege)
+		}
+	}
+
+	if bundle, ok := rolePresets[user.Role]; ok {
+		add("", splitPrivileges(bundle.database)...)
+		add(postgresv1.GrantObjectSchema, splitPrivileges(bundle.schema)...)
+		add(postgresv1.GrantObjectTable, splitPrivileges(bundle.tables)...)
+		add(postgresv1.GrantObjectSequence, splitPrivileges(bundle.sequences)...)
+		add(postgresv1.GrantObjectFunction, splitPrivileges(bundle.functions)...)
+	}
+
+	for _, privilege := range user.DefaultPrivileges {
+		if privilege.Schema != "" && privilege.Schema != "public" {
+			continue
+		}
+		for _, p := range privilege.Privileges {
+			add(defaultPrivilegeObjects[privilege.ObjectType], string(p))
+		}
+	}
+
+	for objectType, privileges := range declared {
+		sort.Strings(privileges)
+		declared[objectType] = slices.Compact(privileges)
+	}
+	return declared
+}
+
+// heldPrivileges runs one of heldPrivilegeQueries, returning the objects the role holds privileges on
+func heldPrivileges(ctx context.Context, db *sql.DB, query, role string) ([]catalogObject, error) {
+	rows, err := db.QueryContext(ctx, query, role)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var objects []catalogObject
+	for rows.Next() {
+		var object catalogObject
+		if err := rows.Scan(&object.identity, pq.Array(&object.held)); err != nil {
+			return nil, err
+		}
+		if len(object.held) > 0 {
+			objects = append(objects, object)
+		}
+	}
+
+	return objects, rows.Err()
+}
+
+func splitPrivileges(list string) []string {
+	if list == "" {
+		return nil
+	}
+	return strings.Split(list, ", ")
+}
@@ -0,0 +1,77 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	postgresv1 "github.com/silverswarm/pg-operator/api/v1"
+)
+
+const (
+	// proxyHost is where an auth proxy listens, it runs next to the operator
+	proxyHost = "127.0.0.1"
+	// proxyCheckTimeout bounds each of the proxy's health checks
+	proxyCheckTimeout = 5 * time.Second
+)
+
+var proxyHTTPClient = &http.Client{Timeout: proxyCheckTimeout}
+
+// CheckProxy reports why the auth proxy of a connection cannot pass connections on, or nil when it is
+// listening and, with a healthCheckURL, ready. Connections without a proxy always pass.
+func (c *Client) CheckProxy(ctx context.Context, pgConn *postgresv1.PostGresConnection) error {
+	proxy := pgConn.Spec.Proxy
+	if proxy == nil {
+		return nil
+	}
+
+	address := net.JoinHostPort(proxyHost, strconv.Itoa(int(proxy.Port)))
+	dialer := net.Dialer{Timeout: proxyCheckTimeout}
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return fmt.Errorf("auth proxy is not listening on %s: %w", address, err)
+	}
+	_ = conn.Close()
+
+	if proxy.HealthCheckURL == "" {
+		return nil
+	}
+	// The API server does not check the URL, the operator must not be made to request arbitrary hosts
+	if err := ValidateProxyHealthCheckURL(proxy.HealthCheckURL); err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, proxy.HealthCheckURL, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := proxyHTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("auth proxy health check failed: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("auth proxy is not ready: %s %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+// ValidateProxyHealthCheckURL reports whether healthCheckURL is an http URL on the loopback interface
+func ValidateProxyHealthCheckURL(healthCheckURL string) error {
+	parsed, err := url.Parse(healthCheckURL)
+	if err != nil || parsed.Scheme != "http" {
+		return fmt.Errorf("proxy healthCheckURL must be an http URL")
+	}
+	host := parsed.Hostname()
+	if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+		return fmt.Errorf("proxy healthCheckURL must point at localhost, the proxy runs next to the operator")
+	}
+	return nil
+}
@@ -0,0 +1,276 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/lib/pq"
+	postgresv1 "github.com/silverswarm/pg-operator/api/v1"
+)
+
+var configParameterPattern = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_.]*$`)
+
+// quoteParameter quotes a configuration parameter name. The parts of a qualified name such as
+// pg_stat_statements.track are quoted on their own, PostgreSQL joins them back with a dot.
+func quoteParameter(name string) string {
+	parts := strings.Split(name, ".")
+	for i, part := range parts {
+		parts[i] = pq.QuoteIdentifier(part)
+	}
+	return strings.Join(parts, ".")
+}
+
+type RoleService struct {
+	client *Client
+}
+
+func NewRoleService(client *Client) *RoleService {
+	return &RoleService{
+		client: client,
+	}
+}
+
+// roleState mirrors the pg_roles columns managed by the operator
+type roleState struct {
+	superuser       bool
+	inherit         bool
+	createRole      bool
+	createDB        bool
+	login           bool
+	replication     bool
+	bypassRLS       bool
+	connectionLimit int32
+	config          []string
+}
+
+// EnsureRole creates the role or alters it so its attributes and configuration match the spec. An existing
+// role is only managed while it carries the ownership marker of this resource, which is set when the resource
+// creates it, so a PostgresRole never takes over roles of Databases, other PostgresRoles or the cluster itself.
+// Returns the configuration parameters the operator manages on the role, also when it fails.
+func (s *RoleService) EnsureRole(ctx context.Context, db *sql.DB, role *postgresv1.PostgresRole) ([]string, error) {
+	name := role.Spec.RoleName
+	previous := role.Status.ConfigParameters
+	desired := desiredRoleState(role.Spec.Attributes)
+
+	current, err := s.getRoleState(ctx, db, name)
+	if err != nil {
+		return previous, fmt.Errorf("failed to read role %s: %w", name, err)
+	}
+
+	if current == nil {
+		comment := managedComment("", "PostgresRole", role.Namespace, role.Name, role.UID)
+		if err := execInTransaction(ctx, db, []string{
+			fmt.Sprintf("CREATE ROLE %s WITH %s", pq.QuoteIdentifier(name), roleOptions(desired)),
+			fmt.Sprintf("COMMENT ON ROLE %s IS %s", pq.QuoteIdentifier(name), pq.QuoteLiteral(comment)),
+		}); err != nil {
+			return previous, fmt.Errorf("failed to create role: %w", err)
+		}
+		// A new role has no configuration yet
+		current = &roleState{}
+	} else {
+		managed, err := s.ManagedBy(ctx, db, role)
+		if err != nil {
+			return previous, err
+		}
+		if !managed {
+			return previous, fmt.Errorf("%w: %s is not managed by PostgresRole %s/%s", ErrRoleExists, name, role.Namespace, role.Name)
+		}
+		if !attributesEqual(*current, desired) {
+			query := fmt.Sprintf("ALTER ROLE %s WITH %s", pq.QuoteIdentifier(name), roleOptions(desired))
+			if _, err := db.ExecContext(ctx, query); err != nil {
+				return previous, fmt.Errorf("failed to alter role: %w", err)
+			}
+		}
+	}
+
+	return ensureRoleConfig(ctx, db, name, current.config, role.Spec.Config, previous)
+}
+
+// ManagedBy reports whether the role exists and carries the ownership marker of the resource
+func (s *RoleService) ManagedBy(ctx context.Context, db *sql.DB, role *postgresv1.PostgresRole) (bool, error) {
+	comment, err := sharedComment(ctx, db, "ROLE", role.Spec.RoleName)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to read comment of role %s: %w", role.Spec.RoleName, err)
+	}
+
+	marker, ok := parseMarker(comment)
+	return ok && marker.kind == "PostgresRole" && marker.uid == role.UID, nil
+}
+
+// DropRole drops the role, which fails while it owns objects or holds privileges. Missing roles are ignored.
+func (s *RoleService) DropRole(ctx context.Context, db *sql.DB, name string) error {
+	if _, err := db.ExecContext(ctx, fmt.Sprintf("DROP ROLE IF EXISTS %s", pq.QuoteIdentifier(name))); err != nil {
+		return fmt.Errorf("failed to drop role: %w", err)
+	}
+	return nil
+}
+
+// EnsureMemberships grants the desired memberships and revokes the ones in
+// previous that are no longer desired. Memberships granted outside the
+// operator are left alone. Returns the memberships now held.
+func (s *RoleService) EnsureMemberships(ctx context.Context, db *sql.DB, roleName string, desired, previous []string) ([]string, error) {
+	for _, parent := range desired {
+		member, err := s.isMember(ctx, db, parent, roleName)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check membership in %s: %w", parent, err)
+		}
+		if member {
+			continue
+		}
+		if _, err := db.ExecContext(ctx, fmt.Sprintf("GRANT %s TO %s", pq.QuoteIdentifier(parent), pq.QuoteIdentifier(roleName))); err != nil {
+			return nil, fmt.Errorf("failed to grant membership in %s: %w", parent, err)
+		}
+	}
+
+	for _, parent := range previous {
+		if slices.Contains(desired, parent) {
+			continue
+		}
+		member, err := s.isMember(ctx, db, parent, roleName)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check membership in %s: %w", parent, err)
+		}
+		if !member {
+			continue
+		}
+		if _, err := db.ExecContext(ctx, fmt.Sprintf("REVOKE %s FROM %s", pq.QuoteIdentifier(parent), pq.QuoteIdentifier(roleName))); err != nil {
+			return nil, fmt.Errorf("failed to revoke membership in %s: %w", parent, err)
+		}
+	}
+
+	held := slices.Clone(desired)
+	sort.Strings(held)
+	return held, nil
+}
+
+// ensureRoleConfig sets the desired role-level parameters and resets the ones in previous, the parameters the
+// operator set before, that are no longer desired. Parameters set on the role by others are left alone. Returns
+// the parameters the operator now manages, which still include previous ones when it fails.
+func ensureRoleConfig(ctx context.Context, db *sql.DB, roleName string, currentConfig []string, desired map[string]string, previous []string) ([]string, error) {
+	current := make(map[string]string, len(currentConfig))
+	for _, setting := range currentConfig {
+		key, value, _ := strings.Cut(setting, "=")
+		current[key] = value
+	}
+
+	keys := make([]string, 0, len(desired))
+	for key := range desired {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	managed := slices.Clone(previous)
+	for _, key := range keys {
+		if !slices.Contains(managed, key) {
+			managed = append(managed, key)
+		}
+	}
+	sort.Strings(managed)
+
+	for _, key := range keys {
+		if !configParameterPattern.MatchString(key) {
+			return managed, fmt.Errorf("invalid configuration parameter name %q", key)
+		}
+		if value, ok := current[key]; ok && value == desired[key] {
+			continue
+		}
+		query := fmt.Sprintf("ALTER ROLE %s SET %s = %s", pq.QuoteIdentifier(roleName), quoteParameter(key), pq.QuoteLiteral(desired[key]))
+		if _, err := db.ExecContext(ctx, query); err != nil {
+			return managed, fmt.Errorf("failed to set %s: %w", key, err)
+		}
+	}
+
+	for _, key := range previous {
+		if _, ok := desired[key]; ok {
+			continue
+		}
+		if _, ok := current[key]; !ok {
+			continue
+		}
+		if !configParameterPattern.MatchString(key) {
+			return managed, fmt.Errorf("invalid configuration parameter name %q", key)
+		}
+		if _, err := db.ExecContext(ctx, fmt.Sprintf("ALTER ROLE %s RESET %s", pq.QuoteIdentifier(roleName), quoteParameter(key))); err != nil {
+			return managed, fmt.Errorf("failed to reset %s: %w", key, err)
+		}
+	}
+
+	return keys, nil
+}
+
+func (s *RoleService) getRoleState(ctx context.Context, db *sql.DB, roleName string) (*roleState, error) {
+	var state roleState
+	query := `SELECT rolsuper, rolinherit, rolcreaterole, rolcreatedb, rolcanlogin, rolreplication, rolbypassrls,
+		rolconnlimit, COALESCE(rolconfig, '{}') FROM pg_roles WHERE rolname = $1`
+	err := db.QueryRowContext(ctx, query, roleName).Scan(&state.superuser, &state.inherit, &state.createRole,
+		&state.createDB, &state.login, &state.replication, &state.bypassRLS, &state.connectionLimit, pq.Array(&state.config))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+func (s *RoleService) isMember(ctx context.Context, db *sql.DB, parent, member string) (bool, error) {
+	var exists bool
+	query := `SELECT EXISTS(SELECT 1 FROM pg_auth_members m
+		JOIN pg_roles r ON m.roleid = r.oid
+		JOIN pg_roles u ON m.member = u.oid
+		WHERE r.rolname = $1 AND u.rolname = $2)`
+	err := db.QueryRowContext(ctx, query, parent, member).Scan(&exists)
+	return exists, err
+}
+
+func desiredRoleState(attributes postgresv1.RoleAttributes) roleState {
+	state := roleState{
+		superuser:       attributes.Superuser,
+		inherit:         attributes.Inherit == nil || *attributes.Inherit,
+		createRole:      attributes.CreateRole,
+		createDB:        attributes.CreateDB,
+		login:           attributes.Login,
+		replication:     attributes.Replication,
+		bypassRLS:       attributes.BypassRLS,
+		connectionLimit: -1,
+	}
+	if attributes.ConnectionLimit != nil {
+		state.connectionLimit = *attributes.ConnectionLimit
+	}
+	return state
+}
+
+func attributesEqual(a, b roleState) bool {
+	return a.superuser == b.superuser && a.inherit == b.inherit && a.createRole == b.createRole &&
+		a.createDB == b.createDB && a.login == b.login && a.replication == b.replication &&
+		a.bypassRLS == b.bypassRLS && a.connectionLimit == b.connectionLimit
+}
+
+func roleOptions(state roleState) string {
+	flag := func(enabled bool, name string) string {
+		if enabled {
+			return name
+		}
+		return "NO" + name
+	}
+
+	return strings.Join([]string{
+		flag(state.superuser, "SUPERUSER"),
+		flag(state.inherit, "INHERIT"),
+		flag(state.createRole, "CREATEROLE"),
+		flag(state.createDB, "CREATEDB"),
+		flag(state.login, "LOGIN"),
+		flag(state.replication, "REPLICATION"),
+		flag(state.bypassRLS, "BYPASSRLS"),
+		fmt.Sprintf("CONNECTION LIMIT %d", state.connectionLimit),
+	}, " ")
+}
@@ -0,0 +1,20 @@
+package postgres
+
+import "testing"
+
+func TestQuoteParameter(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"work_mem", `"work_mem"`},
+		{"pg_stat_statements.track", `"pg_stat_statements"."track"`},
+		{`search_path"; DROP ROLE postgres; --`, `"search_path""; DROP ROLE postgres; --"`},
+	}
+
+	for _, tt := range tests {
+		if got := quoteParameter(tt.name); got != tt.want {
+			t.Errorf("quoteParameter(%q) = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
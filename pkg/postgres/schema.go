@@ -0,0 +1,166 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/lib/pq"
+	postgresv1 "github.com/silverswarm/pg-operator/api/v1"
+)
+
+type SchemaService struct {
+	client *Client
+}
+
+func NewSchemaService(client *Client) *SchemaService {
+	return &SchemaService{
+		client: client,
+	}
+}
+
+// EnsureSchema creates the schema, corrects its owner and applies the grants and default privileges.
+// Privileges of the grants last applied that the spec no longer lists are revoked. Returns the owner of the
+// schema and whether this resource created it.
+func (s *SchemaService) EnsureSchema(ctx context.Context, db *sql.DB, schema *postgresv1.Schema) (string, bool, error) {
+	name := schema.Spec.SchemaName
+
+	owner, err := s.getOwner(ctx, db, name)
+	if err != nil {
+		return "", false, fmt.Errorf("failed to read schema %s: %w", name, err)
+	}
+
+	if owner == "" {
+		// The marker is set together with the schema, so a schema that existed before is never mistaken for
+		// one this resource created
+		query := fmt.Sprintf("CREATE SCHEMA %s", pq.QuoteIdentifier(name))
+		if schema.Spec.Owner != "" {
+			query = fmt.Sprintf("%s AUTHORIZATION %s", query, pq.QuoteIdentifier(schema.Spec.Owner))
+		}
+		comment := managedComment("", "Schema", schema.Namespace, schema.Name, schema.UID)
+		if err := execInTransaction(ctx, db, []string{
+			query,
+			fmt.Sprintf("COMMENT ON SCHEMA %s IS %s", pq.QuoteIdentifier(name), pq.QuoteLiteral(comment)),
+		}); err != nil {
+			return "", false, fmt.Errorf("failed to create schema: %w", err)
+		}
+	} else if schema.Spec.Owner != "" && owner != schema.Spec.Owner {
+		if _, err := db.ExecContext(ctx, fmt.Sprintf("ALTER SCHEMA %s OWNER TO %s", pq.QuoteIdentifier(name), pq.QuoteIdentifier(schema.Spec.Owner))); err != nil {
+			return "", false, fmt.Errorf("failed to change owner: %w", err)
+		}
+	}
+
+	if owner, err = s.getOwner(ctx, db, name); err != nil {
+		return "", false, fmt.Errorf("failed to read schema %s: %w", name, err)
+	}
+	created, err := s.CreatedBy(ctx, db, schema)
+	if err != nil {
+		return owner, false, err
+	}
+
+	if err := s.revokeRemovedGrants(ctx, db, schema); err != nil {
+		return owner, created, err
+	}
+
+	for _, grant := range schema.Spec.Grants {
+		query := fmt.Sprintf("GRANT %s ON SCHEMA %s TO %s", joinPrivileges(grant.Privileges), pq.QuoteIdentifier(name), pq.QuoteIdentifier(grant.Role))
+		if _, err := db.ExecContext(ctx, query); err != nil {
+			return owner, created, fmt.Errorf("failed to grant privileges to %s: %w", grant.Role, err)
+		}
+	}
+
+	for _, privilege := range schema.Spec.DefaultPrivileges {
+		// Default privileges only cover objects created by the role named in FOR ROLE
+		query := fmt.Sprintf("ALTER DEFAULT PRIVILEGES FOR ROLE %s IN SCHEMA %s GRANT %s ON %s TO %s",
+			pq.QuoteIdentifier(owner), pq.QuoteIdentifier(name), joinPrivileges(privilege.Privileges),
+			strings.ToUpper(privilege.ObjectType), pq.QuoteIdentifier(privilege.Role))
+		if _, err := db.ExecContext(ctx, query); err != nil {
+			return owner, created, fmt.Errorf("failed to set default privileges for %s: %w", privilege.Role, err)
+		}
+	}
+
+	return owner, created, nil
+}
+
+// revokeRemovedGrants revokes the privileges of the grants last applied that the spec no longer lists.
+// Privileges granted outside the resource are left alone, as are roles that no longer exist.
+func (s *SchemaService) revokeRemovedGrants(ctx context.Context, db *sql.DB, schema *postgresv1.Schema) error {
+	wanted := make(map[string][]postgresv1.ObjectPrivilege)
+	for _, grant := range schema.Spec.Grants {
+		wanted[grant.Role] = append(wanted[grant.Role], grant.Privileges...)
+	}
+
+	for _, grant := range schema.Status.AppliedGrants {
+		var removed []postgresv1.ObjectPrivilege
+		for _, privilege := range grant.Privileges {
+			if !slices.Contains(wanted[grant.Role], privilege) && !slices.Contains(removed, privilege) {
+				removed = append(removed, privilege)
+			}
+		}
+		if len(removed) == 0 {
+			continue
+		}
+
+		var exists bool
+		if err := db.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM pg_roles WHERE rolname = $1)", grant.Role).Scan(&exists); err != nil {
+			return fmt.Errorf("failed to check role %s: %w", grant.Role, err)
+		}
+		if !exists {
+			continue
+		}
+
+		// Revoking ALL also removes what the spec still grants, the grants that follow restore it
+		query := fmt.Sprintf("REVOKE %s ON SCHEMA %s FROM %s", joinPrivileges(removed), pq.QuoteIdentifier(schema.Spec.SchemaName), pq.QuoteIdentifier(grant.Role))
+		if _, err := db.ExecContext(ctx, query); err != nil {
+			return fmt.Errorf("failed to revoke privileges from %s: %w", grant.Role, err)
+		}
+	}
+	return nil
+}
+
+// CreatedBy reports whether the schema carries the ownership marker of the resource, which is set when the
+// resource creates it
+func (s *SchemaService) CreatedBy(ctx context.Context, db *sql.DB, schema *postgresv1.Schema) (bool, error) {
+	var comment string
+	query := "SELECT COALESCE(obj_description(oid, 'pg_namespace'), '') FROM pg_namespace WHERE nspname = $1"
+	err := db.QueryRowContext(ctx, query, schema.Spec.SchemaName).Scan(&comment)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to read comment of schema %s: %w", schema.Spec.SchemaName, err)
+	}
+
+	marker, ok := parseMarker(comment)
+	return ok && marker.kind == "Schema" && marker.uid == schema.UID, nil
+}
+
+// DropSchema drops the schema and every object it contains
+func (s *SchemaService) DropSchema(ctx context.Context, db *sql.DB, name string) error {
+	if _, err := db.ExecContext(ctx, fmt.Sprintf("DROP SCHEMA IF EXISTS %s CASCADE", pq.QuoteIdentifier(name))); err != nil {
+		return fmt.Errorf("failed to drop schema: %w", err)
+	}
+	return nil
+}
+
+// getOwner returns the owner of the schema, or an empty string if it does not exist
+func (s *SchemaService) getOwner(ctx context.Context, db *sql.DB, name string) (string, error) {
+	var owner string
+	query := "SELECT pg_get_userbyid(nspowner) FROM pg_namespace WHERE nspname = $1"
+	err := db.QueryRowContext(ctx, query, name).Scan(&owner)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	return owner, err
+}
+
+func joinPrivileges(privileges []postgresv1.ObjectPrivilege) string {
+	names := make([]string, 0, len(privileges))
+	for _, privilege := range privileges {
+		names = append(names, string(privilege))
+	}
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,109 @@
+package postgres
+
+import (
+	"crypto/hmac"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+const (
+	scramIterations = 4096
+	scramSaltLength = 16
+)
+
+// scramVerifier computes the SCRAM-SHA-256 secret PostgreSQL stores in pg_authid, so roles can be
+// given a password without the plaintext appearing in statements, logs or pg_stat_activity
+func scramVerifier(password string) (string, error) {
+	salt := make([]byte, scramSaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("failed to generate salt: %w", err)
+	}
+	return scramVerifierWithSalt(password, salt, scramIterations)
+}
+
+func scramVerifierWithSalt(password string, salt []byte, iterations int) (string, error) {
+	salted, err := pbkdf2.Key(sha256.New, saslPrep(password), salt, iterations, sha256.Size)
+	if err != nil {
+		return "", fmt.Errorf("failed to derive key: %w", err)
+	}
+
+	clientKey := scramHMAC(salted, "Client Key")
+	storedKey := sha256.Sum256(clientKey)
+	serverKey := scramHMAC(salted, "Server Key")
+
+	encode := base64.StdEncoding.EncodeToString
+	return fmt.Sprintf("SCRAM-SHA-256$%d:%s$%s:%s", iterations, encode(salt), encode(storedKey[:]), encode(serverKey)), nil
+}
+
+// scramMatches reports whether password produces the given SCRAM-SHA-256 verifier
+func scramMatches(verifier, password string) (bool, error) {
+	// SCRAM-SHA-256$<iterations>:<salt>$<StoredKey>:<ServerKey>
+	parts := strings.Split(verifier, "$")
+	if len(parts) != 3 || parts[0] != "SCRAM-SHA-256" {
+		return false, fmt.Errorf("malformed SCRAM verifier")
+	}
+	count, encodedSalt, ok := strings.Cut(parts[1], ":")
+	iterations, err := strconv.Atoi(count)
+	if !ok || err != nil || iterations <= 0 {
+		return false, fmt.Errorf("malformed SCRAM iteration count")
+	}
+	salt, err := base64.StdEncoding.DecodeString(encodedSalt)
+	if err != nil {
+		return false, fmt.Errorf("malformed SCRAM salt: %w", err)
+	}
+
+	expected, err := scramVerifierWithSalt(password, salt, iterations)
+	if err != nil {
+		return false, err
+	}
+	return hmac.Equal([]byte(expected), []byte(verifier)), nil
+}
+
+func scramHMAC(key []byte, message string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(message))
+	return mac.Sum(nil)
+}
+
+// saslPrep normalizes the password the way PostgreSQL does before hashing. ASCII and invalid UTF-8
+// are used as is; otherwise non-ASCII spaces become spaces, characters mapped to nothing are removed
+// and the result is NFKC normalized. Passwords with control characters are used as is, matching
+// PostgreSQL's fallback for strings SASLprep prohibits.
+func saslPrep(password string) string {
+	ascii := true
+	for i := 0; i < len(password); i++ {
+		if password[i] >= utf8.RuneSelf {
+			ascii = false
+			break
+		}
+	}
+	if ascii || !utf8.ValidString(password) {
+		return password
+	}
+
+	var mapped strings.Builder
+	for _, r := range password {
+		switch {
+		case unicode.IsControl(r):
+			return password
+		case r == '\u00AD' || r == '\u034F' || r == '\u1806' || (r >= '\u180B' && r <= '\u180D') ||
+			(r >= '\u200B' && r <= '\u200D') || r == '\u2060' || (r >= '\uFE00' && r <= '\uFE0F') || r == '\uFEFF':
+			continue
+		case r != ' ' && unicode.Is(unicode.Zs, r):
+			mapped.WriteRune(' ')
+		default:
+			mapped.WriteRune(r)
+		}
+	}
+
+	return norm.NFKC.String(mapped.String())
+}
@@ -0,0 +1,105 @@
+package postgres
+
+import (
+	"encoding/base64"
+	"testing"
+)
+
+func TestScramVerifierWithSalt(t *testing.T) {
+	tests := []struct {
+		name     string
+		password string
+		salt     string
+		want     string
+	}{
+		{
+			// The exchange of RFC 7677 section 3 authenticates against this verifier
+			name:     "RFC 7677",
+			password: "pencil",
+			salt:     "W22ZaJ0SNY7soEsUEjb6gQ==",
+			want:     "SCRAM-SHA-256$4096:W22ZaJ0SNY7soEsUEjb6gQ==$WG5d8oPm3OtcPnkdi4Uo7BkeZkBFzpcXkuLmtbsT4qY=:wfPLwcE6nTWhTAmQ7tl2KeoiWGPlZqQxSrmfPwDl2dU=",
+		},
+		{
+			// Hashed as "pass wordIX": a no-break space, a soft hyphen and ROMAN NUMERAL NINE
+			name:     "non-ASCII",
+			password: "pass\u00a0word\u00ad\u2168",
+			salt:     "AAECAwQFBgcICQoLDA0ODw==",
+			want:     "SCRAM-SHA-256$4096:AAECAwQFBgcICQoLDA0ODw==$kajAjFZgbGkAI7aH7+HtHhboSAoU5q7HNB7B5usZcmU=:nl4oB0Cwiaa0ceFSA0A+qrIVXWlqgrJqT+/tPUz9AMo=",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			salt, err := base64.StdEncoding.DecodeString(tt.salt)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := scramVerifierWithSalt(tt.password, salt, 4096)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("scramVerifierWithSalt() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSaslPrep(t *testing.T) {
+	// The examples of RFC 4013 section 3 first. PostgreSQL keeps passwords SASLprep prohibits or that are not
+	// valid UTF-8 as they are.
+	tests := []struct {
+		password string
+		want     string
+	}{
+		{"I\u00adX", "IX"},
+		{"user", "user"},
+		{"USER", "USER"},
+		{"\u00aa", "a"},
+		{"\u2168", "IX"},
+		{"été\u3000", "été "},
+		{"é\u0007", "é\u0007"},
+		{"\xff ", "\xff "},
+	}
+
+	for _, tt := range tests {
+		if got := saslPrep(tt.password); got != tt.want {
+			t.Errorf("saslPrep(%q) = %q, want %q", tt.password, got, tt.want)
+		}
+	}
+}
+
+func TestScramMatches(t *testing.T) {
+	verifier, err := scramVerifier("s3crét")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		verifier string
+		password string
+		want     bool
+		wantErr  bool
+	}{
+		{name: "same password", verifier: verifier, password: "s3crét", want: true},
+		// NFKC composes e and the combining acute accent into é
+		{name: "same password after normalization", verifier: verifier, password: "s3cre\u0301t", want: true},
+		{name: "other password", verifier: verifier, password: "s3cret"},
+		{name: "md5", verifier: "md5" + "0123456789abcdef0123456789abcdef", password: "s3cret", wantErr: true},
+		{name: "iterations", verifier: "SCRAM-SHA-256$0:AAECAwQFBgcICQoLDA0ODw==$a:b", password: "s3cret", wantErr: true},
+		{name: "salt", verifier: "SCRAM-SHA-256$4096:not base64$a:b", password: "s3cret", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := scramMatches(tt.verifier, tt.password)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("scramMatches() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("scramMatches() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
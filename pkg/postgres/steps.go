@@ -0,0 +1,59 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// Steps whose statement commits on its own, PostgreSQL refuses to run them in a transaction block
+const (
+	StepCreateDatabase = "CreateDatabase"
+	StepMoveTablespace = "MoveTablespace"
+)
+
+// StepRecorder persists that a step completed, so a reconcile failing afterwards still leaves a record of it
+type StepRecorder func(ctx context.Context, step, statement string) error
+
+type stepRecorderKey struct{}
+
+// WithStepRecorder returns a context whose completed steps are reported to recorder
+func WithStepRecorder(ctx context.Context, recorder StepRecorder) context.Context {
+	return context.WithValue(ctx, stepRecorderKey{}, recorder)
+}
+
+// execStep runs the statement of a step and reports it to the recorder of ctx once it succeeded
+func execStep(ctx context.Context, db *sql.DB, step, statement string) error {
+	if _, err := db.ExecContext(ctx, statement); err != nil {
+		return err
+	}
+
+	if record, ok := ctx.Value(stepRecorderKey{}).(StepRecorder); ok {
+		if err := record(ctx, step, maskPasswords(statement)); err != nil {
+			return fmt.Errorf("%s succeeded but recording it failed: %w", step, err)
+		}
+	}
+	return nil
+}
+
+// execInTransaction runs statements such as a batch of grants in one transaction, a failing statement
+// leaves none of them applied
+func execInTransaction(ctx context.Context, db *sql.DB, statements []string) error {
+	if len(statements) == 0 {
+		return nil
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	for _, statement := range statements {
+		if _, err := tx.ExecContext(ctx, statement); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,117 @@
+package postgres
+
+import (
+	"maps"
+	"slices"
+	"testing"
+)
+
+func TestParseURIConnection(t *testing.T) {
+	tests := []struct {
+		name    string
+		uri     string
+		want    *connectionTarget
+		wantErr bool
+	}{
+		{
+			name: "single host with defaults",
+			uri:  "postgresql://app@db.example.com/orders",
+			want: &connectionTarget{
+				hosts:    []hostPort{{host: "db.example.com", port: 5432}},
+				username: "app",
+				database: "orders",
+			},
+		},
+		{
+			name: "IPv6 hosts",
+			uri:  "postgres://app@[2001:db8::1]:6432,[::1]/orders",
+			want: &connectionTarget{
+				hosts:    []hostPort{{host: "2001:db8::1", port: 6432}, {host: "::1", port: 5432}},
+				username: "app",
+				database: "orders",
+			},
+		},
+		{
+			name: "host list in the authority",
+			uri:  "postgresql://app@db1:5433,db2,db3:5434/orders?target_session_attrs=read-write",
+			want: &connectionTarget{
+				hosts:        []hostPort{{host: "db1", port: 5433}, {host: "db2", port: 5432}, {host: "db3", port: 5434}},
+				username:     "app",
+				database:     "orders",
+				sessionAttrs: "read-write",
+			},
+		},
+		{
+			name: "host and port parameters",
+			uri:  "postgresql:///orders?host=db1,db2&port=5433,5434&user=app",
+			want: &connectionTarget{
+				hosts:    []hostPort{{host: "db1", port: 5433}, {host: "db2", port: 5434}},
+				username: "app",
+				database: "orders",
+			},
+		},
+		{
+			name: "single port for every host",
+			uri:  "postgresql:///orders?host=db1,db2&port=6432",
+			want: &connectionTarget{
+				hosts:    []hostPort{{host: "db1", port: 6432}, {host: "db2", port: 6432}},
+				database: "orders",
+			},
+		},
+		{
+			name: "percent-encoded credentials keep plus signs",
+			uri:  "postgresql://app%40corp:p%40ss+w%2Fo%3Ard@db/my%20db?sslmode=verify-full&options=-c%20search_path%3Dapp+x",
+			want: &connectionTarget{
+				hosts:    []hostPort{{host: "db", port: 5432}},
+				username: "app@corp",
+				password: "p@ss+w/o:rd",
+				database: "my db",
+				sslMode:  "verify-full",
+				params:   map[string]string{"options": "-c search_path=app+x"},
+			},
+		},
+		{
+			name: "password parameter overrides the authority",
+			uri:  "postgresql://app:old@db/orders?password=new+one",
+			want: &connectionTarget{
+				hosts:    []hostPort{{host: "db", port: 5432}},
+				username: "app",
+				password: "new+one",
+				database: "orders",
+			},
+		},
+		{name: "port out of range", uri: "postgresql://db:65536/orders", wantErr: true},
+		{name: "port not a number", uri: "postgresql://db:http/orders", wantErr: true},
+		{name: "zero port parameter", uri: "postgresql:///orders?host=db&port=0", wantErr: true},
+		{name: "more ports than hosts", uri: "postgresql:///orders?host=db1,db2&port=1,2,3", wantErr: true},
+		{name: "empty host in list", uri: "postgresql://db1,,db2/orders", wantErr: true},
+		{name: "wrong scheme", uri: "mysql://db/orders", wantErr: true},
+		{name: "bad escape in password", uri: "postgresql://app:s3cr%zzet@db/orders", wantErr: true},
+		{name: "parameter without value", uri: "postgresql://db/orders?sslmode", wantErr: true},
+		{name: "sslkey", uri: "postgresql://db/orders?sslkey=/etc/ssl/private/key.pem", wantErr: true},
+		{name: "sslcert", uri: "postgresql://db/orders?sslcert=/tmp/cert.pem", wantErr: true},
+		{name: "passfile", uri: "postgresql://db/orders?passfile=/root/.pgpass", wantErr: true},
+		{name: "service", uri: "postgresql://db/orders?service=prod", wantErr: true},
+		{name: "unsupported target_session_attrs", uri: "postgresql://db/orders?target_session_attrs=standby", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseURIConnection(tt.uri)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseURIConnection() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if tt.want.params == nil {
+				tt.want.params = map[string]string{}
+			}
+			if !slices.Equal(got.hosts, tt.want.hosts) || got.username != tt.want.username || got.password != tt.want.password ||
+				got.database != tt.want.database || got.sslMode != tt.want.sslMode || got.sessionAttrs != tt.want.sessionAttrs ||
+				!maps.Equal(got.params, tt.want.params) {
+				t.Errorf("parseURIConnection() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -2,14 +2,28 @@ package postgres
 
 import (
 	"context"
-	"crypto/rand"
+	"crypto/md5"
 	"database/sql"
-	"encoding/base64"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"strings"
+	"time"
 
+	"github.com/lib/pq"
 	postgresv1 "github.com/silverswarm/pg-operator/api/v1"
 )
 
+// ErrRoleExists is returned when a role that must be new already exists
+var ErrRoleExists = errors.New("role already exists")
+
+// ErrPasswordUnverifiable is returned when the stored password of a role cannot be read or compared
+var ErrPasswordUnverifiable = errors.New("password cannot be verified")
+
+// ErrPasswordsUnreadable is returned along with ErrPasswordUnverifiable when the connection lacks the
+// rights to read stored passwords, so no role's password can be verified
+var ErrPasswordsUnreadable = errors.New("stored passwords cannot be read")
+
 type UserService struct {
 	client *Client
 }
@@ -20,24 +34,65 @@ func NewUserService(client *Client) *UserService {
 	}
 }
 
-func (s *UserService) EnsureUsers(ctx context.Context, db *sql.DB, database *postgresv1.Database) ([]string, error) {
+// EnsureUsers creates the users of a database, using passwords[user.Name] for new users, and applies their
+// settings and privileges. A failing user does not stop the others: the users that exist are returned along
+// with the failure of every user that failed, keyed by name. parameters holds the role parameters the operator
+// set on each user before and is updated with the ones it manages now.
+func (s *UserService) EnsureUsers(ctx context.Context, db *sql.DB, database *postgresv1.Database, passwords map[string]string,
+	parameters map[string][]string) ([]string, map[string]error) {
 	usersCreated := make([]string, 0, len(database.Spec.Users))
+	failures := make(map[string]error)
 
 	for _, user := range database.Spec.Users {
-		if err := s.EnsureUser(ctx, db, user); err != nil {
-			return usersCreated, fmt.Errorf("failed to ensure user %s: %w", user.Name, err)
+		if err := s.EnsureUser(ctx, db, user, passwords[user.Name]); err != nil {
+			failures[user.Name] = fmt.Errorf("failed to ensure user %s: %w", user.Name, err)
+			continue
 		}
 		usersCreated = append(usersCreated, user.Name)
 
-		if err := s.GrantPermissions(ctx, db, database.Spec.DatabaseName, user); err != nil {
-			return usersCreated, fmt.Errorf("failed to grant permissions to user %s: %w", user.Name, err)
+		if err := s.ensureUserSetup(ctx, db, database, user, passwords[user.Name], parameters); err != nil {
+			failures[user.Name] = err
 		}
 	}
 
-	return usersCreated, nil
+	return usersCreated, failures
 }
 
-func (s *UserService) EnsureUser(ctx context.Context, db *sql.DB, user postgresv1.DatabaseUser) error {
+// ensureUserSetup applies the settings, comment and privileges of an existing user
+func (s *UserService) ensureUserSetup(ctx context.Context, db *sql.DB, database *postgresv1.Database, user postgresv1.DatabaseUser, password string,
+	parameters map[string][]string) error {
+	managed, err := s.ensureSettings(ctx, db, user, password, parameters[user.Name])
+	parameters[user.Name] = managed
+	if err != nil {
+		return fmt.Errorf("failed to apply settings of user %s: %w", user.Name, err)
+	}
+
+	// Shared roles keep the marker of their PostgresRole, which would refuse the role without it
+	current, err := sharedComment(ctx, db, "ROLE", user.Name)
+	if err != nil {
+		return fmt.Errorf("failed to read comment of user %s: %w", user.Name, err)
+	}
+	if marker, ok := parseMarker(current); !ok || marker.kind != "PostgresRole" {
+		comment := managedComment(user.Comment, "Database", database.Namespace, database.Name, database.UID)
+		if err := ensureSharedComment(ctx, db, "ROLE", user.Name, comment); err != nil {
+			return fmt.Errorf("failed to comment on user %s: %w", user.Name, err)
+		}
+	}
+
+	if database.Spec.PermissionModel == postgresv1.PermissionModelGroupRoles {
+		if err := s.ensureGroupMembership(ctx, db, database.Spec.DatabaseName, user); err != nil {
+			return fmt.Errorf("failed to grant group membership to user %s: %w", user.Name, err)
+		}
+		return nil
+	}
+
+	if err := s.GrantPermissions(ctx, db, database.Spec.DatabaseName, user); err != nil {
+		return fmt.Errorf("failed to grant permissions to user %s: %w", user.Name, err)
+	}
+	return nil
+}
+
+func (s *UserService) EnsureUser(ctx context.Context, db *sql.DB, user postgresv1.DatabaseUser, password string) error {
 	exists, err := s.userExists(ctx, db, user.Name)
 	if err != nil {
 		return fmt.Errorf("failed to check if user exists: %w", err)
@@ -47,12 +102,21 @@ func (s *UserService) EnsureUser(ctx context.Context, db *sql.DB, user postgresv
 		return nil
 	}
 
-	password, err := s.generatePassword()
-	if err != nil {
-		return fmt.Errorf("failed to generate password: %w", err)
+	if !canLogin(user) {
+		if _, err := db.ExecContext(ctx, fmt.Sprintf("CREATE ROLE %s NOLOGIN", pq.QuoteIdentifier(user.Name))); err != nil {
+			return fmt.Errorf("failed to create role: %w", err)
+		}
+		return nil
 	}
 
-	createUserQuery := fmt.Sprintf("CREATE USER %s WITH ENCRYPTED PASSWORD '%s'", user.Name, password)
+	if password == "" {
+		return fmt.Errorf("no password available for user %s", user.Name)
+	}
+
+	createUserQuery, err := passwordQuery("CREATE USER %s WITH ENCRYPTED PASSWORD %s", user.Name, password)
+	if err != nil {
+		return err
+	}
 	if _, err := db.ExecContext(ctx, createUserQuery); err != nil {
 		return fmt.Errorf("failed to create user: %w", err)
 	}
@@ -60,49 +124,415 @@ func (s *UserService) EnsureUser(ctx context.Context, db *sql.DB, user postgresv
 	return nil
 }
 
+// NeedsPassword reports whether applying the user takes a password, which is when its role does not exist yet
+// or has to be allowed to log in again
+func (s *UserService) NeedsPassword(ctx context.Context, db *sql.DB, user postgresv1.DatabaseUser) (bool, error) {
+	var login bool
+	err := db.QueryRowContext(ctx, "SELECT rolcanlogin FROM pg_roles WHERE rolname = $1", user.Name).Scan(&login)
+	if errors.Is(err, sql.ErrNoRows) {
+		return true, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to read role %s: %w", user.Name, err)
+	}
+	return !login && canLogin(user) && !user.Disabled, nil
+}
+
+// passwordQuery fills the quoted role name and the SCRAM verifier of password into format, the password
+// itself never appears in the statement
+func passwordQuery(format, name, password string) (string, error) {
+	verifier, err := scramVerifier(password)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf(format, pq.QuoteIdentifier(name), pq.QuoteLiteral(verifier)), nil
+}
+
+func canLogin(user postgresv1.DatabaseUser) bool {
+	return user.Login == nil || *user.Login
+}
+
+// RenameUser renames the role from to name. Nothing is done when from no longer exists, and
+// renaming onto an existing role is refused.
+func (s *UserService) RenameUser(ctx context.Context, db *sql.DB, from, name string) error {
+	exists, err := s.userExists(ctx, db, from)
+	if err != nil {
+		return fmt.Errorf("failed to check if user exists: %w", err)
+	}
+	if !exists {
+		return nil
+	}
+
+	taken, err := s.userExists(ctx, db, name)
+	if err != nil {
+		return fmt.Errorf("failed to check if user exists: %w", err)
+	}
+	if taken {
+		return fmt.Errorf("%w: %s", ErrRoleExists, name)
+	}
+
+	if _, err := db.ExecContext(ctx, fmt.Sprintf("ALTER ROLE %s RENAME TO %s", pq.QuoteIdentifier(from), pq.QuoteIdentifier(name))); err != nil {
+		return fmt.Errorf("failed to rename role %s: %w", from, err)
+	}
+	return nil
+}
+
+// CreateTemporaryUser creates the login role of a TemporaryAccessRequest that stops accepting logins at
+// validUntil, together with the ownership marker of the request. A role carrying that marker was created by an
+// earlier attempt whose status update was lost, it is adopted and gets the new password. Other roles are
+// refused.
+func (s *UserService) CreateTemporaryUser(ctx context.Context, db *sql.DB, request *postgresv1.TemporaryAccessRequest, password string, validUntil time.Time) error {
+	name := request.Status.RoleName
+	exists, err := s.userExists(ctx, db, name)
+	if err != nil {
+		return fmt.Errorf("failed to check if user exists: %w", err)
+	}
+
+	verifier, err := scramVerifier(password)
+	if err != nil {
+		return err
+	}
+	options := fmt.Sprintf("ENCRYPTED PASSWORD %s VALID UNTIL %s", pq.QuoteLiteral(verifier), pq.QuoteLiteral(validUntil.UTC().Format(time.RFC3339)))
+
+	if exists {
+		owned, err := s.IsTemporaryUserOf(ctx, db, request)
+		if err != nil {
+			return err
+		}
+		if !owned {
+			return fmt.Errorf("%w: %s", ErrRoleExists, name)
+		}
+		if _, err := db.ExecContext(ctx, fmt.Sprintf("ALTER ROLE %s WITH LOGIN %s", pq.QuoteIdentifier(name), options)); err != nil {
+			return fmt.Errorf("failed to update user: %w", err)
+		}
+		return nil
+	}
+
+	comment := managedComment("", "TemporaryAccessRequest", request.Namespace, request.Name, request.UID)
+	if err := execInTransaction(ctx, db, []string{
+		fmt.Sprintf("CREATE USER %s WITH %s", pq.QuoteIdentifier(name), options),
+		fmt.Sprintf("COMMENT ON ROLE %s IS %s", pq.QuoteIdentifier(name), pq.QuoteLiteral(comment)),
+	}); err != nil {
+		return fmt.Errorf("failed to create user: %w", err)
+	}
+
+	return nil
+}
+
+// IsTemporaryUserOf reports whether the role of the request exists and carries the ownership marker of the request
+func (s *UserService) IsTemporaryUserOf(ctx context.Context, db *sql.DB, request *postgresv1.TemporaryAccessRequest) (bool, error) {
+	comment, err := sharedComment(ctx, db, "ROLE", request.Status.RoleName)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to read comment of role %s: %w", request.Status.RoleName, err)
+	}
+
+	marker, ok := parseMarker(comment)
+	return ok && marker.kind == "TemporaryAccessRequest" && marker.uid == request.UID, nil
+}
+
+// DropTemporaryUser drops the role of the request like DropUser, unless it does not carry the ownership marker
+// of the request
+func (s *UserService) DropTemporaryUser(ctx context.Context, db *sql.DB, request *postgresv1.TemporaryAccessRequest) error {
+	owned, err := s.IsTemporaryUserOf(ctx, db, request)
+	if err != nil || !owned {
+		return err
+	}
+	return s.DropUser(ctx, db, request.Status.RoleName)
+}
+
+// DropUser terminates the sessions of a role, drops everything it owns in the
+// connected database and removes the role. Missing roles are ignored.
+func (s *UserService) DropUser(ctx context.Context, db *sql.DB, name string) error {
+	exists, err := s.userExists(ctx, db, name)
+	if err != nil {
+		return fmt.Errorf("failed to check if user exists: %w", err)
+	}
+
+	if !exists {
+		return nil
+	}
+
+	if err := terminateUserSessions(ctx, db, name); err != nil {
+		return err
+	}
+
+	if _, err := db.ExecContext(ctx, fmt.Sprintf("DROP OWNED BY %s", pq.QuoteIdentifier(name))); err != nil {
+		return fmt.Errorf("failed to drop owned objects: %w", err)
+	}
+
+	if _, err := db.ExecContext(ctx, fmt.Sprintf("DROP ROLE %s", pq.QuoteIdentifier(name))); err != nil {
+		return fmt.Errorf("failed to drop role: %w", err)
+	}
+
+	return nil
+}
+
+// ReassignAndDropUser hands the objects a role owns in the connected database to newOwner, so the data
+// it created outlives it, and then drops the role like DropUser. Missing roles are ignored.
+func (s *UserService) ReassignAndDropUser(ctx context.Context, db *sql.DB, name, newOwner string) error {
+	exists, err := s.userExists(ctx, db, name)
+	if err != nil {
+		return fmt.Errorf("failed to check if user exists: %w", err)
+	}
+
+	if !exists {
+		return nil
+	}
+
+	reassignQuery := fmt.Sprintf("REASSIGN OWNED BY %s TO %s", pq.QuoteIdentifier(name), pq.QuoteIdentifier(newOwner))
+	if _, err := db.ExecContext(ctx, reassignQuery); err != nil {
+		return fmt.Errorf("failed to reassign owned objects: %w", err)
+	}
+
+	return s.DropUser(ctx, db, name)
+}
+
+func terminateUserSessions(ctx context.Context, db *sql.DB, name string) error {
+	terminateQuery := "SELECT pg_terminate_backend(pid) FROM pg_stat_activity WHERE usename = $1"
+	if _, err := db.ExecContext(ctx, terminateQuery, name); err != nil {
+		return fmt.Errorf("failed to terminate sessions: %w", err)
+	}
+	return nil
+}
+
+// ensureSettings applies the login attribute, connection limit and role parameters of the user, previous being
+// the role parameters the operator set before. A role that is allowed to log in again gets a password, as it may
+// never have had one. Disabled users keep their password so re-enabling them restores the credentials in their
+// secret. Returns the role parameters the operator now manages.
+func (s *UserService) ensureSettings(ctx context.Context, db *sql.DB, user postgresv1.DatabaseUser, password string, previous []string) ([]string, error) {
+	var currentLogin bool
+	var currentLimit int32
+	var currentConfig []string
+	query := "SELECT rolcanlogin, rolconnlimit, COALESCE(rolconfig, '{}') FROM pg_roles WHERE rolname = $1"
+	if err := db.QueryRowContext(ctx, query, user.Name).Scan(&currentLogin, &currentLimit, pq.Array(&currentConfig)); err != nil {
+		return previous, err
+	}
+
+	switch login := canLogin(user) && !user.Disabled; {
+	case login && !currentLogin:
+		if password == "" {
+			return previous, fmt.Errorf("no password available for user %s", user.Name)
+		}
+		verifier, err := scramVerifier(password)
+		if err != nil {
+			return previous, err
+		}
+		alterQuery := fmt.Sprintf("ALTER ROLE %s WITH LOGIN ENCRYPTED PASSWORD %s", pq.QuoteIdentifier(user.Name), pq.QuoteLiteral(verifier))
+		if _, err := db.ExecContext(ctx, alterQuery); err != nil {
+			return previous, fmt.Errorf("failed to allow login: %w", err)
+		}
+	case !login && currentLogin:
+		alterQuery := fmt.Sprintf("ALTER ROLE %s WITH NOLOGIN PASSWORD NULL", pq.QuoteIdentifier(user.Name))
+		if user.Disabled && canLogin(user) {
+			alterQuery = fmt.Sprintf("ALTER ROLE %s WITH NOLOGIN", pq.QuoteIdentifier(user.Name))
+		}
+		if _, err := db.ExecContext(ctx, alterQuery); err != nil {
+			return previous, fmt.Errorf("failed to disallow login: %w", err)
+		}
+	}
+
+	if user.Disabled && user.TerminateSessions {
+		if err := terminateUserSessions(ctx, db, user.Name); err != nil {
+			return previous, err
+		}
+	}
+
+	if user.ConnectionLimit != nil && currentLimit != *user.ConnectionLimit {
+		alterQuery := fmt.Sprintf("ALTER ROLE %s WITH CONNECTION LIMIT %d", pq.QuoteIdentifier(user.Name), *user.ConnectionLimit)
+		if _, err := db.ExecContext(ctx, alterQuery); err != nil {
+			return previous, fmt.Errorf("failed to set connection limit: %w", err)
+		}
+	}
+
+	return ensureRoleConfig(ctx, db, user.Name, currentConfig, user.RoleParameters, previous)
+}
+
+// SetPassword changes the password of an existing user
+func (s *UserService) SetPassword(ctx context.Context, db *sql.DB, name, password string) error {
+	query, err := passwordQuery("ALTER USER %s WITH ENCRYPTED PASSWORD %s", name, password)
+	if err != nil {
+		return err
+	}
+	if _, err := db.ExecContext(ctx, query); err != nil {
+		return fmt.Errorf("failed to set password of %s: %w", name, err)
+	}
+	return nil
+}
+
+// PasswordMatches reports whether password is the current password of a role by comparing it with the
+// verifier in pg_authid, which requires superuser rights. Roles without a password never match.
+func (s *UserService) PasswordMatches(ctx context.Context, db *sql.DB, name, password string) (bool, error) {
+	var stored sql.NullString
+	err := db.QueryRowContext(ctx, "SELECT rolpassword FROM pg_authid WHERE rolname = $1", name).Scan(&stored)
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "42501" {
+		return false, fmt.Errorf("%w: %w: %v", ErrPasswordUnverifiable, ErrPasswordsUnreadable, err)
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to read password of %s: %w", name, err)
+	}
+	if !stored.Valid {
+		return false, nil
+	}
+
+	if md5Hash, ok := strings.CutPrefix(stored.String, "md5"); ok {
+		sum := md5.Sum([]byte(password + name))
+		return hex.EncodeToString(sum[:]) == md5Hash, nil
+	}
+	matches, err := scramMatches(stored.String, password)
+	if err != nil {
+		return false, fmt.Errorf("%w: %v", ErrPasswordUnverifiable, err)
+	}
+	return matches, nil
+}
+
+// RevokeUser revokes the privileges a user holds on the database and the public schema, including
+// default privileges and group memberships. db must be connected to the database itself.
+func (s *UserService) RevokeUser(ctx context.Context, db *sql.DB, database *postgresv1.Database, name string) error {
+	exists, err := s.userExists(ctx, db, name)
+	if err != nil {
+		return fmt.Errorf("failed to check if user exists: %w", err)
+	}
+
+	if !exists {
+		return nil
+	}
+
+	if err := execInTransaction(ctx, db, revokeStatements(database, name)); err != nil {
+		return fmt.Errorf("failed to revoke privileges: %w", err)
+	}
+
+	return nil
+}
+
+// revokeStatements returns the statements revoking what a user may hold on the database
+func revokeStatements(database *postgresv1.Database, name string) []string {
+	databaseName := database.Spec.DatabaseName
+	defaultPrivileges := "ALTER DEFAULT PRIVILEGES"
+	if database.Spec.Owner != "" {
+		defaultPrivileges = fmt.Sprintf("%s FOR ROLE %s", defaultPrivileges, pq.QuoteIdentifier(database.Spec.Owner))
+	}
+	role := pq.QuoteIdentifier(name)
+
+	revokes := []string{
+		fmt.Sprintf("REVOKE ALL ON DATABASE %s FROM %s", pq.QuoteIdentifier(databaseName), role),
+		fmt.Sprintf("REVOKE ALL ON SCHEMA public FROM %s", role),
+		fmt.Sprintf("REVOKE ALL ON ALL TABLES IN SCHEMA public FROM %s", role),
+		fmt.Sprintf("REVOKE ALL ON ALL SEQUENCES IN SCHEMA public FROM %s", role),
+		fmt.Sprintf("REVOKE ALL ON ALL FUNCTIONS IN SCHEMA public FROM %s", role),
+		fmt.Sprintf("%s IN SCHEMA public REVOKE ALL ON TABLES FROM %s", defaultPrivileges, role),
+		fmt.Sprintf("%s IN SCHEMA public REVOKE ALL ON SEQUENCES FROM %s", defaultPrivileges, role),
+		fmt.Sprintf("%s IN SCHEMA public REVOKE ALL ON FUNCTIONS FROM %s", defaultPrivileges, role),
+	}
+	if database.Spec.PermissionModel == postgresv1.PermissionModelGroupRoles {
+		for _, group := range groupPresets {
+			revokes = append(revokes, fmt.Sprintf("REVOKE %s FROM %s", pq.QuoteIdentifier(GroupRoleName(databaseName, group.group)), role))
+		}
+	}
+	return revokes
+}
+
+// SetValidUntil sets the expiry of a role's password, or removes it when validUntil is nil
+func (s *UserService) SetValidUntil(ctx context.Context, db *sql.DB, name string, validUntil *time.Time) error {
+	desired := "infinity"
+	if validUntil != nil {
+		desired = validUntil.UTC().Format(time.RFC3339)
+	}
+
+	var current bool
+	query := "SELECT COALESCE(rolvaliduntil, 'infinity') = $2::timestamptz FROM pg_roles WHERE rolname = $1"
+	if err := db.QueryRowContext(ctx, query, name, desired).Scan(&current); err != nil {
+		return fmt.Errorf("failed to read expiry of %s: %w", name, err)
+	}
+	if current {
+		return nil
+	}
+
+	alterQuery := fmt.Sprintf("ALTER ROLE %s VALID UNTIL %s", pq.QuoteIdentifier(name), pq.QuoteLiteral(desired))
+	if _, err := db.ExecContext(ctx, alterQuery); err != nil {
+		return fmt.Errorf("failed to set expiry of %s: %w", name, err)
+	}
+
+	return nil
+}
+
 func (s *UserService) GrantPermissions(ctx context.Context, db *sql.DB, databaseName string, user postgresv1.DatabaseUser) error {
+	database := pq.QuoteIdentifier(databaseName)
+	role := pq.QuoteIdentifier(user.Name)
+	grants := make([]string, 0, len(user.Permissions))
 	for _, permission := range user.Permissions {
 		var grantQuery string
 		switch permission {
 		case postgresv1.PermissionAll:
-			grantQuery = fmt.Sprintf("GRANT ALL PRIVILEGES ON DATABASE %s TO %s", databaseName, user.Name)
+			grantQuery = fmt.Sprintf("GRANT ALL PRIVILEGES ON DATABASE %s TO %s", database, role)
 		case postgresv1.PermissionConnect:
-			grantQuery = fmt.Sprintf("GRANT CONNECT ON DATABASE %s TO %s", databaseName, user.Name)
+			grantQuery = fmt.Sprintf("GRANT CONNECT ON DATABASE %s TO %s", database, role)
 		case postgresv1.PermissionCreate:
-			grantQuery = fmt.Sprintf("GRANT CREATE ON DATABASE %s TO %s", databaseName, user.Name)
+			grantQuery = fmt.Sprintf("GRANT CREATE ON DATABASE %s TO %s", database, role)
 		case postgresv1.PermissionUsage:
-			grantQuery = fmt.Sprintf("GRANT USAGE ON SCHEMA public TO %s", user.Name)
+			grantQuery = fmt.Sprintf("GRANT USAGE ON SCHEMA public TO %s", role)
 		case postgresv1.PermissionSelect:
-			grantQuery = fmt.Sprintf("GRANT SELECT ON ALL TABLES IN SCHEMA public TO %s", user.Name)
+			grantQuery = fmt.Sprintf("GRANT SELECT ON ALL TABLES IN SCHEMA public TO %s", role)
 		case postgresv1.PermissionInsert:
-			grantQuery = fmt.Sprintf("GRANT INSERT ON ALL TABLES IN SCHEMA public TO %s", user.Name)
+			grantQuery = fmt.Sprintf("GRANT INSERT ON ALL TABLES IN SCHEMA public TO %s", role)
 		case postgresv1.PermissionUpdate:
-			grantQuery = fmt.Sprintf("GRANT UPDATE ON ALL TABLES IN SCHEMA public TO %s", user.Name)
+			grantQuery = fmt.Sprintf("GRANT UPDATE ON ALL TABLES IN SCHEMA public TO %s", role)
 		case postgresv1.PermissionDelete:
-			grantQuery = fmt.Sprintf("GRANT DELETE ON ALL TABLES IN SCHEMA public TO %s", user.Name)
+			grantQuery = fmt.Sprintf("GRANT DELETE ON ALL TABLES IN SCHEMA public TO %s", role)
 		default:
 			return fmt.Errorf("unsupported permission: %s", permission)
 		}
+		grants = append(grants, grantQuery)
+	}
 
-		if _, err := db.ExecContext(ctx, grantQuery); err != nil {
-			return fmt.Errorf("failed to grant %s permission: %w", permission, err)
-		}
+	if err := execInTransaction(ctx, db, grants); err != nil {
+		return fmt.Errorf("failed to grant permissions: %w", err)
 	}
+	return nil
+}
 
+// GrantDefaultPrivileges issues ALTER DEFAULT PRIVILEGES for the user. db must be connected to the
+// target database since default privileges are stored per database. owner is used for entries
+// without forRole; when empty the privileges cover objects created by the connecting user.
+func (s *UserService) GrantDefaultPrivileges(ctx context.Context, db *sql.DB, owner string, user postgresv1.DatabaseUser) error {
+	if err := execInTransaction(ctx, db, defaultPrivilegeStatements(owner, user)); err != nil {
+		return fmt.Errorf("failed to grant default privileges: %w", err)
+	}
 	return nil
 }
 
+// defaultPrivilegeStatements returns the ALTER DEFAULT PRIVILEGES statements of a user
+func defaultPrivilegeStatements(owner string, user postgresv1.DatabaseUser) []string {
+	grants := make([]string, 0, len(user.DefaultPrivileges))
+	for _, privilege := range user.DefaultPrivileges {
+		schema := privilege.Schema
+		if schema == "" {
+			schema = "public"
+		}
+
+		forRole := privilege.ForRole
+		if forRole == "" {
+			forRole = owner
+		}
+
+		query := "ALTER DEFAULT PRIVILEGES"
+		if forRole != "" {
+			query = fmt.Sprintf("%s FOR ROLE %s", query, pq.QuoteIdentifier(forRole))
+		}
+		query = fmt.Sprintf("%s IN SCHEMA %s GRANT %s ON %s TO %s", query, pq.QuoteIdentifier(schema),
+			joinPrivileges(privilege.Privileges), strings.ToUpper(privilege.ObjectType), pq.QuoteIdentifier(user.Name))
+		grants = append(grants, query)
+	}
+	return grants
+}
+
 func (s *UserService) userExists(ctx context.Context, db *sql.DB, username string) (bool, error) {
 	var exists bool
-	query := "SELECT EXISTS(SELECT 1 FROM pg_user WHERE usename = $1)"
+	// pg_roles rather than pg_user, which leaves out NOLOGIN and disabled users
+	query := "SELECT EXISTS(SELECT 1 FROM pg_roles WHERE rolname = $1)"
 	err := db.QueryRowContext(ctx, query, username).Scan(&exists)
 	return exists, err
 }
-
-func (s *UserService) generatePassword() (string, error) {
-	bytes := make([]byte, 32)
-	if _, err := rand.Read(bytes); err != nil {
-		return "", err
-	}
-	return base64.URLEncoding.EncodeToString(bytes), nil
-}
@@ -0,0 +1,69 @@
+package postgres
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	postgresv1 "github.com/silverswarm/pg-operator/api/v1"
+)
+
+func TestPasswordQuery(t *testing.T) {
+	name := `app"; DROP ROLE postgres; --`
+	password := `it's"; DROP ROLE postgres; --`
+
+	got, err := passwordQuery("CREATE USER %s WITH ENCRYPTED PASSWORD %s", name, password)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	prefix := `CREATE USER "app""; DROP ROLE postgres; --" WITH ENCRYPTED PASSWORD '`
+	verifier, ok := strings.CutPrefix(got, prefix)
+	if !ok || !strings.HasSuffix(verifier, "'") {
+		t.Fatalf("passwordQuery() = %s, want %s<verifier>'", got, prefix)
+	}
+	if strings.Contains(got, "it's") {
+		t.Errorf("passwordQuery() = %s, contains the password", got)
+	}
+	if matches, err := scramMatches(strings.TrimSuffix(verifier, "'"), password); err != nil || !matches {
+		t.Errorf("verifier of passwordQuery() does not match the password: %v", err)
+	}
+}
+
+func TestRevokeStatements(t *testing.T) {
+	database := &postgresv1.Database{Spec: postgresv1.DatabaseSpec{
+		DatabaseName:    `orders"`,
+		Owner:           `own"er`,
+		PermissionModel: postgresv1.PermissionModelGroupRoles,
+	}}
+
+	got := revokeStatements(database, `app"; --`)
+	for _, want := range []string{
+		`REVOKE ALL ON DATABASE "orders""" FROM "app""; --"`,
+		`ALTER DEFAULT PRIVILEGES FOR ROLE "own""er" IN SCHEMA public REVOKE ALL ON TABLES FROM "app""; --"`,
+		`REVOKE "orders""_readonly" FROM "app""; --"`,
+	} {
+		if !slices.Contains(got, want) {
+			t.Errorf("revokeStatements() = %q, want it to contain %s", got, want)
+		}
+	}
+}
+
+func TestDefaultPrivilegeStatements(t *testing.T) {
+	user := postgresv1.DatabaseUser{
+		Name: `app"user`,
+		DefaultPrivileges: []postgresv1.UserDefaultPrivilege{
+			{ObjectType: "tables", Privileges: []postgresv1.ObjectPrivilege{"SELECT", "INSERT"}},
+			{Schema: `sales"; --`, ObjectType: "sequences", Privileges: []postgresv1.ObjectPrivilege{"USAGE"}, ForRole: `mig"rator`},
+		},
+	}
+
+	got := defaultPrivilegeStatements(`own"er`, user)
+	want := []string{
+		`ALTER DEFAULT PRIVILEGES FOR ROLE "own""er" IN SCHEMA "public" GRANT SELECT, INSERT ON TABLES TO "app""user"`,
+		`ALTER DEFAULT PRIVILEGES FOR ROLE "mig""rator" IN SCHEMA "sales""; --" GRANT USAGE ON SEQUENCES TO "app""user"`,
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("defaultPrivilegeStatements() = %q, want %q", got, want)
+	}
+}
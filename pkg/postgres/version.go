@@ -0,0 +1,45 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// Server versions in server_version_num form that statements of the operator depend on
+const (
+	// postgres13 added WITH (FORCE) to DROP DATABASE
+	postgres13 = 130000
+	// postgres15 added LOCALE_PROVIDER and ICU_LOCALE to CREATE DATABASE
+	postgres15 = 150000
+)
+
+// serverVersionNum returns the version of the connected server, e.g. 160004 for 16.4
+func serverVersionNum(ctx context.Context, db *sql.DB) (int, error) {
+	var version int
+	if err := db.QueryRowContext(ctx, "SELECT current_setting('server_version_num')::int").Scan(&version); err != nil {
+		return 0, fmt.Errorf("failed to read server version: %w", err)
+	}
+	return version, nil
+}
+
+// requireVersion fails before a statement the server would reject, naming the feature that needs a newer server
+func requireVersion(ctx context.Context, db *sql.DB, minimum int, feature string) error {
+	version, err := serverVersionNum(ctx, db)
+	if err != nil {
+		return err
+	}
+	if version < minimum {
+		return fmt.Errorf("%s needs PostgreSQL %s or later, the server runs %s", feature, formatVersion(minimum), formatVersion(version))
+	}
+	return nil
+}
+
+// formatVersion renders a server_version_num, which has two digits for the minor version from 10 on
+// and two each for the major and minor version before
+func formatVersion(version int) string {
+	if version >= 100000 {
+		return fmt.Sprintf("%d.%d", version/10000, version%10000)
+	}
+	return fmt.Sprintf("%d.%d.%d", version/10000, version/100%100, version%100)
+}
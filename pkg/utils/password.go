@@ -1,23 +1,120 @@
 package utils
 
 import (
+	"bytes"
 	"crypto/rand"
-	"encoding/base64"
 	"fmt"
 	"math/big"
+	"strings"
 )
 
+const specialChars = "!@#$%^&*"
+
+// Password charsets selectable in a PasswordPolicy
 const (
-	passwordLength = 32
-	specialChars   = "!@#$%^&*"
+	CharsetAlphanumeric = "alphanumeric"
+	CharsetURLSafe      = "urlsafe"
+	CharsetASCII        = "ascii"
 )
 
-func GenerateSecurePassword() (string, error) {
-	bytes := make([]byte, passwordLength)
-	if _, err := rand.Read(bytes); err != nil {
-		return "", fmt.Errorf("failed to generate random bytes: %w", err)
+var charsets = map[string]string{
+	CharsetAlphanumeric: "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789",
+	CharsetURLSafe:      "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_",
+	// Printable ASCII without space, quotes and backslash, which tend to break shells and config files
+	CharsetASCII: "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789!#$%&()*+,-./:;<=>?@[]^_{|}~",
+}
+
+// ambiguousChars are easily confused when passwords are read by humans
+const ambiguousChars = "0O1lI|"
+
+// PasswordPolicy controls how passwords are generated
+type PasswordPolicy struct {
+	// Length of the password
+	Length int
+	// Charset is one of the Charset constants
+	Charset string
+	// RequireSpecial makes sure the password contains a special character
+	RequireSpecial bool
+	// ExcludeAmbiguous leaves out characters such as 0, O, 1 and l
+	ExcludeAmbiguous bool
+}
+
+// DefaultPasswordPolicy is used when the operator is not configured otherwise
+var DefaultPasswordPolicy = PasswordPolicy{Length: 32, Charset: CharsetAlphanumeric}
+
+// Validate reports settings the generator cannot honor
+func (p PasswordPolicy) Validate() error {
+	if p.Length < 12 || p.Length > 128 {
+		return fmt.Errorf("password length must be between 12 and 128, got %d", p.Length)
+	}
+	if _, ok := charsets[p.Charset]; !ok {
+		return fmt.Errorf("unknown password charset %q", p.Charset)
+	}
+	return nil
+}
+
+// GeneratePassword generates a random password following the policy
+func GeneratePassword(policy PasswordPolicy) (string, error) {
+	if err := policy.Validate(); err != nil {
+		return "", err
+	}
+
+	pool := charsets[policy.Charset]
+	if policy.RequireSpecial {
+		pool += specialChars
+	}
+	exclude := ""
+	if policy.ExcludeAmbiguous {
+		exclude = ambiguousChars
+	}
+	// Also drops the duplicates the specials add to the ascii charset, which would skew the distribution
+	pool = withoutChars(pool, exclude)
+	specials := withoutChars(specialChars, exclude)
+
+	password := make([]byte, policy.Length)
+	hasSpecial := false
+	for i := range password {
+		c, err := randomChar(pool)
+		if err != nil {
+			return "", err
+		}
+		password[i] = c
+		hasSpecial = hasSpecial || strings.IndexByte(specials, c) >= 0
+	}
+
+	if policy.RequireSpecial && !hasSpecial {
+		pos, err := rand.Int(rand.Reader, big.NewInt(int64(len(password))))
+		if err != nil {
+			return "", fmt.Errorf("failed to generate random position: %w", err)
+		}
+		c, err := randomChar(specials)
+		if err != nil {
+			return "", err
+		}
+		password[pos.Int64()] = c
+	}
+
+	return string(password), nil
+}
+
+func randomChar(chars string) (byte, error) {
+	idx, err := rand.Int(rand.Reader, big.NewInt(int64(len(chars))))
+	if err != nil {
+		return 0, fmt.Errorf("failed to generate random character: %w", err)
+	}
+	return chars[idx.Int64()], nil
+}
+
+// withoutChars removes the given characters and duplicates from chars
+func withoutChars(chars, remove string) string {
+	var result []byte
+	for i := 0; i < len(chars); i++ {
+		c := chars[i]
+		if strings.IndexByte(remove, c) < 0 && bytes.IndexByte(result, c) < 0 {
+			result = append(result, c)
+		}
 	}
-	return base64.URLEncoding.EncodeToString(bytes), nil
+	return string(result)
 }
 
 func GenerateReadablePassword(length int) (string, error) {
@@ -0,0 +1,582 @@
+package validation
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+
+	postgresv1 "github.com/silverswarm/pg-operator/api/v1"
+	"github.com/silverswarm/pg-operator/pkg/k8s"
+	"github.com/silverswarm/pg-operator/pkg/postgres"
+)
+
+// maxIdentifierLength is PostgreSQL's NAMEDATALEN - 1
+const maxIdentifierLength = 63
+
+var identifierPattern = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9_-]*$`)
+
+var supportedPermissions = []postgresv1.Permission{
+	postgresv1.PermissionConnect,
+	postgresv1.PermissionCreate,
+	postgresv1.PermissionUsage,
+	postgresv1.PermissionSelect,
+	postgresv1.PermissionInsert,
+	postgresv1.PermissionUpdate,
+	postgresv1.PermissionDelete,
+	postgresv1.PermissionAll,
+}
+
+// defaultPrivilegeTypes lists the privileges ALTER DEFAULT PRIVILEGES accepts per object type
+var defaultPrivilegeTypes = map[string][]postgresv1.ObjectPrivilege{
+	"tables":    {"SELECT", "INSERT", "UPDATE", "DELETE", "TRUNCATE", "REFERENCES", "TRIGGER", "ALL"},
+	"sequences": {"USAGE", "SELECT", "UPDATE", "ALL"},
+	"functions": {"EXECUTE", "ALL"},
+}
+
+// reservedDatabaseNames are system databases the operator must not create or take over
+var reservedDatabaseNames = []string{"postgres", "template0", "template1"}
+
+// reservedRoleNames are the superuser and names PostgreSQL does not allow for roles
+var reservedRoleNames = []string{"postgres", "public", "none"}
+
+var supportedSSLModes = []string{"disable", "allow", "prefer", "require", "verify-ca", "verify-full"}
+
+// ValidateDatabase checks a Database for errors that the API server schema cannot catch
+func ValidateDatabase(database *postgresv1.Database) field.ErrorList {
+	var allErrs field.ErrorList
+	specPath := field.NewPath("spec")
+
+	if database.Spec.ConnectionRef.Name == "" {
+		allErrs = append(allErrs, field.Required(specPath.Child("connectionRef", "name"), ""))
+	}
+
+	allErrs = append(allErrs, ValidateIdentifier(database.Spec.DatabaseName, specPath.Child("databaseName"))...)
+	allErrs = append(allErrs, validateReservedName(database.Spec.DatabaseName, reservedDatabaseNames, specPath.Child("databaseName"))...)
+
+	if database.Spec.Owner != "" {
+		allErrs = append(allErrs, ValidateIdentifier(database.Spec.Owner, specPath.Child("owner"))...)
+	}
+
+	if database.Spec.IcuLocale != "" && database.Spec.LocaleProvider != "icu" {
+		allErrs = append(allErrs, field.Invalid(specPath.Child("icuLocale"), database.Spec.IcuLocale, "requires localeProvider icu"))
+	}
+	if database.Spec.LocaleProvider == "icu" && database.Spec.IcuLocale == "" {
+		allErrs = append(allErrs, field.Required(specPath.Child("icuLocale"), "required when localeProvider is icu"))
+	}
+
+	if database.Spec.Tablespace != "" {
+		allErrs = append(allErrs, ValidateIdentifier(database.Spec.Tablespace, specPath.Child("tablespace"))...)
+	}
+
+	if final := database.Spec.FinalBackup; final != nil {
+		allErrs = append(allErrs, validateObjectName(final.Destination.CredentialsSecret.Name, specPath.Child("finalBackup", "destination", "credentialsSecret", "name"))...)
+		if final.Destination.Bucket == "" {
+			allErrs = append(allErrs, field.Required(specPath.Child("finalBackup", "destination", "bucket"), ""))
+		}
+	} else if database.Spec.DeletionPolicy == postgresv1.DatabaseDeletionDropWithBackup {
+		allErrs = append(allErrs, field.Required(specPath.Child("finalBackup"), "required with the DropWithBackup deletionPolicy"))
+	}
+
+	if database.Spec.TemplateDatabase != "" {
+		allErrs = append(allErrs, ValidateIdentifier(database.Spec.TemplateDatabase, specPath.Child("templateDatabase"))...)
+		if database.Spec.TemplateDatabase == database.Spec.DatabaseName {
+			allErrs = append(allErrs, field.Invalid(specPath.Child("templateDatabase"), database.Spec.TemplateDatabase, "must differ from databaseName"))
+		}
+	}
+
+	if database.Spec.SecretNameTemplate != "" {
+		if err := k8s.ValidateSecretNameTemplate(database.Spec.SecretNameTemplate); err != nil {
+			allErrs = append(allErrs, field.Invalid(specPath.Child("secretNameTemplate"), database.Spec.SecretNameTemplate, err.Error()))
+		}
+	}
+
+	userNames := make(map[string]bool, len(database.Spec.Users))
+	secretNames := make(map[string]bool, len(database.Spec.Users))
+	for i, user := range database.Spec.Users {
+		userPath := specPath.Child("users").Index(i)
+
+		allErrs = append(allErrs, ValidateIdentifier(user.Name, userPath.Child("name"))...)
+		allErrs = append(allErrs, validateReservedName(user.Name, reservedRoleNames, userPath.Child("name"))...)
+		if userNames[user.Name] {
+			allErrs = append(allErrs, field.Duplicate(userPath.Child("name"), user.Name))
+		}
+		userNames[user.Name] = true
+
+		if database.Spec.PermissionModel == postgresv1.PermissionModelGroupRoles {
+			if user.Group == "" {
+				allErrs = append(allErrs, field.Required(userPath.Child("group"), "required with the groupRoles permission model"))
+			}
+			if len(user.Permissions) > 0 {
+				allErrs = append(allErrs, field.Forbidden(userPath.Child("permissions"), "permissions are granted through the group with the groupRoles permission model"))
+			}
+			if user.Role != "" {
+				allErrs = append(allErrs, field.Forbidden(userPath.Child("role"), "privileges are granted through the group with the groupRoles permission model"))
+			}
+		} else {
+			if len(user.Permissions) == 0 && user.Role == "" {
+				allErrs = append(allErrs, field.Required(userPath.Child("permissions"), "at least one permission or a role is required"))
+			}
+			if user.Group != "" {
+				allErrs = append(allErrs, field.Forbidden(userPath.Child("group"), "only used with the groupRoles permission model"))
+			}
+		}
+		for j, permission := range user.Permissions {
+			if !slices.Contains(supportedPermissions, permission) {
+				allErrs = append(allErrs, field.NotSupported(userPath.Child("permissions").Index(j), permission, supportedPermissions))
+			}
+		}
+
+		if user.Login != nil && !*user.Login {
+			if user.PasswordSecretRef != nil {
+				allErrs = append(allErrs, field.Forbidden(userPath.Child("passwordSecretRef"), "users with login false have no password"))
+			}
+			if user.PasswordRotation != nil && user.PasswordRotation.Enabled {
+				allErrs = append(allErrs, field.Forbidden(userPath.Child("passwordRotation"), "users with login false have no password"))
+			}
+		}
+
+		if user.PasswordSecretRef != nil {
+			allErrs = append(allErrs, validateObjectName(user.PasswordSecretRef.Name, userPath.Child("passwordSecretRef", "name"))...)
+			if user.PasswordSecretRef.Name == k8s.UserSecretName(database, user) && k8s.UserSecretNamespace(database, user) == database.Namespace {
+				allErrs = append(allErrs, field.Invalid(userPath.Child("passwordSecretRef", "name"), user.PasswordSecretRef.Name,
+					"must differ from the secret created for the user; set createSecret to false to only use the source"))
+			}
+		}
+
+		if user.TTL != nil && user.TTL.Duration <= 0 {
+			allErrs = append(allErrs, field.Invalid(userPath.Child("ttl"), user.TTL.Duration.String(), "must be greater than zero"))
+		}
+		if user.DropAfterExpiry && user.ValidUntil == nil && user.TTL == nil {
+			allErrs = append(allErrs, field.Required(userPath.Child("ttl"), "validUntil or ttl is required with dropAfterExpiry"))
+		}
+
+		for j, privilege := range user.DefaultPrivileges {
+			privilegePath := userPath.Child("defaultPrivileges").Index(j)
+			allowed, ok := defaultPrivilegeTypes[privilege.ObjectType]
+			if !ok {
+				allErrs = append(allErrs, field.NotSupported(privilegePath.Child("objectType"), privilege.ObjectType, []string{"tables", "sequences", "functions"}))
+				continue
+			}
+			for k, p := range privilege.Privileges {
+				if !slices.Contains(allowed, p) {
+					allErrs = append(allErrs, field.NotSupported(privilegePath.Child("privileges").Index(k), p, allowed))
+				}
+			}
+		}
+
+		if user.ReplicateTo != nil {
+			replicatePath := userPath.Child("replicateTo")
+			if !k8s.HasUserSecret(user) {
+				allErrs = append(allErrs, field.Forbidden(replicatePath, "the user has no secret to replicate"))
+			}
+			for j, namespace := range user.ReplicateTo.Namespaces {
+				for _, msg := range validation.IsDNS1123Label(namespace) {
+					allErrs = append(allErrs, field.Invalid(replicatePath.Child("namespaces").Index(j), namespace, msg))
+				}
+			}
+			if selector := user.ReplicateTo.NamespaceSelector; selector != nil {
+				if _, err := metav1.LabelSelectorAsSelector(selector); err != nil {
+					allErrs = append(allErrs, field.Invalid(replicatePath.Child("namespaceSelector"), selector, err.Error()))
+				}
+			}
+		}
+
+		if delivery := user.PasswordDelivery; delivery != nil {
+			deliveryPath := userPath.Child("passwordDelivery")
+			if user.Login != nil && !*user.Login {
+				allErrs = append(allErrs, field.Forbidden(deliveryPath, "users with login false have no password"))
+			}
+			if user.PasswordSecretRef != nil {
+				allErrs = append(allErrs, field.Forbidden(deliveryPath, "the password is read from passwordSecretRef"))
+			}
+			if user.PasswordRotation != nil && user.PasswordRotation.Enabled {
+				allErrs = append(allErrs, field.Forbidden(userPath.Child("passwordRotation"), "delivered passwords are only rotated through the rotate-credentials annotation"))
+			}
+			if delivery.Sink == postgresv1.PasswordDeliveryVault && database.Spec.Vault == nil {
+				allErrs = append(allErrs, field.Required(specPath.Child("vault"), "required to deliver passwords to Vault"))
+			}
+			if delivery.TTL != nil && delivery.TTL.Duration <= 0 {
+				allErrs = append(allErrs, field.Invalid(deliveryPath.Child("ttl"), delivery.TTL.Duration.String(), "must be greater than zero"))
+			}
+			if delivery.Sink != postgresv1.PasswordDeliveryVault {
+				secretName := k8s.UserSecretName(database, user)
+				for _, msg := range validation.IsDNS1123Subdomain(secretName) {
+					allErrs = append(allErrs, field.Invalid(userPath.Child("secretName"), secretName, msg))
+				}
+				if user.SecretNamespace != "" {
+					for _, msg := range validation.IsDNS1123Label(user.SecretNamespace) {
+						allErrs = append(allErrs, field.Invalid(userPath.Child("secretNamespace"), user.SecretNamespace, msg))
+					}
+				}
+				secretKey := k8s.UserSecretNamespace(database, user) + "/" + secretName
+				if secretNames[secretKey] {
+					allErrs = append(allErrs, field.Duplicate(userPath.Child("secretName"), secretName))
+				}
+				secretNames[secretKey] = true
+			}
+		}
+
+		if !k8s.HasUserSecret(user) {
+			continue
+		}
+		secretName := k8s.UserSecretName(database, user)
+		for _, msg := range validation.IsDNS1123Subdomain(secretName) {
+			allErrs = append(allErrs, field.Invalid(userPath.Child("secretName"), secretName, msg))
+		}
+		if user.SecretNamespace != "" {
+			for _, msg := range validation.IsDNS1123Label(user.SecretNamespace) {
+				allErrs = append(allErrs, field.Invalid(userPath.Child("secretNamespace"), user.SecretNamespace, msg))
+			}
+		}
+		if len(user.RolloutDeployments) > 0 {
+			rolloutPath := userPath.Child("rolloutDeployments")
+			if user.ImmutableSecret {
+				allErrs = append(allErrs, field.Forbidden(rolloutPath, "immutable secrets change their name, Deployments cannot follow them"))
+			}
+			for _, msg := range validation.IsQualifiedName(postgresv1.PodTemplateChecksumPrefix + secretName) {
+				allErrs = append(allErrs, field.Invalid(userPath.Child("secretName"), secretName, "must be usable in the checksum annotation: "+msg))
+			}
+			for j, deployment := range user.RolloutDeployments {
+				allErrs = append(allErrs, validateObjectName(deployment, rolloutPath.Index(j))...)
+			}
+		}
+		secretKey := k8s.UserSecretNamespace(database, user) + "/" + secretName
+		if secretNames[secretKey] {
+			allErrs = append(allErrs, field.Duplicate(userPath.Child("secretName"), secretName))
+		}
+		secretNames[secretKey] = true
+	}
+
+	for i, user := range database.Spec.Users {
+		if user.RenamedFrom != "" && user.RenamedFrom != user.Name && userNames[user.RenamedFrom] {
+			allErrs = append(allErrs, field.Invalid(specPath.Child("users").Index(i).Child("renamedFrom"), user.RenamedFrom,
+				"names another user of the database"))
+		}
+	}
+
+	if binding := database.Spec.ServiceBinding; binding != nil {
+		bindingPath := specPath.Child("serviceBinding")
+		found := false
+		for _, user := range database.Spec.Users {
+			if k8s.HasUserSecret(user) && (binding.User == "" || binding.User == user.Name) {
+				found = true
+				break
+			}
+		}
+		if !found && binding.User != "" {
+			allErrs = append(allErrs, field.Invalid(bindingPath.Child("user"), binding.User, "must name a user with a secret"))
+		} else if !found {
+			allErrs = append(allErrs, field.Required(bindingPath.Child("user"), "the database has no user with a secret to publish"))
+		}
+		if binding.SecretName != "" {
+			allErrs = append(allErrs, validateObjectName(binding.SecretName, bindingPath.Child("secretName"))...)
+			if secretNames[database.Namespace+"/"+binding.SecretName] {
+				allErrs = append(allErrs, field.Duplicate(bindingPath.Child("secretName"), binding.SecretName))
+			}
+		}
+	}
+
+	if pooler := database.Spec.Pooler; pooler != nil && pooler.Enabled {
+		// The Pooler's service is named <name>-pooler and must be a DNS label
+		for _, msg := range validation.IsDNS1123Label(database.Name + "-pooler") {
+			allErrs = append(allErrs, field.Invalid(specPath.Child("pooler"), database.Name, "the Database name does not make a valid Pooler name: "+msg))
+		}
+		if pooler.Instances < 0 {
+			allErrs = append(allErrs, field.Invalid(specPath.Child("pooler", "instances"), pooler.Instances, "must be at least 1"))
+		}
+		if pooler.MaxConnections < 0 {
+			allErrs = append(allErrs, field.Invalid(specPath.Child("pooler", "maxConnections"), pooler.MaxConnections, "must be at least 1"))
+		}
+	}
+
+	extensionNames := make(map[string]bool, len(database.Spec.Extensions))
+	for i, extension := range database.Spec.Extensions {
+		extensionPath := specPath.Child("extensions").Index(i)
+		if extension.Name == "" {
+			allErrs = append(allErrs, field.Required(extensionPath.Child("name"), ""))
+		}
+		if extensionNames[extension.Name] {
+			allErrs = append(allErrs, field.Duplicate(extensionPath.Child("name"), extension.Name))
+		}
+		extensionNames[extension.Name] = true
+
+		if extension.Schema != "" {
+			allErrs = append(allErrs, ValidateIdentifier(extension.Schema, extensionPath.Child("schema"))...)
+		}
+	}
+
+	if initSQL := database.Spec.InitSQL; initSQL != nil {
+		initPath := specPath.Child("initSQL")
+		switch {
+		case initSQL.SQL != "" && initSQL.ConfigMapRef != nil:
+			allErrs = append(allErrs, field.Forbidden(initPath, "only one of sql and configMapRef may be set"))
+		case initSQL.SQL == "" && initSQL.ConfigMapRef == nil:
+			allErrs = append(allErrs, field.Required(initPath, "one of sql and configMapRef is required"))
+		case initSQL.ConfigMapRef != nil:
+			allErrs = append(allErrs, validateObjectName(initSQL.ConfigMapRef.Name, initPath.Child("configMapRef", "name"))...)
+		}
+	}
+
+	return allErrs
+}
+
+// ValidatePostGresConnection checks a PostGresConnection for errors that the API server schema cannot catch
+func ValidatePostGresConnection(pgConn *postgresv1.PostGresConnection) field.ErrorList {
+	var allErrs field.ErrorList
+	specPath := field.NewPath("spec")
+
+	switch {
+	case pgConn.Spec.URI != "" && pgConn.Spec.URISecretRef != nil:
+		allErrs = append(allErrs, field.Forbidden(specPath.Child("uriSecretRef"), "only one of uri and uriSecretRef may be set"))
+	case pgConn.Spec.URI != "":
+		if err := postgres.ValidateConnectionURI(pgConn.Spec.URI); err != nil {
+			// The URI may hold a password, it is not repeated in the error
+			allErrs = append(allErrs, field.Invalid(specPath.Child("uri"), "", err.Error()))
+		}
+	case pgConn.Spec.URISecretRef != nil:
+		allErrs = append(allErrs, validateObjectName(pgConn.Spec.URISecretRef.Name, specPath.Child("uriSecretRef", "name"))...)
+		if pgConn.Spec.URISecretRef.Key == "" {
+			allErrs = append(allErrs, field.Required(specPath.Child("uriSecretRef", "key"), ""))
+		}
+	case pgConn.Spec.Proxy != nil:
+		if pgConn.Spec.CredentialsSecretRef == nil && pgConn.Spec.SuperUserSecret == nil && pgConn.Spec.CredentialsFrom == nil {
+			allErrs = append(allErrs, field.Required(specPath.Child("credentialsSecretRef"), "required with proxy"))
+		}
+	case pgConn.Spec.ClusterName == "" && pgConn.Spec.Host == "":
+		allErrs = append(allErrs, field.Required(specPath.Child("clusterName"), "required unless host, uri, uriSecretRef or proxy is set"))
+	case pgConn.Spec.ClusterName == "" && pgConn.Spec.CredentialsSecretRef == nil && pgConn.Spec.SuperUserSecret == nil && pgConn.Spec.CredentialsFrom == nil:
+		allErrs = append(allErrs, field.Required(specPath.Child("credentialsSecretRef"), "required without clusterName"))
+	}
+
+	if pgConn.Spec.Port < 0 || pgConn.Spec.Port > 65535 {
+		allErrs = append(allErrs, field.Invalid(specPath.Child("port"), pgConn.Spec.Port, "must be between 1 and 65535"))
+	}
+
+	if pgConn.Spec.SSLMode != "" && !slices.Contains(supportedSSLModes, pgConn.Spec.SSLMode) {
+		allErrs = append(allErrs, field.NotSupported(specPath.Child("sslMode"), pgConn.Spec.SSLMode, supportedSSLModes))
+	}
+
+	if pgConn.Spec.SuperUserSecret != nil {
+		allErrs = append(allErrs, validateObjectName(pgConn.Spec.SuperUserSecret.Name, specPath.Child("superUserSecret", "name"))...)
+	}
+
+	if pgConn.Spec.CredentialsSecretRef != nil {
+		allErrs = append(allErrs, validateObjectName(pgConn.Spec.CredentialsSecretRef.Name, specPath.Child("credentialsSecretRef", "name"))...)
+		if pgConn.Spec.SuperUserSecret != nil {
+			allErrs = append(allErrs, field.Forbidden(specPath.Child("credentialsSecretRef"), "only one of superUserSecret and credentialsSecretRef may be set"))
+		}
+	}
+
+	if external := pgConn.Spec.CredentialsFrom; external != nil {
+		externalPath := specPath.Child("credentialsFrom")
+		if pgConn.Spec.SuperUserSecret != nil || pgConn.Spec.CredentialsSecretRef != nil {
+			allErrs = append(allErrs, field.Forbidden(externalPath, "only one of superUserSecret, credentialsSecretRef and credentialsFrom may be set"))
+		}
+		switch external.Provider {
+		case postgresv1.CredentialsProviderAzure:
+			if external.Azure == nil {
+				allErrs = append(allErrs, field.Required(externalPath.Child("azure"), "required with the azure provider"))
+			} else if external.Azure.Username == "" {
+				allErrs = append(allErrs, field.Required(externalPath.Child("azure", "username"), ""))
+			}
+			if external.Vault != nil {
+				allErrs = append(allErrs, field.Forbidden(externalPath.Child("vault"), "not used with the azure provider"))
+			}
+		case "", postgresv1.CredentialsProviderVault:
+			if external.Vault == nil || external.Vault.Path == "" {
+				allErrs = append(allErrs, field.Required(externalPath.Child("vault", "path"), ""))
+			}
+			if external.Azure != nil {
+				allErrs = append(allErrs, field.Forbidden(externalPath.Child("azure"), "only used with the azure provider"))
+			}
+		default:
+			allErrs = append(allErrs, field.NotSupported(externalPath.Child("provider"), external.Provider,
+				[]string{postgresv1.CredentialsProviderVault, postgresv1.CredentialsProviderAzure}))
+		}
+		// An auth proxy verifies the server itself, access tokens are never sent through one. The sslmode of
+		// a connection URI is checked when connecting.
+		verified := pgConn.Spec.Proxy != nil && external.Provider != postgresv1.CredentialsProviderAzure
+		if !verified && pgConn.Spec.URI == "" && pgConn.Spec.URISecretRef == nil && pgConn.Spec.SSLMode != "verify-full" {
+			allErrs = append(allErrs, field.Invalid(specPath.Child("sslMode"), pgConn.Spec.SSLMode, "must be verify-full with credentialsFrom"))
+		}
+		if external.RefreshInterval != nil && external.RefreshInterval.Duration <= 0 {
+			allErrs = append(allErrs, field.Invalid(externalPath.Child("refreshInterval"), external.RefreshInterval.Duration.String(), "must be greater than zero"))
+		}
+	}
+
+	if pooler := pgConn.Spec.Pooler; pooler != nil {
+		poolerPath := specPath.Child("pooler")
+		switch {
+		case pooler.Name == "" && pooler.Host == "":
+			allErrs = append(allErrs, field.Required(poolerPath, "one of name and host is required"))
+		case pooler.Host == "":
+			allErrs = append(allErrs, validateObjectName(pooler.Name, poolerPath.Child("name"))...)
+		}
+		if pooler.Port < 0 || pooler.Port > 65535 {
+			allErrs = append(allErrs, field.Invalid(poolerPath.Child("port"), pooler.Port, "must be between 1 and 65535"))
+		}
+		if pooler.Management && (pgConn.Spec.URI != "" || pgConn.Spec.URISecretRef != nil) {
+			allErrs = append(allErrs, field.Forbidden(poolerPath.Child("management"), "connections described by a URI connect through the hosts of the URI"))
+		}
+	}
+
+	for i, fallback := range pgConn.Spec.FallbackHosts {
+		fallbackPath := specPath.Child("fallbackHosts").Index(i)
+		if fallback.Host == "" {
+			allErrs = append(allErrs, field.Required(fallbackPath.Child("host"), ""))
+		}
+		if fallback.Port < 0 || fallback.Port > 65535 {
+			allErrs = append(allErrs, field.Invalid(fallbackPath.Child("port"), fallback.Port, "must be between 1 and 65535"))
+		}
+	}
+	if len(pgConn.Spec.FallbackHosts) > 0 && pgConn.Spec.ReadOnly {
+		allErrs = append(allErrs, field.Forbidden(specPath.Child("fallbackHosts"), "fallback hosts only accept writable servers, which a read-only connection does not need"))
+	}
+	if len(pgConn.Spec.FallbackHosts) > 0 && (pgConn.Spec.URI != "" || pgConn.Spec.URISecretRef != nil) {
+		allErrs = append(allErrs, field.Forbidden(specPath.Child("fallbackHosts"), "list the hosts in the connection URI instead"))
+	}
+
+	if proxy := pgConn.Spec.Proxy; proxy != nil {
+		proxyPath := specPath.Child("proxy")
+		if pgConn.Spec.ClusterName != "" || pgConn.Spec.Host != "" || pgConn.Spec.URI != "" || pgConn.Spec.URISecretRef != nil || len(pgConn.Spec.FallbackHosts) > 0 {
+			allErrs = append(allErrs, field.Forbidden(proxyPath, "the proxy is the only host of a connection, clusterName, host, uri, uriSecretRef and fallbackHosts may not be set"))
+		}
+		if pooler := pgConn.Spec.Pooler; pooler != nil && pooler.Management {
+			allErrs = append(allErrs, field.Forbidden(specPath.Child("pooler", "management"), "connections through a proxy connect to the proxy"))
+		}
+		if proxy.Port < 1 || proxy.Port > 65535 {
+			allErrs = append(allErrs, field.Invalid(proxyPath.Child("port"), proxy.Port, "must be between 1 and 65535"))
+		}
+		if proxy.SecretHost == "" {
+			allErrs = append(allErrs, field.Required(proxyPath.Child("secretHost"), "user secrets cannot publish the operator's proxy"))
+		}
+		if proxy.SecretPort < 0 || proxy.SecretPort > 65535 {
+			allErrs = append(allErrs, field.Invalid(proxyPath.Child("secretPort"), proxy.SecretPort, "must be between 1 and 65535"))
+		}
+		if proxy.HealthCheckURL != "" {
+			if err := postgres.ValidateProxyHealthCheckURL(proxy.HealthCheckURL); err != nil {
+				allErrs = append(allErrs, field.Invalid(proxyPath.Child("healthCheckURL"), proxy.HealthCheckURL, err.Error()))
+			}
+		}
+	}
+
+	if err := postgres.ValidateConnectionOptions(pgConn.Spec.ConnectionOptions); err != nil {
+		allErrs = append(allErrs, field.Forbidden(specPath.Child("connectionOptions"), err.Error()))
+	}
+
+	if timeout := pgConn.Spec.ConnectTimeout; timeout != nil && timeout.Duration <= 0 {
+		allErrs = append(allErrs, field.Invalid(specPath.Child("connectTimeout"), timeout.Duration.String(), "must be greater than zero"))
+	}
+	if timeout := pgConn.Spec.StatementTimeout; timeout != nil && timeout.Duration < 0 {
+		allErrs = append(allErrs, field.Invalid(specPath.Child("statementTimeout"), timeout.Duration.String(), "must not be negative"))
+	}
+	if timeout := pgConn.Spec.LockTimeout; timeout != nil && timeout.Duration < 0 {
+		allErrs = append(allErrs, field.Invalid(specPath.Child("lockTimeout"), timeout.Duration.String(), "must not be negative"))
+	}
+	if interval := pgConn.Spec.RevalidationInterval; interval != nil && interval.Duration <= 0 {
+		allErrs = append(allErrs, field.Invalid(specPath.Child("revalidationInterval"), interval.Duration.String(), "must be greater than zero"))
+	}
+
+	if maxDatabases := pgConn.Spec.MaxDatabases; maxDatabases != nil && *maxDatabases < 0 {
+		allErrs = append(allErrs, field.Invalid(specPath.Child("maxDatabases"), *maxDatabases, "must not be negative"))
+	}
+
+	if allowed := pgConn.Spec.AllowedNamespaces; allowed != nil {
+		allowedPath := specPath.Child("allowedNamespaces")
+		for i, namespace := range allowed.Names {
+			for _, msg := range validation.IsDNS1123Label(namespace) {
+				allErrs = append(allErrs, field.Invalid(allowedPath.Child("names").Index(i), namespace, msg))
+			}
+		}
+		if allowed.Selector != nil {
+			if _, err := metav1.LabelSelectorAsSelector(allowed.Selector); err != nil {
+				allErrs = append(allErrs, field.Invalid(allowedPath.Child("selector"), allowed.Selector, err.Error()))
+			}
+		}
+	}
+
+	if pgConn.Spec.WaitForCluster && pgConn.Spec.ClusterName == "" {
+		allErrs = append(allErrs, field.Required(specPath.Child("clusterName"), "required with waitForCluster"))
+	}
+
+	if serverName := pgConn.Spec.SSLServerName; serverName != "" {
+		for _, msg := range validation.IsDNS1123Subdomain(serverName) {
+			allErrs = append(allErrs, field.Invalid(specPath.Child("sslServerName"), serverName, msg))
+		}
+	}
+
+	if tls := pgConn.Spec.TLS; tls != nil {
+		tlsPath := specPath.Child("tls")
+		if tls.CASecretRef != nil && tls.CAConfigMapRef != nil {
+			allErrs = append(allErrs, field.Forbidden(tlsPath, "only one of caSecretRef and caConfigMapRef may be set"))
+		}
+		if tls.UseClusterCA {
+			if tls.CASecretRef != nil || tls.CAConfigMapRef != nil {
+				allErrs = append(allErrs, field.Forbidden(tlsPath.Child("useClusterCA"), "may not be combined with caSecretRef or caConfigMapRef"))
+			}
+			if pgConn.Spec.ClusterName == "" {
+				allErrs = append(allErrs, field.Required(specPath.Child("clusterName"), "required with useClusterCA"))
+			}
+		}
+		if tls.CASecretRef != nil {
+			allErrs = append(allErrs, validateObjectName(tls.CASecretRef.Name, tlsPath.Child("caSecretRef", "name"))...)
+		}
+		if tls.CAConfigMapRef != nil {
+			allErrs = append(allErrs, validateObjectName(tls.CAConfigMapRef.Name, tlsPath.Child("caConfigMapRef", "name"))...)
+		}
+	}
+
+	return allErrs
+}
+
+// ValidateIdentifier checks that a PostgreSQL identifier is accepted by the operator
+func ValidateIdentifier(name string, fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+
+	if name == "" {
+		return append(allErrs, field.Required(fldPath, ""))
+	}
+
+	if len(name) > maxIdentifierLength {
+		allErrs = append(allErrs, field.TooLong(fldPath, name, maxIdentifierLength))
+	}
+
+	if !identifierPattern.MatchString(name) {
+		allErrs = append(allErrs, field.Invalid(fldPath, name, "must start with a letter and contain only letters, digits, underscores and dashes"))
+	}
+
+	return allErrs
+}
+
+func validateObjectName(name string, fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+
+	if name == "" {
+		return append(allErrs, field.Required(fldPath, ""))
+	}
+
+	for _, msg := range validation.IsDNS1123Subdomain(name) {
+		allErrs = append(allErrs, field.Invalid(fldPath, name, msg))
+	}
+
+	return allErrs
+}
+
+// validateReservedName rejects system names and the pg_ prefix PostgreSQL reserves. Identifiers
+// are quoted, so names differing in case are distinct objects.
+func validateReservedName(name string, reserved []string, fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+
+	if slices.Contains(reserved, name) {
+		allErrs = append(allErrs, field.Invalid(fldPath, name, "is reserved"))
+	}
+	if strings.HasPrefix(name, "pg_") {
+		allErrs = append(allErrs, field.Invalid(fldPath, name, "must not start with pg_"))
+	}
+
+	return allErrs
+}
@@ -26,6 +26,7 @@ import (
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
 	"k8s.io/apimachinery/pkg/types"
 
 	postgresv1 "github.com/silverswarm/pg-operator/api/v1"
@@ -167,7 +168,11 @@ var _ = Describe("PostgreSQL Operator", Ordered, func() {
 			Expect(err).NotTo(HaveOccurred())
 			Expect(database.Status.Ready).To(BeTrue())
 			Expect(database.Status.DatabaseCreated).To(BeTrue())
-			Expect(database.Status.UsersCreated).To(ContainElements("app_user", "readonly_user"))
+			Expect(database.Status.Users).To(HaveLen(2))
+			for _, user := range database.Status.Users {
+				Expect(user.Name).To(BeElementOf("app_user", "readonly_user"))
+				Expect(meta.IsStatusConditionTrue(user.Conditions, "Created")).To(BeTrue(), "user %s should be created", user.Name)
+			}
 		})
 
 		It("should create user secrets", func() {